@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"chatatui_backend/db"
+	"chatatui_backend/importer"
+)
+
+// commands :: Admin subcommands, run in place of the server as `ctui_backend <command> ...`.
+var commands = map[string]func(args []string) error{
+	"backup":  backupCommand,
+	"restore": restoreCommand,
+	"import":  importCommand,
+}
+
+// runCommand :: Returns false if args don't name a command, in which case the
+//    server should be started as usual.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		return false
+	}
+	if err := command(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", args[0], err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// backupCommand :: Writes a snapshot of a bbolt file no server has open. Running
+//    servers are backed up through GET /admin/backup instead.
+func backupCommand(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	path := flags.String("db", "../DevDB/chatatui_dev.db", "The bbolt file to back up")
+	out := flags.String("o", "", "Where to write the snapshot. \"-\" writes to stdout. Defaults to a timestamped file within -dir")
+	dir := flags.String("dir", ".", "Directory to write timestamped snapshots into")
+	keep := flags.Int("keep", 0, "Keep only the newest this many snapshots within -dir. 0 keeps them all")
+	flags.Parse(args)
+
+	switch *out {
+	case "-":
+		_, err := db.SnapshotFile(*path, os.Stdout)
+		return err
+	case "":
+		written, err := db.WriteBackup(fileSnapshotter(*path), *dir, time.Now(), *keep)
+		if err != nil {
+			return err
+		}
+		fmt.Println(written)
+		return nil
+	}
+
+	file, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := db.SnapshotFile(*path, file); err != nil {
+		file.Close()
+		os.Remove(*out)
+		return err
+	}
+	return file.Close()
+}
+
+// fileSnapshotter :: A db.Snapshotter for a bbolt file no server has open.
+type fileSnapshotter string
+
+func(path fileSnapshotter)WriteSnapshot(w io.Writer)( int64, error ){
+	return db.SnapshotFile(string(path), w)
+}
+
+// restoreCommand :: Swaps a validated snapshot in for the server's bbolt file. The
+//    server has to be stopped first.
+func restoreCommand(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	path := flags.String("db", "../DevDB/chatatui_dev.db", "The bbolt file to restore over")
+	from := flags.String("from", "", "The snapshot to restore")
+	flags.Parse(args)
+
+	if *from == "" {
+		return fmt.Errorf("-from is required")
+	}
+	previous, err := db.RestoreSnapshot(*from, *path)
+	if err != nil {
+		return err
+	}
+	if previous != "" {
+		fmt.Printf("Restored %s. The previous Database was moved to %s\n", *path, previous)
+	} else {
+		fmt.Printf("Restored %s\n", *path)
+	}
+	return nil
+}
+
+// importCommand :: Imports IRC logs, or Slack export archives, creating a Chatroom per
+//    channel owned by -owner. bbolt files are locked by a running server, so stop it first.
+//
+//    import -owner admin -format irc -room lobby '#lobby.2023-01-01.log' '#lobby.2023-01-02.log'
+//    import -owner admin -format slack export.zip
+func importCommand(args []string) error {
+	config := defaultConfig()
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.StringVar(&config.Database, "db", "bbolt", "Database backend to import into: \"bbolt\" or \"sqlite\"")
+	owner := flags.String("owner", "", "Username of the existing User who will own the imported Chatrooms")
+	format := flags.String("format", "irc", "What's being imported: \"irc\" logs, or a \"slack\" export archive")
+	room := flags.String("room", "", "Chatroom to import IRC logs into. Defaults to the first log's file name, up to it's first '.'")
+	private := flags.Bool("private", false, "Make the IRC Chatroom private")
+	batch := flags.Int("batch", importer.DefaultBatchSize, "How many Messages are saved within each transaction")
+	flags.Parse(args)
+
+	if *owner == "" {
+		return fmt.Errorf("-owner is required")
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("Nothing to import")
+	}
+	if *format == "slack" && flags.NArg() != 1 {
+		return fmt.Errorf("Import one Slack export archive at a time")
+	}
+
+	var channels []*importer.Channel
+	switch *format {
+	case "irc":
+		channel, err := parseIRCLogs(flags.Args(), *room)
+		if err != nil {
+			return err
+		}
+		channel.Public = !*private
+		channels = append(channels, channel)
+	case "slack":
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if channels, err = importer.ParseSlackExport(file, info.Size()); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown format \"%s\"", *format)
+	}
+
+	database, err := openDatabase(config)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+	ownerUser, err := database.GetUserbyUsername(*owner)
+	if err != nil {
+		return fmt.Errorf("Owner \"%s\" not found", *owner)
+	}
+
+	imp := importer.NewImporter(database, ownerUser.UserID)
+	imp.BatchSize = *batch
+	for _, channel := range channels {
+		result, err := imp.Import(channel)
+		if err != nil {
+			return fmt.Errorf("%s: %s", channel.Name, err)
+		}
+		fmt.Printf("%s: %d Messages, %d new placeholder Users\n", result.Chatroom, result.Messages, result.Users)
+	}
+	return nil
+}
+
+// parseIRCLogs :: Every log is read into the same Channel, in the order given.
+func parseIRCLogs(paths []string, room string)( *importer.Channel, error ){
+	if room == "" {
+		// "#lobby.2023-01-01.log" logs into "lobby".
+		room, _, _ = strings.Cut(filepath.Base(paths[0]), ".")
+	}
+	channel := &importer.Channel{ Name: room }
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		log, err := importer.ParseIRCLog(file, room)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		channel.Messages = append(channel.Messages, log.Messages...)
+	}
+	return channel, nil
+}
@@ -0,0 +1,268 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+const (
+  DefaultBackupInterval = 24 * time.Hour
+  DefaultBackupsKept    = 7
+  backupPrefix          = "chatatui-"
+  backupSuffix          = ".db"
+  backupTimeFormat      = "20060102T150405"
+
+  // How long to wait on another process holding the bbolt file, before giving up.
+  snapshotLockTimeout = time.Second
+)
+
+// Snapshotter :: Implemented by Databases that can stream a consistent copy of
+//    themselves, while they're still being written to.
+type Snapshotter interface {
+  WriteSnapshot(w io.Writer)( int64, error )
+}
+
+// WriteSnapshot :: The copy is made within a single read transaction, so writers
+//    are never blocked, and the snapshot is exactly the Database as of when the
+//    transaction began.
+func(db *BBoltDB)WriteSnapshot(w io.Writer)( int64, error ){
+  var n int64
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    var err error
+    n, err = tx.WriteTo(w)
+    return err
+  })
+  return n, err
+}
+
+// SnapshotFile :: Streams a snapshot of the bbolt file at path, for when no server
+//    has it open. A running server holds the file's lock, and has to be asked for
+//    a snapshot instead.
+func SnapshotFile(path string, w io.Writer)( int64, error ){
+  bdb, err := bbolt.Open(path, 0600, &bbolt.Options{ ReadOnly: true, Timeout: snapshotLockTimeout })
+  if err != nil {
+    return 0, fmt.Errorf("Failed to open \"%s\": %s. If the server is running, use GET /admin/backup instead", path, err)
+  }
+  defer bdb.Close()
+  return (&BBoltDB{ bdb }).WriteSnapshot(w)
+}
+
+// BackupFileName :: Sorts oldest to newest.
+func BackupFileName(now time.Time) string {
+  return backupPrefix + now.UTC().Format(backupTimeFormat) + backupSuffix
+}
+
+// WriteBackup :: Writes a snapshot into dir, and deletes all but the newest keep
+//    backups. The snapshot is written to a temporary file first, so dir never
+//    holds a partial backup. Returns the new backup's path.
+func WriteBackup(s Snapshotter, dir string, now time.Time, keep int)( string, error ){
+  if err := os.MkdirAll(dir, 0700); err != nil {
+    return "", err
+  }
+  tmp, err := os.CreateTemp(dir, "."+backupPrefix+"*.tmp")
+  if err != nil {
+    return "", err
+  }
+  defer os.Remove(tmp.Name())
+
+  if _, err := s.WriteSnapshot(tmp); err != nil {
+    tmp.Close()
+    return "", err
+  }
+  if err := tmp.Sync(); err != nil {
+    tmp.Close()
+    return "", err
+  }
+  if err := tmp.Close(); err != nil {
+    return "", err
+  }
+  path := filepath.Join(dir, BackupFileName(now))
+  if err := os.Rename(tmp.Name(), path); err != nil {
+    return "", err
+  }
+  return path, rotateBackups(dir, keep)
+}
+
+// rotateBackups :: A keep of 0 or less keeps every backup.
+func rotateBackups(dir string, keep int) error {
+  if keep <= 0 {
+    return nil
+  }
+  backups, err := ListBackups(dir)
+  if err != nil {
+    return err
+  }
+  for len(backups) > keep {
+    if err := os.Remove(backups[0]); err != nil {
+      return err
+    }
+    backups = backups[1:]
+  }
+  return nil
+}
+
+// ListBackups :: Every backup within dir, oldest first.
+func ListBackups(dir string)( []string, error ){
+  entries, err := os.ReadDir(dir)
+  if err != nil {
+    return nil, err
+  }
+  var backups []string
+  for _, entry := range entries {
+    name := entry.Name()
+    if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+      continue
+    }
+    backups = append(backups, filepath.Join(dir, name))
+  }
+  sort.Strings(backups)
+  return backups, nil
+}
+
+// ValidateSnapshot :: Checks that the file at path is a bbolt file this server can
+//    open. Every page is checked for consistency, and it's schema version must
+//    not be newer than LatestSchemaVersion. Older versions are migrated once opened.
+func ValidateSnapshot(path string) error {
+  bdb, err := bbolt.Open(path, 0600, &bbolt.Options{ ReadOnly: true, Timeout: snapshotLockTimeout })
+  if err != nil {
+    return fmt.Errorf("Not a bbolt file: %s", err)
+  }
+  defer bdb.Close()
+
+  return bdb.View(func(tx *bbolt.Tx) error {
+    // Check has to be drained, whatever it finds, before the transaction is closed.
+    var corrupt []string
+    for err := range tx.Check() {
+      corrupt = append(corrupt, err.Error())
+    }
+    if len(corrupt) != 0 {
+      return fmt.Errorf("Snapshot is corrupt: %s", strings.Join(corrupt, "; "))
+    }
+    meta := tx.Bucket([]byte(META))
+    if meta == nil {
+      return BucketNotFoundError{META}
+    }
+    data := meta.Get([]byte(SCHEMAVERSION))
+    if data == nil {
+      return GetDataError{SCHEMAVERSION, META}
+    }
+    if version := btoi(data); version > LatestSchemaVersion() {
+      return SchemaVersionError{version, LatestSchemaVersion()}
+    }
+    return nil
+  })
+}
+
+// RestoreSnapshot :: Validates snapshot, and swaps it in for the bbolt file at path.
+//    The server must be stopped. The file being replaced is kept alongside it,
+//    and it's new path is returned. Empty if there was nothing at path. A restore
+//    that fails part way leaves the file at path where it was.
+func RestoreSnapshot(snapshot, path string)( string, error ){
+  if err := ValidateSnapshot(snapshot); err != nil {
+    return "", err
+  }
+  if _, err := os.Stat(path); err == nil {
+    // Only a timeout means someone else holds the file. Anything else is likely
+    //    the very corruption being restored away from.
+    bdb, err := bbolt.Open(path, 0600, &bbolt.Options{ Timeout: snapshotLockTimeout })
+    if err == bbolt.ErrTimeout {
+      return "", fmt.Errorf("\"%s\" is in use. Stop the server before restoring", path)
+    }
+    if err == nil {
+      bdb.Close()
+    }
+  }
+
+  tmp := path + ".restore"
+  if err := copyFile(snapshot, tmp); err != nil {
+    os.Remove(tmp)
+    return "", err
+  }
+  var previous string
+  if _, err := os.Stat(path); err == nil {
+    previous = path + ".pre-restore-" + time.Now().UTC().Format(backupTimeFormat)
+    if err := os.Rename(path, previous); err != nil {
+      os.Remove(tmp)
+      return "", err
+    }
+  }
+  if err := os.Rename(tmp, path); err != nil {
+    // Put the file being replaced back, so the server still finds a Database.
+    os.Remove(tmp)
+    if previous != "" {
+      if restoreErr := os.Rename(previous, path); restoreErr != nil {
+        return previous, fmt.Errorf("%s, and failed to move \"%s\" back: %s", err, previous, restoreErr)
+      }
+    }
+    return "", err
+  }
+  log.Printf(" -> RestoreSnapshot: Restored \"%s\" from \"%s\"", path, snapshot)
+  return previous, nil
+}
+
+func copyFile(src, dst string) error {
+  in, err := os.Open(src)
+  if err != nil {
+    return err
+  }
+  defer in.Close()
+  out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+  if err != nil {
+    return err
+  }
+  if _, err := io.Copy(out, in); err != nil {
+    out.Close()
+    return err
+  }
+  if err := out.Sync(); err != nil {
+    out.Close()
+    return err
+  }
+  return out.Close()
+}
+
+// Backups :: Writes a backup into Dir every Interval, keeping the newest Keep.
+type Backups struct {
+  snapshotter Snapshotter
+  Dir         string
+  Interval    time.Duration
+  Keep        int
+}
+
+func NewBackups(snapshotter Snapshotter, dir string) *Backups {
+  return &Backups{
+    snapshotter: snapshotter,
+    Dir:         dir,
+    Interval:    DefaultBackupInterval,
+    Keep:        DefaultBackupsKept,
+  }
+}
+
+// Run :: The first backup is written one Interval after starting, until stop is closed.
+func(b *Backups)Run(stop <-chan struct{}) {
+  if b.Interval <= 0 {
+    b.Interval = DefaultBackupInterval
+  }
+  ticker := time.NewTicker(b.Interval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-stop:
+      return
+    case now := <-ticker.C:
+      if path, err := WriteBackup(b.snapshotter, b.Dir, now, b.Keep); err != nil {
+        log.Printf(" -> Backups: Failed to write backup: %s", err)
+      } else {
+        log.Printf(" -> Backups: Wrote \"%s\"", path)
+      }
+    }
+  }
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBackups(t *testing.T) {
+  dir := t.TempDir()
+  database, err := NewDatabase(filepath.Join(dir, "chatatui_backup.db"))
+  if err != nil {
+    t.Fatalf("FAILED: Failed to open Database: %v", err)
+  }
+  defer database.Close()
+
+  user := User{ UserID: uuid.New(), Username: "archivist" }
+  if err := database.SaveUser(user, nil); err != nil {
+    t.Fatalf("FAILED: Failed to save User: %v", err)
+  }
+
+  backupDir := filepath.Join(dir, "backups")
+  var latest string
+  t.Run("Rotation keeps the newest", func(t *testing.T){
+    now := time.Now()
+    for i := 0; i < 3; i++ {
+      path, err := WriteBackup(database, backupDir, now.Add(time.Duration(i) * time.Hour), 2)
+      if err != nil {
+        t.Fatalf("FAILED: Failed to write backup: %v", err)
+      }
+      latest = path
+    }
+    backups, err := ListBackups(backupDir)
+    if err != nil || len(backups) != 2 || backups[1] != latest {
+      t.Errorf("FAILED: Got %v, %v Want the newest 2 backups, ending with %s", backups, err, latest)
+    }
+  })
+
+  t.Run("Validate snapshots", func(t *testing.T){
+    if err := ValidateSnapshot(latest); err != nil {
+      t.Errorf("FAILED: Got %v Want a valid snapshot", err)
+    }
+    garbage := filepath.Join(dir, "garbage.db")
+    os.WriteFile(garbage, []byte("definitely not a bbolt file"), 0600)
+    if err := ValidateSnapshot(garbage); err == nil {
+      t.Errorf("FAILED: Got nil Want an error for a garbage snapshot")
+    }
+  })
+
+  t.Run("Restore a snapshot", func(t *testing.T){
+    path := filepath.Join(dir, "restored.db")
+    os.WriteFile(path, []byte("replace me"), 0600)
+    previous, err := RestoreSnapshot(latest, path)
+    if err != nil || previous == "" {
+      t.Fatalf("FAILED: Got %q, %v Want the previous file kept", previous, err)
+    }
+    if data, _ := os.ReadFile(previous); string(data) != "replace me" {
+      t.Errorf("FAILED: Got %q Want the previous file's contents", data)
+    }
+
+    restored, err := NewDatabase(path)
+    if err != nil {
+      t.Fatalf("FAILED: Failed to open restored Database: %v", err)
+    }
+    defer restored.Close()
+    if got, err := restored.GetUserbyUsername(user.Username); err != nil || got.UserID != user.UserID {
+      t.Errorf("FAILED: Got %+v, %v Want %s", got, err, user.Username)
+    }
+  })
+
+  t.Run("Refuse to restore over an open Database", func(t *testing.T){
+    if _, err := RestoreSnapshot(latest, filepath.Join(dir, "chatatui_backup.db")); err == nil {
+      t.Errorf("FAILED: Got nil Want an error while the Database is open")
+    }
+  })
+}
@@ -30,34 +30,27 @@ func NewDatabase(path string)( *BBoltDB, error ){
   }
   log.Printf(" -> NewDatabase: After successful bbolt.Open(%s, 0600, nil)", path)
 
-  return &BBoltDB{
+  database := &BBoltDB{
     db,
-  }, nil
+  }
+  if err := database.migrate(); err != nil {
+    db.Close()
+    return nil, err
+  }
+  return database, nil
 }
 
 func(db *BBoltDB)GetChatroom(name string)( *Chatroom, error ){
-  var chatroom Chatroom
+  var chatroom *Chatroom
   err := db.db.View(func(tx *bbolt.Tx) error {
-    b := tx.Bucket([]byte(CHATROOMS))
-    if b == nil {
-      log.Printf(" -> GetChatroom: Bucket \"%s\" not found", CHATROOMS)
-      return BucketNotFoundError{CHATROOMS}
-    }
-    data := b.Get([]byte(name))
-    if data == nil {
-      return GetDataError{name, CHATROOMS}
-    }
-
-    dec := codec.NewDecoderBytes(data, &JSONHandle)
-    if dec == nil {
-      return DecoderError{"Failed to create Decoder"}
-    }
-    return dec.Decode(&chatroom)
+    var err error
+    chatroom, err = boltGetChatroom(tx, name)
+    return err
   })
   if err != nil {
     return nil, err
   }
-  return &chatroom, err
+  return chatroom, nil
 }
 
 // SaveChatroom : Requires a Chatroom Object. Gets/creates the CHATROOMS bucket
@@ -70,15 +63,14 @@ func(db *BBoltDB)SaveChatroom(
   update   bool,
 ) error {
   return db.db.Update(func(tx *bbolt.Tx) error {
-    bucket, err := tx.CreateBucketIfNotExists([]byte(CHATROOMS))
-    if err != nil {
-      log.Printf(" -> Error: SaveChatroom - Failed to get %s Bucket: %s", CHATROOMS, err)
+    bucket := tx.Bucket([]byte(CHATROOMS))
+    if bucket == nil {
+      log.Printf(" -> Error: SaveChatroom - Failed to get %s Bucket", CHATROOMS)
       return BucketNotFoundError{CHATROOMS}
     }
 
-
     if !update {
-      exist, err := db.DoesChatroomExist(chatroom.RoomName)
+      exist, err := boltDoesChatroomExist(tx, chatroom.RoomName)
       if err != nil {
         fmt.Printf(" -> SaveChatroom: Error checking if chatroom exists.")
         return err
@@ -88,7 +80,8 @@ func(db *BBoltDB)SaveChatroom(
         return fmt.Errorf("Chatroom name already taken")
       }
 
-      if err := db.SaveChatroomMember(
+      if err := boltSaveChatroomMember(
+        tx,
         chatroom.RoomName,
         chatroom.OwnerID,
         Owner,
@@ -96,18 +89,17 @@ func(db *BBoltDB)SaveChatroom(
         log.Printf(" -> SaveChatroom: Failed to store Chatroom Owner in /ChatroomMembers")
         return fmt.Errorf("Sever Error: Couldn't Store Chatroom Owner")
       }
+      if err := boltSaveDefaultRoles(tx, chatroom.RoomName); err != nil {
+        return err
+      }
     } else {
-      status, err := db.GetChatroomMemberStatus(
-        chatroom.RoomName,
-        chatroom.OwnerID,
-      )
-      if err != nil {
-        log.Printf(" -> SaveChatroom: Failed to retreive UserStatus for OwneID in Chatroom")
-        return fmt.Errorf("OwnerID not found in Chatroom Members.")
+      member, err := boltGetChatroomMember(tx, chatroom.RoomName, chatroom.OwnerID)
+      if _, ok := err.(GetDataError); err != nil && !ok {
+        return err
       }
-      if *status != Owner || *status != Moderator {
-        log.Printf(" -> SaveChatroom: Invalid Credentials for updating Chatroom")
-        return fmt.Errorf("Invalid Credentials for updating Chatroom")
+      if err := Authorize(member, PermChangeSettings, nil); err != nil {
+        log.Printf(" -> SaveChatroom: Invalid Credentials for updating Chatroom: %s", err)
+        return err
       }
     }
 
@@ -124,6 +116,199 @@ func(db *BBoltDB)SaveChatroom(
   })
 }
 
+// UpdateChatroomSettings :: Reads, and rewrites, /Chatrooms/{room_name} within the
+//    same transaction, so concurrent updates to different settings don't undo
+//    one another.
+func(db *BBoltDB)UpdateChatroomSettings(
+  chatroomName string,
+  userID       UUID,
+  settings     *ChatroomSettings,
+)( *Chatroom, error ){
+  var chatroom *Chatroom
+  err := db.db.Update(func(tx *bbolt.Tx) error {
+    var err error
+    if chatroom, err = boltGetChatroom(tx, chatroomName); err != nil {
+      return err
+    }
+    if chatroom.IsDirect() {
+      return InvalidSettingsError{"Direct Chatrooms have no settings"}
+    }
+    member, err := boltGetChatroomMember(tx, chatroomName, userID)
+    if _, ok := err.(GetDataError); err != nil && !ok {
+      return err
+    }
+    if err := Authorize(member, PermChangeSettings, nil); err != nil {
+      log.Printf(" -> UpdateChatroomSettings: Invalid Credentials for updating Chatroom: %s", err)
+      return err
+    }
+    if err := settings.Apply(chatroom); err != nil {
+      return InvalidSettingsError{err.Error()}
+    }
+    return boltPutChatroom(tx, chatroom)
+  })
+  if err != nil {
+    return nil, err
+  }
+  return chatroom, nil
+}
+
+// GetChatroomDirectory :: Deactivated Chatrooms are moved out of /Chatrooms, so
+//    walking it is enough. Anything also found within /InactiveChatrooms is skipped
+//    regardless.
+func(db *BBoltDB)GetChatroomDirectory()( []DirectoryEntry, error ){
+  entries := []DirectoryEntry{}
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    active := tx.Bucket([]byte(CHATROOMS))
+    if active == nil {
+      return BucketNotFoundError{CHATROOMS}
+    }
+    inactive := tx.Bucket([]byte(INACTIVECHATROOMS))
+    if inactive == nil {
+      return BucketNotFoundError{INACTIVECHATROOMS}
+    }
+    return active.ForEach(func(k, v []byte) error {
+      if inactive.Get(k) != nil {
+        return nil
+      }
+      var chatroom Chatroom
+      dec := codec.NewDecoderBytes(v, &JSONHandle)
+      if err := dec.Decode(&chatroom); err != nil {
+        return DecoderError{err.Error()}
+      }
+      if !chatroom.Public || chatroom.IsDirect() {
+        return nil
+      }
+      entry, err := boltDirectoryEntry(tx, &chatroom)
+      if err != nil {
+        return err
+      }
+      entries = append(entries, *entry)
+      return nil
+    })
+  })
+  if err != nil {
+    return nil, err
+  }
+  return entries, nil
+}
+
+func(db *BBoltDB)GetDirectoryEntry(chatroomName string)( *DirectoryEntry, error ){
+  var entry *DirectoryEntry
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    chatroom, err := boltGetChatroom(tx, chatroomName)
+    if err != nil {
+      return err
+    }
+    entry, err = boltDirectoryEntry(tx, chatroom)
+    return err
+  })
+  if err != nil {
+    return nil, err
+  }
+  return entry, nil
+}
+
+// boltDirectoryEntry :: LastActivity comes from the last key of the Chatroom's Messages.
+func boltDirectoryEntry(tx *bbolt.Tx, chatroom *Chatroom)( *DirectoryEntry, error ){
+  entry := &DirectoryEntry{ RoomID: chatroom.RoomID, RoomName: chatroom.RoomName }
+
+  members, err := boltGetChatroomMembers(tx, chatroom.RoomName)
+  if err != nil {
+    return nil, err
+  }
+  entry.MemberCount = CountMembers(members)
+
+  messages, err := boltRoomMessages(tx, chatroom.RoomName, false)
+  if err != nil {
+    return nil, err
+  }
+  if messages != nil {
+    if _, data := messages.Cursor().Last(); data != nil {
+      var latest Message
+      dec := codec.NewDecoderBytes(data, &JSONHandle)
+      if err := dec.Decode(&latest); err != nil {
+        return nil, DecoderError{err.Error()}
+      }
+      entry.LastActivity = latest.TimeStamp
+    }
+  }
+  return entry, nil
+}
+
+// GetChatrooms :: Every Chatroom still within /Chatrooms, public or not.
+func(db *BBoltDB)GetChatrooms()( []Chatroom, error ){
+  rooms := []Chatroom{}
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    active := tx.Bucket([]byte(CHATROOMS))
+    if active == nil {
+      return BucketNotFoundError{CHATROOMS}
+    }
+    inactive := tx.Bucket([]byte(INACTIVECHATROOMS))
+    if inactive == nil {
+      return BucketNotFoundError{INACTIVECHATROOMS}
+    }
+    return active.ForEach(func(k, v []byte) error {
+      if inactive.Get(k) != nil {
+        return nil
+      }
+      var chatroom Chatroom
+      dec := codec.NewDecoderBytes(v, &JSONHandle)
+      if err := dec.Decode(&chatroom); err != nil {
+        return DecoderError{err.Error()}
+      }
+      rooms = append(rooms, chatroom)
+      return nil
+    })
+  })
+  if err != nil {
+    return nil, err
+  }
+  return rooms, nil
+}
+
+// OpenDirectChatroom :: Creates the Chatroom and both of it's Members within the
+//    same transaction, so two Users opening it at once still end up sharing one.
+func(db *BBoltDB)OpenDirectChatroom(userID, peerID UUID)( *Chatroom, error ){
+  chatroom := NewDirectChatroom(userID, peerID)
+  err := db.db.Update(func(tx *bbolt.Tx) error {
+    existing, err := boltGetChatroom(tx, chatroom.RoomName)
+    if err == nil {
+      chatroom = *existing
+      return nil
+    }
+    if _, ok := err.(GetDataError); !ok {
+      return err
+    }
+    exist, err := boltDoesChatroomExist(tx, chatroom.RoomName)
+    if err != nil {
+      return err
+    }
+    if exist {
+      log.Printf(" -> OpenDirectChatroom: \"%s\" has been deactivated", chatroom.RoomName)
+      return GetDataError{chatroom.RoomName, CHATROOMS}
+    }
+
+    var data []byte
+    enc := codec.NewEncoderBytes(&data, &JSONHandle)
+    if err := enc.Encode(chatroom); err != nil {
+      return EncoderError{err.Error()}
+    }
+    if err := tx.Bucket([]byte(CHATROOMS)).Put([]byte(chatroom.RoomName), data); err != nil {
+      return PutDataError{chatroom.RoomName, CHATROOMS, err.Error()}
+    }
+    for _, id := range []UUID{ userID, peerID } {
+      if err := boltSaveChatroomMember(tx, chatroom.RoomName, id, Member); err != nil {
+        return err
+      }
+    }
+    return boltSaveDefaultRoles(tx, chatroom.RoomName)
+  })
+  if err != nil {
+    return nil, err
+  }
+  return &chatroom, nil
+}
+
 // DeactivateChatroom: We Deactivate a Chatroom by first testing if the requestee
 //    is the Owner of said chatroom. If so, we simply copy over the Chatroom from
 //    Bucket /Chatrooms -> /InactiveChatrooms. Which isn't accessed from outside
@@ -135,10 +320,10 @@ func(db *BBoltDB)DeactivateChatroom(roomName string, userID UUID) error {
       log.Printf(" -> Error: SaveChatroom - Failed to get %s Bucket", CHATROOMS)
       return BucketNotFoundError{CHATROOMS}
     }
-    inactiveBucket, err := tx.CreateBucketIfNotExists([]byte(INACTIVECHATROOMS))
-    if err != nil {
-      log.Printf(" -> Error: SaveChatroom - Failed to get %s Bucket: %s", INACTIVECHATROOMS, err)
-      return BucketNotFoundError{CHATROOMS}
+    inactiveBucket := tx.Bucket([]byte(INACTIVECHATROOMS))
+    if inactiveBucket == nil {
+      log.Printf(" -> Error: SaveChatroom - Failed to get %s Bucket", INACTIVECHATROOMS)
+      return BucketNotFoundError{INACTIVECHATROOMS}
     }
 
     cm := activeBucket.Get([]byte(roomName))
@@ -146,14 +331,13 @@ func(db *BBoltDB)DeactivateChatroom(roomName string, userID UUID) error {
       return fmt.Errorf("Chatroom Doesn't Exist")
     }
 
-    status, err := db.GetChatroomMemberStatus(roomName, userID)
-    if err != nil {
-      log.Printf(" -> SaveChatroom: Failed to retreive UserStatus for OwneID in Chatroom")
-      return fmt.Errorf("OwnerID not found in Chatroom Members.")
+    member, err := boltGetChatroomMember(tx, roomName, userID)
+    if _, ok := err.(GetDataError); err != nil && !ok {
+      return err
     }
-    if *status != Owner{
-      log.Printf(" -> SaveChatroom: Invalid Credentials for updating Chatroom")
-      return fmt.Errorf("Invalid Credentials for updating Chatroom")
+    if err := Authorize(member, PermDeactivate, nil); err != nil {
+      log.Printf(" -> DeactivateChatroom: Invalid Credentials for deactivating Chatroom: %s", err)
+      return err
     }
 
     if err := inactiveBucket.Put([]byte(roomName), cm); err != nil {
@@ -170,50 +354,60 @@ func(db *BBoltDB)DeactivateChatroom(roomName string, userID UUID) error {
   })
 }
 
-// Get Chatroom: For joining Private chatrooms. Secret cannot be nil. If
-// /Chatrooms/{chatroom}'s secret is NOT nil, an Error will be returned.
+// JoinChatroom: Private Chatrooms require an unexpired Invitation within
+//    /Invitations/{user_id}/{room_id}, which is used up once the User joins.
 func(db *BBoltDB)JoinChatroom(
   chatroom string,
   username string,
-  invitation []byte,
 ) error {
   return db.db.Update(func(tx *bbolt.Tx) error {
-    cr, err := db.GetChatroom(chatroom)
+    cr, err := boltGetChatroom(tx, chatroom)
     if err != nil {
       log.Printf(" -> Error: JoinChatroom: Chatroom doesn't exist")
       return err
     }
+    if cr.IsDirect() {
+      return FailedSecurityCheckError{"Direct Chatroom", "Direct Chatrooms can't be joined"}
+    }
+    user, err := boltGetUserbyUsername(tx, username)
+    if err != nil {
+      return err
+    }
 
-    invitations := tx.Bucket([]byte(INVITATIONS))
-    if invitations == nil {
-      log.Printf(" -> Error: JoinChatroom - Failed to get %s Bucket: %s", INVITATIONS, err)
-      return BucketNotFoundError{INVITATIONS}
+    status, err := boltGetChatroomMemberStatus(tx, chatroom, user.UserID)
+    if _, ok := err.(GetDataError); err != nil && !ok {
+      return err
+    }
+    if done, err := checkRejoin(status); done {
+      return err
     }
-    user, err := db.GetUserbyUsername(username)
+    members, err := boltGetChatroomMembers(tx, chatroom)
     if err != nil {
       return err
     }
+    if err := checkCapacity(cr, members); err != nil {
+      return err
+    }
 
+    invitation, err := boltGetInvitation(tx, cr.RoomID, user.UserID)
+    if err != nil {
+      return err
+    }
     if !cr.Public {
-      inviteKey := inviteKey(&cr.RoomID, &user.UserID)
-      roomInvitation := invitations.Get([]byte(inviteKey))
-      if roomInvitation == nil {
-        log.Printf(" -> JoinChatroom: Room Invitation doesn't exist")
-        return GetDataError{inviteKey, INVITATIONS}
-      }
-      if err := CompareSecret(invitation, roomInvitation); err != nil {
-        log.Printf(" -> JoinChatroom: Invitation was incorrect.")
-        return FailedSecurityCheckError{"Invitation", err.Error()}
+      if err := checkInvitation(invitation, chatroom, user.UserID); err != nil {
+        log.Printf(" -> JoinChatroom: Invitation was missing or expired.")
+        return err
       }
-
-      // Invitation not needed anymore. Remove it.
-      if err := db.RemoveInvitation(cr.RoomID, user.UserID); err != nil {
+    }
+    // Invitation not needed anymore. Remove it.
+    if invitation != nil {
+      if err := boltRemoveInvitation(tx, cr.RoomID, user.UserID); err != nil {
         return err
       }
     }
 
     // Add User as a Memeber in Chatroom
-    return db.SaveChatroomMember(chatroom, user.UserID, Member)
+    return boltSaveChatroomMember(tx, chatroom, user.UserID, Member)
   })
 }
 
@@ -222,657 +416,2236 @@ func(db *BBoltDB)DoesChatroomExist(
 )( bool,error ){
   exists := false
   err := db.db.View(func(tx *bbolt.Tx) error {
-    active := tx.Bucket([]byte(CHATROOMS))
-    if active == nil {
-      log.Printf(" -> DoesChatroomExist: Failed to retreive Bucket \"%s\"", CHATROOMS)
-      return BucketNotFoundError{CHATROOMS}
-    }
-    deactive := tx.Bucket([]byte(DEACTIVATEDUSERS))
-    if deactive == nil {
-      log.Printf(" -> DoesChatroomExist: Failed to retreive Bucket \"%s\"", CHATROOMS)
-      return BucketNotFoundError{CHATROOMS}
-    }
-    if cm := active.Get([]byte(chatroom)); cm != nil {
-      exists = true
-    }
-    if cm := deactive.Get([]byte(chatroom)); cm != nil {
-      exists = true
-    }
-    return nil
+    var err error
+    exists, err = boltDoesChatroomExist(tx, chatroom)
+    return err
   })
   return exists, err
 }
 
-func(db *BBoltDB)GetChatroomMembers(chatroomName string)( map[UUID]MemberType, error) {
-  members := make(map[UUID]MemberType)
-
-  err := db.db.View(func(tx *bbolt.Tx) error {
-    bucket := tx.Bucket([]byte(CHATROOMMEMBERS))
-    if bucket == nil {
-      return BucketNotFoundError{CHATROOMMEMBERS}
+// RemoveChatroomMember :: Deletes /ChatroomMembers/{chatroom}-{user_id}, along with the
+//    User's /JoinedChatrooms and /ReadMarkers entries.
+func(db *BBoltDB)RemoveChatroomMember(chatroomName string, userID UUID) error {
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    if _, err := boltGetChatroomMemberStatus(tx, chatroomName, userID); err != nil {
+      return err
+    }
+    key := chatroomName + "-" + userID.String()
+    if err := tx.Bucket([]byte(CHATROOMMEMBERS)).Delete([]byte(key)); err != nil {
+      return DeleteDataError{key, CHATROOMMEMBERS, err.Error()}
     }
 
-    prefix := []byte(chatroomName + "-")
-    c := bucket.Cursor()
+    joined, err := boltRoomBucket(tx, JOINEDCHATROOMS, userID.String(), false)
+    if err != nil {
+      return err
+    }
+    if joined != nil {
+      if err := joined.Delete([]byte(chatroomName)); err != nil {
+        return DeleteDataError{chatroomName, JOINEDCHATROOMS, err.Error()}
+      }
+    }
 
-    for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
-      // Here, we remove prefix for 'k', thus leaving us with the 'UserID'
-      keyStr := string(k[len(prefix):])
-      userID, err := uuid.Parse(keyStr)
-      if err != nil {
-        log.Printf(" -> GetChatroomMembers: Failed to parse UserID")
-        return err
+    markers, err := boltRoomBucket(tx, READMARKERS, chatroomName, false)
+    if err != nil {
+      return err
+    }
+    if markers != nil {
+      if err := markers.Delete([]byte(userID.String())); err != nil {
+        return DeleteDataError{userID.String(), READMARKERS, err.Error()}
       }
-      memberType := MemberType(v[0])
-      members[userID] = memberType
     }
     return nil
   })
-  if err != nil {
-    log.Printf(" -> GetChatroomMembers: Failed to retreive all the Members of \"%s\"", chatroomName)
-    return nil, err
-  }
+}
 
-  return members, nil
+func(db *BBoltDB)GetChatroomMember(chatroomName string, userID UUID)( *ChatroomMember, error ){
+  var member *ChatroomMember
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    var err error
+    member, err = boltGetChatroomMember(tx, chatroomName, userID)
+    return err
+  })
+  return member, err
 }
 
-// StoreInvitation: Stores a Chatroom User invitation. The actualy invitation should be created outside this func. Stores the invite in /Invitations/{roomID-userID}
-func(db *BBoltDB)StoreInvitation(roomID UUID, userID UUID, invitation []byte) error {
+// SetChatroomMemberRole :: /ChatroomMembers values hold the MemberType's byte,
+//    followed by the name of the Member's Role.
+func(db *BBoltDB)SetChatroomMemberRole(chatroomName string, userID UUID, role string) error {
   return db.db.Update(func(tx *bbolt.Tx) error {
-    bucket, err := tx.CreateBucketIfNotExists([]byte(INVITATIONS))
+    if _, err := boltGetChatroomMemberStatus(tx, chatroomName, userID); err != nil {
+      return err
+    }
+    found, err := boltGetRole(tx, chatroomName, role)
     if err != nil {
-      log.Printf(" -> StoreInvitation: Failed to create/get /%s Bucket", INVITATIONS)
-      return BucketNotFoundError{INVITATIONS}
+      return err
     }
-    inviteKey := inviteKey(&roomID, &userID)
-
-    if err := bucket.Put([]byte(inviteKey), invitation); err != nil {
-      log.Printf(" -> StoreInvitation: Failed to store Chatroom Invitation.")
-      return PutDataError{INVITATIONS, inviteKey, err.Error()}
+    if found == nil {
+      return GetDataError{chatroomName + "/" + role, ROLES}
     }
 
-    return nil
+    memberType := memberTypeForRole(role)
+    key := chatroomName + "-" + userID.String()
+    if err := tx.Bucket([]byte(CHATROOMMEMBERS)).Put([]byte(key), append([]byte{byte(memberType)}, role...)); err != nil {
+      return PutDataError{key, CHATROOMMEMBERS, err.Error()}
+    }
+    return boltSaveJoinedChatroom(tx, chatroomName, userID, memberType)
   })
 }
 
-func(db *BBoltDB)RemoveInvitation(roomID UUID, userID UUID) error {
-  return db.db.Update(func(tx *bbolt.Tx) error {
-    bucket := tx.Bucket([]byte(INVITATIONS))
+func(db *BBoltDB)GetChatroomRoles(chatroomName string)( []Role, error ){
+  roles := []Role{}
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    bucket, err := boltRoomBucket(tx, ROLES, chatroomName, false)
+    if err != nil {
+      return err
+    }
     if bucket == nil {
-      return BucketNotFoundError{INVITATIONS}
+      return GetDataError{chatroomName, ROLES}
     }
+    return bucket.ForEach(func(_, v []byte) error {
+      var role Role
+      dec := codec.NewDecoderBytes(v, &JSONHandle)
+      if err := dec.Decode(&role); err != nil {
+        return DecoderError{err.Error()}
+      }
+      roles = append(roles, role)
+      return nil
+    })
+  })
+  if err != nil {
+    return nil, err
+  }
+  sortRoles(roles)
+  return roles, nil
+}
 
-    inviteKey := inviteKey(&roomID, &userID)
-
-    if err := bucket.Delete([]byte(inviteKey)); err != nil {
-      log.Printf(" -> RemoveInvitation: Failed to remove /%s/%s", INVITATIONS, inviteKey)
-      return DeleteDataError{INVITATIONS, inviteKey, err.Error()}
+func(db *BBoltDB)SaveChatroomRole(chatroomName string, role *Role) error {
+  if err := ValidateRole(role); err != nil {
+    return err
+  }
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    if _, err := boltGetChatroom(tx, chatroomName); err != nil {
+      return err
     }
-    return nil
+    return boltPutRole(tx, chatroomName, role)
   })
 }
 
-func(db *BBoltDB)HandleRawMessage(raw []byte) error{
+// DeleteChatroomRole :: Walks every /ChatroomMembers/{chatroom}-{user_id} key, handing
+//    MemberRole back to whoever held the Role.
+func(db *BBoltDB)DeleteChatroomRole(chatroomName, name string) error {
+  if IsDefaultRole(name) {
+    return FailedSecurityCheckError{"Role", "Default Roles can't be deleted"}
+  }
   return db.db.Update(func(tx *bbolt.Tx) error {
-    bucket, err := tx.CreateBucketIfNotExists([]byte(MESSAGES))
+    found, err := boltGetRole(tx, chatroomName, name)
     if err != nil {
-      return BucketNotFoundError{MESSAGES}
+      return err
     }
-    extraction := struct{
-      Chatroom string  `codec:"chatroom"`
-      Message  Message `codec:"message"`
-    }{}
-    dec := codec.NewDecoderBytes(raw, &JSONHandle)
-    if err := dec.Decode(&extraction); err != nil {
-      return DecoderError{err.Error()}
+    if found == nil {
+      return GetDataError{chatroomName + "/" + name, ROLES}
     }
-
-    messageKey := extraction.Chatroom + "-" + extraction.Message.TimeStamp.Format(DATEFMT)
-
-    var data[]byte
-    enc := codec.NewEncoderBytes(&data, &JSONHandle)
-    if err := enc.Encode(extraction.Message); err != nil {
-      return EncoderError{err.Error()}
+    bucket, err := boltRoomBucket(tx, ROLES, chatroomName, false)
+    if err != nil {
+      return err
     }
-    if err := bucket.Put([]byte(messageKey), data); err != nil {
-      return PutDataError{messageKey, MESSAGES, err.Error()}
+    if err := bucket.Delete([]byte(name)); err != nil {
+      return DeleteDataError{chatroomName + "/" + name, ROLES, err.Error()}
     }
 
+    const uuidLength = 36
+    prefix := []byte(chatroomName + "-")
+    members := tx.Bucket([]byte(CHATROOMMEMBERS))
+    var holders [][]byte
+    c := members.Cursor()
+    for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+      if len(k) == len(prefix)+uuidLength && len(v) > 1 && string(v[1:]) == name {
+        holders = append(holders, append([]byte(nil), k...))
+      }
+    }
+    for _, k := range holders {
+      if err := members.Put(k, []byte{byte(Member)}); err != nil {
+        return PutDataError{string(k), CHATROOMMEMBERS, err.Error()}
+      }
+    }
     return nil
   })
 }
 
-// SaveMessage :: Takes and stores a New Message object under /Messages/{chatroom-timestamp}. Might change this later.
-func(db *BBoltDB)SaveMessage(chatroom string, message *Message) error {
+func(db *BBoltDB)GetChatroomMembers(chatroomName string)( map[UUID]MemberType, error) {
+  var members map[UUID]MemberType
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    var err error
+    members, err = boltGetChatroomMembers(tx, chatroomName)
+    return err
+  })
+  if err != nil {
+    log.Printf(" -> GetChatroomMembers: Failed to retreive all the Members of \"%s\"", chatroomName)
+    return nil, err
+  }
+
+  return members, nil
+}
+
+// SaveInvitation: Stores, or replaces, the Invitation within /Invitations/{user_id}/{room_id}.
+func(db *BBoltDB)SaveInvitation(invitation *Invitation) error {
   return db.db.Update(func(tx *bbolt.Tx) error {
-    b, err := tx.CreateBucketIfNotExists([]byte(MESSAGES))
+    bucket, err := boltRoomBucket(tx, INVITATIONS, invitation.UserID.String(), true)
     if err != nil {
-      log.Printf(" -> Error: SaveMessage - Failed to get %s Bucket: %s", CHATROOMS, err)
-      return BucketNotFoundError{MESSAGES}
-    }
-    exist, err := db.DoesChatroomExist(chatroom)
-    if err != nil {
-      log.Printf(" -> SaveMessage: Error while checking if chatroom exists.")
+      log.Printf(" -> SaveInvitation: Failed to get /%s Bucket", INVITATIONS)
       return err
     }
-    if !exist {
-      log.Printf(" -> SaveMessage: Error - Chatroom Does't exist.")
-      return fmt.Errorf("Error: Received Message for a chatroom that doesn't exist")
-    }
-
-    messageKey := chatroom + "-" + message.TimeStamp.Format(DATEFMT)
 
     var data []byte
     enc := codec.NewEncoderBytes(&data, &JSONHandle)
-    if err := enc.Encode(message); err != nil {
+    if err := enc.Encode(invitation); err != nil {
       return EncoderError{err.Error()}
     }
-    if err := b.Put([]byte(messageKey), data); err != nil {
-      return PutDataError{messageKey, MESSAGES, err.Error()}
+    if err := bucket.Put([]byte(invitation.RoomID.String()), data); err != nil {
+      log.Printf(" -> SaveInvitation: Failed to store Chatroom Invitation.")
+      return PutDataError{inviteKey(&invitation.RoomID, &invitation.UserID), INVITATIONS, err.Error()}
     }
     return nil
   })
 }
 
-// Pagination: Based on time. At the moment, this only paginates where
-//  a page of 1 == 1 Day. Will need to find a more refined approach for paginating messages
-func(db *BBoltDB)Paginate(
-  chatroomName string,
-  page, limit int,
-)( []byte, error ){
-  if limit <= 0 || page <= 0 {
-    return nil, fmt.Errorf("Invalid page or limit value")
-  }
-  var rawMessages [][]byte
+func(db *BBoltDB)GetInvitation(roomID UUID, userID UUID)( *Invitation, error ){
+  var invitation *Invitation
   err := db.db.View(func(tx *bbolt.Tx) error {
-    b := tx.Bucket([]byte(MESSAGES))
-    if b == nil {
-      log.Printf("Bucket %s not found", MESSAGES)
-      return BucketNotFoundError{MESSAGES}
-    }
-
-    c := b.Cursor()
-
-    startKey := chatroomName + "-" + computeTimestampForPage(page, limit)
-    k, v := c.Seek([]byte(startKey))
-
-    limiter := func(i int) bool {
-      return i < limit &&
-        k != nil &&
-        bytes.HasPrefix(k,[]byte(chatroomName+"-"));
+    var err error
+    if invitation, err = boltGetInvitation(tx, roomID, userID); err != nil {
+      return err
     }
-
-    for i := 0; limiter(i); i++ {
-      // var message Message
-      // dec := codec.NewDecoderBytes(v, &JSONHandle)
-      // if err := dec.Decode(&message); err != nil {
-      //   return DecoderError{err.Error()}
-      // }
-      rawMessages = append(rawMessages, v)
-      k, v = c.Next()
+    if invitation == nil {
+      return GetDataError{inviteKey(&roomID, &userID), INVITATIONS}
     }
     return nil
   })
   if err != nil {
     return nil, err
   }
-  // We'll seperate our Messages with byte("[") & byte("]") for client-side serialzation
-  // I'm not too sure if I want to Ship out messages this way, or by Encoding and Decoding, from
-  // the Database => HTTP.Repsonse body. I'll need to run some benchmarks to see if this is more
-  // Viable.. Maybe I'll abstract the Byte manipulation away, that way I can support more formats
-  // other than just JSON.
-  var combined = []byte("[")
-  for i, msg := range rawMessages {
-    combined = append(combined, msg...)
-    if i != len(rawMessages)-1 {
-      combined = append(combined, ',')
-    }
-  }
-  combined = append(combined, ']')
-
-  return combined, nil
-}
-func computeTimestampForPage(page, limit int) string {
-  date := time.Now().AddDate(0, 0, -((page - 1) * limit))
-  return date.Format(DATEFMT)
+  return invitation, nil
 }
 
-func(db *BBoltDB)UpdateChatroomUserStatus(chatroom, username string, status Status) error {
-  return db.db.Update(func(tx *bbolt.Tx) error {
-    userBucket := tx.Bucket([]byte(USERNAMES))
-    if userBucket == nil {
-      return BucketNotFoundError{USERNAMES}
+// GetPendingInvitations :: Walks /Invitations/{user_id}. Expired Invitations are
+//    left out, and left in place, since JoinChatroom refuses them anyway.
+func(db *BBoltDB)GetPendingInvitations(userID UUID)( []Invitation, error ){
+  invitations := []Invitation{}
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    bucket, err := boltRoomBucket(tx, INVITATIONS, userID.String(), false)
+    if err != nil || bucket == nil {
+      return err
     }
-    data := userBucket.Get([]byte(username))
-    if data == nil {
-      return GetDataError{username, USERNAMES}
-    }
-    var user User
-    dec := codec.NewDecoderBytes(data, &JSONHandle)
-    if err := dec.Decode(&user); err != nil {
-      return DecoderError{err.Error()}
-    }
-
-    memtype, err := db.GetChatroomMemberStatus(chatroom, user.UserID)
-    if err != nil || memtype == nil || *memtype == Blocked{
-      return FailedSecurityCheckError{
-        "MemberType: nil or blocked",
-        err.Error(),
+    now := time.Now()
+    return bucket.ForEach(func(_, v []byte) error {
+      var invitation Invitation
+      dec := codec.NewDecoderBytes(v, &JSONHandle)
+      if err := dec.Decode(&invitation); err != nil {
+        return DecoderError{err.Error()}
       }
-    }
+      if invitation.Expired(now) {
+        return nil
+      }
+      if _, err := boltGetChatroom(tx, invitation.Chatroom); err != nil {
+        if _, ok := err.(GetDataError); ok {
+          return nil
+        }
+        return err
+      }
+      invitations = append(invitations, invitation)
+      return nil
+    })
+  })
+  if err != nil {
+    return nil, err
+  }
+  sortInvitations(invitations)
+  return invitations, nil
+}
 
-    liveBucket, err := tx.CreateBucketIfNotExists([]byte(LIVEMEMBER))
-    if err != nil {
-      return BucketNotFoundError{LIVEMEMBER}
-    }
+func(db *BBoltDB)RemoveInvitation(roomID UUID, userID UUID) error {
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    return boltRemoveInvitation(tx, roomID, userID)
+  })
+}
 
-    data = liveBucket.Get([]byte(chatroom))
+func(db *BBoltDB)SaveInviteLink(link *InviteLink) error {
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    return boltPutInviteLink(tx, link)
+  })
+}
 
-    users := make(map[UserName]string)
+func(db *BBoltDB)GetInviteLink(code string)( *InviteLink, error ){
+  var link *InviteLink
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    var err error
+    link, err = boltGetInviteLink(tx, code)
+    return err
+  })
+  if err != nil {
+    return nil, err
+  }
+  return link, nil
+}
 
-    dec = codec.NewDecoderBytes(data, &JSONHandle)
-    if err := dec.Decode(&users); err != nil {
-      return DecoderError{err.Error()}
+func(db *BBoltDB)RedeemInviteLink(code string, userID UUID)( *InviteLink, error ){
+  var link *InviteLink
+  err := db.db.Update(func(tx *bbolt.Tx) error {
+    var err error
+    if link, err = boltGetInviteLink(tx, code); err != nil {
+      return err
     }
-
-    switch status {
-    case Online:
-      users[username] = "Online"
-    case Background:
-      users[username] = "Background"
-    case Offline:
-      users[username] = "Offline"
-    case Delete:
-      // Should only be called from Owner/Moderator/member(when deleting self)
-      delete(users, username)
-    default:
-      return fmt.Errorf("Unknonw Status Request")
+    chatroom, err := boltGetChatroom(tx, link.Chatroom)
+    if err != nil {
+      return err
     }
-
-    var bytes []byte
-    enc := codec.NewEncoderBytes(&bytes, &JSONHandle)
-    if err := enc.Encode(users); err != nil {
-      return EncoderError{err.Error()}
+    status, err := boltGetChatroomMemberStatus(tx, link.Chatroom, userID)
+    if _, ok := err.(GetDataError); err != nil && !ok {
+      return err
     }
-
-    if err := liveBucket.Put([]byte(chatroom),bytes); err != nil {
-      return PutDataError{LIVEMEMBER, chatroom, err.Error()}
+    members, err := boltGetChatroomMembers(tx, link.Chatroom)
+    if err != nil {
+      return err
     }
-
-    return nil
+    if err := link.redeemFor(chatroom, status, members); err != nil {
+      return err
+    }
+    if err := boltPutInviteLink(tx, link); err != nil {
+      return err
+    }
+    return boltSaveChatroomMember(tx, link.Chatroom, userID, Member)
   })
+  if err != nil {
+    return nil, err
+  }
+  return link, nil
 }
 
-func(db *BBoltDB)GetChatroomUserStatus(chatroom string)( map[UserName]string, error ) {
-  var stats = make(map[UserName]string)
-  err := db.db.View(func(tx *bbolt.Tx) error {
-    bucket := tx.Bucket([]byte(LIVEMEMBER))
-    if bucket == nil {
-      return BucketNotFoundError{LIVEMEMBER}
-    }
+func(db *BBoltDB)HandleRawMessage(chatroomName string, userID UUID, raw []byte)( []byte, error ){
+  extraction, err := decodeChatroomMessage(raw, chatroomName, userID)
+  if err != nil {
+    return nil, err
+  }
 
-    data := bucket.Get([]byte(chatroom))
-    if data == nil {
-      return GetDataError{chatroom, LIVEMEMBER}
+  err = db.db.Update(func(tx *bbolt.Tx) error {
+    chatroom, err := boltGetChatroom(tx, extraction.Chatroom)
+    if err != nil {
+      return err
     }
-
-    dec := codec.NewDecoderBytes(data, &JSONHandle)
-    if err := dec.Decode(&stats); err != nil {
-      return DecoderError{err.Error()}
+    member, err := boltGetChatroomMember(tx, extraction.Chatroom, extraction.Message.UserID)
+    if _, ok := err.(GetDataError); err != nil && !ok {
+      return err
     }
-
-    return nil
+    if err := authorizePost(chatroom, member); err != nil {
+      return err
+    }
+    return boltPutMessage(tx, extraction.Chatroom, &extraction.Message)
   })
   if err != nil {
     return nil, err
   }
-
-  return stats, nil
+  return encodeChatroomMessage(extraction)
 }
 
-// SaveChatroomMember :: For Saving/updating a Chatroom's Member's List.
-func( db *BBoltDB )SaveChatroomMember(
-  chatroomName string,
-  userID UUID,
-  memberType MemberType,
-) error{
+// SaveMessage :: Takes and stores a New Message object under /Messages/{chatroom}/{seq}.
+func(db *BBoltDB)SaveMessage(chatroom string, message *Message) error {
   return db.db.Update(func(tx *bbolt.Tx) error {
-    bucket, err := tx.CreateBucketIfNotExists([]byte(CHATROOMMEMBERS))
+    exist, err := boltDoesChatroomExist(tx, chatroom)
     if err != nil {
-      log.Printf(" -> Error: SaveChatroomMember - Failed to get %s Bucket: %s", CHATROOMMEMBERS, err)
-      return BucketNotFoundError{CHATROOMMEMBERS}
+      log.Printf(" -> SaveMessage: Error while checking if chatroom exists.")
+      return err
     }
-    key := chatroomName + "-" + userID.String()
-
-    if err := bucket.Put([]byte(key), []byte{byte(memberType)}); err != nil {
-      log.Printf(" -> SaveChatroomMember: Failed to update/save Chatroom Member")
-      return PutDataError{key, CHATROOMMEMBERS, err.Error()}
+    if !exist {
+      log.Printf(" -> SaveMessage: Error - Chatroom Does't exist.")
+      return fmt.Errorf("Error: Received Message for a chatroom that doesn't exist")
     }
-    return nil
+
+    return boltPutMessage(tx, chatroom, message)
   })
 }
 
-func(db *BBoltDB)GetChatroomMemberStatus(
-  chatroomName string,
-  userID UUID,
-)( *MemberType, error ){
-  var userStatus *MemberType = nil
-  err := db.db.View(func(tx *bbolt.Tx) error {
-    bucket := tx.Bucket([]byte(CHATROOMMEMBERS))
-    if bucket == nil {
-      return BucketNotFoundError{CHATROOMMEMBERS}
+func(db *BBoltDB)SaveMessages(chatroom string, messages []Message) error {
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    exist, err := boltDoesChatroomExist(tx, chatroom)
+    if err != nil {
+      return err
     }
-
-    key := chatroomName + "-" + userID.String()
-    data := bucket.Get([]byte(key))
-    if data == nil {
-      return GetDataError{key, CHATROOMMEMBERS}
+    if !exist {
+      log.Printf(" -> SaveMessages: Error - Chatroom Does't exist.")
+      return fmt.Errorf("Error: Received Messages for a chatroom that doesn't exist")
+    }
+    for i := range messages {
+      if err := boltPutMessage(tx, chatroom, &messages[i]); err != nil {
+        return err
+      }
     }
-
-    stat := MemberType(data[0])
-
-    userStatus = &stat
     return nil
   })
-
-  return userStatus, err
 }
 
-// SaveUser :: Takes in a User Object. Will create 5 seperate bucket entries.
-//      /Users           : For storing the User data sturct   [   userID : User            ]
-//      /Usernames       : For indexing /Users via Username   [ username : userID          ]
-//      /UsersOnline     : For storing user's online state    [ username : bool            ]
-//      /UserTokens      : For storing a user's AccessTokens  [   userID : Token           ]
-//      /JoinedChatrooms : For storing users joined chatrooms [ username : JoinedChatrooms ]
-func(db *BBoltDB)SaveUser(user User, token *token.Token) error {
-  return db.db.Update(func(tx *bbolt.Tx) error {
-    uid := []byte(user.UserID.String())
-    username := []byte(user.Username)
+// PurgeMessages :: Finds the expired Messages within a read transaction, so the
+//    write transaction deleting them is only ever held for limit Messages.
+func(db *BBoltDB)PurgeMessages(
+  chatroomName string,
+  retention    Retention,
+  now          time.Time,
+  limit        int,
+)( int, error ){
+  if retention.Forever() {
+    return 0, nil
+  }
+  if limit <= 0 {
+    limit = DefaultPurgeBatchSize
+  }
 
-    // /Users -> Holds Entire User Object [ userID : User ]
-    bucket, err := tx.CreateBucketIfNotExists([]byte(USERS))
-    if err != nil {
-      log.Printf(" -> Error: SaveUser - Failed to get %s Bucket: %s", USERS, err)
-      return BucketNotFoundError{USERS}
+  var expired []uint64
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    room, err := boltRoomMessages(tx, chatroomName, false)
+    if err != nil || room == nil {
+      return err
     }
-
-    var out []byte
-    enc := codec.NewEncoderBytes(&out, &JSONHandle)
-    if err := enc.Encode(user); err != nil {
-      log.Printf(" -> Error: SaveUser - Failed to Encode new User: %s", err)
-      return EncoderError{err.Error()}
+    newer := room.Stats().KeyN
+    c := room.Cursor()
+    for k, v := c.First(); k != nil && len(expired) < limit; k, v = c.Next() {
+      newer--
+      var message Message
+      dec := codec.NewDecoderBytes(v, &JSONHandle)
+      if err := dec.Decode(&message); err != nil {
+        return DecoderError{err.Error()}
+      }
+      if !retention.expired(message.TimeStamp, newer, now) {
+        break
+      }
+      expired = append(expired, btoi(k))
     }
+    return nil
+  })
+  if err != nil || len(expired) == 0 {
+    return 0, err
+  }
 
-    if err = bucket.Put(uid, out); err != nil {
-      log.Printf(" -> Error: Failed to Create new user in Database")
-      return PutDataError{user.UserID.String(), USERS, err.Error()}
+  err = db.db.Update(func(tx *bbolt.Tx) error {
+    for _, seq := range expired {
+      if err := boltPurgeMessage(tx, chatroomName, seq); err != nil {
+        return err
+      }
     }
+    return nil
+  })
+  if err != nil {
+    return 0, err
+  }
+  return len(expired), nil
+}
 
-    // /Usernames -> Holds Entire Users for indexing Users
-    //               via Username [ username : userID ]
-    bucket, err = tx.CreateBucketIfNotExists([]byte(USERNAMES))
-    if err != nil {
-      log.Printf(" -> Error: Failed to Create %s Bucket: %s", USERNAMES, err)
-      return BucketNotFoundError{USERNAMES}
-    }
+// Paginate :: Walks /Messages/{chatroom}/{seq} with a cursor. Since keys are big-endian
+//    sequence numbers, seeking to a Message is a direct B+tree lookup.
+func(db *BBoltDB)Paginate(
+  chatroomName string,
+  before, after uint64,
+  limit int,
+)( *MessagePage, error ){
+  limit = ClampPageSize(limit)
 
-    if err = bucket.Put(username, uid); err != nil {
-      log.Printf(" -> Error: The Username \"%s\" is aldready taken: %s", username, err)
-      return PutDataError{user.Username, USERNAMES, err.Error()}
+  var msgs []Message
+  var hasOlder, hasNewer bool
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    b, err := boltRoomMessages(tx, chatroomName, false)
+    if err != nil || b == nil {
+      return err
     }
 
-    // /UsersOnline -> Used for storing a user's online status [ username : bool ]
-    if err := db.SaveUsersOnlineStatus(user.Username, true); err != nil {
+    msgs, hasOlder, hasNewer, err = boltPaginate(b.Cursor(), before, after, limit,
+      func(_, v []byte)( *Message, error ){
+        var message Message
+        dec := codec.NewDecoderBytes(v, &JSONHandle)
+        if err := dec.Decode(&message); err != nil {
+          return nil, DecoderError{err.Error()}
+        }
+        return &message, nil
+      },
+    )
+    return err
+  })
+  if err != nil {
+    return nil, err
+  }
+  return NewMessagePage(msgs, hasOlder, hasNewer), nil
+}
+
+// ScanMessages :: Seeks straight to the Message after 'after'.
+func(db *BBoltDB)ScanMessages(chatroomName string, after uint64, limit int)( []Message, error ){
+  var msgs []Message
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    b, err := boltRoomMessages(tx, chatroomName, false)
+    if err != nil || b == nil {
       return err
     }
-
-    if token != nil {
-      if err := db.SaveUserToken(user.UserID, token); err != nil {
-        return err
+    c := b.Cursor()
+    for k, v := c.Seek(itob(after+1)); k != nil && len(msgs) < limit; k, v = c.Next() {
+      var message Message
+      dec := codec.NewDecoderBytes(v, &JSONHandle)
+      if err := dec.Decode(&message); err != nil {
+        return DecoderError{err.Error()}
       }
-    }
-
-    if err := bucket.Put(username, BoolToBytes(true)); err != nil {
-      return PutDataError{user.Username, USERNAMES, err.Error()}
+      msgs = append(msgs, message)
     }
     return nil
   })
+  if err != nil {
+    return nil, err
+  }
+  return msgs, nil
 }
 
-func(db *BBoltDB)GetUserByID(id UUID)( *User,error ){
-  var user *User = nil
+// PaginateThread :: Walks /Threads/{chatroom}/{thread_id}, loading each reply from
+//    /Messages/{chatroom}/{seq}.
+func(db *BBoltDB)PaginateThread(
+  chatroomName string,
+  threadID     UUID,
+  before, after uint64,
+  limit int,
+)( *MessagePage, error ){
+  limit = ClampPageSize(limit)
+
+  var msgs []Message
+  var hasOlder, hasNewer bool
   err := db.db.View(func(tx *bbolt.Tx) error {
-    bucket := tx.Bucket([]byte(USERS))
-    if bucket == nil {
-      log.Printf(" -> GetUserByID - Bucket \"%s\" not found.", USERS)
-      return BucketNotFoundError{USERS}
+    thread, err := boltThread(tx, chatroomName, threadID, false)
+    if err != nil || thread == nil {
+      return err
     }
-    data := bucket.Get(id[:])
-    if data == nil {
-      log.Printf(" -> GetUserById - User ID \"%s\" not found.", id)
-      return GetDataError{id.String(), USERS}
+    room, err := boltRoomMessages(tx, chatroomName, false)
+    if err != nil {
+      return err
     }
-    dec := codec.NewDecoderBytes(data, &JSONHandle)
-    return dec.Decode(&user)
+    if room == nil {
+      return GetDataError{chatroomName, MESSAGES}
+    }
+
+    msgs, hasOlder, hasNewer, err = boltPaginate(thread.Cursor(), before, after, limit,
+      func(k, _ []byte)( *Message, error ){
+        data := room.Get(k)
+        if data == nil {
+          return nil, GetDataError{fmt.Sprintf("%s/%d", chatroomName, btoi(k)), MESSAGES}
+        }
+        var message Message
+        dec := codec.NewDecoderBytes(data, &JSONHandle)
+        if err := dec.Decode(&message); err != nil {
+          return nil, DecoderError{err.Error()}
+        }
+        return &message, nil
+      },
+    )
+    return err
   })
+  if err != nil {
+    return nil, err
+  }
+  return NewMessagePage(msgs, hasOlder, hasNewer), nil
+}
 
-  return user, err
+func(db *BBoltDB)GetMessage(chatroom string, messageID UUID)( *Message, error ){
+  var message *Message
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    var err error
+    message, err = boltGetMessage(tx, chatroom, messageID)
+    return err
+  })
+  return message, err
 }
 
-func(db *BBoltDB)ActivateUser(userID UUID) error {
-  return db.db.Update(func(tx *bbolt.Tx) error {
-    deactivatedBucket := tx.Bucket([]byte(DEACTIVATEDUSERS))
-    if deactivatedBucket == nil {
-      return fmt.Errorf(" -> ActivateUser: Failed to get \"%s\" Bucket", DEACTIVATEDUSERS)
+// EditMessage :: The Message keeps it's Seq. Only it's Content, EditedAt, and it's
+//    terms within /SearchIndex change.
+func(db *BBoltDB)EditMessage(
+  chatroom  string,
+  messageID UUID,
+  editorID  UUID,
+  content   string,
+)( *Message, error ){
+  var message *Message
+  err := db.db.Update(func(tx *bbolt.Tx) error {
+    var err error
+    if message, err = boltGetMessage(tx, chatroom, messageID); err != nil {
+      return err
     }
-    data := deactivatedBucket.Get([]byte(userID.String()))
-    if data == nil {
-      return GetDataError{userID.String(), DEACTIVATEDUSERS}
+    if message.IsDeleted() {
+      return MessageDeletedError{messageID.String()}
     }
 
-    var user User
-    dec := codec.NewDecoderBytes(data, &JSONHandle)
-    if dec == nil {
-      return fmt.Errorf(" -> ActivateUser: Failed to create codec Decoder.")
-    }
-    if err := dec.Decode(&user); err != nil {
-      log.Printf(" -> ActivateUser: Failed to Decode User")
+    revisions, err := boltGetMessageRevisions(tx, chatroom, messageID)
+    if err != nil {
       return err
     }
-
-    usersBucket := tx.Bucket([]byte(USERS))
-    if usersBucket == nil {
-      return fmt.Errorf(" -> ActivateUser: Failed to get \"%s\" Bucket", USERS)
+    now := time.Now()
+    revisions = append(revisions, MessageRevision{ message.Content, now, editorID })
+    var data []byte
+    enc := codec.NewEncoderBytes(&data, &JSONHandle)
+    if err := enc.Encode(revisions); err != nil {
+      return EncoderError{err.Error()}
     }
-
-    usernameBucket := tx.Bucket([]byte(USERNAMES))
-    if usernameBucket == nil {
-      return fmt.Errorf(" ->  ActivateUser: Failed to get \"%s\" Bucket", USERNAMES)
+    b, err := boltRoomBucket(tx, MESSAGEREVISIONS, chatroom, true)
+    if err != nil {
+      return err
+    }
+    if err := b.Put([]byte(messageID.String()), data); err != nil {
+      return PutDataError{messageID.String(), MESSAGEREVISIONS, err.Error()}
     }
 
-    if err := usersBucket.Put(userID[:], data); err != nil {
-      log.Printf(" -> ActivateUser: Failed to Move User to /%s Bucket.", USERS)
+    if err := boltUnindexMessage(tx, chatroom, message); err != nil {
       return err
     }
-    if err := usernameBucket.Put([]byte(user.Username), userID[:]); err != nil {
-      log.Printf(" -> ActivateUser: Failed to add User to /%s Bucket.", USERNAMES)
+    message.Content = content
+    message.EditedAt = now
+    if err := boltStoreMessage(tx, chatroom, message); err != nil {
       return err
     }
+    return boltIndexMessage(tx, chatroom, message)
+  })
+  if err != nil {
+    log.Printf(" -> EditMessage: Failed to edit Message \"%s\": %s", messageID, err)
+    return nil, err
+  }
+  return message, nil
+}
 
-    if err := deactivatedBucket.Delete(userID[:]); err != nil {
-      log.Printf(" -> ActivateUser: Failed to Delete User to /%s Bucket.", DEACTIVATEDUSERS)
+// DeleteMessage :: Leaves a tombstone at /Messages/{chatroom}/{seq}, and drops
+//    everything else that holds the Message's Content.
+func(db *BBoltDB)DeleteMessage(chatroom string, messageID UUID)( *Message, error ){
+  var message *Message
+  err := db.db.Update(func(tx *bbolt.Tx) error {
+    var err error
+    if message, err = boltGetMessage(tx, chatroom, messageID); err != nil {
       return err
     }
+    if message.IsDeleted() {
+      return MessageDeletedError{messageID.String()}
+    }
 
-    return nil
-  })
-}
-
-// DeactivateUser: Takes a User from /User/{user_id} and moves it to the /DeletedUser's Bucket
-func(db *BBoltDB)DeactivateUser(userID UUID) error {
-  return db.db.Update(func(tx *bbolt.Tx) error {
-    // Get User from /Users
-    usersBucket := tx.Bucket([]byte(USERS))
-    if usersBucket == nil {
-      return fmt.Errorf(" -> DeactivateUser: Failed to get \"%s\" Bucket", USERS)
-    }
-    data := usersBucket.Get([]byte(userID.String()))
-    if data == nil {
-      return GetDataError{userID.String(), USERS}
+    if err := boltUnindexMessage(tx, chatroom, message); err != nil {
+      return err
     }
-
-    var user User
-    dec := codec.NewDecoderBytes(data, &JSONHandle)
-    if dec == nil {
-      return fmt.Errorf(" -> DeactivateUser: Failed to create codec Decoder.")
+    b, err := boltRoomBucket(tx, MESSAGEREVISIONS, chatroom, false)
+    if err != nil {
+      return err
     }
-    if err := dec.Decode(&user); err != nil {
-      log.Printf(" -> DeactivateUser: Failed to Decode User")
-      return DecoderError{err.Error()}
+    if b != nil {
+      if err := b.Delete([]byte(messageID.String())); err != nil {
+        return DeleteDataError{messageID.String(), MESSAGEREVISIONS, err.Error()}
+      }
     }
 
-    deactivatedBucket, err := tx.CreateBucketIfNotExists([]byte(DEACTIVATEDUSERS ))
+    deleted, err := boltRoomBucket(tx, DELETEDMESSAGES, chatroom, true)
     if err != nil {
-      log.Printf(" -> DeactivateUser: Failed to create or get \"%s\" Bucket.", DEACTIVATEDUSERS)
-      return BucketNotFoundError{DEACTIVATEDUSERS}
+      return err
     }
-    if err := deactivatedBucket.Put(userID[:], data); err != nil {
-      log.Printf(" -> DeactivateUser: Failed to Move User to /%s Bucket.", DEACTIVATEDUSERS)
-      return PutDataError{userID.String(), DEACTIVATEDUSERS, err.Error()}
+    if err := deleted.Put(itob(message.Seq), []byte{}); err != nil {
+      return PutDataError{messageID.String(), DELETEDMESSAGES, err.Error()}
     }
 
-    usernameBucket := tx.Bucket([]byte(USERNAMES))
-    if usernameBucket == nil {
-      return BucketNotFoundError{USERNAMES}
-    }
+    message.Content = ""
+    message.Reactions = nil
+    message.DeletedAt = time.Now()
+    return boltStoreMessage(tx, chatroom, message)
+  })
+  if err != nil {
+    log.Printf(" -> DeleteMessage: Failed to delete Message \"%s\": %s", messageID, err)
+    return nil, err
+  }
+  return message, nil
+}
+
+func(db *BBoltDB)AddReaction(chatroom string, messageID UUID, userID UUID, reaction string)( *Message, error ){
+  return db.updateReaction(chatroom, messageID, func(message *Message) bool {
+    return message.addReaction(reaction, userID)
+  })
+}
+
+func(db *BBoltDB)RemoveReaction(chatroom string, messageID UUID, userID UUID, reaction string)( *Message, error ){
+  return db.updateReaction(chatroom, messageID, func(message *Message) bool {
+    return message.removeReaction(reaction, userID)
+  })
+}
 
-    // Removes User from /Usernames bucket
-    if err := usernameBucket.Delete([]byte(user.Username)); err != nil {
-      log.Printf(" -> DeactivateUser: Failed to remove \"%s\" from /%s Bucket", user.Username, USERNAMES)
-      return DeleteDataError{user.Username, USERNAMES, err.Error()}
+// updateReaction :: Reactions live on the Message itself, so every Message read
+//    from /Messages already carries them. update returns false when nothing changed.
+func(db *BBoltDB)updateReaction(
+  chatroom  string,
+  messageID UUID,
+  update    func(message *Message) bool,
+)( *Message, error ){
+  var message *Message
+  err := db.db.Update(func(tx *bbolt.Tx) error {
+    var err error
+    if message, err = boltGetMessage(tx, chatroom, messageID); err != nil {
+      return err
     }
-    // Once we know everything has passed, we now attempt to delete the User from /Users.
-    // Yea, I know that BBolt will rollback any changes if this anonymous function returns an error. But safer than sorry.
-    if err := usersBucket.Delete([]byte(userID.String())); err != nil {
-      log.Printf(" -> DeactivateUser: Failed to decode User \"%s\" from /%s bucket", userID.String(), USERS)
-      return DeleteDataError{userID.String(), USERS, err.Error()}
+    if message.IsDeleted() {
+      return MessageDeletedError{messageID.String()}
     }
-    return nil
+    if !update(message) {
+      return nil
+    }
+    return boltStoreMessage(tx, chatroom, message)
+  })
+  if err != nil {
+    return nil, err
+  }
+  return message, nil
+}
+
+func(db *BBoltDB)GetMessageRevisions(chatroom string, messageID UUID)( []MessageRevision, error ){
+  var revisions []MessageRevision
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    if _, err := boltGetMessage(tx, chatroom, messageID); err != nil {
+      return err
+    }
+    var err error
+    revisions, err = boltGetMessageRevisions(tx, chatroom, messageID)
+    return err
   })
+  return revisions, err
 }
 
-func(db *BBoltDB)GetUserbyUsername(username string)( *User, error ){
-  var user *User = nil
+// SearchMessages :: Prefix scans /SearchIndex for the first term, then confirms the
+//    rest of the terms with direct lookups, before loading each matching Message.
+//    A scoped search only scans the prefix of each Chatroom it may look within.
+func(db *BBoltDB)SearchMessages(query SearchQuery)( []ChatroomMessage, error ){
+  if len(query.Terms) == 0 {
+    return []ChatroomMessage{}, nil
+  }
 
+  results := []ChatroomMessage{}
   err := db.db.View(func(tx *bbolt.Tx) error {
-    bucket := tx.Bucket([]byte(USERNAMES))
-    if bucket == nil {
-      log.Printf(" -> GetUserbyUsername - Bucket \"%s\" not found.", USERNAMES)
-      return BucketNotFoundError{USERNAMES}
-    }
-    uid := bucket.Get([]byte(username))
-    if uid == nil {
-      log.Printf(" -> GetUserbyUsername - User ID \"%s\" not found.", username)
-      return GetDataError{username, USERNAMES}
+    index := tx.Bucket([]byte(SEARCHINDEX))
+    if index == nil {
+      return BucketNotFoundError{SEARCHINDEX}
     }
 
-    bucket = tx.Bucket([]byte(USERS))
-    if bucket == nil {
-      log.Printf(" -> GetUserbyUsername - Bucket \"%s\" not found.", USERS)
-      return BucketNotFoundError{USERS}
+    if !query.scoped() {
+      found, err := boltSearchPrefix(tx, index, query, searchIndexPrefix(query.Terms[0]), 0)
+      results = found
+      return err
     }
-    data := bucket.Get(uid)
-    if data == nil {
-      log.Printf(" -> GetUserbyUsername - User not found.")
-      return GetDataError{username, USERS}
+    for _, chatroom := range query.rooms() {
+      prefix := append(append(searchIndexPrefix(query.Terms[0]), chatroom...), 0)
+      found, err := boltSearchPrefix(tx, index, query, prefix, query.Limit)
+      if err != nil {
+        return err
+      }
+      results = append(results, found...)
     }
-
-    dec := codec.NewDecoderBytes(data, &JSONHandle)
-    return dec.Decode(&user)
+    return nil
   })
-
-  return user, err
+  if err != nil {
+    return nil, err
+  }
+  return limitSearchResults(query, results), nil
 }
 
-func(db *BBoltDB)SaveUsersOnlineStatus(username string, isOnline bool) error {
-  return db.db.Update(func(tx *bbolt.Tx) error {
-    bucket, err := tx.CreateBucketIfNotExists([]byte(USERSONLINE))
+func(db *BBoltDB)MarkRead(chatroom string, userID UUID, seq uint64)( uint64, error ){
+  var marker uint64
+  err := db.db.Update(func(tx *bbolt.Tx) error {
+    status, err := boltGetChatroomMemberStatus(tx, chatroom, userID)
     if err != nil {
-      log.Printf(" -> SaveUsersOnlineStatus: Failed to Create or get \"%s\" Bucket", USERSONLINE)
-      return BucketNotFoundError{USERSONLINE}
+      return err
+    }
+    if status == nil || *status == Blocked {
+      return GetDataError{chatroom + "-" + userID.String(), CHATROOMMEMBERS}
+    }
+
+    latest, err := boltLatestSeq(tx, chatroom)
+    if err != nil {
+      return err
+    }
+    if seq == 0 || seq > latest {
+      seq = latest
+    }
+    if marker, err = boltGetReadMarker(tx, chatroom, userID); err != nil {
+      return err
+    }
+    if seq <= marker {
+      return nil
+    }
+
+    markers, err := boltRoomBucket(tx, READMARKERS, chatroom, true)
+    if err != nil {
+      return err
     }
-    if err := bucket.Put([]byte(username), BoolToBytes(isOnline)); err != nil {
-      var s string
-      if isOnline{ s = "Online" } else { s = "Offline" }
-      log.Printf(
-        " -> SaveUsersOnlineStatus: Failed to update/create \"%s\"'s online status to \"%s\": %s",
-        username, s, err,
-      )
-      return PutDataError{s, USERSONLINE, err.Error()}
+    if err := markers.Put([]byte(userID.String()), itob(seq)); err != nil {
+      return PutDataError{userID.String(), READMARKERS, err.Error()}
     }
+    marker = seq
     return nil
   })
+  if err != nil {
+    return 0, err
+  }
+  return marker, nil
+}
+
+// GetJoinedChatrooms :: Walks /JoinedChatrooms/{user_id}, which bbolt already keeps
+//    sorted by Chatroom name. Unread counts come from boltUnreadCount.
+func(db *BBoltDB)GetJoinedChatrooms(userID UUID)( []JoinedChatroom, error ){
+  rooms := []JoinedChatroom{}
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    joined, err := boltRoomBucket(tx, JOINEDCHATROOMS, userID.String(), false)
+    if err != nil || joined == nil {
+      return err
+    }
+    return joined.ForEach(func(k, v []byte) error {
+      var room JoinedChatroom
+      dec := codec.NewDecoderBytes(v, &JSONHandle)
+      if err := dec.Decode(&room); err != nil {
+        return DecoderError{err.Error()}
+      }
+      chatroom, err := boltGetChatroom(tx, room.Chatroom)
+      if err != nil {
+        if _, ok := err.(GetDataError); ok {
+          // Deactivated Chatrooms are left out.
+          return nil
+        }
+        return err
+      }
+      if chatroom.IsDirect() {
+        room.PeerID = chatroom.DirectPeer(userID)
+      }
+
+      if room.LastRead, err = boltGetReadMarker(tx, room.Chatroom, userID); err != nil {
+        return err
+      }
+      if room.Unread, err = boltUnreadCount(tx, room.Chatroom, room.LastRead); err != nil {
+        return err
+      }
+      rooms = append(rooms, room)
+      return nil
+    })
+  })
+  if err != nil {
+    return nil, err
+  }
+  return rooms, nil
 }
 
-// SaveUserToken :: Used for both creating and updating a UserToken within the
-//     /UserTokens Bucket.
-func(db *BBoltDB)SaveUserToken(userID UUID, token *token.Token) error {
+func(db *BBoltDB)UpdateChatroomUserStatus(chatroom, username string, status Status) error {
   return db.db.Update(func(tx *bbolt.Tx) error {
-    b, err := tx.CreateBucketIfNotExists([]byte(USERTOKENS))
+    user, err := boltGetUserbyUsername(tx, username)
     if err != nil {
-      return BucketNotFoundError{USERTOKENS}
+      return err
     }
 
-    var data []byte
-    enc := codec.NewEncoderBytes(&data, &JSONHandle)
-    if err := enc.Encode(token); err != nil {
+    memtype, err := boltGetChatroomMemberStatus(tx, chatroom, user.UserID)
+    if err != nil {
+      return FailedSecurityCheckError{"MemberType: nil", err.Error()}
+    }
+    if *memtype == Blocked {
+      return FailedSecurityCheckError{"MemberType: blocked", "user is blocked"}
+    }
+
+    liveBucket := tx.Bucket([]byte(LIVEMEMBER))
+    if liveBucket == nil {
+      return BucketNotFoundError{LIVEMEMBER}
+    }
+
+    users := make(map[UserName]string)
+
+    if data := liveBucket.Get([]byte(chatroom)); data != nil {
+      dec := codec.NewDecoderBytes(data, &JSONHandle)
+      if err := dec.Decode(&users); err != nil {
+        return DecoderError{err.Error()}
+      }
+    }
+
+    switch status {
+    case Online:
+      users[username] = "Online"
+    case Background:
+      users[username] = "Background"
+    case Offline:
+      users[username] = "Offline"
+    case Delete:
+      // Should only be called from Owner/Moderator/member(when deleting self)
+      delete(users, username)
+    default:
+      return fmt.Errorf("Unknonw Status Request")
+    }
+
+    var bytes []byte
+    enc := codec.NewEncoderBytes(&bytes, &JSONHandle)
+    if err := enc.Encode(users); err != nil {
       return EncoderError{err.Error()}
     }
 
-    if err := b.Put([]byte(userID.String()), data); err != nil {
-      return PutDataError{userID.String(), USERTOKENS, err.Error()}
+    if err := liveBucket.Put([]byte(chatroom),bytes); err != nil {
+      return PutDataError{LIVEMEMBER, chatroom, err.Error()}
     }
+
     return nil
   })
 }
 
-func(db *BBoltDB)GetUserToken(userID UUID)( *token.Token, error ){
-  var token token.Token
+func(db *BBoltDB)GetChatroomUserStatus(chatroom string)( map[UserName]string, error ) {
+  var stats = make(map[UserName]string)
   err := db.db.View(func(tx *bbolt.Tx) error {
-    b := tx.Bucket([]byte(USERTOKENS))
-    if b == nil {
-      log.Printf(" -> GetUserToken: Bucket \"%s\" not found", USERTOKENS)
-      return BucketNotFoundError{USERTOKENS}
+    bucket := tx.Bucket([]byte(LIVEMEMBER))
+    if bucket == nil {
+      return BucketNotFoundError{LIVEMEMBER}
     }
-    data := b.Get([]byte(userID.String()))
+
+    data := bucket.Get([]byte(chatroom))
     if data == nil {
-      log.Printf(" -> GetUserToken: User Token for \"%s\" doesn't exist", userID.String())
-      return GetDataError{userID.String(), USERTOKENS}
+      return GetDataError{chatroom, LIVEMEMBER}
     }
 
     dec := codec.NewDecoderBytes(data, &JSONHandle)
-    if dec == nil {
-      return DecoderError{"Decoder is nil"}
+    if err := dec.Decode(&stats); err != nil {
+      return DecoderError{err.Error()}
     }
-    return dec.Decode(&token)
+
+    return nil
   })
   if err != nil {
     return nil, err
   }
-  return &token, nil
+
+  return stats, nil
 }
 
-func(db *BBoltDB)Close() {
-  db.db.Close()
+// SaveChatroomMember :: For Saving/updating a Chatroom's Member's List.
+func( db *BBoltDB )SaveChatroomMember(
+  chatroomName string,
+  userID UUID,
+  memberType MemberType,
+) error{
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    return boltSaveChatroomMember(tx, chatroomName, userID, memberType)
+  })
 }
 
-// ----------------------------- DB Helper Funcs -----------------------------
-func BoolToBytes(b bool) []byte {
-  if b { return []byte{1} }
-  return []byte{0}
+func(db *BBoltDB)GetChatroomMemberStatus(
+  chatroomName string,
+  userID UUID,
+)( *MemberType, error ){
+  var userStatus *MemberType = nil
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    var err error
+    userStatus, err = boltGetChatroomMemberStatus(tx, chatroomName, userID)
+    return err
+  })
+
+  return userStatus, err
 }
-func BytesToBool(b []byte) bool {
-  return b[0] == 1
+
+// SaveUser :: Takes in a User Object. Will create 5 seperate bucket entries.
+//      /Users           : For storing the User data sturct   [   userID : User            ]
+//      /Usernames       : For indexing /Users via Username   [ username : userID          ]
+//      /UsersOnline     : For storing user's online state    [ username : bool            ]
+//      /UserTokens      : For storing a user's AccessTokens  [   userID : Token           ]
+//      /JoinedChatrooms : For storing users joined chatrooms [ username : JoinedChatrooms ]
+func(db *BBoltDB)SaveUser(user User, token *token.Token) error {
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    uid := []byte(user.UserID.String())
+    username := []byte(user.Username)
+
+    // /Users -> Holds Entire User Object [ userID : User ]
+    bucket := tx.Bucket([]byte(USERS))
+    if bucket == nil {
+      log.Printf(" -> Error: SaveUser - Failed to get %s Bucket", USERS)
+      return BucketNotFoundError{USERS}
+    }
+
+    var out []byte
+    enc := codec.NewEncoderBytes(&out, &JSONHandle)
+    if err := enc.Encode(user); err != nil {
+      log.Printf(" -> Error: SaveUser - Failed to Encode new User: %s", err)
+      return EncoderError{err.Error()}
+    }
+
+    if err := bucket.Put(uid, out); err != nil {
+      log.Printf(" -> Error: Failed to Create new user in Database")
+      return PutDataError{user.UserID.String(), USERS, err.Error()}
+    }
+
+    // /Usernames -> Holds Entire Users for indexing Users
+    //               via Username [ username : userID ]
+    bucket = tx.Bucket([]byte(USERNAMES))
+    if bucket == nil {
+      log.Printf(" -> Error: Failed to get %s Bucket", USERNAMES)
+      return BucketNotFoundError{USERNAMES}
+    }
+
+    // Deactivated Users keep their Username, so it's never handed to anyone else.
+    if taken := bucket.Get(username); taken != nil && string(taken) != string(uid) {
+      log.Printf(" -> Error: The Username \"%s\" is aldready taken", username)
+      return PutDataError{user.Username, USERNAMES, "username already taken"}
+    }
+    if err := bucket.Put(username, uid); err != nil {
+      log.Printf(" -> Error: The Username \"%s\" is aldready taken: %s", username, err)
+      return PutDataError{user.Username, USERNAMES, err.Error()}
+    }
+
+    // /UsersOnline -> Used for storing a user's online status [ username : bool ]
+    // Users are only Online while connected to a Chatroom.
+    if online := tx.Bucket([]byte(USERSONLINE)); online == nil || online.Get(username) == nil {
+      if err := boltSaveUsersOnlineStatus(tx, user.Username, false); err != nil {
+        return err
+      }
+    }
+
+    if token != nil {
+      if err := boltSaveUserToken(tx, user.UserID, token); err != nil {
+        return err
+      }
+    }
+    return nil
+  })
+}
+
+func(db *BBoltDB)GetUserByID(id UUID)( *User,error ){
+  var user *User = nil
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    var err error
+    user, err = boltGetUserByID(tx, id)
+    return err
+  })
+
+  return user, err
+}
+
+// ActivateUser :: Moves the User from /DeactivatedUsers back into /Users. Their
+//    Username was kept reserved within /Usernames the whole time.
+func(db *BBoltDB)ActivateUser(userID UUID) error {
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    uid := []byte(userID.String())
+    deactivatedBucket := tx.Bucket([]byte(DEACTIVATEDUSERS))
+    if deactivatedBucket == nil {
+      return BucketNotFoundError{DEACTIVATEDUSERS}
+    }
+    data := deactivatedBucket.Get(uid)
+    if data == nil {
+      return GetDataError{userID.String(), DEACTIVATEDUSERS}
+    }
+
+    var user User
+    dec := codec.NewDecoderBytes(data, &JSONHandle)
+    if err := dec.Decode(&user); err != nil {
+      log.Printf(" -> ActivateUser: Failed to Decode User")
+      return DecoderError{err.Error()}
+    }
+
+    usersBucket := tx.Bucket([]byte(USERS))
+    if usersBucket == nil {
+      return BucketNotFoundError{USERS}
+    }
+    usernameBucket := tx.Bucket([]byte(USERNAMES))
+    if usernameBucket == nil {
+      return BucketNotFoundError{USERNAMES}
+    }
+
+    if err := usersBucket.Put(uid, data); err != nil {
+      log.Printf(" -> ActivateUser: Failed to Move User to /%s Bucket.", USERS)
+      return PutDataError{userID.String(), USERS, err.Error()}
+    }
+    if err := usernameBucket.Put([]byte(user.Username), uid); err != nil {
+      log.Printf(" -> ActivateUser: Failed to add User to /%s Bucket.", USERNAMES)
+      return PutDataError{user.Username, USERNAMES, err.Error()}
+    }
+    if err := deactivatedBucket.Delete(uid); err != nil {
+      log.Printf(" -> ActivateUser: Failed to remove User from /%s Bucket.", DEACTIVATEDUSERS)
+      return DeleteDataError{userID.String(), DEACTIVATEDUSERS, err.Error()}
+    }
+    return nil
+  })
+}
+
+// DeactivateUser :: Moves the User from /Users into /DeactivatedUsers, and revokes
+//    their Access Token. Nothing else is freed. Their Username stays reserved within
+//    /Usernames, and their Messages and memberships are left where they are.
+func(db *BBoltDB)DeactivateUser(userID UUID) error {
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    uid := []byte(userID.String())
+
+    // Get User from /Users
+    usersBucket := tx.Bucket([]byte(USERS))
+    if usersBucket == nil {
+      return BucketNotFoundError{USERS}
+    }
+    data := usersBucket.Get(uid)
+    if data == nil {
+      return GetDataError{userID.String(), USERS}
+    }
+
+    var user User
+    dec := codec.NewDecoderBytes(data, &JSONHandle)
+    if err := dec.Decode(&user); err != nil {
+      log.Printf(" -> DeactivateUser: Failed to Decode User")
+      return DecoderError{err.Error()}
+    }
+
+    deactivatedBucket := tx.Bucket([]byte(DEACTIVATEDUSERS))
+    if deactivatedBucket == nil {
+      log.Printf(" -> DeactivateUser: Failed to get \"%s\" Bucket.", DEACTIVATEDUSERS)
+      return BucketNotFoundError{DEACTIVATEDUSERS}
+    }
+    if err := deactivatedBucket.Put(uid, data); err != nil {
+      log.Printf(" -> DeactivateUser: Failed to Move User to /%s Bucket.", DEACTIVATEDUSERS)
+      return PutDataError{userID.String(), DEACTIVATEDUSERS, err.Error()}
+    }
+    if err := usersBucket.Delete(uid); err != nil {
+      log.Printf(" -> DeactivateUser: Failed to remove User \"%s\" from /%s bucket", userID.String(), USERS)
+      return DeleteDataError{userID.String(), USERS, err.Error()}
+    }
+
+    tokens := tx.Bucket([]byte(USERTOKENS))
+    if tokens == nil {
+      return BucketNotFoundError{USERTOKENS}
+    }
+    if err := tokens.Delete(uid); err != nil {
+      return DeleteDataError{userID.String(), USERTOKENS, err.Error()}
+    }
+    return boltSaveUsersOnlineStatus(tx, user.Username, false)
+  })
+}
+
+func(db *BBoltDB)GetDeactivatedUserByID(id UUID)( *User, error ){
+  var user User
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    deactivated := tx.Bucket([]byte(DEACTIVATEDUSERS))
+    if deactivated == nil {
+      return BucketNotFoundError{DEACTIVATEDUSERS}
+    }
+    data := deactivated.Get([]byte(id.String()))
+    if data == nil {
+      return GetDataError{id.String(), DEACTIVATEDUSERS}
+    }
+    dec := codec.NewDecoderBytes(data, &JSONHandle)
+    if err := dec.Decode(&user); err != nil {
+      return DecoderError{err.Error()}
+    }
+    return nil
+  })
+  if err != nil {
+    return nil, err
+  }
+  return &user, nil
+}
+
+// GetDeactivatedUser :: /Usernames still points at a deactivated User, which is
+//    then found within /DeactivatedUsers rather than /Users.
+func(db *BBoltDB)GetDeactivatedUser(username string)( *User, error ){
+  var user User
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    usernames := tx.Bucket([]byte(USERNAMES))
+    if usernames == nil {
+      return BucketNotFoundError{USERNAMES}
+    }
+    uid := usernames.Get([]byte(username))
+    if uid == nil {
+      return GetDataError{username, USERNAMES}
+    }
+    deactivated := tx.Bucket([]byte(DEACTIVATEDUSERS))
+    if deactivated == nil {
+      return BucketNotFoundError{DEACTIVATEDUSERS}
+    }
+    data := deactivated.Get(uid)
+    if data == nil {
+      return GetDataError{username, DEACTIVATEDUSERS}
+    }
+    dec := codec.NewDecoderBytes(data, &JSONHandle)
+    if err := dec.Decode(&user); err != nil {
+      return DecoderError{err.Error()}
+    }
+    return nil
+  })
+  if err != nil {
+    return nil, err
+  }
+  return &user, nil
+}
+
+func(db *BBoltDB)GetUserbyUsername(username string)( *User, error ){
+  var user *User = nil
+
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    var err error
+    user, err = boltGetUserbyUsername(tx, username)
+    return err
+  })
+
+  return user, err
+}
+
+func(db *BBoltDB)SaveUsersOnlineStatus(username string, isOnline bool) error {
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    return boltSaveUsersOnlineStatus(tx, username, isOnline)
+  })
+}
+
+// SaveUserToken :: Used for both creating and updating a UserToken within the
+//     /UserTokens Bucket.
+func(db *BBoltDB)SaveUserToken(userID UUID, token *token.Token) error {
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    return boltSaveUserToken(tx, userID, token)
+  })
+}
+
+func(db *BBoltDB)GetUserToken(userID UUID)( *token.Token, error ){
+  var token token.Token
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    b := tx.Bucket([]byte(USERTOKENS))
+    if b == nil {
+      log.Printf(" -> GetUserToken: Bucket \"%s\" not found", USERTOKENS)
+      return BucketNotFoundError{USERTOKENS}
+    }
+    data := b.Get([]byte(userID.String()))
+    if data == nil {
+      log.Printf(" -> GetUserToken: User Token for \"%s\" doesn't exist", userID.String())
+      return GetDataError{userID.String(), USERTOKENS}
+    }
+
+    dec := codec.NewDecoderBytes(data, &JSONHandle)
+    if dec == nil {
+      return DecoderError{"Decoder is nil"}
+    }
+    return dec.Decode(&token)
+  })
+  if err != nil {
+    return nil, err
+  }
+  return &token, nil
+}
+
+func(db *BBoltDB)GetProfile(userID UUID)( *Profile, error ){
+  profile := Profile{ UserID: userID }
+  err := db.db.View(func(tx *bbolt.Tx) error {
+    user, err := boltGetUserByID(tx, userID)
+    if err != nil {
+      return err
+    }
+    bucket := tx.Bucket([]byte(PROFILES))
+    if bucket == nil {
+      return BucketNotFoundError{PROFILES}
+    }
+    if data := bucket.Get([]byte(userID.String())); data != nil {
+      dec := codec.NewDecoderBytes(data, &JSONHandle)
+      if err := dec.Decode(&profile); err != nil {
+        return DecoderError{err.Error()}
+      }
+    }
+    profile.Username = user.Username
+    return nil
+  })
+  if err != nil {
+    return nil, err
+  }
+  return &profile, nil
+}
+
+func(db *BBoltDB)SaveProfile(profile *Profile) error {
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    if _, err := boltGetUserByID(tx, profile.UserID); err != nil {
+      return err
+    }
+    bucket := tx.Bucket([]byte(PROFILES))
+    if bucket == nil {
+      return BucketNotFoundError{PROFILES}
+    }
+    stored := *profile
+    stored.Username = ""
+    var data []byte
+    enc := codec.NewEncoderBytes(&data, &JSONHandle)
+    if err := enc.Encode(stored); err != nil {
+      return EncoderError{err.Error()}
+    }
+    if err := bucket.Put([]byte(profile.UserID.String()), data); err != nil {
+      return PutDataError{profile.UserID.String(), PROFILES, err.Error()}
+    }
+    return nil
+  })
+}
+
+func(db *BBoltDB)UpdateUserPassword(userID UUID, hashedPassword []byte, token *token.Token) error {
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    uid := []byte(userID.String())
+    bucket := tx.Bucket([]byte(USERS))
+    if bucket == nil {
+      return BucketNotFoundError{USERS}
+    }
+    data := bucket.Get(uid)
+    if data == nil {
+      return GetDataError{userID.String(), USERS}
+    }
+    var user User
+    dec := codec.NewDecoderBytes(data, &JSONHandle)
+    if err := dec.Decode(&user); err != nil {
+      log.Printf(" -> UpdateUserPassword: Failed to Decode User")
+      return DecoderError{err.Error()}
+    }
+
+    user.HashedPassword = hashedPassword
+    var out []byte
+    enc := codec.NewEncoderBytes(&out, &JSONHandle)
+    if err := enc.Encode(user); err != nil {
+      return EncoderError{err.Error()}
+    }
+    if err := bucket.Put(uid, out); err != nil {
+      return PutDataError{userID.String(), USERS, err.Error()}
+    }
+
+    if token != nil {
+      if err := boltSaveUserToken(tx, userID, token); err != nil {
+        return err
+      }
+    } else {
+      tokens := tx.Bucket([]byte(USERTOKENS))
+      if tokens == nil {
+        return BucketNotFoundError{USERTOKENS}
+      }
+      if err := tokens.Delete(uid); err != nil {
+        return DeleteDataError{userID.String(), USERTOKENS, err.Error()}
+      }
+    }
+    return boltRemovePasswordResets(tx, userID)
+  })
+}
+
+func(db *BBoltDB)SavePasswordReset(reset *PasswordReset) error {
+  return db.db.Update(func(tx *bbolt.Tx) error {
+    if err := boltRemovePasswordResets(tx, reset.UserID); err != nil {
+      return err
+    }
+    bucket := tx.Bucket([]byte(PASSWORDRESETS))
+    var data []byte
+    enc := codec.NewEncoderBytes(&data, &JSONHandle)
+    if err := enc.Encode(reset); err != nil {
+      return EncoderError{err.Error()}
+    }
+    if err := bucket.Put([]byte(reset.TokenHash), data); err != nil {
+      return PutDataError{reset.UserID.String(), PASSWORDRESETS, err.Error()}
+    }
+    return nil
+  })
+}
+
+func(db *BBoltDB)ConsumePasswordReset(resetToken string)( *PasswordReset, error ){
+  var reset PasswordReset
+  hash := []byte(HashResetToken(resetToken))
+  err := db.db.Update(func(tx *bbolt.Tx) error {
+    bucket := tx.Bucket([]byte(PASSWORDRESETS))
+    if bucket == nil {
+      return BucketNotFoundError{PASSWORDRESETS}
+    }
+    data := bucket.Get(hash)
+    if data == nil {
+      return GetDataError{"reset token", PASSWORDRESETS}
+    }
+    dec := codec.NewDecoderBytes(data, &JSONHandle)
+    if err := dec.Decode(&reset); err != nil {
+      return DecoderError{err.Error()}
+    }
+    if err := bucket.Delete(hash); err != nil {
+      return DeleteDataError{reset.UserID.String(), PASSWORDRESETS, err.Error()}
+    }
+    return nil
+  })
+  if err != nil {
+    return nil, err
+  }
+  if err := checkPasswordReset(&reset); err != nil {
+    return nil, err
+  }
+  return &reset, nil
+}
+
+func(db *BBoltDB)Close() {
+  db.db.Close()
+}
+
+// ----------------------------- DB Helper Funcs -----------------------------
+// The bolt* helpers work within an already open transaction. bbolt only allows
+// a single write transaction at a time, so calling db.db.Update from within
+// another Update would deadlock. Compose these instead.
+
+func boltGetChatroom(tx *bbolt.Tx, name string)( *Chatroom, error ){
+  b := tx.Bucket([]byte(CHATROOMS))
+  if b == nil {
+    return nil, BucketNotFoundError{CHATROOMS}
+  }
+  data := b.Get([]byte(name))
+  if data == nil {
+    return nil, GetDataError{name, CHATROOMS}
+  }
+
+  var chatroom Chatroom
+  dec := codec.NewDecoderBytes(data, &JSONHandle)
+  if err := dec.Decode(&chatroom); err != nil {
+    return nil, DecoderError{err.Error()}
+  }
+  return &chatroom, nil
+}
+
+// boltGetChatroomMembers :: Walks every /ChatroomMembers/{chatroom}-{user_id} key.
+func boltGetChatroomMembers(tx *bbolt.Tx, chatroomName string)( map[UUID]MemberType, error ){
+  bucket := tx.Bucket([]byte(CHATROOMMEMBERS))
+  if bucket == nil {
+    return nil, BucketNotFoundError{CHATROOMMEMBERS}
+  }
+
+  members := make(map[UUID]MemberType)
+  prefix := []byte(chatroomName + "-")
+  c := bucket.Cursor()
+
+  for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+    // Here, we remove prefix for 'k', thus leaving us with the 'UserID'
+    keyStr := string(k[len(prefix):])
+    userID, err := uuid.Parse(keyStr)
+    if err != nil {
+      log.Printf(" -> GetChatroomMembers: Failed to parse UserID")
+      return nil, err
+    }
+    memberType := MemberType(v[0])
+    members[userID] = memberType
+  }
+  return members, nil
+}
+
+// boltPutChatroom :: Stores, or replaces, /Chatrooms/{room_name}.
+func boltPutChatroom(tx *bbolt.Tx, chatroom *Chatroom) error {
+  var data []byte
+  enc := codec.NewEncoderBytes(&data, &JSONHandle)
+  if err := enc.Encode(chatroom); err != nil {
+    return EncoderError{err.Error()}
+  }
+  if err := tx.Bucket([]byte(CHATROOMS)).Put([]byte(chatroom.RoomName), data); err != nil {
+    return PutDataError{chatroom.RoomName, CHATROOMS, err.Error()}
+  }
+  return nil
+}
+
+// boltRoomMessages :: Returns the nested /Messages/{chatroom} Bucket. If create is
+//    false and the Chatroom has yet to receive a Message, the Bucket will be nil.
+func boltRoomMessages(tx *bbolt.Tx, chatroom string, create bool)( *bbolt.Bucket, error ){
+  return boltRoomBucket(tx, MESSAGES, chatroom, create)
+}
+
+// boltRoomBucket :: Returns the nested /{bucket}/{chatroom} Bucket. If create is
+//    false and the nested Bucket doesn't exist yet, it will be nil.
+func boltRoomBucket(tx *bbolt.Tx, bucket, chatroom string, create bool)( *bbolt.Bucket, error ){
+  parent := tx.Bucket([]byte(bucket))
+  if parent == nil {
+    return nil, BucketNotFoundError{bucket}
+  }
+  if !create {
+    return parent.Bucket([]byte(chatroom)), nil
+  }
+  room, err := parent.CreateBucketIfNotExists([]byte(chatroom))
+  if err != nil {
+    log.Printf(" -> boltRoomBucket: Failed to create /%s/%s Bucket: %s", bucket, chatroom, err)
+    return nil, BucketNotFoundError{bucket + "/" + chatroom}
+  }
+  return room, nil
+}
+
+// boltPutMessage :: Stores message under /Messages/{chatroom}/{seq}, where seq is
+//    the next value of the Chatroom bucket's sequence. Sets message.Seq.
+func boltPutMessage(tx *bbolt.Tx, chatroom string, message *Message) error {
+  message.ThreadID = UUID{}
+  if message.IsReply() {
+    parent, err := boltGetMessage(tx, chatroom, message.ReplyTo)
+    if err != nil {
+      return err
+    }
+    if err := message.joinThread(parent); err != nil {
+      return err
+    }
+  }
+
+  room, err := boltRoomMessages(tx, chatroom, true)
+  if err != nil {
+    return err
+  }
+  seq, err := room.NextSequence()
+  if err != nil {
+    return PutDataError{chatroom, MESSAGES, err.Error()}
+  }
+  message.Seq = seq
+
+  if err := boltStoreMessage(tx, chatroom, message); err != nil {
+    return err
+  }
+  ids, err := boltRoomBucket(tx, MESSAGEIDS, chatroom, true)
+  if err != nil {
+    return err
+  }
+  if err := ids.Put([]byte(message.ID.String()), itob(seq)); err != nil {
+    return PutDataError{message.ID.String(), MESSAGEIDS, err.Error()}
+  }
+  if message.ThreadID != (UUID{}) {
+    thread, err := boltThread(tx, chatroom, message.ThreadID, true)
+    if err != nil {
+      return err
+    }
+    if err := thread.Put(itob(seq), []byte{}); err != nil {
+      return PutDataError{message.ThreadID.String(), THREADS, err.Error()}
+    }
+  }
+  return boltIndexMessage(tx, chatroom, message)
+}
+
+// boltUnreadCount :: Seqs are handed out one after another, and only ever removed
+//    oldest first by PurgeMessages, so every seq between the oldest Message left and
+//    the bucket's sequence is still there. Counting the Messages after lastRead then
+//    only takes walking the tombstones after it, within /DeletedMessages/{chatroom}.
+func boltUnreadCount(tx *bbolt.Tx, chatroom string, lastRead uint64)( uint64, error ){
+  messages, err := boltRoomMessages(tx, chatroom, false)
+  if err != nil || messages == nil {
+    return 0, err
+  }
+  oldest, _ := messages.Cursor().First()
+  if oldest == nil {
+    return 0, nil
+  }
+  if first := btoi(oldest); lastRead < first {
+    lastRead = first - 1
+  }
+  latest := messages.Sequence()
+  if latest <= lastRead {
+    return 0, nil
+  }
+  unread := latest - lastRead
+
+  deleted, err := boltRoomBucket(tx, DELETEDMESSAGES, chatroom, false)
+  if err != nil || deleted == nil {
+    return unread, err
+  }
+  c := deleted.Cursor()
+  for k, _ := c.Seek(itob(lastRead+1)); k != nil; k, _ = c.Next() {
+    unread--
+  }
+  return unread, nil
+}
+
+// boltThread :: Returns /Threads/{chatroom}/{thread_id}, which holds the seq of
+//    every reply within the thread. Nil if create is false and there are no replies.
+func boltThread(tx *bbolt.Tx, chatroom string, threadID UUID, create bool)( *bbolt.Bucket, error ){
+  room, err := boltRoomBucket(tx, THREADS, chatroom, create)
+  if err != nil || room == nil {
+    return nil, err
+  }
+  if !create {
+    return room.Bucket([]byte(threadID.String())), nil
+  }
+  thread, err := room.CreateBucketIfNotExists([]byte(threadID.String()))
+  if err != nil {
+    return nil, BucketNotFoundError{THREADS + "/" + chatroom + "/" + threadID.String()}
+  }
+  return thread, nil
+}
+
+// boltPaginate :: Walks c, a cursor over big-endian seq keys, the same way for
+//    both Paginate and PaginateThread. load turns a key/value pair into it's Message.
+func boltPaginate(
+  c *bbolt.Cursor,
+  before, after uint64,
+  limit int,
+  load func(k, v []byte)( *Message, error ),
+)( []Message, bool, bool, error ){
+  var msgs []Message
+  appendMessage := func(k, v []byte) error {
+    message, err := load(k, v)
+    if err != nil {
+      return err
+    }
+    msgs = append(msgs, *message)
+    return nil
+  }
+
+  if after > 0 && before == 0 {
+    for k, v := c.Seek(itob(after+1)); k != nil && len(msgs) < limit; k, v = c.Next() {
+      if err := appendMessage(k, v); err != nil {
+        return nil, false, false, err
+      }
+    }
+  } else {
+    var k, v []byte
+    if before > 0 {
+      if k, _ = c.Seek(itob(before)); k == nil {
+        k, v = c.Last()
+      } else {
+        k, v = c.Prev()
+      }
+    } else {
+      k, v = c.Last()
+    }
+    for ; k != nil && btoi(k) > after && len(msgs) < limit; k, v = c.Prev() {
+      if err := appendMessage(k, v); err != nil {
+        return nil, false, false, err
+      }
+    }
+    for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+      msgs[i], msgs[j] = msgs[j], msgs[i]
+    }
+  }
+
+  var hasOlder, hasNewer bool
+  if len(msgs) > 0 {
+    c.Seek(itob(msgs[0].Seq))
+    k, _ := c.Prev()
+    hasOlder = k != nil
+    k, _ = c.Seek(itob(msgs[len(msgs)-1].Seq + 1))
+    hasNewer = k != nil
+  }
+  return msgs, hasOlder, hasNewer, nil
+}
+
+// boltStoreMessage :: (Over)writes message at /Messages/{chatroom}/{message.Seq}.
+func boltStoreMessage(tx *bbolt.Tx, chatroom string, message *Message) error {
+  room, err := boltRoomMessages(tx, chatroom, true)
+  if err != nil {
+    return err
+  }
+  var data []byte
+  enc := codec.NewEncoderBytes(&data, &JSONHandle)
+  if err := enc.Encode(message); err != nil {
+    return EncoderError{err.Error()}
+  }
+  if err := room.Put(itob(message.Seq), data); err != nil {
+    return PutDataError{fmt.Sprintf("%s/%d", chatroom, message.Seq), MESSAGES, err.Error()}
+  }
+  return nil
+}
+
+// boltGetMessage :: Looks up the Message's seq within /MessageIDs/{chatroom}, and
+//    then the Message itself.
+func boltGetMessage(tx *bbolt.Tx, chatroom string, messageID UUID)( *Message, error ){
+  ids, err := boltRoomBucket(tx, MESSAGEIDS, chatroom, false)
+  if err != nil {
+    return nil, err
+  }
+  if ids == nil {
+    return nil, GetDataError{messageID.String(), MESSAGEIDS}
+  }
+  seq := ids.Get([]byte(messageID.String()))
+  if seq == nil {
+    return nil, GetDataError{messageID.String(), MESSAGEIDS}
+  }
+
+  room, err := boltRoomMessages(tx, chatroom, false)
+  if err != nil {
+    return nil, err
+  }
+  if room == nil {
+    return nil, GetDataError{chatroom, MESSAGES}
+  }
+  data := room.Get(seq)
+  if data == nil {
+    return nil, GetDataError{messageID.String(), MESSAGES}
+  }
+  var message Message
+  dec := codec.NewDecoderBytes(data, &JSONHandle)
+  if err := dec.Decode(&message); err != nil {
+    return nil, DecoderError{err.Error()}
+  }
+  return &message, nil
+}
+
+// boltPurgeMessage :: Deletes /Messages/{chatroom}/{seq}, along with every trace of
+//    it within /MessageIDs, /Threads, /MessageRevisions and /SearchIndex. Unlike a
+//    deleted Message, no tombstone is left behind.
+func boltPurgeMessage(tx *bbolt.Tx, chatroom string, seq uint64) error {
+  room, err := boltRoomMessages(tx, chatroom, false)
+  if err != nil || room == nil {
+    return err
+  }
+  data := room.Get(itob(seq))
+  if data == nil {
+    return nil
+  }
+  var message Message
+  dec := codec.NewDecoderBytes(data, &JSONHandle)
+  if err := dec.Decode(&message); err != nil {
+    return DecoderError{err.Error()}
+  }
+
+  if err := boltUnindexMessage(tx, chatroom, &message); err != nil {
+    return err
+  }
+  for _, bucket := range []string{ MESSAGEIDS, MESSAGEREVISIONS } {
+    b, err := boltRoomBucket(tx, bucket, chatroom, false)
+    if err != nil {
+      return err
+    }
+    if b == nil {
+      continue
+    }
+    if err := b.Delete([]byte(message.ID.String())); err != nil {
+      return DeleteDataError{message.ID.String(), bucket, err.Error()}
+    }
+  }
+  if message.ThreadID != (UUID{}) {
+    thread, err := boltThread(tx, chatroom, message.ThreadID, false)
+    if err != nil {
+      return err
+    }
+    if thread != nil {
+      if err := thread.Delete(itob(seq)); err != nil {
+        return DeleteDataError{message.ThreadID.String(), THREADS, err.Error()}
+      }
+    }
+  } else {
+    // Any Message without a ThreadID may be a thread's root. It's replies are newer,
+    //    and purged after it, but the thread itself would be left behind.
+    threads, err := boltRoomBucket(tx, THREADS, chatroom, false)
+    if err != nil {
+      return err
+    }
+    if threads != nil && threads.Bucket([]byte(message.ID.String())) != nil {
+      if err := threads.DeleteBucket([]byte(message.ID.String())); err != nil {
+        return DeleteDataError{message.ID.String(), THREADS, err.Error()}
+      }
+    }
+  }
+  if message.IsDeleted() {
+    deleted, err := boltRoomBucket(tx, DELETEDMESSAGES, chatroom, false)
+    if err != nil {
+      return err
+    }
+    if deleted != nil {
+      if err := deleted.Delete(itob(seq)); err != nil {
+        return DeleteDataError{message.ID.String(), DELETEDMESSAGES, err.Error()}
+      }
+    }
+  }
+  if err := room.Delete(itob(seq)); err != nil {
+    return DeleteDataError{fmt.Sprintf("%s/%d", chatroom, seq), MESSAGES, err.Error()}
+  }
+  return nil
+}
+
+// boltGetMessageRevisions :: /MessageRevisions/{chatroom}/{message_id} holds every
+//    MessageRevision of a Message, encoded as a single list.
+func boltGetMessageRevisions(tx *bbolt.Tx, chatroom string, messageID UUID)( []MessageRevision, error ){
+  revisions := []MessageRevision{}
+  b, err := boltRoomBucket(tx, MESSAGEREVISIONS, chatroom, false)
+  if err != nil || b == nil {
+    return revisions, err
+  }
+  data := b.Get([]byte(messageID.String()))
+  if data == nil {
+    return revisions, nil
+  }
+  dec := codec.NewDecoderBytes(data, &JSONHandle)
+  if err := dec.Decode(&revisions); err != nil {
+    return nil, DecoderError{err.Error()}
+  }
+  return revisions, nil
+}
+
+// searchIndexPrefix :: The part of every /SearchIndex key shared by a term's hits.
+func searchIndexPrefix(term string) []byte {
+  return append([]byte(term), 0)
+}
+
+// boltSearchPrefix :: Walks the /SearchIndex keys under prefix backwards, so a
+//    Chatroom's newest Messages come first, and stops after limit matches. A limit
+//    of 0 walks every key.
+func boltSearchPrefix(tx *bbolt.Tx, index *bbolt.Bucket, query SearchQuery, prefix []byte, limit int)( []ChatroomMessage, error ){
+  // prefix always ends with a 0 separator. Swapping it for a 1 gives the first key
+  //    past every key under prefix.
+  past := append(append([]byte{}, prefix[:len(prefix)-1]...), 1)
+
+  found := []ChatroomMessage{}
+  c := index.Cursor()
+  k, _ := c.Seek(past)
+  if k == nil {
+    k, _ = c.Last()
+  } else {
+    k, _ = c.Prev()
+  }
+  for ; k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Prev() {
+    rest := k[len(query.Terms[0])+1:]
+    sep := bytes.IndexByte(rest, 0)
+    if sep < 0 || len(rest) != sep+9 {
+      continue
+    }
+    chatroom := string(rest[:sep])
+    seq := btoi(rest[sep+1:])
+    if !query.allows(chatroom) {
+      continue
+    }
+
+    matched := true
+    for _, term := range query.Terms[1:] {
+      if index.Get(searchIndexKey(term, chatroom, seq)) == nil {
+        matched = false
+        break
+      }
+    }
+    if !matched {
+      continue
+    }
+
+    room, err := boltRoomMessages(tx, chatroom, false)
+    if err != nil {
+      return nil, err
+    }
+    if room == nil {
+      continue
+    }
+    data := room.Get(itob(seq))
+    if data == nil {
+      continue
+    }
+    var message Message
+    dec := codec.NewDecoderBytes(data, &JSONHandle)
+    if err := dec.Decode(&message); err != nil {
+      return nil, DecoderError{err.Error()}
+    }
+    if !query.matches(&message) {
+      continue
+    }
+    found = append(found, ChatroomMessage{ Chatroom: chatroom, Message: message })
+    if limit > 0 && len(found) == limit {
+      break
+    }
+  }
+  return found, nil
+}
+
+// searchIndexKey :: /SearchIndex/{term \x00 chatroom \x00 seq}. Sorting by term first
+//    lets a single prefix scan find every Message containing a term.
+func searchIndexKey(term, chatroom string, seq uint64) []byte {
+  key := make([]byte, 0, len(term)+len(chatroom)+10)
+  key = append(key, term...)
+  key = append(key, 0)
+  key = append(key, chatroom...)
+  key = append(key, 0)
+  return append(key, itob(seq)...)
+}
+
+// boltUnindexMessage :: Removes every term within message.Content from /SearchIndex.
+func boltUnindexMessage(tx *bbolt.Tx, chatroom string, message *Message) error {
+  index := tx.Bucket([]byte(SEARCHINDEX))
+  if index == nil {
+    return BucketNotFoundError{SEARCHINDEX}
+  }
+  for _, term := range Tokenize(message.Content) {
+    if err := index.Delete(searchIndexKey(term, chatroom, message.Seq)); err != nil {
+      return DeleteDataError{term, SEARCHINDEX, err.Error()}
+    }
+  }
+  return nil
+}
+
+// boltIndexMessage :: Adds every term within message.Content to /SearchIndex.
+func boltIndexMessage(tx *bbolt.Tx, chatroom string, message *Message) error {
+  index := tx.Bucket([]byte(SEARCHINDEX))
+  if index == nil {
+    return BucketNotFoundError{SEARCHINDEX}
+  }
+  for _, term := range Tokenize(message.Content) {
+    if err := index.Put(searchIndexKey(term, chatroom, message.Seq), []byte{}); err != nil {
+      return PutDataError{term, SEARCHINDEX, err.Error()}
+    }
+  }
+  return nil
+}
+
+// boltGetInvitation :: Returns nil, without an error, if userID was never invited.
+func boltGetInvitation(tx *bbolt.Tx, roomID, userID UUID)( *Invitation, error ){
+  bucket, err := boltRoomBucket(tx, INVITATIONS, userID.String(), false)
+  if err != nil || bucket == nil {
+    return nil, err
+  }
+  data := bucket.Get([]byte(roomID.String()))
+  if data == nil {
+    return nil, nil
+  }
+  var invitation Invitation
+  dec := codec.NewDecoderBytes(data, &JSONHandle)
+  if err := dec.Decode(&invitation); err != nil {
+    return nil, DecoderError{err.Error()}
+  }
+  return &invitation, nil
+}
+
+func boltRemoveInvitation(tx *bbolt.Tx, roomID, userID UUID) error {
+  bucket, err := boltRoomBucket(tx, INVITATIONS, userID.String(), false)
+  if err != nil || bucket == nil {
+    return err
+  }
+  if err := bucket.Delete([]byte(roomID.String())); err != nil {
+    log.Printf(" -> RemoveInvitation: Failed to remove /%s/%s/%s", INVITATIONS, userID, roomID)
+    return DeleteDataError{inviteKey(&roomID, &userID), INVITATIONS, err.Error()}
+  }
+  return nil
+}
+
+func boltGetInviteLink(tx *bbolt.Tx, code string)( *InviteLink, error ){
+  bucket := tx.Bucket([]byte(INVITELINKS))
+  if bucket == nil {
+    return nil, BucketNotFoundError{INVITELINKS}
+  }
+  data := bucket.Get([]byte(code))
+  if data == nil {
+    return nil, GetDataError{code, INVITELINKS}
+  }
+  var link InviteLink
+  dec := codec.NewDecoderBytes(data, &JSONHandle)
+  if err := dec.Decode(&link); err != nil {
+    return nil, DecoderError{err.Error()}
+  }
+  return &link, nil
+}
+
+func boltPutInviteLink(tx *bbolt.Tx, link *InviteLink) error {
+  bucket := tx.Bucket([]byte(INVITELINKS))
+  if bucket == nil {
+    return BucketNotFoundError{INVITELINKS}
+  }
+  var data []byte
+  enc := codec.NewEncoderBytes(&data, &JSONHandle)
+  if err := enc.Encode(link); err != nil {
+    return EncoderError{err.Error()}
+  }
+  if err := bucket.Put([]byte(link.Code), data); err != nil {
+    return PutDataError{link.Code, INVITELINKS, err.Error()}
+  }
+  return nil
+}
+
+// boltRemovePasswordResets :: Removes every PasswordReset for userID, along with any
+//    that have expired while nobody was looking. /PasswordResets is keyed by hash,
+//    so it's walked in full, but it only ever holds a handful of entries.
+func boltRemovePasswordResets(tx *bbolt.Tx, userID UUID) error {
+  bucket := tx.Bucket([]byte(PASSWORDRESETS))
+  if bucket == nil {
+    return BucketNotFoundError{PASSWORDRESETS}
+  }
+  now := time.Now()
+  var stale [][]byte
+  err := bucket.ForEach(func(k, v []byte) error {
+    var reset PasswordReset
+    dec := codec.NewDecoderBytes(v, &JSONHandle)
+    if err := dec.Decode(&reset); err != nil {
+      return DecoderError{err.Error()}
+    }
+    if reset.UserID == userID || reset.Expired(now) {
+      stale = append(stale, append([]byte{}, k...))
+    }
+    return nil
+  })
+  if err != nil {
+    return err
+  }
+  for _, k := range stale {
+    if err := bucket.Delete(k); err != nil {
+      return DeleteDataError{userID.String(), PASSWORDRESETS, err.Error()}
+    }
+  }
+  return nil
+}
+
+// boltDoesChatroomExist :: A Chatroom name stays taken after it's been deactivated.
+func boltDoesChatroomExist(tx *bbolt.Tx, chatroom string)( bool,error ){
+  active := tx.Bucket([]byte(CHATROOMS))
+  if active == nil {
+    log.Printf(" -> DoesChatroomExist: Failed to retreive Bucket \"%s\"", CHATROOMS)
+    return false, BucketNotFoundError{CHATROOMS}
+  }
+  inactive := tx.Bucket([]byte(INACTIVECHATROOMS))
+  if inactive == nil {
+    log.Printf(" -> DoesChatroomExist: Failed to retreive Bucket \"%s\"", INACTIVECHATROOMS)
+    return false, BucketNotFoundError{INACTIVECHATROOMS}
+  }
+  return active.Get([]byte(chatroom)) != nil || inactive.Get([]byte(chatroom)) != nil, nil
+}
+
+func boltSaveChatroomMember(
+  tx *bbolt.Tx,
+  chatroomName string,
+  userID UUID,
+  memberType MemberType,
+) error {
+  bucket := tx.Bucket([]byte(CHATROOMMEMBERS))
+  if bucket == nil {
+    log.Printf(" -> Error: SaveChatroomMember - Failed to get %s Bucket", CHATROOMMEMBERS)
+    return BucketNotFoundError{CHATROOMMEMBERS}
+  }
+  key := chatroomName + "-" + userID.String()
+
+  if err := bucket.Put([]byte(key), []byte{byte(memberType)}); err != nil {
+    log.Printf(" -> SaveChatroomMember: Failed to update/save Chatroom Member")
+    return PutDataError{key, CHATROOMMEMBERS, err.Error()}
+  }
+  return boltSaveJoinedChatroom(tx, chatroomName, userID, memberType)
+}
+
+// boltSaveJoinedChatroom :: Keeps /JoinedChatrooms/{user_id}/{chatroom} in step with
+//    /ChatroomMembers. Blocked Members are dropped from the User's list.
+func boltSaveJoinedChatroom(
+  tx *bbolt.Tx,
+  chatroomName string,
+  userID UUID,
+  memberType MemberType,
+) error {
+  joined, err := boltRoomBucket(tx, JOINEDCHATROOMS, userID.String(), true)
+  if err != nil {
+    return err
+  }
+  if memberType == Blocked {
+    if err := joined.Delete([]byte(chatroomName)); err != nil {
+      return DeleteDataError{chatroomName, JOINEDCHATROOMS, err.Error()}
+    }
+    return nil
+  }
+
+  var data []byte
+  enc := codec.NewEncoderBytes(&data, &JSONHandle)
+  if err := enc.Encode(JoinedChatroom{ Chatroom: chatroomName, MemberType: memberType }); err != nil {
+    return EncoderError{err.Error()}
+  }
+  if err := joined.Put([]byte(chatroomName), data); err != nil {
+    return PutDataError{chatroomName, JOINEDCHATROOMS, err.Error()}
+  }
+  return nil
+}
+
+// boltLatestSeq :: The Seq of the newest Message within chatroom, or 0. Read from
+//    the Bucket's sequence, which outlives the Messages purged by PurgeMessages.
+func boltLatestSeq(tx *bbolt.Tx, chatroom string)( uint64, error ){
+  room, err := boltRoomMessages(tx, chatroom, false)
+  if err != nil || room == nil {
+    return 0, err
+  }
+  return room.Sequence(), nil
+}
+
+// boltGetReadMarker :: /ReadMarkers/{chatroom}/{user_id}. 0 if the User has yet to read anything.
+func boltGetReadMarker(tx *bbolt.Tx, chatroom string, userID UUID)( uint64, error ){
+  markers, err := boltRoomBucket(tx, READMARKERS, chatroom, false)
+  if err != nil || markers == nil {
+    return 0, err
+  }
+  if data := markers.Get([]byte(userID.String())); data != nil {
+    return btoi(data), nil
+  }
+  return 0, nil
+}
+
+func boltGetChatroomMemberStatus(
+  tx *bbolt.Tx,
+  chatroomName string,
+  userID UUID,
+)( *MemberType, error ){
+  bucket := tx.Bucket([]byte(CHATROOMMEMBERS))
+  if bucket == nil {
+    return nil, BucketNotFoundError{CHATROOMMEMBERS}
+  }
+
+  key := chatroomName + "-" + userID.String()
+  data := bucket.Get([]byte(key))
+  if data == nil {
+    return nil, GetDataError{key, CHATROOMMEMBERS}
+  }
+
+  stat := MemberType(data[0])
+  return &stat, nil
+}
+
+// boltGetChatroomMember :: Resolves the Role held by /ChatroomMembers/{chatroom}-{user_id}.
+func boltGetChatroomMember(tx *bbolt.Tx, chatroomName string, userID UUID)( *ChatroomMember, error ){
+  bucket := tx.Bucket([]byte(CHATROOMMEMBERS))
+  if bucket == nil {
+    return nil, BucketNotFoundError{CHATROOMMEMBERS}
+  }
+  key := chatroomName + "-" + userID.String()
+  data := bucket.Get([]byte(key))
+  if data == nil {
+    return nil, GetDataError{key, CHATROOMMEMBERS}
+  }
+  return resolveMember(userID, MemberType(data[0]), string(data[1:]), func(name string)( *Role, error ){
+    return boltGetRole(tx, chatroomName, name)
+  })
+}
+
+// boltGetRole :: /Roles/{chatroom}/{name}. nil if the Role doesn't exist.
+func boltGetRole(tx *bbolt.Tx, chatroomName, name string)( *Role, error ){
+  bucket, err := boltRoomBucket(tx, ROLES, chatroomName, false)
+  if err != nil || bucket == nil {
+    return nil, err
+  }
+  data := bucket.Get([]byte(name))
+  if data == nil {
+    return nil, nil
+  }
+  var role Role
+  dec := codec.NewDecoderBytes(data, &JSONHandle)
+  if err := dec.Decode(&role); err != nil {
+    return nil, DecoderError{err.Error()}
+  }
+  return &role, nil
+}
+
+func boltPutRole(tx *bbolt.Tx, chatroomName string, role *Role) error {
+  bucket, err := boltRoomBucket(tx, ROLES, chatroomName, true)
+  if err != nil {
+    return err
+  }
+  var data []byte
+  enc := codec.NewEncoderBytes(&data, &JSONHandle)
+  if err := enc.Encode(role); err != nil {
+    return EncoderError{err.Error()}
+  }
+  if err := bucket.Put([]byte(role.Name), data); err != nil {
+    return PutDataError{chatroomName + "/" + role.Name, ROLES, err.Error()}
+  }
+  return nil
+}
+
+func boltSaveDefaultRoles(tx *bbolt.Tx, chatroomName string) error {
+  for _, role := range DefaultRoles() {
+    if err := boltPutRole(tx, chatroomName, &role); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func boltGetUserByID(tx *bbolt.Tx, id UUID)( *User, error ){
+  bucket := tx.Bucket([]byte(USERS))
+  if bucket == nil {
+    log.Printf(" -> GetUserByID - Bucket \"%s\" not found.", USERS)
+    return nil, BucketNotFoundError{USERS}
+  }
+  data := bucket.Get([]byte(id.String()))
+  if data == nil {
+    log.Printf(" -> GetUserById - User ID \"%s\" not found.", id)
+    return nil, GetDataError{id.String(), USERS}
+  }
+  var user User
+  dec := codec.NewDecoderBytes(data, &JSONHandle)
+  if err := dec.Decode(&user); err != nil {
+    return nil, DecoderError{err.Error()}
+  }
+  return &user, nil
+}
+
+func boltGetUserbyUsername(tx *bbolt.Tx, username string)( *User, error ){
+  bucket := tx.Bucket([]byte(USERNAMES))
+  if bucket == nil {
+    log.Printf(" -> GetUserbyUsername - Bucket \"%s\" not found.", USERNAMES)
+    return nil, BucketNotFoundError{USERNAMES}
+  }
+  uid := bucket.Get([]byte(username))
+  if uid == nil {
+    log.Printf(" -> GetUserbyUsername - User ID \"%s\" not found.", username)
+    return nil, GetDataError{username, USERNAMES}
+  }
+
+  bucket = tx.Bucket([]byte(USERS))
+  if bucket == nil {
+    log.Printf(" -> GetUserbyUsername - Bucket \"%s\" not found.", USERS)
+    return nil, BucketNotFoundError{USERS}
+  }
+  data := bucket.Get(uid)
+  if data == nil {
+    log.Printf(" -> GetUserbyUsername - User not found.")
+    return nil, GetDataError{username, USERS}
+  }
+
+  var user User
+  dec := codec.NewDecoderBytes(data, &JSONHandle)
+  if err := dec.Decode(&user); err != nil {
+    return nil, DecoderError{err.Error()}
+  }
+  return &user, nil
+}
+
+func boltSaveUsersOnlineStatus(tx *bbolt.Tx, username string, isOnline bool) error {
+  bucket := tx.Bucket([]byte(USERSONLINE))
+  if bucket == nil {
+    log.Printf(" -> SaveUsersOnlineStatus: Failed to get \"%s\" Bucket", USERSONLINE)
+    return BucketNotFoundError{USERSONLINE}
+  }
+  if err := bucket.Put([]byte(username), BoolToBytes(isOnline)); err != nil {
+    var s string
+    if isOnline{ s = "Online" } else { s = "Offline" }
+    log.Printf(
+      " -> SaveUsersOnlineStatus: Failed to update/create \"%s\"'s online status to \"%s\": %s",
+      username, s, err,
+    )
+    return PutDataError{s, USERSONLINE, err.Error()}
+  }
+  return nil
+}
+
+// boltSaveUserToken :: Used for both creating and updating a UserToken within the
+//     /UserTokens Bucket.
+func boltSaveUserToken(tx *bbolt.Tx, userID UUID, token *token.Token) error {
+  b := tx.Bucket([]byte(USERTOKENS))
+  if b == nil {
+    return BucketNotFoundError{USERTOKENS}
+  }
+
+  var data []byte
+  enc := codec.NewEncoderBytes(&data, &JSONHandle)
+  if err := enc.Encode(token); err != nil {
+    return EncoderError{err.Error()}
+  }
+
+  if err := b.Put([]byte(userID.String()), data); err != nil {
+    return PutDataError{userID.String(), USERTOKENS, err.Error()}
+  }
+  return nil
+}
+
+func BoolToBytes(b bool) []byte {
+  if b { return []byte{1} }
+  return []byte{0}
+}
+func BytesToBool(b []byte) bool {
+  return b[0] == 1
+}
+
+// decodeChatroomMessage :: Decodes a raw ChatroomMessage sent by userID over
+//    chatroom's Websocket. Which Chatroom, and which User, is decided by the
+//    connection. Clients may leave either out, but can't claim anything else. The
+//    ID is always chosen by the server, a client reusing an existing ID would take
+//    over it's edits, deletes and reactions. So is the TimeStamp, which keeps Seq
+//    order and time order the same for PurgeMessages, the Directory and search.
+func decodeChatroomMessage(raw []byte, chatroom string, userID UUID)( *ChatroomMessage, error ){
+  var msg ChatroomMessage
+  dec := codec.NewDecoderBytes(raw, &JSONHandle)
+  if err := dec.Decode(&msg); err != nil {
+    return nil, DecoderError{err.Error()}
+  }
+  if msg.Chatroom != "" && msg.Chatroom != chatroom {
+    return nil, FailedSecurityCheckError{"Chatroom: "+msg.Chatroom, "Message was sent over another Chatroom's Websocket"}
+  }
+  if msg.Message.UserID != uuid.Nil && msg.Message.UserID != userID {
+    return nil, FailedSecurityCheckError{"UserID: "+msg.Message.UserID.String(), "Message was sent by another User"}
+  }
+  msg.Chatroom = chatroom
+  msg.Message.UserID = userID
+  msg.Message.ID = uuid.New()
+  msg.Message.TimeStamp = time.Now()
+  // Edits, deletes, and threads only ever come from the Database, never from a client.
+  msg.Event = ""
+  msg.Message.EditedAt = time.Time{}
+  msg.Message.DeletedAt = time.Time{}
+  msg.Message.ThreadID = UUID{}
+  msg.Message.Reactions = nil
+  return &msg, nil
+}
+
+func encodeChatroomMessage(msg *ChatroomMessage)( []byte, error ){
+  var data []byte
+  enc := codec.NewEncoderBytes(&data, &JSONHandle)
+  if err := enc.Encode(msg); err != nil {
+    return nil, EncoderError{err.Error()}
+  }
+  return data, nil
 }
 
 func inviteKey(roomID *UUID, userID *UUID) string {
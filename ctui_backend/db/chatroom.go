@@ -1,7 +1,13 @@
 package db
 
 import (
+	"fmt"
+	"strconv"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
 	// "chatatui_backend/token"
   // "github.com/ugorji/go/codec"
 )
@@ -17,16 +23,453 @@ const (
   Delete
 )
 
+// String :: How a Status is stored, and sent to clients.
+func(s Status)String() string {
+  switch s {
+  case Online:
+    return "Online"
+  case Background:
+    return "Background"
+  case Offline:
+    return "Offline"
+  case Delete:
+    return "Delete"
+  }
+  return "Unknown"
+}
+
+// Message.Seq :: Assigned by the Database when the Message is stored. Seq is
+//    monotonic within a Chatroom, and is the canonical position of a Message.
+// Message.EditedAt, Message.DeletedAt :: Zero until the Message is edited or
+//    deleted. A deleted Message is kept as a tombstone, with it's Content cleared,
+//    so that it keeps it's place within the Chatroom's history.
+// Message.ReplyTo, Message.ThreadID :: Set by the client, ReplyTo is the Message
+//    being replied to. ThreadID is set by the Database, and is the ID of the first
+//    Message of the thread. Both are zero for Messages outside of a thread.
 type Message struct {
-  ID         UUID      `codec:"id"`
-  TimeStamp  time.Time `codec:"time_stamp"`
-  UserID     UUID      `codec:"user_id"`
+  ID         UUID       `codec:"id"`
+  Seq        uint64     `codec:"seq"`
+  TimeStamp  time.Time  `codec:"time_stamp"`
+  UserID     UUID       `codec:"user_id"`
+  Content    string     `codec:"content"`
+  EditedAt   time.Time  `codec:"edited_at,omitempty"`
+  DeletedAt  time.Time  `codec:"deleted_at,omitempty"`
+  ReplyTo    UUID       `codec:"reply_to,omitempty"`
+  ThreadID   UUID       `codec:"thread_id,omitempty"`
+  Reactions  []Reaction `codec:"reactions,omitempty"`
+}
+
+// Reaction :: Every User who reacted to a Message with the same emoji or text.
+//    Reactions are kept in the order they were first used.
+type Reaction struct {
+  Reaction string `codec:"reaction"`
+  Count    int    `codec:"count"`
+  UserIDs  []UUID `codec:"user_ids"`
+}
+
+const MaxReactionLength = 32
+
+// ValidReaction :: A Reaction is a single emoji, or a short piece of text like "+1".
+func ValidReaction(reaction string) bool {
+  if reaction == "" || len(reaction) > MaxReactionLength || !utf8.ValidString(reaction) {
+    return false
+  }
+  for _, r := range reaction {
+    if unicode.IsSpace(r) || unicode.IsControl(r) {
+      return false
+    }
+  }
+  return true
+}
+
+// addReaction :: Returns false if userID had already reacted with reaction.
+func(m *Message)addReaction(reaction string, userID UUID) bool {
+  for i := range m.Reactions {
+    r := &m.Reactions[i]
+    if r.Reaction != reaction {
+      continue
+    }
+    for _, id := range r.UserIDs {
+      if id == userID {
+        return false
+      }
+    }
+    r.UserIDs = append(r.UserIDs, userID)
+    r.Count = len(r.UserIDs)
+    return true
+  }
+  m.Reactions = append(m.Reactions, Reaction{ reaction, 1, []UUID{ userID } })
+  return true
+}
+
+// removeReaction :: Returns false if userID hadn't reacted with reaction. Drops
+//    the Reaction entirely once nobody is left.
+func(m *Message)removeReaction(reaction string, userID UUID) bool {
+  for i := range m.Reactions {
+    r := &m.Reactions[i]
+    if r.Reaction != reaction {
+      continue
+    }
+    for j, id := range r.UserIDs {
+      if id != userID {
+        continue
+      }
+      r.UserIDs = append(r.UserIDs[:j], r.UserIDs[j+1:]...)
+      r.Count = len(r.UserIDs)
+      if r.Count == 0 {
+        m.Reactions = append(m.Reactions[:i], m.Reactions[i+1:]...)
+      }
+      if len(m.Reactions) == 0 {
+        m.Reactions = nil
+      }
+      return true
+    }
+    return false
+  }
+  return false
+}
+
+// ReactionCount :: How many Users reacted to the Message with reaction.
+func(m *Message)ReactionCount(reaction string) int {
+  for _, r := range m.Reactions {
+    if r.Reaction == reaction {
+      return r.Count
+    }
+  }
+  return 0
+}
+
+func(m *Message)IsDeleted() bool {
+  return !m.DeletedAt.IsZero()
+}
+
+func(m *Message)IsReply() bool {
+  return m.ReplyTo != (UUID{})
+}
+
+// joinThread :: Replies belong to the same thread as the Message they reply to.
+//    Deleted Messages can't be replied to.
+func(m *Message)joinThread(parent *Message) error {
+  if parent.IsDeleted() {
+    return MessageDeletedError{parent.ID.String()}
+  }
+  if parent.ThreadID != (UUID{}) {
+    m.ThreadID = parent.ThreadID
+  } else {
+    m.ThreadID = parent.ID
+  }
+  return nil
+}
+
+// MessageRevision :: A previous Content of an edited Message, along with when,
+//    and by whom, it was replaced.
+type MessageRevision struct {
   Content    string    `codec:"content"`
+  ReplacedAt time.Time `codec:"replaced_at"`
+  ReplacedBy UUID      `codec:"replaced_by"`
+}
+
+// --> ChatroomMessage Events. A brand new Message is sent without an Event.
+const (
+  MessageEdited   = "edited"
+  MessageDeleted  = "deleted"
+  ReactionAdded   = "reaction_added"
+  ReactionRemoved = "reaction_removed"
+  ChatroomUpdated = "chatroom_updated"
+  PresenceChanged = "presence"
+  MessageRejected = "rejected"
+)
+
+// ChatroomEvent :: Sent over a Chatroom's Websocket once it's settings change.
+//    Settings holds the whole, updated, Chatroom.
+type ChatroomEvent struct {
+  Event     string   `codec:"event"`
+  Chatroom  string   `codec:"chatroom"`
+  Settings  Chatroom `codec:"settings"`
+  UpdatedBy UUID     `codec:"updated_by"`
+}
+
+// ReactionEvent :: Sent over a Chatroom's Websocket when a Reaction changes. Much
+//    lighter than re-sending the whole Message. Count is the new total for Reaction.
+type ReactionEvent struct {
+  Event     string `codec:"event"`
+  Chatroom  string `codec:"chatroom"`
+  MessageID UUID   `codec:"message_id"`
+  Reaction  string `codec:"reaction"`
+  UserID    UUID   `codec:"user_id"`
+  Count     int    `codec:"count"`
 }
 
+// RejectedEvent :: Sent back to the one Client whose Message wasn't stored, so it
+//    isn't left waiting on a Message that will never be broadcast.
+type RejectedEvent struct {
+  Event    string `codec:"event"`
+  Chatroom string `codec:"chatroom"`
+  Reason   string `codec:"reason"`
+}
+
+// PresenceEvent :: Sent over a Chatroom's Websocket whenever a Member connects,
+//    goes idle, comes back, or leaves. Status is "Online", "Background" or "Offline".
+type PresenceEvent struct {
+  Event    string   `codec:"event"`
+  Chatroom string   `codec:"chatroom"`
+  UserID   UUID     `codec:"user_id"`
+  Username UserName `codec:"username"`
+  Status   string   `codec:"status"`
+}
+
+// ChatroomMessage :: What's sent over a Chatroom's Websocket. Both by the client,
+//    and back out to every client connected to the Chatroom's Hub. Event tells
+//    clients to replace an existing Message, with the same ID, in place.
+type ChatroomMessage struct {
+  Event    string  `codec:"event,omitempty"`
+  Chatroom string  `codec:"chatroom"`
+  Message  Message `codec:"message"`
+}
+
+// Chatroom.PeerID :: Only set for Direct Chatrooms, which are private 1:1
+//    conversations between OwnerID and PeerID. Both are plain Members, and nobody
+//    else can join.
+// Chatroom.SlowMode :: The number of seconds each User has to wait between
+//    Messages. 0 turns slow mode off.
+// Chatroom.MaxMembers :: How many non-Blocked Members the Chatroom can hold. 0 is
+//    unlimited.
+// Chatroom.ReadOnly :: Announcement Chatrooms. Only Members allowed to change the
+//    Chatroom's settings can post.
+// Chatroom.MaxMessageAge, Chatroom.MaxMessages :: The Chatroom's Retention, in
+//    seconds and Messages. 0 falls back onto the server's default.
 type Chatroom struct {
-  RoomID      UUID      `codec:"room_id"`
-  RoomName    RoomName  `codec:"room_name"`
-  OwnerID     UUID      `codec:"owner_id"`
-  Public      bool      `codec:"public"`
+  RoomID        UUID     `codec:"room_id"`
+  RoomName      RoomName `codec:"room_name"`
+  OwnerID       UUID     `codec:"owner_id"`
+  Public        bool     `codec:"public"`
+  PeerID        UUID     `codec:"peer_id,omitempty"`
+  Topic         string   `codec:"topic,omitempty"`
+  Description   string   `codec:"description,omitempty"`
+  SlowMode      int      `codec:"slow_mode,omitempty"`
+  MaxMembers    int      `codec:"max_members,omitempty"`
+  ReadOnly      bool     `codec:"read_only,omitempty"`
+  MaxMessageAge int      `codec:"max_message_age,omitempty"`
+  MaxMessages   int      `codec:"max_messages,omitempty"`
+}
+
+const (
+  MaxTopicLength       = 120
+  MaxDescriptionLength = 1000
+  MaxSlowMode          = 6 * 60 * 60
+)
+
+// ChatroomSettings :: A partial update to a Chatroom's settings, sent with
+//    PUT /chatrooms/{room_name}. Fields left out are kept as they are.
+type ChatroomSettings struct {
+  Public        *bool   `codec:"public,omitempty"`
+  Topic         *string `codec:"topic,omitempty"`
+  Description   *string `codec:"description,omitempty"`
+  SlowMode      *int    `codec:"slow_mode,omitempty"`
+  MaxMembers    *int    `codec:"max_members,omitempty"`
+  ReadOnly      *bool   `codec:"read_only,omitempty"`
+  MaxMessageAge *int    `codec:"max_message_age,omitempty"`
+  MaxMessages   *int    `codec:"max_messages,omitempty"`
+}
+
+// Apply :: Copies settings onto chatroom, and validates the result.
+func(s *ChatroomSettings)Apply(chatroom *Chatroom) error {
+  if s.Public != nil {
+    chatroom.Public = *s.Public
+  }
+  if s.Topic != nil {
+    chatroom.Topic = *s.Topic
+  }
+  if s.Description != nil {
+    chatroom.Description = *s.Description
+  }
+  if s.SlowMode != nil {
+    chatroom.SlowMode = *s.SlowMode
+  }
+  if s.MaxMembers != nil {
+    chatroom.MaxMembers = *s.MaxMembers
+  }
+  if s.ReadOnly != nil {
+    chatroom.ReadOnly = *s.ReadOnly
+  }
+  if s.MaxMessageAge != nil {
+    chatroom.MaxMessageAge = *s.MaxMessageAge
+  }
+  if s.MaxMessages != nil {
+    chatroom.MaxMessages = *s.MaxMessages
+  }
+  return ValidateChatroomSettings(chatroom)
+}
+
+// ValidateRoomName :: Chatroom names are between 5 and 50 characters long, and only
+//    consist of letters and numbers.
+func ValidateRoomName(name string) error {
+  if len(name) <= 5 || len(name) >= 50{
+    return fmt.Errorf("RoomName must be between 5 and 50 characters")
+  }
+  for _, r := range name {
+    if !unicode.IsLetter(r)  && !unicode.IsNumber(r){
+      return fmt.Errorf(
+        "RoomName can only consist of Letters and Numbers: \"%v\" is not aloud",
+        r,
+      )
+    }
+  }
+  return nil
+}
+
+// ValidateChatroomSettings :: Checks everything about a Chatroom a Member can change.
+//    Lowering MaxMembers below the current number of Members is allowed, it only
+//    stops anyone else from joining.
+func ValidateChatroomSettings(chatroom *Chatroom) error {
+  if utf8.RuneCountInString(chatroom.Topic) > MaxTopicLength || !utf8.ValidString(chatroom.Topic) {
+    return fmt.Errorf("Topics can be at most %d characters long", MaxTopicLength)
+  }
+  for _, r := range chatroom.Topic {
+    if unicode.IsControl(r) {
+      return fmt.Errorf("Topics must fit on a single line")
+    }
+  }
+  if utf8.RuneCountInString(chatroom.Description) > MaxDescriptionLength || !utf8.ValidString(chatroom.Description) {
+    return fmt.Errorf("Descriptions can be at most %d characters long", MaxDescriptionLength)
+  }
+  if chatroom.SlowMode < 0 || chatroom.SlowMode > MaxSlowMode {
+    return fmt.Errorf("Slow mode must be between 0 and %d seconds", MaxSlowMode)
+  }
+  if chatroom.MaxMembers < 0 {
+    return fmt.Errorf("The maximum number of Members can't be negative")
+  }
+  if chatroom.MaxMessageAge < 0 || chatroom.MaxMessages < 0 {
+    return fmt.Errorf("Message retention can't be negative")
+  }
+  return nil
+}
+
+// SlowModeInterval :: How long each User has to wait between Messages.
+func(c *Chatroom)SlowModeInterval() time.Duration {
+  return time.Duration(c.SlowMode) * time.Second
+}
+
+// Full :: Whether a Chatroom with members non-Blocked Members has room for another.
+func(c *Chatroom)Full(members int) bool {
+  return c.MaxMembers > 0 && members >= c.MaxMembers
+}
+
+// checkCapacity :: Shared by every JoinChatroom.
+func checkCapacity(chatroom *Chatroom, members map[UUID]MemberType) error {
+  if chatroom.Full(CountMembers(members)) {
+    return ChatroomFullError{chatroom.RoomName}
+  }
+  return nil
+}
+
+// CountMembers :: Blocked Members don't count towards a Chatroom's MaxMembers.
+func CountMembers(members map[UUID]MemberType) int {
+  count := 0
+  for _, memberType := range members {
+    if memberType != Blocked {
+      count++
+    }
+  }
+  return count
+}
+
+// authorizePost :: Shared by every HandleRawMessage. Read-only Chatrooms only take
+//    Messages from Members who can change the Chatroom's settings.
+func authorizePost(chatroom *Chatroom, member *ChatroomMember) error {
+  perm := PermPost
+  if chatroom.ReadOnly {
+    perm |= PermChangeSettings
+  }
+  return Authorize(member, perm, nil)
+}
+
+// NewDirectChatroom :: userID opens a Direct Chatroom with peerID.
+func NewDirectChatroom(userID, peerID UUID) Chatroom {
+  return Chatroom{
+    RoomID:   uuid.New(),
+    RoomName: DirectChatroomName(userID, peerID),
+    OwnerID:  userID,
+    PeerID:   peerID,
+  }
+}
+
+// DirectChatroomName :: The same for both Users, whichever of them opens it. The
+//    ':'s keep it from colliding with a name a User could pick.
+func DirectChatroomName(a, b UUID) RoomName {
+  x, y := a.String(), b.String()
+  if y < x {
+    x, y = y, x
+  }
+  return "dm:" + x + ":" + y
+}
+
+func(c *Chatroom)IsDirect() bool {
+  return c.PeerID != (UUID{})
+}
+
+// DirectPeer :: The other User of a Direct Chatroom.
+func(c *Chatroom)DirectPeer(userID UUID) UUID {
+  if c.OwnerID == userID {
+    return c.PeerID
+  }
+  return c.OwnerID
+}
+
+// MessagePage :: A single page of a Chatroom's Messages, sorted oldest to newest.
+//    PrevCursor fetches the page of older Messages(?before=), and NextCursor the
+//    page of newer Messages(?after=). A cursor is left empty when there's nothing
+//    more to fetch in that direction.
+type MessagePage struct {
+  Messages   []Message `codec:"messages"`
+  NextCursor string    `codec:"next_cursor"`
+  PrevCursor string    `codec:"prev_cursor"`
+}
+
+// ThreadPage :: The first Message of a thread, along with a page of it's replies.
+type ThreadPage struct {
+  Root    Message      `codec:"root"`
+  Replies *MessagePage `codec:"replies"`
+}
+
+// EncodeCursor :: Cursors are opaque to clients. Currently they're just a Message.Seq
+func EncodeCursor(seq uint64) string {
+  return strconv.FormatUint(seq, 10)
+}
+
+// DecodeCursor :: An empty cursor decodes to 0, meaning unbounded.
+func DecodeCursor(cursor string)( uint64, error ){
+  if cursor == "" {
+    return 0, nil
+  }
+  return strconv.ParseUint(cursor, 10, 64)
+}
+
+// NewMessagePage :: Builds a MessagePage from msgs, which must already be sorted by Seq.
+func NewMessagePage(msgs []Message, hasOlder, hasNewer bool) *MessagePage {
+  page := &MessagePage{ Messages: msgs }
+  if page.Messages == nil {
+    page.Messages = []Message{}
+  }
+  if len(msgs) == 0 {
+    return page
+  }
+  if hasOlder {
+    page.PrevCursor = EncodeCursor(msgs[0].Seq)
+  }
+  if hasNewer {
+    page.NextCursor = EncodeCursor(msgs[len(msgs)-1].Seq)
+  }
+  return page
+}
+
+// ClampPageSize :: Falls back onto DefaultPageSize, and never exceeds MaxPageSize
+func ClampPageSize(limit int) int {
+  if limit <= 0 {
+    return DefaultPageSize
+  }
+  if limit > MaxPageSize {
+    return MaxPageSize
+  }
+  return limit
 }
@@ -2,6 +2,7 @@ package db
 
 import (
 	"chatatui_backend/token"
+	"time"
 )
 
 // --> DB Keys
@@ -18,6 +19,18 @@ const (
   USERTOKENS        = "UserTokens"
   JOINEDCHATROOMS   = "JoinedChatrooms"
   INVITATIONS       = "Invitations"
+  INVITELINKS       = "InviteLinks"
+  PASSWORDRESETS    = "PasswordResets"
+  PROFILES          = "Profiles"
+  SEARCHINDEX       = "SearchIndex"
+  MESSAGEIDS        = "MessageIDs"
+  MESSAGEREVISIONS  = "MessageRevisions"
+  DELETEDMESSAGES   = "DeletedMessages"
+  THREADS           = "Threads"
+  READMARKERS       = "ReadMarkers"
+  ROLES             = "Roles"
+  META              = "Meta"
+  SCHEMAVERSION     = "SchemaVersion"
   DATEFMT           = "20060102150405.999999999"
 )
 
@@ -33,35 +46,136 @@ type ChatatuiDatabase interface {
   // SaveChatroom :: Used for both Creating and Updating a Chatroom db item.
   SaveChatroom(chatroom *Chatroom, update bool) error
 
+  // UpdateChatroomSettings :: Applies settings if userID is allowed to change them, returning the updated Chatroom.
+  UpdateChatroomSettings(chatroomName string, userID UUID, settings *ChatroomSettings)( *Chatroom, error )
+
+  // GetChatroomDirectory :: Every active, public Chatroom, with it's MemberCount and LastActivity, in a single read. Direct Chatrooms are never public. OnlineCount is left to the caller.
+  GetChatroomDirectory()( []DirectoryEntry, error )
+  // GetDirectoryEntry :: The same DirectoryEntry GetChatroomDirectory lists, for a single active Chatroom, public or not.
+  GetDirectoryEntry(chatroomName string)( *DirectoryEntry, error )
+
+  // GetChatrooms :: Every active Chatroom, sorted by name. Private and Direct Chatrooms included.
+  GetChatrooms()( []Chatroom, error )
+
+  // OpenDirectChatroom :: Returns the Direct Chatroom shared by userID and peerID, creating it, with both
+  //    Users as Members, if it doesn't exist yet.
+  OpenDirectChatroom(userID, peerID UUID)( *Chatroom, error )
+
   // DeactivateChatroom :: Deactivates Chatroom after confirming user's identity
   DeactivateChatroom(roomName string, userID UUID) error
 
-  // JoinChatroom :: Takes optional secret(for private chatrooms). Compares it to stores secret in /Chatrooms. If passes Will store 'JoinedChatroom' object under username in /JoinedChatrooms bucket.
-  JoinChatroom(chatroom string,username string,invitation []byte) error
+  // JoinChatroom :: Private Chatrooms require an unexpired Invitation, which is used up, and full Chatrooms can't be joined. If passes Will store 'JoinedChatroom' object under username in /JoinedChatrooms bucket.
+  JoinChatroom(chatroom string,username string) error
 
   // UpdateChatroomUserStatus :: Change the status of a user within a particular Chatroom.
   UpdateChatroomUserStatus(chatroom, username string, status Status) error
 
+  // GetChatroomUserStatus :: The live status("Online", "Background" or "Offline") of every User within chatroom, by Username.
+  GetChatroomUserStatus(chatroom string)( map[UserName]string, error )
+
   // SaveChatroomMember :: in a user, and token object. Creates various Bucket entries for a new User
   SaveChatroomMember(chatroomName string,userID UUID,memberType MemberType) error
 
+  // RemoveChatroomMember :: Removes userID from the Chatroom, along with their read marker. Unlike
+  //    a Blocked Member, they're free to join again.
+  RemoveChatroomMember(chatroomName string, userID UUID) error
+
+  // GetChatroomMember :: userID's membership of the Chatroom, along with the Role they hold. Pass it to Authorize.
+  GetChatroomMember(chatroomName string, userID UUID)( *ChatroomMember, error )
+
+  // SetChatroomMemberRole :: Hands an existing Member one of the Chatroom's Roles. Their MemberType follows it.
+  SetChatroomMemberRole(chatroomName string, userID UUID, role string) error
+
+  // GetChatroomRoles :: Every Role within the Chatroom, highest ranking first.
+  GetChatroomRoles(chatroomName string)( []Role, error )
+
+  // SaveChatroomRole :: Creates, or updates, a Role within the Chatroom. Checked with ValidateRole.
+  SaveChatroomRole(chatroomName string, role *Role) error
+
+  // DeleteChatroomRole :: Deletes a custom Role. Members holding it fall back onto MemberRole.
+  DeleteChatroomRole(chatroomName, name string) error
+
   // GetChatroomMembers :: With a given Chatroom name, this will return a map of current Chatroom Members, where map[UserName]MemberStatus
   GetChatroomMembers(chatroomName string)( map[UUID]MemberType, error)
 
-  // StoreInvitation :: Takes in a pre-compiled invitation, we pass it though 'SaltSecret', and then store it in the buck /Invitations/{roomID-userID}
-  StoreInvitation(roomID UUID, userID UUID, invitation []byte) error
+  // SaveInvitation :: Stores, or replaces, an Invitation for invitation.UserID to join invitation.RoomID.
+  SaveInvitation(invitation *Invitation) error
+
+  // GetInvitation :: Returns the Invitation for userID to join roomID, expired or not.
+  GetInvitation(roomID UUID, userID UUID)( *Invitation, error )
+
+  // GetPendingInvitations :: Every unexpired Invitation to an active Chatroom for userID, newest first.
+  GetPendingInvitations(userID UUID)( []Invitation, error )
 
   // RemoveInvitation :: Removes a Invitation from /Invitations
   RemoveInvitation(roomID UUID, userID UUID) error
 
-  // HandleRawMessage :: Takes in a Raw message. Extracts meta data and Message, stores it in /Message Bucket
-  HandleRawMessage(raw []byte) error
+  // SaveInviteLink :: Stores a new InviteLink under it's Code.
+  SaveInviteLink(link *InviteLink) error
+
+  // GetInviteLink :: Returns the InviteLink for code, usable or not.
+  GetInviteLink(code string)( *InviteLink, error )
 
-  // SaveMessage :: Takes in a Chatroom name and a Message Object. If Chatroom exists. Stores the Message in /Messages bucket.
+  // RedeemInviteLink :: Counts a use of the InviteLink and adds userID to it's Chatroom as a Member, all at once.
+  //    Fails if the InviteLink has expired, been used up, or it's Chatroom has been deactivated, with
+  //    ChatroomFullError once the Chatroom is full, and AlreadyMemberError if userID is already a Member.
+  RedeemInviteLink(code string, userID UUID)( *InviteLink, error )
+
+  // HandleRawMessage :: Takes in a Raw ChatroomMessage sent by userID over chatroomName's Websocket. Extracts meta data and
+  //    Message, stores it in /Messages/{chatroomName}. A Message claiming to be for another Chatroom, or from another User,
+  //    is refused. Returns the stored ChatroomMessage, re-encoded with it's sequence number, ready to be broadcast.
+  HandleRawMessage(chatroomName string, userID UUID, raw []byte)( []byte, error )
+
+  // SaveMessage :: Takes in a Chatroom name and a Message Object. If Chatroom exists. Stores the Message in /Messages/{chatroom}, and sets message.Seq.
+  //    If message.ReplyTo is set, the Message it replies to must exist within the same Chatroom. Sets message.ThreadID.
   SaveMessage(chatroom string, message *Message) error
 
-  // Pagination: Based on time. At the moment, this only paginates where a page of 1 == 1 Day. Will need to find a more refined approach for paginating messages
-  Paginate(chatroomName string, page, limit int)( []byte,error )
+  // SaveMessages :: Same as SaveMessage, for a batch of Messages saved within a single transaction. Either every
+  //    Message is saved, or none are. Replies may reply to Messages earlier within the same batch.
+  SaveMessages(chatroom string, messages []Message) error
+
+  // PurgeMessages :: Permanently deletes up to limit of the Chatroom's oldest Messages that have expired under retention,
+  //    stopping at the first one that hasn't. Returns how many were deleted. Seq numbers are never reused.
+  PurgeMessages(chatroomName string, retention Retention, now time.Time, limit int)( int, error )
+
+  // Paginate :: Cursor based. Returns up to 'limit' Messages where after < Message.Seq < before. A bound of 0 is
+  //    unbounded. With only 'after' set, the page starts right after it. Otherwise, the page ends right before 'before'.
+  Paginate(chatroomName string, before, after uint64, limit int)( *MessagePage, error )
+
+  // ScanMessages :: Up to limit Messages with Seq > after, oldest first. Tombstones included. Used to walk a
+  //    whole Chatroom in batches, such as by ExportMessages.
+  ScanMessages(chatroomName string, after uint64, limit int)( []Message, error )
+
+  // PaginateThread :: Same as Paginate, but only over the replies within the thread rooted at threadID.
+  PaginateThread(chatroomName string, threadID UUID, before, after uint64, limit int)( *MessagePage, error )
+
+  // GetMessage :: Returns the Message with the given ID from within chatroom. Tombstones included.
+  GetMessage(chatroom string, messageID UUID)( *Message, error )
+
+  // EditMessage :: Replaces a Message's Content, keeping the old Content as a MessageRevision. Returns the edited Message.
+  EditMessage(chatroom string, messageID UUID, editorID UUID, content string)( *Message, error )
+
+  // DeleteMessage :: Turns a Message into a tombstone. It's Content, MessageRevisions and Reactions are dropped. Returns the tombstone.
+  DeleteMessage(chatroom string, messageID UUID)( *Message, error )
+
+  // AddReaction :: Adds userID to the Message's Reaction. Reacting twice is a no-op. Returns the updated Message.
+  AddReaction(chatroom string, messageID UUID, userID UUID, reaction string)( *Message, error )
+
+  // RemoveReaction :: Removes userID from the Message's Reaction. Removing a missing Reaction is a no-op. Returns the updated Message.
+  RemoveReaction(chatroom string, messageID UUID, userID UUID, reaction string)( *Message, error )
+
+  // GetMessageRevisions :: Returns every previous Content of a Message, oldest first.
+  GetMessageRevisions(chatroom string, messageID UUID)( []MessageRevision, error )
+
+  // SearchMessages :: Returns up to query.Limit Messages matching query, newest first, from within query.Chatrooms.
+  SearchMessages(query SearchQuery)( []ChatroomMessage, error )
+
+  // MarkRead :: Advances the User's read marker within chatroom to seq, or to the latest Message if seq is 0.
+  //    Read markers never move backwards. Returns the User's read marker.
+  MarkRead(chatroom string, userID UUID, seq uint64)( uint64, error )
+
+  // GetJoinedChatrooms :: Every active Chatroom the User is a non-Blocked member of, sorted by name, with unread counts.
+  GetJoinedChatrooms(userID UUID)( []JoinedChatroom, error )
 
   // GetChatroomMemberStatus: First, we check to see if /ChatroomMembers/{room_id}-{user_id} exists.If so, we return the Members Status
   GetChatroomMemberStatus(chatroomName string, userID UUID)( *MemberType, error )
@@ -76,8 +190,14 @@ type ChatatuiDatabase interface {
   ActivateUser(userID UUID) error
 
   // DeactivateUser: Takes a User from /User/{user_id} and moves it to the /DeletedUser's Bucket. That way a user can reactivate their account whenevere they like.
+  //    Their Access Token is revoked and they're marked offline. Nothing is freed, their Username stays reserved.
   DeactivateUser(userID UUID) error
 
+  // GetDeactivatedUser :: Returns a deactivated User by Username, so signing in can offer to reactivate them.
+  GetDeactivatedUser(username string)( *User, error )
+  // GetDeactivatedUserByID :: Same as GetDeactivatedUser, by UserID. Their Messages still need an author, such as imported placeholders.
+  GetDeactivatedUserByID(id UUID)( *User, error )
+
   // GetUserbyUsername :: Returns a User object by indexing the /Users Bucket via UserName
   GetUserbyUsername(username string)( *User, error )
 
@@ -89,4 +209,24 @@ type ChatatuiDatabase interface {
 
   // GetUserToken :: Helper fucntion for quickly fetching a User's Access Token.
   GetUserToken(userID UUID)( *token.Token, error )
+
+  // GetProfile :: Returns userID's Profile, with their current Username. Deactivated Users have no Profile to show.
+  GetProfile(userID UUID)( *Profile, error )
+
+  // SaveProfile :: Stores, or replaces, the Profile of profile.UserID, who must be an active User. Username isn't stored.
+  SaveProfile(profile *Profile) error
+
+  // UpdateUserPassword :: Replaces the User's HashedPassword and Access Token together, signing out every other
+  //    session. A nil token revokes the Access Token instead. Every pending PasswordReset for the User is dropped.
+  UpdateUserPassword(userID UUID, hashedPassword []byte, token *token.Token) error
+
+  // SavePasswordReset :: Stores a new PasswordReset, replacing any earlier one for the same User.
+  SavePasswordReset(reset *PasswordReset) error
+
+  // ConsumePasswordReset :: Looks up the PasswordReset for resetToken and removes it, so it can only ever be used
+  //    once. Fails if it has expired.
+  ConsumePasswordReset(resetToken string)( *PasswordReset, error )
+
+  // Close :: Releases whatever resources the Database is holding onto.
+  Close()
 }
@@ -0,0 +1,1384 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chatatui_backend/token"
+
+	"github.com/google/uuid"
+	"github.com/ugorji/go/codec"
+)
+
+func TestMemoryDB(t *testing.T) {
+  testChatatuiDatabase(t, NewMemoryDatabase())
+}
+
+func TestSQLiteDB(t *testing.T) {
+  database, err := NewSQLiteDatabase(filepath.Join(t.TempDir(), "chatatui_test.sqlite"))
+  if err != nil {
+    t.Fatalf("FAILED: Failed to open SQLite Database: %v", err)
+  }
+  defer database.Close()
+
+  testChatatuiDatabase(t, database)
+}
+
+func TestBBoltDB(t *testing.T) {
+  database, err := NewDatabase(filepath.Join(t.TempDir(), "chatatui_test.db"))
+  if err != nil {
+    t.Fatalf("FAILED: Failed to open BBolt Database: %v", err)
+  }
+  defer database.Close()
+
+  testChatatuiDatabase(t, database)
+}
+
+// testChatatuiDatabase :: Every ChatatuiDatabase implementation should pass the
+//    same set of tests.
+func testChatatuiDatabase(t *testing.T, database ChatatuiDatabase) {
+  owner := User{ UserID: uuid.New(), Username: "owner", HashedPassword: []byte("hash") }
+  member := User{ UserID: uuid.New(), Username: "member", HashedPassword: []byte("hash") }
+  room := Chatroom{ RoomID: uuid.New(), RoomName: "memoryroom", OwnerID: owner.UserID, Public: true }
+
+  t.Run("Save and Get Users", func(t *testing.T){
+    for _, user := range []User{ owner, member } {
+      if err := database.SaveUser(user, nil); err != nil {
+        t.Errorf("FAILED: Failed to save User \"%s\": %v", user.Username, err)
+        return
+      }
+    }
+    got, err := database.GetUserbyUsername(member.Username)
+    if err != nil {
+      t.Errorf("FAILED: Failed to get User by Username: %v", err)
+      return
+    }
+    if got.UserID != member.UserID {
+      t.Errorf("FAILED: Got %v Want %v", got.UserID, member.UserID)
+      return
+    }
+    if _, err := database.GetUserByID(uuid.New()); err == nil {
+      t.Errorf("FAILED: Expected GetDataError for unknown UserID")
+      return
+    } else if _, ok := err.(GetDataError); !ok {
+      t.Errorf("FAILED: Got %T Want GetDataError", err)
+    }
+  })
+
+  t.Run("Create and Join Chatroom", func(t *testing.T){
+    if err := database.SaveChatroom(&room, false); err != nil {
+      t.Errorf("FAILED: Failed to create Chatroom: %v", err)
+      return
+    }
+    if err := database.SaveChatroom(&room, false); err == nil {
+      t.Errorf("FAILED: Chatroom name should already be taken")
+      return
+    }
+    if err := database.JoinChatroom(room.RoomName, member.Username); err != nil {
+      t.Errorf("FAILED: Failed to join public Chatroom: %v", err)
+      return
+    }
+    status, err := database.GetChatroomMemberStatus(room.RoomName, member.UserID)
+    if err != nil || *status != Member {
+      t.Errorf("FAILED: Got %v, %v Want Member", status, err)
+      return
+    }
+    if err := database.DeactivateChatroom(room.RoomName, member.UserID); err == nil {
+      t.Errorf("FAILED: A Member should not be able to deactivate the Chatroom")
+    }
+  })
+
+  t.Run("Private Chatroom requires Invitation", func(t *testing.T){
+    private := Chatroom{ RoomID: uuid.New(), RoomName: "privateroom", OwnerID: owner.UserID }
+    if err := database.SaveChatroom(&private, false); err != nil {
+      t.Errorf("FAILED: Failed to create Chatroom: %v", err)
+      return
+    }
+    err := database.JoinChatroom(private.RoomName, member.Username)
+    if _, ok := err.(GetDataError); !ok {
+      t.Errorf("FAILED: Got %v Want GetDataError", err)
+    }
+
+    expired := NewInvitation(&private, member.UserID, owner.UserID, time.Hour)
+    expired.ExpiresAt = time.Now().Add(-time.Minute)
+    if err := database.SaveInvitation(&expired); err != nil {
+      t.Errorf("FAILED: Failed to save Invitation: %v", err)
+      return
+    }
+    if pending, err := database.GetPendingInvitations(member.UserID); err != nil || len(pending) != 0 {
+      t.Errorf("FAILED: Got %+v, %v Want expired Invitations left out", pending, err)
+    }
+    err = database.JoinChatroom(private.RoomName, member.Username)
+    if _, ok := err.(FailedSecurityCheckError); !ok {
+      t.Errorf("FAILED: Got %v Want FailedSecurityCheckError for an expired Invitation", err)
+    }
+
+    invitation := NewInvitation(&private, member.UserID, owner.UserID, 0)
+    if err := database.SaveInvitation(&invitation); err != nil {
+      t.Errorf("FAILED: Failed to save Invitation: %v", err)
+      return
+    }
+    pending, err := database.GetPendingInvitations(member.UserID)
+    if err != nil || len(pending) != 1 || pending[0].Chatroom != private.RoomName || pending[0].InvitedBy != owner.UserID {
+      t.Errorf("FAILED: Got %+v, %v Want the Invitation to \"%s\"", pending, err, private.RoomName)
+    }
+    if got, err := database.GetInvitation(private.RoomID, member.UserID); err != nil || !got.ExpiresAt.Equal(invitation.ExpiresAt) {
+      t.Errorf("FAILED: Got %+v, %v Want %+v", got, err, invitation)
+    }
+    if err := database.JoinChatroom(private.RoomName, member.Username); err != nil {
+      t.Errorf("FAILED: Failed to join with an Invitation: %v", err)
+      return
+    }
+    if _, err := database.GetInvitation(private.RoomID, member.UserID); err == nil {
+      t.Errorf("FAILED: Invitation should be used up once joined")
+    }
+    if status, err := database.GetChatroomMemberStatus(private.RoomName, member.UserID); err != nil || *status != Member {
+      t.Errorf("FAILED: Got %v, %v Want Member", status, err)
+    }
+  })
+
+  t.Run("Save and Paginate Messages", func(t *testing.T){
+    now := time.Now()
+    for i := 0; i < 5; i++ {
+      msg := Message{
+        ID:        uuid.New(),
+        TimeStamp: now.Add(time.Duration(i) * time.Second),
+        UserID:    member.UserID,
+        Content:   "hello",
+      }
+      if err := database.SaveMessage(room.RoomName, &msg); err != nil {
+        t.Errorf("FAILED: Failed to save Message: %v", err)
+        return
+      }
+      if msg.Seq != uint64(i+1) {
+        t.Errorf("FAILED: Got Seq %d Want %d", msg.Seq, i+1)
+        return
+      }
+    }
+    if err := database.SaveMessage("missingroom", &Message{ TimeStamp: now }); err == nil {
+      t.Errorf("FAILED: Saved a Message to a Chatroom that doesn't exist")
+      return
+    }
+
+    cases := []struct{
+      name          string
+      before, after uint64
+      limit         int
+      want          []uint64
+      prev, next    string
+    }{
+      { "Latest",        0, 0, 2,  []uint64{ 4, 5 },       "4", ""  },
+      { "Before",        4, 0, 2,  []uint64{ 2, 3 },       "2", "3" },
+      { "After",         0, 3, 10, []uint64{ 4, 5 },       "4", ""  },
+      { "Between",       5, 1, 10, []uint64{ 2, 3, 4 },    "2", "4" },
+      { "Past the end",  0, 5, 10, []uint64{ },            "",  ""  },
+    }
+    for _, tc := range cases {
+      page, err := database.Paginate(room.RoomName, tc.before, tc.after, tc.limit)
+      if err != nil {
+        t.Errorf("FAILED: %s: Failed to Paginate: %v", tc.name, err)
+        continue
+      }
+      var got []uint64
+      for _, msg := range page.Messages {
+        got = append(got, msg.Seq)
+      }
+      if fmt.Sprint(got) != fmt.Sprint(tc.want) || page.PrevCursor != tc.prev || page.NextCursor != tc.next {
+        t.Errorf(
+          "FAILED: %s: Got %v prev=%q next=%q Want %v prev=%q next=%q",
+          tc.name, got, page.PrevCursor, page.NextCursor, tc.want, tc.prev, tc.next,
+        )
+      }
+    }
+  })
+
+  t.Run("Sequences are per Chatroom", func(t *testing.T){
+    // "memoryroom" is a prefix of "memoryroom2". Neither should see the other's Messages.
+    prefixed := Chatroom{ RoomID: uuid.New(), RoomName: "memoryroom2", OwnerID: owner.UserID, Public: true }
+    if err := database.SaveChatroom(&prefixed, false); err != nil {
+      t.Errorf("FAILED: Failed to create Chatroom: %v", err)
+      return
+    }
+
+    var raw []byte
+    enc := codec.NewEncoderBytes(&raw, &JSONHandle)
+    enc.Encode(ChatroomMessage{
+      Chatroom: prefixed.RoomName,
+      Message:  Message{ UserID: owner.UserID, Content: "first" },
+    })
+    stored, err := database.HandleRawMessage(prefixed.RoomName, owner.UserID, raw)
+    if err != nil {
+      t.Errorf("FAILED: Failed to handle raw Message: %v", err)
+      return
+    }
+    var got ChatroomMessage
+    if err := codec.NewDecoderBytes(stored, &JSONHandle).Decode(&got); err != nil {
+      t.Errorf("FAILED: Failed to decode stored Message: %v", err)
+      return
+    }
+    if got.Message.Seq != 1 || got.Message.ID == uuid.Nil {
+      t.Errorf("FAILED: Got Seq %d, ID %v Want Seq 1 and a new ID", got.Message.Seq, got.Message.ID)
+      return
+    }
+
+    page, err := database.Paginate(room.RoomName, 0, 0, MaxPageSize)
+    if err != nil {
+      t.Errorf("FAILED: Failed to Paginate: %v", err)
+      return
+    }
+    for _, msg := range page.Messages {
+      if msg.Content == "first" {
+        t.Errorf("FAILED: Message from \"%s\" bled into \"%s\"", prefixed.RoomName, room.RoomName)
+      }
+    }
+  })
+  t.Run("Search Messages", func(t *testing.T){
+    now := time.Now().Add(time.Minute)
+    saves := []struct{ room string; user User; content string }{
+      { room.RoomName, owner,  "Hello, World!" },
+      { room.RoomName, member, "world peace" },
+      { "memoryroom2", owner,  "HELLO world again" },
+    }
+    for i, s := range saves {
+      msg := Message{ ID: uuid.New(), TimeStamp: now.Add(time.Duration(i) * time.Second), UserID: s.user.UserID, Content: s.content }
+      if err := database.SaveMessage(s.room, &msg); err != nil {
+        t.Errorf("FAILED: Failed to save Message: %v", err)
+        return
+      }
+    }
+
+    cases := []struct{
+      name  string
+      query SearchQuery
+      want  []string
+    }{
+      { "Every term",  NewSearchQuery("world HELLO"), []string{ "HELLO world again", "Hello, World!" } },
+      { "Single term", NewSearchQuery("peace"),       []string{ "world peace" } },
+      { "Chatroom",    SearchQuery{ Terms: []string{ "world" }, Chatroom: room.RoomName }, []string{ "world peace", "Hello, World!" } },
+      { "User",        SearchQuery{ Terms: []string{ "world" }, UserID: owner.UserID },    []string{ "HELLO world again", "Hello, World!" } },
+      { "Before",      SearchQuery{ Terms: []string{ "world" }, Before: now.Add(time.Second) }, []string{ "Hello, World!" } },
+      { "After",       SearchQuery{ Terms: []string{ "world" }, After: now },              []string{ "HELLO world again", "world peace" } },
+      { "Chatrooms",   SearchQuery{ Terms: []string{ "world" }, Chatrooms: []RoomName{ "memoryroom2" } }, []string{ "HELLO world again" } },
+      { "No Chatrooms", SearchQuery{ Terms: []string{ "world" }, Chatrooms: []RoomName{} }, []string{ } },
+      { "Limit",       SearchQuery{ Terms: []string{ "world" }, Chatrooms: []RoomName{ room.RoomName, "memoryroom2" }, Limit: 2 }, []string{ "HELLO world again", "world peace" } },
+      { "No match",    NewSearchQuery("goodbye"),     []string{ } },
+      { "No terms",    NewSearchQuery("!"),           []string{ } },
+    }
+    for _, tc := range cases {
+      results, err := database.SearchMessages(tc.query)
+      if err != nil {
+        t.Errorf("FAILED: %s: Failed to search Messages: %v", tc.name, err)
+        continue
+      }
+      got := []string{}
+      for _, result := range results {
+        got = append(got, result.Message.Content)
+      }
+      if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+        t.Errorf("FAILED: %s: Got %q Want %q", tc.name, got, tc.want)
+      }
+    }
+  })
+
+  t.Run("Edit and Delete Messages", func(t *testing.T){
+    msg := Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: member.UserID, Content: "first draft" }
+    if err := database.SaveMessage(room.RoomName, &msg); err != nil {
+      t.Errorf("FAILED: Failed to save Message: %v", err)
+      return
+    }
+
+    edited, err := database.EditMessage(room.RoomName, msg.ID, member.UserID, "final version")
+    if err != nil {
+      t.Errorf("FAILED: Failed to edit Message: %v", err)
+      return
+    }
+    if edited.Content != "final version" || edited.EditedAt.IsZero() || edited.Seq != msg.Seq {
+      t.Errorf("FAILED: Got %+v Want edited Content with Seq %d", edited, msg.Seq)
+    }
+    got, err := database.GetMessage(room.RoomName, msg.ID)
+    if err != nil || got.Content != "final version" {
+      t.Errorf("FAILED: Got %+v, %v Want the edited Message", got, err)
+    }
+    if _, err := database.GetMessage("memoryroom2", msg.ID); err == nil {
+      t.Errorf("FAILED: Found a Message within the wrong Chatroom")
+    }
+
+    revisions, err := database.GetMessageRevisions(room.RoomName, msg.ID)
+    if err != nil || len(revisions) != 1 || revisions[0].Content != "first draft" || revisions[0].ReplacedBy != member.UserID {
+      t.Errorf("FAILED: Got %+v, %v Want a single \"first draft\" revision", revisions, err)
+    }
+    for term, want := range map[string]int{ "draft": 0, "final": 1 } {
+      results, err := database.SearchMessages(SearchQuery{ Terms: []string{ term }, Chatroom: room.RoomName })
+      if err != nil || len(results) != want {
+        t.Errorf("FAILED: Searching \"%s\" Got %d results, %v Want %d", term, len(results), err, want)
+      }
+    }
+
+    tombstone, err := database.DeleteMessage(room.RoomName, msg.ID)
+    if err != nil {
+      t.Errorf("FAILED: Failed to delete Message: %v", err)
+      return
+    }
+    if !tombstone.IsDeleted() || tombstone.Content != "" {
+      t.Errorf("FAILED: Got %+v Want a tombstone", tombstone)
+    }
+    page, err := database.Paginate(room.RoomName, 0, 0, 1)
+    if err != nil || len(page.Messages) != 1 || page.Messages[0].ID != msg.ID || !page.Messages[0].IsDeleted() {
+      t.Errorf("FAILED: Got %+v, %v Want the tombstone to keep it's place", page, err)
+    }
+    if revisions, _ := database.GetMessageRevisions(room.RoomName, msg.ID); len(revisions) != 0 {
+      t.Errorf("FAILED: Deleted Message kept %d revisions", len(revisions))
+    }
+    if results, _ := database.SearchMessages(NewSearchQuery("final")); len(results) != 0 {
+      t.Errorf("FAILED: Deleted Message is still searchable")
+    }
+
+    if _, err := database.EditMessage(room.RoomName, msg.ID, member.UserID, "again"); err == nil {
+      t.Errorf("FAILED: Edited a deleted Message")
+    } else if _, ok := err.(MessageDeletedError); !ok {
+      t.Errorf("FAILED: Got %T Want MessageDeletedError", err)
+    }
+    if _, err := database.DeleteMessage(room.RoomName, uuid.New()); err == nil {
+      t.Errorf("FAILED: Deleted a Message that doesn't exist")
+    }
+
+    // Clients don't get to pick IDs, or they could take over someone else's Message.
+    //    Nor TimeStamps, or a single one far in the future would hold up retention.
+    var raw []byte
+    future := time.Now().Add(24 * time.Hour)
+    codec.NewEncoderBytes(&raw, &JSONHandle).Encode(ChatroomMessage{
+      Chatroom: room.RoomName,
+      Message:  Message{ ID: msg.ID, UserID: owner.UserID, Content: "hijacked", TimeStamp: future },
+    })
+    stored, err := database.HandleRawMessage(room.RoomName, owner.UserID, raw)
+    if err != nil {
+      t.Errorf("FAILED: Failed to handle raw Message: %v", err)
+    }
+    var handled ChatroomMessage
+    codec.NewDecoderBytes(stored, &JSONHandle).Decode(&handled)
+    if !handled.Message.TimeStamp.Before(future) {
+      t.Errorf("FAILED: Got TimeStamp %v Want the server's own time", handled.Message.TimeStamp)
+    }
+    if got, err := database.GetMessage(room.RoomName, msg.ID); err != nil || !got.IsDeleted() {
+      t.Errorf("FAILED: Got %+v, %v Want the ID to still point at the tombstone", got, err)
+    }
+  })
+
+  t.Run("Threaded replies", func(t *testing.T){
+    root := Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: owner.UserID, Content: "thread root" }
+    if err := database.SaveMessage(room.RoomName, &root); err != nil {
+      t.Errorf("FAILED: Failed to save root Message: %v", err)
+      return
+    }
+
+    // Replies to replies still belong to the root's thread.
+    var replies []Message
+    parent := root.ID
+    for i := 0; i < 3; i++ {
+      reply := Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: member.UserID, Content: "reply", ReplyTo: parent }
+      if err := database.SaveMessage(room.RoomName, &reply); err != nil {
+        t.Errorf("FAILED: Failed to save reply: %v", err)
+        return
+      }
+      if reply.ThreadID != root.ID {
+        t.Errorf("FAILED: Got ThreadID %v Want %v", reply.ThreadID, root.ID)
+      }
+      replies = append(replies, reply)
+      parent = reply.ID
+    }
+    // Not part of the thread.
+    other := Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: owner.UserID, Content: "unrelated" }
+    if err := database.SaveMessage(room.RoomName, &other); err != nil {
+      t.Errorf("FAILED: Failed to save Message: %v", err)
+      return
+    }
+
+    page, err := database.PaginateThread(room.RoomName, root.ID, 0, 0, 2)
+    if err != nil {
+      t.Errorf("FAILED: Failed to Paginate thread: %v", err)
+      return
+    }
+    if len(page.Messages) != 2 || page.Messages[1].ID != replies[2].ID || page.PrevCursor == "" || page.NextCursor != "" {
+      t.Errorf("FAILED: Got %+v Want the latest 2 replies, with older replies left", page)
+      return
+    }
+    before, _ := DecodeCursor(page.PrevCursor)
+    page, err = database.PaginateThread(room.RoomName, root.ID, before, 0, 2)
+    if err != nil || len(page.Messages) != 1 || page.Messages[0].ID != replies[0].ID || page.PrevCursor != "" {
+      t.Errorf("FAILED: Got %+v, %v Want only the first reply", page, err)
+    }
+
+    if page, err := database.PaginateThread(room.RoomName, other.ID, 0, 0, 10); err != nil || len(page.Messages) != 0 {
+      t.Errorf("FAILED: Got %+v, %v Want an empty thread", page, err)
+    }
+
+    orphan := Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: member.UserID, ReplyTo: uuid.New() }
+    if err := database.SaveMessage(room.RoomName, &orphan); err == nil {
+      t.Errorf("FAILED: Saved a reply to a Message that doesn't exist")
+    }
+  })
+
+  t.Run("Reactions", func(t *testing.T){
+    msg := Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: owner.UserID, Content: "ship it?" }
+    if err := database.SaveMessage(room.RoomName, &msg); err != nil {
+      t.Errorf("FAILED: Failed to save Message: %v", err)
+      return
+    }
+
+    steps := []struct{
+      name     string
+      add      bool
+      user     User
+      reaction string
+      want     string
+    }{
+      { "First reaction",    true,  member, "+1", "[+1:1]"      },
+      { "Reacting twice",    true,  member, "+1", "[+1:1]"      },
+      { "Second reactor",    true,  owner,  "+1", "[+1:2]"      },
+      { "Second reaction",   true,  member, "🚀", "[+1:2 🚀:1]" },
+      { "Remove reaction",   false, member, "+1", "[+1:1 🚀:1]" },
+      { "Remove missing",    false, member, "+1", "[+1:1 🚀:1]" },
+      { "Last reactor gone", false, owner,  "+1", "[🚀:1]"      },
+    }
+    for _, step := range steps {
+      var got *Message
+      var err error
+      if step.add {
+        got, err = database.AddReaction(room.RoomName, msg.ID, step.user.UserID, step.reaction)
+      } else {
+        got, err = database.RemoveReaction(room.RoomName, msg.ID, step.user.UserID, step.reaction)
+      }
+      if err != nil {
+        t.Errorf("FAILED: %s: %v", step.name, err)
+        return
+      }
+      var summary []string
+      for _, r := range got.Reactions {
+        summary = append(summary, fmt.Sprintf("%s:%d", r.Reaction, r.Count))
+      }
+      if fmt.Sprint(summary) != step.want {
+        t.Errorf("FAILED: %s: Got %v Want %s", step.name, summary, step.want)
+      }
+    }
+
+    page, err := database.Paginate(room.RoomName, 0, 0, 1)
+    if err != nil || len(page.Messages) != 1 || page.Messages[0].ReactionCount("🚀") != 1 {
+      t.Errorf("FAILED: Got %+v, %v Want Reactions to be returned with the Message", page, err)
+    }
+
+    if _, err := database.DeleteMessage(room.RoomName, msg.ID); err != nil {
+      t.Errorf("FAILED: Failed to delete Message: %v", err)
+      return
+    }
+    if _, err := database.AddReaction(room.RoomName, msg.ID, member.UserID, "+1"); err == nil {
+      t.Errorf("FAILED: Reacted to a deleted Message")
+    }
+    if got, err := database.GetMessage(room.RoomName, msg.ID); err != nil || len(got.Reactions) != 0 {
+      t.Errorf("FAILED: Got %+v, %v Want a tombstone without Reactions", got, err)
+    }
+  })
+
+  t.Run("Read markers and Joined Chatrooms", func(t *testing.T){
+    reader := User{ UserID: uuid.New(), Username: "reader", HashedPassword: []byte("hash") }
+    if err := database.SaveUser(reader, nil); err != nil {
+      t.Errorf("FAILED: Failed to save User: %v", err)
+      return
+    }
+    unread := Chatroom{ RoomID: uuid.New(), RoomName: "unread", OwnerID: owner.UserID, Public: true }
+    if err := database.SaveChatroom(&unread, false); err != nil {
+      t.Errorf("FAILED: Failed to create Chatroom: %v", err)
+      return
+    }
+    if err := database.JoinChatroom(unread.RoomName, reader.Username); err != nil {
+      t.Errorf("FAILED: Failed to join Chatroom: %v", err)
+      return
+    }
+    var news []UUID
+    for i := 0; i < 5; i++ {
+      msg := Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: owner.UserID, Content: "news" }
+      if err := database.SaveMessage(unread.RoomName, &msg); err != nil {
+        t.Errorf("FAILED: Failed to save Message: %v", err)
+        return
+      }
+      news = append(news, msg.ID)
+    }
+    if _, err := database.DeleteMessage(unread.RoomName, news[4]); err != nil {
+      t.Errorf("FAILED: Failed to delete Message: %v", err)
+      return
+    }
+
+    summary := func() string {
+      rooms, err := database.GetJoinedChatrooms(reader.UserID)
+      if err != nil {
+        return err.Error()
+      }
+      return fmt.Sprint(rooms)
+    }
+    want := func(lastRead, unreadCount uint64) string {
+      return fmt.Sprint([]JoinedChatroom{{ Chatroom: unread.RoomName, MemberType: Member, LastRead: lastRead, Unread: unreadCount }})
+    }
+
+    if got := summary(); got != want(0, 4) {
+      t.Errorf("FAILED: Got %s Want %s", got, want(0, 4))
+    }
+    steps := []struct{
+      name       string
+      seq, want  uint64
+    }{
+      { "Read up to 2",              2, 2 },
+      { "Never moves backwards",     1, 2 },
+      { "Past the end is clamped", 100, 5 },
+    }
+    for _, step := range steps {
+      marker, err := database.MarkRead(unread.RoomName, reader.UserID, step.seq)
+      if err != nil || marker != step.want {
+        t.Errorf("FAILED: %s: Got %d, %v Want %d", step.name, marker, err, step.want)
+      }
+    }
+    if got := summary(); got != want(5, 0) {
+      t.Errorf("FAILED: Got %s Want %s", got, want(5, 0))
+    }
+
+    if _, err := database.MarkRead(room.RoomName, reader.UserID, 0); err == nil {
+      t.Errorf("FAILED: Marked a Chatroom as read without being a Member")
+    }
+    if err := database.SaveChatroomMember(unread.RoomName, reader.UserID, Blocked); err != nil {
+      t.Errorf("FAILED: Failed to block Member: %v", err)
+      return
+    }
+    if got := summary(); got != "[]" {
+      t.Errorf("FAILED: Got %s Want Blocked Chatrooms left out", got)
+    }
+  })
+
+  t.Run("Direct Chatrooms", func(t *testing.T){
+    dm, err := database.OpenDirectChatroom(owner.UserID, member.UserID)
+    if err != nil {
+      t.Errorf("FAILED: Failed to open Direct Chatroom: %v", err)
+      return
+    }
+    again, err := database.OpenDirectChatroom(member.UserID, owner.UserID)
+    if err != nil || again.RoomID != dm.RoomID {
+      t.Errorf("FAILED: Got %+v, %v Want the same Direct Chatroom %+v", again, err, dm)
+      return
+    }
+    if !dm.IsDirect() || dm.Public || dm.DirectPeer(member.UserID) != owner.UserID {
+      t.Errorf("FAILED: Got %+v Want a private Direct Chatroom between both Users", dm)
+    }
+
+    for _, id := range []UUID{ owner.UserID, member.UserID } {
+      status, err := database.GetChatroomMemberStatus(dm.RoomName, id)
+      if err != nil || *status != Member {
+        t.Errorf("FAILED: Got %v, %v Want both Users to be Members", status, err)
+      }
+    }
+    outsider := User{ UserID: uuid.New(), Username: "outsider", HashedPassword: []byte("hash") }
+    if err := database.SaveUser(outsider, nil); err != nil {
+      t.Errorf("FAILED: Failed to save User: %v", err)
+      return
+    }
+    if err := database.JoinChatroom(dm.RoomName, outsider.Username); err == nil {
+      t.Errorf("FAILED: A third User joined a Direct Chatroom")
+    }
+
+    rooms, err := database.GetJoinedChatrooms(member.UserID)
+    if err != nil {
+      t.Errorf("FAILED: Failed to get joined Chatrooms: %v", err)
+      return
+    }
+    found := false
+    for _, r := range rooms {
+      if r.Chatroom == dm.RoomName {
+        found = r.PeerID == owner.UserID
+      } else if r.PeerID != (UUID{}) {
+        t.Errorf("FAILED: Got PeerID %v for \"%s\" Want none", r.PeerID, r.Chatroom)
+      }
+    }
+    if !found {
+      t.Errorf("FAILED: Got %+v Want \"%s\" with PeerID %v", rooms, dm.RoomName, owner.UserID)
+    }
+  })
+
+  t.Run("Public Chatrooms", func(t *testing.T){
+    hidden := Chatroom{ RoomID: uuid.New(), RoomName: "hiddenroom", OwnerID: owner.UserID }
+    closed := Chatroom{ RoomID: uuid.New(), RoomName: "closedroom", OwnerID: owner.UserID, Public: true }
+    for _, cr := range []*Chatroom{ &hidden, &closed } {
+      if err := database.SaveChatroom(cr, false); err != nil {
+        t.Errorf("FAILED: Failed to create Chatroom: %v", err)
+        return
+      }
+    }
+    if err := database.DeactivateChatroom(closed.RoomName, owner.UserID); err != nil {
+      t.Errorf("FAILED: Failed to deactivate Chatroom: %v", err)
+      return
+    }
+
+    directory, err := database.GetChatroomDirectory()
+    if err != nil {
+      t.Errorf("FAILED: Failed to get the Chatroom Directory: %v", err)
+      return
+    }
+    var names []string
+    for _, entry := range directory {
+      names = append(names, entry.RoomName)
+    }
+    sort.Strings(names)
+    if got, want := fmt.Sprint(names), fmt.Sprint([]string{ "memoryroom", "memoryroom2", "unread" }); got != want {
+      t.Errorf("FAILED: Got %s Want %s", got, want)
+    }
+    for _, entry := range directory {
+      members, _ := database.GetChatroomMembers(entry.RoomName)
+      latest, _ := database.Paginate(entry.RoomName, 0, 0, 1)
+      if entry.MemberCount != CountMembers(members) || len(latest.Messages) == 0 || !entry.LastActivity.Equal(latest.Messages[0].TimeStamp) {
+        t.Errorf("FAILED: Got %+v Want %d Members and the newest Message's TimeStamp", entry, CountMembers(members))
+      }
+      single, err := database.GetDirectoryEntry(entry.RoomName)
+      if err != nil || fmt.Sprint(*single) != fmt.Sprint(entry) {
+        t.Errorf("FAILED: Got %+v, %v Want %+v", single, err, entry)
+      }
+    }
+    if entry, err := database.GetDirectoryEntry(hidden.RoomName); err != nil || entry.RoomID != hidden.RoomID {
+      t.Errorf("FAILED: Got %+v, %v Want an entry for a private Chatroom", entry, err)
+    }
+    if _, err := database.GetDirectoryEntry(closed.RoomName); err == nil {
+      t.Errorf("FAILED: Got an entry for a deactivated Chatroom")
+    }
+  })
+
+  t.Run("Invite links", func(t *testing.T){
+    private, err := database.GetChatroom("privateroom")
+    if err != nil {
+      t.Errorf("FAILED: Failed to get Chatroom: %v", err)
+      return
+    }
+    link, err := NewInviteLink(private, owner.UserID, time.Hour, 2)
+    if err != nil {
+      t.Errorf("FAILED: Failed to create InviteLink: %v", err)
+      return
+    }
+    expired, _ := NewInviteLink(private, owner.UserID, time.Hour, 0)
+    expired.ExpiresAt = time.Now().Add(-time.Minute)
+    for _, l := range []*InviteLink{ &link, &expired } {
+      if err := database.SaveInviteLink(l); err != nil {
+        t.Errorf("FAILED: Failed to save InviteLink: %v", err)
+        return
+      }
+    }
+
+    var linked []User
+    for _, name := range []string{ "linked1", "linked2", "linked3" } {
+      user := User{ UserID: uuid.New(), Username: name, HashedPassword: []byte("hash") }
+      if err := database.SaveUser(user, nil); err != nil {
+        t.Errorf("FAILED: Failed to save User: %v", err)
+        return
+      }
+      linked = append(linked, user)
+    }
+
+    if _, err := database.RedeemInviteLink(link.Code, owner.UserID); err == nil {
+      t.Errorf("FAILED: The Owner redeemed an InviteLink to their own Chatroom")
+    } else if _, ok := err.(AlreadyMemberError); !ok {
+      t.Errorf("FAILED: Got %T Want AlreadyMemberError", err)
+    }
+    for i := 1; i <= 2; i++ {
+      got, err := database.RedeemInviteLink(link.Code, linked[i-1].UserID)
+      if err != nil || got.Uses != i || got.Chatroom != private.RoomName {
+        t.Errorf("FAILED: Redeem %d Got %+v, %v Want %d uses of \"%s\"", i, got, err, i, private.RoomName)
+      }
+      if status, err := database.GetChatroomMemberStatus(private.RoomName, linked[i-1].UserID); err != nil || *status != Member {
+        t.Errorf("FAILED: Got %v, %v Want \"%s\" to have joined", status, err, linked[i-1].Username)
+      }
+    }
+    if _, err := database.RedeemInviteLink(link.Code, linked[2].UserID); err == nil {
+      t.Errorf("FAILED: Redeemed an InviteLink past it's MaxUses")
+    } else if _, ok := err.(FailedSecurityCheckError); !ok {
+      t.Errorf("FAILED: Got %T Want FailedSecurityCheckError", err)
+    }
+    if got, err := database.GetInviteLink(link.Code); err != nil || got.Uses != 2 || got.Usable(time.Now()) {
+      t.Errorf("FAILED: Got %+v, %v Want a used up InviteLink", got, err)
+    }
+    if _, err := database.RedeemInviteLink(expired.Code, linked[2].UserID); err == nil {
+      t.Errorf("FAILED: Redeemed an expired InviteLink")
+    }
+    if _, err := database.GetChatroomMemberStatus(private.RoomName, linked[2].UserID); err == nil {
+      t.Errorf("FAILED: Joined through a used up InviteLink")
+    }
+
+    // A full Chatroom doesn't use up the InviteLink.
+    full := Chatroom{ RoomID: uuid.New(), RoomName: "fullroom", OwnerID: owner.UserID, MaxMembers: 1 }
+    if err := database.SaveChatroom(&full, false); err != nil {
+      t.Errorf("FAILED: Failed to create Chatroom: %v", err)
+      return
+    }
+    fullLink, _ := NewInviteLink(&full, owner.UserID, time.Hour, 0)
+    if err := database.SaveInviteLink(&fullLink); err != nil {
+      t.Errorf("FAILED: Failed to save InviteLink: %v", err)
+      return
+    }
+    if _, err := database.RedeemInviteLink(fullLink.Code, linked[2].UserID); err == nil {
+      t.Errorf("FAILED: Redeemed an InviteLink into a full Chatroom")
+    } else if _, ok := err.(ChatroomFullError); !ok {
+      t.Errorf("FAILED: Got %T Want ChatroomFullError", err)
+    }
+    if got, err := database.GetInviteLink(fullLink.Code); err != nil || got.Uses != 0 {
+      t.Errorf("FAILED: Got %+v, %v Want the InviteLink left unused", got, err)
+    }
+
+    // Redeeming all at once can't overshoot the InviteLink's MaxUses, or the
+    // Chatroom's MaxMembers.
+    crowded := Chatroom{ RoomID: uuid.New(), RoomName: "crowdedroom", OwnerID: owner.UserID, MaxMembers: 4 }
+    if err := database.SaveChatroom(&crowded, false); err != nil {
+      t.Errorf("FAILED: Failed to create Chatroom: %v", err)
+      return
+    }
+    for _, maxUses := range []int{ 2, 0 } {
+      crowdLink, _ := NewInviteLink(&crowded, owner.UserID, time.Hour, maxUses)
+      if err := database.SaveInviteLink(&crowdLink); err != nil {
+        t.Errorf("FAILED: Failed to save InviteLink: %v", err)
+        return
+      }
+      var wg sync.WaitGroup
+      for i := 0; i < 6; i++ {
+        user := User{ UserID: uuid.New(), Username: fmt.Sprintf("crowd%d-%d", maxUses, i), HashedPassword: []byte("hash") }
+        if err := database.SaveUser(user, nil); err != nil {
+          t.Errorf("FAILED: Failed to save User: %v", err)
+          return
+        }
+        wg.Add(1)
+        go func(){
+          defer wg.Done()
+          database.RedeemInviteLink(crowdLink.Code, user.UserID)
+        }()
+      }
+      wg.Wait()
+    }
+    if members, err := database.GetChatroomMembers(crowded.RoomName); err != nil || CountMembers(members) != 4 {
+      t.Errorf("FAILED: Got %d Members, %v Want the Owner, 2 from the first InviteLink, and 1 more", len(members), err)
+    }
+    if _, err := database.GetInviteLink("nosuchcode"); err == nil {
+      t.Errorf("FAILED: Expected GetDataError for an unknown code")
+    } else if _, ok := err.(GetDataError); !ok {
+      t.Errorf("FAILED: Got %T Want GetDataError", err)
+    }
+  })
+
+  t.Run("Bans and removing Members", func(t *testing.T){
+    moderated := Chatroom{ RoomID: uuid.New(), RoomName: "moderated", OwnerID: owner.UserID, Public: true }
+    if err := database.SaveChatroom(&moderated, false); err != nil {
+      t.Errorf("FAILED: Failed to create Chatroom: %v", err)
+      return
+    }
+    for _, username := range []string{ owner.Username, member.Username } {
+      if err := database.JoinChatroom(moderated.RoomName, username); err != nil {
+        t.Errorf("FAILED: \"%s\" Failed to join Chatroom: %v", username, err)
+        return
+      }
+    }
+    if status, err := database.GetChatroomMemberStatus(moderated.RoomName, owner.UserID); err != nil || *status != Owner {
+      t.Errorf("FAILED: Got %v, %v Want rejoining to leave the Owner an Owner", status, err)
+    }
+
+    if err := database.SaveChatroomMember(moderated.RoomName, member.UserID, Blocked); err != nil {
+      t.Errorf("FAILED: Failed to block Member: %v", err)
+      return
+    }
+    if err := database.JoinChatroom(moderated.RoomName, member.Username); err == nil {
+      t.Errorf("FAILED: A Blocked User rejoined a public Chatroom")
+    } else if _, ok := err.(FailedSecurityCheckError); !ok {
+      t.Errorf("FAILED: Got %T Want FailedSecurityCheckError", err)
+    }
+
+    if err := database.SaveChatroomMember(moderated.RoomName, member.UserID, Member); err != nil {
+      t.Errorf("FAILED: Failed to unblock Member: %v", err)
+      return
+    }
+    if _, err := database.MarkRead(moderated.RoomName, member.UserID, 0); err != nil {
+      t.Errorf("FAILED: Failed to mark Chatroom read: %v", err)
+    }
+    if err := database.RemoveChatroomMember(moderated.RoomName, member.UserID); err != nil {
+      t.Errorf("FAILED: Failed to remove Member: %v", err)
+      return
+    }
+    if _, err := database.GetChatroomMemberStatus(moderated.RoomName, member.UserID); err == nil {
+      t.Errorf("FAILED: Removed Member is still a Member")
+    }
+    joined, err := database.GetJoinedChatrooms(member.UserID)
+    if err != nil {
+      t.Errorf("FAILED: Failed to get Joined Chatrooms: %v", err)
+    }
+    for _, jc := range joined {
+      if jc.Chatroom == moderated.RoomName {
+        t.Errorf("FAILED: Removed Member still lists \"%s\" as joined", moderated.RoomName)
+      }
+    }
+    if err := database.RemoveChatroomMember(moderated.RoomName, member.UserID); err == nil {
+      t.Errorf("FAILED: Expected GetDataError removing a non-Member")
+    } else if _, ok := err.(GetDataError); !ok {
+      t.Errorf("FAILED: Got %T Want GetDataError", err)
+    }
+
+    if err := database.JoinChatroom(moderated.RoomName, member.Username); err != nil {
+      t.Errorf("FAILED: Removed Member Failed to rejoin: %v", err)
+    }
+  })
+
+  t.Run("Roles", func(t *testing.T){
+    roles, err := database.GetChatroomRoles("moderated")
+    if err != nil {
+      t.Errorf("FAILED: Failed to get Roles: %v", err)
+      return
+    }
+    var names []string
+    for _, role := range roles {
+      names = append(names, role.Name)
+    }
+    if got, want := fmt.Sprint(names), fmt.Sprint([]string{ OwnerRole, ModeratorRole, MemberRole }); got != want {
+      t.Errorf("FAILED: Got %s Want %s", got, want)
+    }
+
+    helper := Role{ Name: "helper", Permissions: PermPost | PermPin, Rank: 50 }
+    if err := database.SaveChatroomRole("moderated", &helper); err != nil {
+      t.Errorf("FAILED: Failed to save Role: %v", err)
+      return
+    }
+    if err := database.SaveChatroomRole("moderated", &Role{ Name: OwnerRole, Permissions: PermPost, Rank: 1 }); err == nil {
+      t.Errorf("FAILED: Changed the Owner's Role")
+    }
+    if err := database.SetChatroomMemberRole("moderated", member.UserID, "nosuchrole"); err == nil {
+      t.Errorf("FAILED: Handed out a Role that doesn't exist")
+    }
+    if err := database.SetChatroomMemberRole("moderated", member.UserID, helper.Name); err != nil {
+      t.Errorf("FAILED: Failed to hand out Role: %v", err)
+      return
+    }
+    got, err := database.GetChatroomMember("moderated", member.UserID)
+    if err != nil || got.Role != helper || got.MemberType != Member {
+      t.Errorf("FAILED: Got %+v, %v Want a Member holding %+v", got, err, helper)
+    }
+    if err := Authorize(got, PermPin, nil); err != nil {
+      t.Errorf("FAILED: helper can't pin: %v", err)
+    }
+    if err := Authorize(got, PermInvite, nil); err == nil {
+      t.Errorf("FAILED: helper can invite")
+    }
+    if err := database.SaveChatroom(&Chatroom{ RoomID: uuid.New(), RoomName: "moderated", OwnerID: member.UserID }, true); err == nil {
+      t.Errorf("FAILED: helper changed the Chatroom's settings")
+    }
+
+    if err := database.DeleteChatroomRole("moderated", MemberRole); err == nil {
+      t.Errorf("FAILED: Deleted a default Role")
+    }
+    if err := database.DeleteChatroomRole("moderated", helper.Name); err != nil {
+      t.Errorf("FAILED: Failed to delete Role: %v", err)
+    }
+    if got, err := database.GetChatroomMember("moderated", member.UserID); err != nil || got.Role.Name != MemberRole {
+      t.Errorf("FAILED: Got %+v, %v Want helpers to fall back onto \"%s\"", got, err, MemberRole)
+    }
+
+    muted := Role{ Name: "muted", Rank: 200 }
+    if err := database.SaveChatroomRole("moderated", &muted); err != nil {
+      t.Errorf("FAILED: Failed to save Role: %v", err)
+      return
+    }
+    if err := database.SetChatroomMemberRole("moderated", member.UserID, muted.Name); err != nil {
+      t.Errorf("FAILED: Failed to hand out Role: %v", err)
+    }
+    var raw []byte
+    codec.NewEncoderBytes(&raw, &JSONHandle).Encode(ChatroomMessage{
+      Chatroom: "moderated",
+      Message:  Message{ UserID: member.UserID, Content: "can't post" },
+    })
+    if _, err := database.HandleRawMessage("moderated", member.UserID, raw); err == nil {
+      t.Errorf("FAILED: A Member without %s posted a Message", PermPost)
+    } else if _, ok := err.(FailedSecurityCheckError); !ok {
+      t.Errorf("FAILED: Got %T Want FailedSecurityCheckError", err)
+    }
+
+    // The connection decides who's posting, and where. Not the Message.
+    for _, tc := range []struct{
+      name     string
+      chatroom string
+      userID   UUID
+    }{
+      { "posted as the Owner",          "moderated",   owner.UserID },
+      { "posted into another Chatroom", room.RoomName, member.UserID },
+    } {
+      var spoofed []byte
+      codec.NewEncoderBytes(&spoofed, &JSONHandle).Encode(ChatroomMessage{
+        Chatroom: tc.chatroom,
+        Message:  Message{ UserID: tc.userID, Content: "spoofed" },
+      })
+      if _, err := database.HandleRawMessage("moderated", member.UserID, spoofed); err == nil {
+        t.Errorf("FAILED: A Member %s", tc.name)
+      } else if _, ok := err.(FailedSecurityCheckError); !ok {
+        t.Errorf("FAILED: %s: Got %T Want FailedSecurityCheckError", tc.name, err)
+      }
+    }
+
+    if err := database.SetChatroomMemberRole("moderated", member.UserID, ModeratorRole); err != nil {
+      t.Errorf("FAILED: Failed to promote Member: %v", err)
+    }
+    if status, err := database.GetChatroomMemberStatus("moderated", member.UserID); err != nil || *status != Moderator {
+      t.Errorf("FAILED: Got %v, %v Want the MemberType to follow the Role", status, err)
+    }
+    if err := database.DeactivateChatroom("moderated", member.UserID); err == nil {
+      t.Errorf("FAILED: A Moderator deactivated the Chatroom")
+    } else if _, ok := err.(FailedSecurityCheckError); !ok {
+      t.Errorf("FAILED: Got %T Want FailedSecurityCheckError", err)
+    }
+  })
+
+  t.Run("Chatroom settings", func(t *testing.T){
+    settingsroom := Chatroom{ RoomID: uuid.New(), RoomName: "settingsroom", OwnerID: owner.UserID, Public: true }
+    if err := database.SaveChatroom(&settingsroom, false); err != nil {
+      t.Errorf("FAILED: Failed to create Chatroom: %v", err)
+      return
+    }
+    if err := database.JoinChatroom(settingsroom.RoomName, member.Username); err != nil {
+      t.Errorf("FAILED: Failed to join Chatroom: %v", err)
+      return
+    }
+
+    topic, maxMembers, readOnly := "announcements only", 2, true
+    settings := ChatroomSettings{ Topic: &topic, MaxMembers: &maxMembers, ReadOnly: &readOnly }
+    if _, err := database.UpdateChatroomSettings(settingsroom.RoomName, member.UserID, &settings); err == nil {
+      t.Errorf("FAILED: A Member changed the Chatroom's settings")
+    } else if _, ok := err.(FailedSecurityCheckError); !ok {
+      t.Errorf("FAILED: Got %T Want FailedSecurityCheckError", err)
+    }
+    if _, err := database.UpdateChatroomSettings(settingsroom.RoomName, owner.UserID, &settings); err != nil {
+      t.Errorf("FAILED: Failed to update settings: %v", err)
+      return
+    }
+    slowMode := -1
+    if _, err := database.UpdateChatroomSettings(settingsroom.RoomName, owner.UserID, &ChatroomSettings{ SlowMode: &slowMode }); err == nil {
+      t.Errorf("FAILED: Saved a negative slow mode")
+    }
+    got, err := database.GetChatroom(settingsroom.RoomName)
+    if err != nil || got.Topic != topic || got.MaxMembers != maxMembers || !got.ReadOnly || !got.Public || got.SlowMode != 0 {
+      t.Errorf("FAILED: Got %+v, %v Want the updated settings", got, err)
+    }
+
+    latecomer := User{ UserID: uuid.New(), Username: "latecomer", HashedPassword: []byte("hash") }
+    if err := database.SaveUser(latecomer, nil); err != nil {
+      t.Errorf("FAILED: Failed to save User: %v", err)
+      return
+    }
+    if err := database.JoinChatroom(settingsroom.RoomName, latecomer.Username); err == nil {
+      t.Errorf("FAILED: Joined a full Chatroom")
+    } else if _, ok := err.(ChatroomFullError); !ok {
+      t.Errorf("FAILED: Got %T Want ChatroomFullError", err)
+    }
+
+    // claimed is who the Message says it's from. A Member can't borrow the Owner's
+    // right to post by claiming to be them.
+    for _, tc := range []struct{
+      userID  UUID
+      claimed UUID
+      allowed bool
+    }{
+      { member.UserID, member.UserID, false },
+      { member.UserID, owner.UserID,  false },
+      { owner.UserID,  owner.UserID,  true },
+    } {
+      var raw []byte
+      codec.NewEncoderBytes(&raw, &JSONHandle).Encode(ChatroomMessage{
+        Chatroom: settingsroom.RoomName,
+        Message:  Message{ UserID: tc.claimed, Content: "announcement" },
+      })
+      if _, err := database.HandleRawMessage(settingsroom.RoomName, tc.userID, raw); (err == nil) != tc.allowed {
+        t.Errorf("FAILED: Got %v Want allowed=%v within a read-only Chatroom", err, tc.allowed)
+      }
+    }
+  })
+
+  t.Run("Retention", func(t *testing.T){
+    retention := Chatroom{ RoomID: uuid.New(), RoomName: "retention", OwnerID: owner.UserID }
+    if err := database.SaveChatroom(&retention, false); err != nil {
+      t.Errorf("FAILED: Failed to create Chatroom: %v", err)
+      return
+    }
+    now := time.Now()
+    msgs := []Message{
+      { ID: uuid.New(), TimeStamp: now.Add(-3*time.Hour), UserID: owner.UserID, Content: "ancient one" },
+      { ID: uuid.New(), TimeStamp: now.Add(-3*time.Hour), UserID: owner.UserID, Content: "ancient two" },
+      { ID: uuid.New(), TimeStamp: now.Add(-2*time.Hour), UserID: owner.UserID, Content: "ancient three" },
+      { ID: uuid.New(), TimeStamp: now, UserID: owner.UserID, Content: "recent four" },
+      { ID: uuid.New(), TimeStamp: now, UserID: owner.UserID, Content: "recent five" },
+    }
+    msgs[2].ReplyTo = msgs[0].ID
+    for i := range msgs {
+      if err := database.SaveMessage(retention.RoomName, &msgs[i]); err != nil {
+        t.Errorf("FAILED: Failed to save Message: %v", err)
+        return
+      }
+    }
+
+    byAge := Retention{ MaxAge: time.Hour }
+    for _, want := range []int{ 2, 1, 0 } {
+      if purged, err := database.PurgeMessages(retention.RoomName, byAge, now, 2); err != nil || purged != want {
+        t.Errorf("FAILED: Got %d, %v Want %d Messages purged", purged, err, want)
+      }
+      // The root goes with the first batch, and takes it's thread along, even
+      //    though the reply is still there.
+      if want == 2 {
+        thread, err := database.PaginateThread(retention.RoomName, msgs[0].ID, 0, 0, 10)
+        if err != nil || len(thread.Messages) != 0 {
+          t.Errorf("FAILED: Got %+v, %v Want the purged root's thread gone", thread, err)
+        }
+      }
+    }
+    if _, err := database.GetMessage(retention.RoomName, msgs[0].ID); err == nil {
+      t.Errorf("FAILED: Purged Message is still there")
+    }
+    if results, err := database.SearchMessages(SearchQuery{ Terms: []string{ "ancient" }, Chatroom: retention.RoomName }); err != nil || len(results) != 0 {
+      t.Errorf("FAILED: Got %d results, %v Want purged Messages out of the search index", len(results), err)
+    }
+    page, err := database.Paginate(retention.RoomName, 0, 0, 10)
+    if err != nil || len(page.Messages) != 2 || page.Messages[0].Seq != 4 || page.PrevCursor != "" {
+      t.Errorf("FAILED: Got %+v, %v Want only Messages 4 and 5", page, err)
+    }
+
+    if purged, err := database.PurgeMessages(retention.RoomName, Retention{ MaxMessages: 1 }, now, 10); err != nil || purged != 1 {
+      t.Errorf("FAILED: Got %d, %v Want 1 Message purged", purged, err)
+    }
+    if purged, err := database.PurgeMessages(retention.RoomName, byAge, now.Add(2*time.Hour), 10); err != nil || purged != 1 {
+      t.Errorf("FAILED: Got %d, %v Want the last Message purged", purged, err)
+    }
+    next := Message{ ID: uuid.New(), TimeStamp: now, UserID: owner.UserID, Content: "after the purge" }
+    if err := database.SaveMessage(retention.RoomName, &next); err != nil || next.Seq != 6 {
+      t.Errorf("FAILED: Got seq %d, %v Want seq numbers to keep climbing", next.Seq, err)
+    }
+  })
+
+  t.Run("Deactivate and reactivate Users", func(t *testing.T){
+    ghost := User{ UserID: uuid.New(), Username: "ghost", HashedPassword: []byte("hash") }
+    if err := database.SaveUser(ghost, &token.Token{ Token: "ghost-token" }); err != nil {
+      t.Errorf("FAILED: Failed to save User: %v", err)
+      return
+    }
+    if err := database.DeactivateUser(ghost.UserID); err != nil {
+      t.Errorf("FAILED: Failed to deactivate User: %v", err)
+      return
+    }
+    if _, err := database.GetUserByID(ghost.UserID); err == nil {
+      t.Errorf("FAILED: Deactivated User is still visible by ID")
+    }
+    if _, err := database.GetUserbyUsername(ghost.Username); err == nil {
+      t.Errorf("FAILED: Deactivated User is still visible by Username")
+    }
+    if _, err := database.GetUserToken(ghost.UserID); err == nil {
+      t.Errorf("FAILED: Deactivated User's Access Token wasn't revoked")
+    }
+    if got, err := database.GetDeactivatedUser(ghost.Username); err != nil || got.UserID != ghost.UserID {
+      t.Errorf("FAILED: Got %+v, %v Want the deactivated User", got, err)
+    }
+    if err := database.SaveUser(User{ UserID: uuid.New(), Username: ghost.Username }, nil); err == nil {
+      t.Errorf("FAILED: Got nil Want the Username to stay reserved")
+    }
+
+    if err := database.ActivateUser(ghost.UserID); err != nil {
+      t.Errorf("FAILED: Failed to reactivate User: %v", err)
+      return
+    }
+    if got, err := database.GetUserbyUsername(ghost.Username); err != nil || got.UserID != ghost.UserID {
+      t.Errorf("FAILED: Got %+v, %v Want the reactivated User", got, err)
+    }
+    if _, err := database.GetDeactivatedUser(ghost.Username); err == nil {
+      t.Errorf("FAILED: Reactivated User is still deactivated")
+    }
+  })
+
+  t.Run("Change passwords and Password Resets", func(t *testing.T){
+    forgetful := User{ UserID: uuid.New(), Username: "forgetful", HashedPassword: []byte("old") }
+    if err := database.SaveUser(forgetful, &token.Token{ Token: "old-token" }); err != nil {
+      t.Errorf("FAILED: Failed to save User: %v", err)
+      return
+    }
+
+    stale, staleToken, err := NewPasswordReset(forgetful.UserID, 0)
+    if err != nil {
+      t.Errorf("FAILED: Failed to create PasswordReset: %v", err)
+      return
+    }
+    reset, resetToken, _ := NewPasswordReset(forgetful.UserID, time.Minute)
+    expired, expiredToken, _ := NewPasswordReset(owner.UserID, time.Minute)
+    expired.ExpiresAt = time.Now().Add(-time.Second)
+    for _, r := range []*PasswordReset{ &stale, &reset, &expired } {
+      if err := database.SavePasswordReset(r); err != nil {
+        t.Errorf("FAILED: Failed to save PasswordReset: %v", err)
+        return
+      }
+    }
+
+    if _, err := database.ConsumePasswordReset(staleToken); err == nil {
+      t.Errorf("FAILED: Got nil Want a newer PasswordReset to replace the older one")
+    }
+    if _, err := database.ConsumePasswordReset(expiredToken); err == nil {
+      t.Errorf("FAILED: Got nil Want an error for an expired PasswordReset")
+    }
+    if _, err := database.ConsumePasswordReset("made-up"); err == nil {
+      t.Errorf("FAILED: Got nil Want an error for an unknown reset token")
+    }
+    got, err := database.ConsumePasswordReset(resetToken)
+    if err != nil || got.UserID != forgetful.UserID {
+      t.Errorf("FAILED: Got %+v, %v Want forgetful's PasswordReset", got, err)
+    }
+    if _, err := database.ConsumePasswordReset(resetToken); err == nil {
+      t.Errorf("FAILED: Got nil Want a PasswordReset to only be used once")
+    }
+
+    if err := database.UpdateUserPassword(forgetful.UserID, []byte("new"), &token.Token{ Token: "new-token" }); err != nil {
+      t.Errorf("FAILED: Failed to change password: %v", err)
+      return
+    }
+    user, err := database.GetUserbyUsername(forgetful.Username)
+    if err != nil || string(user.HashedPassword) != "new" {
+      t.Errorf("FAILED: Got %+v, %v Want the new password", user, err)
+    }
+    if accessToken, err := database.GetUserToken(forgetful.UserID); err != nil || accessToken.Token != "new-token" {
+      t.Errorf("FAILED: Got %+v, %v Want the new Access Token", accessToken, err)
+    }
+
+    pending, pendingToken, _ := NewPasswordReset(forgetful.UserID, 0)
+    database.SavePasswordReset(&pending)
+    if err := database.UpdateUserPassword(forgetful.UserID, []byte("newer"), nil); err != nil {
+      t.Errorf("FAILED: Failed to reset password: %v", err)
+      return
+    }
+    if _, err := database.GetUserToken(forgetful.UserID); err == nil {
+      t.Errorf("FAILED: Got an Access Token Want it revoked")
+    }
+    if _, err := database.ConsumePasswordReset(pendingToken); err == nil {
+      t.Errorf("FAILED: Got nil Want pending PasswordResets dropped along with the old password")
+    }
+    if err := database.UpdateUserPassword(uuid.New(), []byte("nobody"), nil); err == nil {
+      t.Errorf("FAILED: Got nil Want an error for an unknown User")
+    }
+  })
+
+  t.Run("Profiles", func(t *testing.T){
+    profile, err := database.GetProfile(member.UserID)
+    if err != nil || profile.Username != member.Username || profile.DisplayName != "" {
+      t.Errorf("FAILED: Got %+v, %v Want an empty Profile for %s", profile, err, member.Username)
+      return
+    }
+
+    profile.Username = "impostor"
+    profile.DisplayName = "Member"
+    profile.Timezone = "Europe/Berlin"
+    profile.Avatar = "\x1b[31m<3\x1b[0m\n/\\"
+    profile.UpdatedAt = time.Now()
+    if err := database.SaveProfile(profile); err != nil {
+      t.Errorf("FAILED: Failed to save Profile: %v", err)
+      return
+    }
+    got, err := database.GetProfile(member.UserID)
+    if err != nil || got.Username != member.Username || got.DisplayName != "Member" || got.Avatar != profile.Avatar || !got.UpdatedAt.Equal(profile.UpdatedAt) {
+      t.Errorf("FAILED: Got %+v, %v Want the saved Profile under %s", got, err, member.Username)
+    }
+
+    if err := database.SaveProfile(&Profile{ UserID: uuid.New(), DisplayName: "Nobody" }); err == nil {
+      t.Errorf("FAILED: Got nil Want an error saving the Profile of an unknown User")
+    }
+    hidden := User{ UserID: uuid.New(), Username: "hidden" }
+    database.SaveUser(hidden, nil)
+    database.SaveProfile(&Profile{ UserID: hidden.UserID, Bio: "Gone" })
+    database.DeactivateUser(hidden.UserID)
+    if _, err := database.GetProfile(hidden.UserID); err == nil {
+      t.Errorf("FAILED: Got the Profile of a deactivated User")
+    }
+  })
+
+  t.Run("Save Messages in batches", func(t *testing.T){
+    batched := Chatroom{ RoomID: uuid.New(), RoomName: "batched", OwnerID: owner.UserID }
+    if err := database.SaveChatroom(&batched, false); err != nil {
+      t.Errorf("FAILED: Failed to create Chatroom: %v", err)
+      return
+    }
+    now := time.Now()
+    msgs := []Message{
+      { ID: uuid.New(), TimeStamp: now, UserID: owner.UserID,  Content: "batch root" },
+      { ID: uuid.New(), TimeStamp: now, UserID: member.UserID, Content: "batch reply" },
+    }
+    msgs[1].ReplyTo = msgs[0].ID
+    if err := database.SaveMessages(batched.RoomName, msgs); err != nil || msgs[1].Seq != 2 || msgs[1].ThreadID != msgs[0].ID {
+      t.Errorf("FAILED: Got %+v, %v Want seq 2 replying within the batch", msgs[1], err)
+    }
+
+    broken := []Message{
+      { ID: uuid.New(), TimeStamp: now, UserID: owner.UserID, Content: "never saved" },
+      { ID: uuid.New(), TimeStamp: now, UserID: owner.UserID, Content: "orphan", ReplyTo: uuid.New() },
+    }
+    if err := database.SaveMessages(batched.RoomName, broken); err == nil {
+      t.Errorf("FAILED: Got nil Want an error for a reply to a missing Message")
+    }
+    if page, err := database.Paginate(batched.RoomName, 0, 0, 10); err != nil || len(page.Messages) != 2 {
+      t.Errorf("FAILED: Got %+v, %v Want the failed batch rolled back", page, err)
+    }
+    if err := database.SaveMessages("nosuchroom", msgs); err == nil {
+      t.Errorf("FAILED: Got nil Want an error for a missing Chatroom")
+    }
+  })
+
+  t.Run("Export Messages", func(t *testing.T){
+    archive := Chatroom{ RoomID: uuid.New(), RoomName: "archive", OwnerID: owner.UserID }
+    if err := database.SaveChatroom(&archive, false); err != nil {
+      t.Errorf("FAILED: Failed to create Chatroom: %v", err)
+      return
+    }
+    now := time.Now()
+    msgs := []Message{
+      { ID: uuid.New(), TimeStamp: now.Add(-2*time.Hour), UserID: owner.UserID,  Content: "too old" },
+      { ID: uuid.New(), TimeStamp: now.Add(-time.Hour),   UserID: owner.UserID,  Content: "first" },
+      { ID: uuid.New(), TimeStamp: now.Add(-time.Hour),   UserID: member.UserID, Content: "deleted" },
+      { ID: uuid.New(), TimeStamp: now,                   UserID: member.UserID, Content: "second" },
+    }
+    for i := range msgs {
+      if err := database.SaveMessage(archive.RoomName, &msgs[i]); err != nil {
+        t.Errorf("FAILED: Failed to save Message: %v", err)
+        return
+      }
+    }
+    if _, err := database.DeleteMessage(archive.RoomName, msgs[2].ID); err != nil {
+      t.Errorf("FAILED: Failed to delete Message: %v", err)
+      return
+    }
+
+    scanned, err := database.ScanMessages(archive.RoomName, 1, 2)
+    if err != nil || len(scanned) != 2 || scanned[0].Seq != 2 || scanned[1].Seq != 3 {
+      t.Errorf("FAILED: Got %+v, %v Want Messages 2 and 3", scanned, err)
+    }
+
+    var exported []string
+    err = ExportMessages(database, archive.RoomName, ExportFilter{ From: now.Add(-90*time.Minute) }, func(message *ExportedMessage) error {
+      exported = append(exported, message.Username+": "+message.Content)
+      return nil
+    })
+    if err != nil || len(exported) != 2 || exported[0] != "owner: first" || exported[1] != "member: second" {
+      t.Errorf("FAILED: Got %v, %v Want [owner: first member: second]", exported, err)
+    }
+  })
+}
+
+func TestPageDirectory(t *testing.T) {
+  now := time.Now()
+  entries := []DirectoryEntry{
+    { RoomName: "alpha",   MemberCount: 2, LastActivity: now.Add(-time.Hour) },
+    { RoomName: "bravo",   MemberCount: 5 },
+    { RoomName: "charlie", MemberCount: 2, LastActivity: now },
+    { RoomName: "delta",   MemberCount: 9, LastActivity: now.Add(-time.Minute) },
+  }
+
+  tests := []struct{
+    order string
+    want  string
+  }{
+    { SortByName,     "[alpha bravo] [charlie delta]" },
+    { SortByMembers,  "[delta bravo] [alpha charlie]" },
+    { SortByActivity, "[charlie delta] [alpha bravo]" },
+  }
+  for _, tc := range tests {
+    t.Run(tc.order, func(t *testing.T){
+      var pages []string
+      cursor := ""
+      for i := 0; i < len(entries); i++ {
+        page, err := PageDirectory(append([]DirectoryEntry{}, entries...), tc.order, cursor, 2)
+        if err != nil {
+          t.Errorf("FAILED: Failed to page Directory: %v", err)
+          return
+        }
+        var names []string
+        for _, e := range page.Chatrooms {
+          names = append(names, e.RoomName)
+        }
+        pages = append(pages, fmt.Sprint(names))
+        if cursor = page.NextCursor; cursor == "" {
+          break
+        }
+      }
+      if got := strings.Join(pages, " "); got != tc.want {
+        t.Errorf("FAILED: Got %s Want %s", got, tc.want)
+      }
+    })
+  }
+
+  if _, err := PageDirectory(entries, "popularity", "", 2); err == nil {
+    t.Errorf("FAILED: Expected an error for an unknown sort order")
+  }
+  if _, err := PageDirectory(entries, SortByName, "not a cursor", 2); err == nil {
+    t.Errorf("FAILED: Expected an error for a malformed cursor")
+  }
+}
+
+func TestJanitor(t *testing.T) {
+  database := NewMemoryDatabase()
+  owner := User{ UserID: uuid.New(), Username: "owner", HashedPassword: []byte("hash") }
+  if err := database.SaveUser(owner, nil); err != nil {
+    t.Fatalf("FAILED: Failed to save User: %v", err)
+  }
+
+  now := time.Now()
+  rooms := []Chatroom{
+    { RoomID: uuid.New(), RoomName: "defaults", OwnerID: owner.UserID },
+    { RoomID: uuid.New(), RoomName: "keepsfive", OwnerID: owner.UserID, MaxMessages: 5 },
+  }
+  for i := range rooms {
+    if err := database.SaveChatroom(&rooms[i], false); err != nil {
+      t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+    }
+    for j := 0; j < 10; j++ {
+      message := Message{ ID: uuid.New(), TimeStamp: now, UserID: owner.UserID, Content: "hello" }
+      if err := database.SaveMessage(rooms[i].RoomName, &message); err != nil {
+        t.Fatalf("FAILED: Failed to save Message: %v", err)
+      }
+    }
+  }
+
+  janitor := NewJanitor(database, Retention{ MaxMessages: 8 })
+  janitor.BatchSize = 2
+  if purged, err := janitor.Sweep(now, nil); err != nil || purged != 7 {
+    t.Errorf("FAILED: Got %d, %v Want 7 Messages purged", purged, err)
+  }
+  for room, want := range map[string]int{ "defaults": 8, "keepsfive": 5 } {
+    if page, err := database.Paginate(room, 0, 0, 100); err != nil || len(page.Messages) != want {
+      t.Errorf("FAILED: %s: Got %d Messages, %v Want %d", room, len(page.Messages), err, want)
+    }
+  }
+}
+
+func stringPtr(s string) *string {
+  return &s
+}
+
+func TestValidateProfile(t *testing.T) {
+  wide := strings.Repeat("#", MaxAvatarWidth)
+  steps := []struct{
+    name     string
+    settings ProfileSettings
+    valid    bool
+  }{
+    { "Everything",              ProfileSettings{ DisplayName: stringPtr("Ada"), Bio: stringPtr("Hello\nWorld"), Timezone: stringPtr("America/New_York") }, true },
+    { "Coloured avatar",         ProfileSettings{ Avatar: stringPtr("\x1b[1;32m" + wide + "\x1b[0m\r\n" + wide + "\n") },                                   true },
+    { "Multi-line display name", ProfileSettings{ DisplayName: stringPtr("Ada\nLovelace") },                                                                false },
+    { "Long company",            ProfileSettings{ Company: stringPtr(strings.Repeat("c", MaxCompanyLength+1)) },                                            false },
+    { "Long bio",                ProfileSettings{ Bio: stringPtr(strings.Repeat("b", MaxBioLength+1)) },                                                    false },
+    { "Unknown timezone",        ProfileSettings{ Timezone: stringPtr("Mars/Olympus_Mons") },                                                               false },
+    { "Local timezone",          ProfileSettings{ Timezone: stringPtr("Local") },                                                                           false },
+    { "Wide avatar",             ProfileSettings{ Avatar: stringPtr(wide + "#") },                                                                          false },
+    { "Tall avatar",             ProfileSettings{ Avatar: stringPtr(strings.Repeat("o\n", MaxAvatarHeight+1)) },                                            false },
+    { "Cursor movement",         ProfileSettings{ Avatar: stringPtr("\x1b[2J\x1b[H") },                                                                     false },
+  }
+  for _, step := range steps {
+    var profile Profile
+    err := step.settings.Apply(&profile)
+    if (err == nil) != step.valid {
+      t.Errorf("FAILED: %s: Got %v Want valid %t", step.name, err, step.valid)
+    }
+  }
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --> Chatroom Directory sort orders
+const (
+  SortByName     = "name"
+  SortByMembers  = "members"
+  SortByActivity = "activity"
+)
+
+// DirectoryEntry :: A single public Chatroom, as listed by GET /chatrooms. LastActivity
+//    is the TimeStamp of the Chatroom's newest Message, and zero if it has none.
+type DirectoryEntry struct {
+  RoomID       UUID      `codec:"room_id"`
+  RoomName     RoomName  `codec:"room_name"`
+  MemberCount  int       `codec:"member_count"`
+  OnlineCount  int       `codec:"online_count"`
+  LastActivity time.Time `codec:"last_activity,omitempty"`
+}
+
+// DirectoryPage :: A single page of the Chatroom Directory. Pass NextCursor back as
+//    ?cursor= for the next page. It's left empty on the last page.
+type DirectoryPage struct {
+  Chatrooms  []DirectoryEntry `codec:"chatrooms"`
+  NextCursor string           `codec:"next_cursor"`
+}
+
+func ValidDirectorySort(order string) bool {
+  switch order {
+  case SortByName, SortByMembers, SortByActivity:
+    return true
+  }
+  return false
+}
+
+// directoryKey :: Names sort A-Z. Member counts and activity sort largest, and
+//    newest, first. Ties are always broken by name.
+func directoryKey(entry *DirectoryEntry, order string) int64 {
+  switch order {
+  case SortByMembers:
+    return int64(entry.MemberCount)
+  case SortByActivity:
+    if entry.LastActivity.IsZero() {
+      return 0
+    }
+    return entry.LastActivity.UnixNano()
+  }
+  return 0
+}
+
+func directoryBefore(aKey int64, aName string, bKey int64, bName string) bool {
+  if aKey != bKey {
+    return aKey > bKey
+  }
+  return aName < bName
+}
+
+// PageDirectory :: Sorts entries by order, and returns up to limit of them after
+//    cursor. Cursors hold the sort key and name of the last entry of a page, so a
+//    Chatroom gaining members between pages can't shift the rest of the pages.
+func PageDirectory(entries []DirectoryEntry, order, cursor string, limit int)( *DirectoryPage, error ){
+  if !ValidDirectorySort(order) {
+    return nil, fmt.Errorf("Unknown sort order \"%s\"", order)
+  }
+  sort.Slice(entries, func(i, j int) bool {
+    a, b := &entries[i], &entries[j]
+    return directoryBefore(directoryKey(a, order), a.RoomName, directoryKey(b, order), b.RoomName)
+  })
+
+  start := 0
+  if cursor != "" {
+    key, name, err := decodeDirectoryCursor(cursor)
+    if err != nil {
+      return nil, err
+    }
+    start = sort.Search(len(entries), func(i int) bool {
+      return directoryBefore(key, name, directoryKey(&entries[i], order), entries[i].RoomName)
+    })
+  }
+
+  limit = ClampPageSize(limit)
+  end := start + limit
+  if end > len(entries) {
+    end = len(entries)
+  }
+  page := &DirectoryPage{ Chatrooms: append([]DirectoryEntry{}, entries[start:end]...) }
+  if end < len(entries) {
+    last := &entries[end-1]
+    page.NextCursor = encodeDirectoryCursor(directoryKey(last, order), last.RoomName)
+  }
+  return page, nil
+}
+
+func encodeDirectoryCursor(key int64, name string) string {
+  return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(key, 10) + ":" + name))
+}
+
+func decodeDirectoryCursor(cursor string)( int64, string, error ){
+  data, err := base64.RawURLEncoding.DecodeString(cursor)
+  if err != nil {
+    return 0, "", fmt.Errorf("Invalid cursor")
+  }
+  i := strings.IndexByte(string(data), ':')
+  if i < 0 {
+    return 0, "", fmt.Errorf("Invalid cursor")
+  }
+  key, err := strconv.ParseInt(string(data[:i]), 10, 64)
+  if err != nil {
+    return 0, "", fmt.Errorf("Invalid cursor")
+  }
+  return key, string(data[i+1:]), nil
+}
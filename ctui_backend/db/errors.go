@@ -48,3 +48,33 @@ func(e FailedSecurityCheckError)Error() string {
     e.t, e.err,
   )
 }
+
+type MessageDeletedError struct{ id string }
+func(e MessageDeletedError)Error() string {
+  return fmt.Sprintf("Error: DatabaseError - Message \"%s\" has been deleted", e.id)
+}
+
+type SchemaVersionError struct{ got, known uint64 }
+func(e SchemaVersionError)Error() string {
+  return fmt.Sprintf(
+    "Error: DatabaseError - Schema version %d is newer than the latest known version %d",
+    e.got, e.known,
+  )
+}
+
+type ChatroomFullError struct{ name string }
+func(e ChatroomFullError)Error() string {
+  return fmt.Sprintf("Error: DatabaseError - Chatroom \"%s\" is full", e.name)
+}
+
+// InvalidSettingsError :: Settings a Chatroom can't take. The reason is meant for
+//    the User, so it's returned as is.
+type InvalidSettingsError struct{ err string }
+func(e InvalidSettingsError)Error() string {
+  return e.err
+}
+
+type AlreadyMemberError struct{ name string }
+func(e AlreadyMemberError)Error() string {
+  return fmt.Sprintf("Error: DatabaseError - Already a Member of Chatroom \"%s\"", e.name)
+}
@@ -0,0 +1,280 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ugorji/go/codec"
+)
+
+// --> Export Formats
+const (
+  ExportNDJSON   = "ndjson"
+  ExportJSON     = "json"
+  ExportText     = "txt"
+  ExportMarkdown = "md"
+
+  // How many Messages ExportMessages loads at a time.
+  exportBatchSize  = MaxPageSize
+  exportTimeFormat = "2006-01-02 15:04:05"
+)
+
+// ExportedMessage :: A Message as it's written out by an Exporter, with it's
+//    author's Username resolved.
+type ExportedMessage struct {
+  Seq       uint64    `codec:"seq"`
+  ID        UUID      `codec:"id"`
+  TimeStamp time.Time `codec:"time_stamp"`
+  UserID    UUID      `codec:"user_id"`
+  Username  UserName  `codec:"username"`
+  Content   string    `codec:"content"`
+  EditedAt  time.Time `codec:"edited_at,omitempty"`
+  ReplyTo   UUID      `codec:"reply_to,omitempty"`
+}
+
+// ExportFilter :: Only Messages sent between From and To, both inclusive, are
+//    exported. A zero bound is unbounded.
+type ExportFilter struct {
+  From time.Time
+  To   time.Time
+}
+
+func(f ExportFilter)includes(timeStamp time.Time) bool {
+  if !f.From.IsZero() && timeStamp.Before(f.From) {
+    return false
+  }
+  return f.To.IsZero() || !timeStamp.After(f.To)
+}
+
+// ExportMessages :: Walks the Chatroom's Messages oldest first, handing each one
+//    within filter to fn with it's author's Username resolved. Messages are loaded
+//    a batch at a time, and fn is never called while the Database is locked, so
+//    it's free to write out to a slow client. Tombstones are skipped.
+func ExportMessages(
+  database     ChatatuiDatabase,
+  chatroomName string,
+  filter       ExportFilter,
+  fn           func(message *ExportedMessage) error,
+) error {
+  usernames := map[UUID]UserName{}
+  resolve := func(userID UUID) UserName {
+    if username, ok := usernames[userID]; ok {
+      return username
+    }
+    // Deactivated Users, like imported placeholders, keep their Username. Users
+    //    that have since been deleted keep their UserID.
+    username := userID.String()
+    if user, err := database.GetUserByID(userID); err == nil {
+      username = user.Username
+    } else if user, err := database.GetDeactivatedUserByID(userID); err == nil {
+      username = user.Username
+    }
+    usernames[userID] = username
+    return username
+  }
+
+  var after uint64
+  for {
+    msgs, err := database.ScanMessages(chatroomName, after, exportBatchSize)
+    if err != nil {
+      return err
+    }
+    for i := range msgs {
+      message := &msgs[i]
+      if !message.DeletedAt.IsZero() || !filter.includes(message.TimeStamp) {
+        continue
+      }
+      err := fn(&ExportedMessage{
+        Seq:       message.Seq,
+        ID:        message.ID,
+        TimeStamp: message.TimeStamp,
+        UserID:    message.UserID,
+        Username:  resolve(message.UserID),
+        Content:   message.Content,
+        EditedAt:  message.EditedAt,
+        ReplyTo:   message.ReplyTo,
+      })
+      if err != nil {
+        return err
+      }
+    }
+    if len(msgs) < exportBatchSize {
+      return nil
+    }
+    after = msgs[len(msgs)-1].Seq
+  }
+}
+
+// Exporter :: Writes out a Chatroom's Messages in one of the Export Formats, one
+//    Message at a time. Close finishes off the export, and has to be called even
+//    when no Messages were written.
+type Exporter interface {
+  WriteMessage(message *ExportedMessage) error
+  Close() error
+}
+
+// NewExporter :: Returns an error for unknown formats.
+func NewExporter(format string, w io.Writer, chatroomName string)( Exporter, error ){
+  switch format {
+  case ExportNDJSON:
+    return &ndjsonExporter{ w }, nil
+  case ExportJSON:
+    return &jsonExporter{ w: w, chatroom: chatroomName }, nil
+  case ExportText:
+    return &textExporter{ w }, nil
+  case ExportMarkdown:
+    return &markdownExporter{ w: w, chatroom: chatroomName }, nil
+  }
+  return nil, fmt.Errorf("Unknown export format \"%s\"", format)
+}
+
+// ExportContentType :: The Content-Type each Export Format is served with.
+func ExportContentType(format string) string {
+  switch format {
+  case ExportNDJSON:
+    return "application/x-ndjson"
+  case ExportJSON:
+    return "application/json"
+  case ExportMarkdown:
+    return "text/markdown; charset=utf-8"
+  }
+  return "text/plain; charset=utf-8"
+}
+
+func encodeExportedMessage(message *ExportedMessage)( []byte, error ){
+  var data []byte
+  enc := codec.NewEncoderBytes(&data, &JSONHandle)
+  if err := enc.Encode(message); err != nil {
+    return nil, EncoderError{err.Error()}
+  }
+  return data, nil
+}
+
+// ndjsonExporter :: One JSON encoded ExportedMessage per line.
+type ndjsonExporter struct {
+  w io.Writer
+}
+
+func(e *ndjsonExporter)WriteMessage(message *ExportedMessage) error {
+  data, err := encodeExportedMessage(message)
+  if err != nil {
+    return err
+  }
+  _, err = e.w.Write(append(data, '\n'))
+  return err
+}
+
+func(e *ndjsonExporter)Close() error {
+  return nil
+}
+
+// jsonExporter :: {"chatroom": ..., "messages": [...]}, written out as it goes
+//    rather than encoded all at once.
+type jsonExporter struct {
+  w        io.Writer
+  chatroom string
+  written  int
+}
+
+func(e *jsonExporter)begin() error {
+  name, err := encodeJSONString(e.chatroom)
+  if err != nil {
+    return err
+  }
+  _, err = fmt.Fprintf(e.w, "{\"chatroom\":%s,\"messages\":[", name)
+  return err
+}
+
+func(e *jsonExporter)WriteMessage(message *ExportedMessage) error {
+  separator := ","
+  if e.written == 0 {
+    if err := e.begin(); err != nil {
+      return err
+    }
+    separator = ""
+  }
+  data, err := encodeExportedMessage(message)
+  if err != nil {
+    return err
+  }
+  if _, err := io.WriteString(e.w, separator); err != nil {
+    return err
+  }
+  if _, err := e.w.Write(data); err != nil {
+    return err
+  }
+  e.written++
+  return nil
+}
+
+func(e *jsonExporter)Close() error {
+  if e.written == 0 {
+    if err := e.begin(); err != nil {
+      return err
+    }
+  }
+  _, err := io.WriteString(e.w, "]}\n")
+  return err
+}
+
+func encodeJSONString(s string)( string, error ){
+  var data []byte
+  enc := codec.NewEncoderBytes(&data, &JSONHandle)
+  if err := enc.Encode(s); err != nil {
+    return "", EncoderError{err.Error()}
+  }
+  return string(data), nil
+}
+
+// textExporter :: IRC log style. "[2006-01-02 15:04:05] <username> content", with
+//    the continuation lines of multi-line Messages indented.
+type textExporter struct {
+  w io.Writer
+}
+
+func(e *textExporter)WriteMessage(message *ExportedMessage) error {
+  content := strings.ReplaceAll(message.Content, "\n", "\n    ")
+  _, err := fmt.Fprintf(e.w, "[%s] <%s> %s\n", message.TimeStamp.UTC().Format(exportTimeFormat), message.Username, content)
+  return err
+}
+
+func(e *textExporter)Close() error {
+  return nil
+}
+
+// markdownExporter :: A heading for the Chatroom, and a paragraph per Message.
+type markdownExporter struct {
+  w        io.Writer
+  chatroom string
+  started  bool
+}
+
+func(e *markdownExporter)begin() error {
+  if e.started {
+    return nil
+  }
+  e.started = true
+  _, err := fmt.Fprintf(e.w, "# %s\n\n", e.chatroom)
+  return err
+}
+
+func(e *markdownExporter)WriteMessage(message *ExportedMessage) error {
+  if err := e.begin(); err != nil {
+    return err
+  }
+  edited := ""
+  if !message.EditedAt.IsZero() {
+    edited = " (edited)"
+  }
+  _, err := fmt.Fprintf(e.w, "**%s** _%s UTC_%s\n\n%s\n\n",
+    message.Username, message.TimeStamp.UTC().Format(exportTimeFormat), edited,
+    strings.ReplaceAll(message.Content, "\n", "  \n"),
+  )
+  return err
+}
+
+func(e *markdownExporter)Close() error {
+  return e.begin()
+}
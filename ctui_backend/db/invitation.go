@@ -0,0 +1,147 @@
+package db
+
+import (
+	"crypto/rand"
+	"math/big"
+	"sort"
+	"time"
+)
+
+const (
+  DefaultInvitationTTL = 7 * 24 * time.Hour
+  MaxInvitationTTL     = 30 * 24 * time.Hour
+  inviteCodeLength     = 10
+  inviteCodeAlphabet   = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+)
+
+// Invitation :: Lets UserID join a private Chatroom. Created by an Owner or
+//    Moderator, and used up once UserID joins. Stored under /Invitations/{user_id}/{room_id}.
+type Invitation struct {
+  RoomID    UUID      `codec:"room_id"`
+  Chatroom  RoomName  `codec:"chatroom"`
+  UserID    UUID      `codec:"user_id"`
+  InvitedBy UUID      `codec:"invited_by"`
+  CreatedAt time.Time `codec:"created_at"`
+  ExpiresAt time.Time `codec:"expires_at"`
+}
+
+// NewInvitation :: A ttl of 0 falls back onto DefaultInvitationTTL, and never
+//    exceeds MaxInvitationTTL.
+func NewInvitation(chatroom *Chatroom, userID, invitedBy UUID, ttl time.Duration) Invitation {
+  if ttl <= 0 {
+    ttl = DefaultInvitationTTL
+  }
+  if ttl > MaxInvitationTTL {
+    ttl = MaxInvitationTTL
+  }
+  now := time.Now()
+  return Invitation{
+    RoomID:    chatroom.RoomID,
+    Chatroom:  chatroom.RoomName,
+    UserID:    userID,
+    InvitedBy: invitedBy,
+    CreatedAt: now,
+    ExpiresAt: now.Add(ttl),
+  }
+}
+
+func(i *Invitation)Expired(now time.Time) bool {
+  return !now.Before(i.ExpiresAt)
+}
+
+// checkInvitation :: Shared by every JoinChatroom. invitation is nil when the User
+//    was never invited.
+func checkInvitation(invitation *Invitation, chatroom string, userID UUID) error {
+  if invitation == nil {
+    return GetDataError{chatroom + "-" + userID.String(), INVITATIONS}
+  }
+  if invitation.Expired(time.Now()) {
+    return FailedSecurityCheckError{"Invitation", "Invitation has expired"}
+  }
+  return nil
+}
+
+// sortInvitations :: Newest first.
+func sortInvitations(invitations []Invitation) {
+  sort.Slice(invitations, func(i, j int) bool {
+    if !invitations[i].CreatedAt.Equal(invitations[j].CreatedAt) {
+      return invitations[i].CreatedAt.After(invitations[j].CreatedAt)
+    }
+    return invitations[i].Chatroom < invitations[j].Chatroom
+  })
+}
+
+// InviteLink :: A code anyone holding it can redeem to join Chatroom as a Member,
+//    until it expires or has been used MaxUses times. A MaxUses of 0 is unlimited.
+//    Stored under /InviteLinks/{code}.
+type InviteLink struct {
+  Code      string    `codec:"code"`
+  RoomID    UUID      `codec:"room_id"`
+  Chatroom  RoomName  `codec:"chatroom"`
+  CreatedBy UUID      `codec:"created_by"`
+  CreatedAt time.Time `codec:"created_at"`
+  ExpiresAt time.Time `codec:"expires_at"`
+  MaxUses   int       `codec:"max_uses"`
+  Uses      int       `codec:"uses"`
+}
+
+// NewInviteLink :: ttl is clamped the same way as an Invitation's. Codes leave out
+//    characters that are easy to mistake for one another, like 0 and O.
+func NewInviteLink(chatroom *Chatroom, createdBy UUID, ttl time.Duration, maxUses int)( InviteLink, error ){
+  if ttl <= 0 {
+    ttl = DefaultInvitationTTL
+  }
+  if ttl > MaxInvitationTTL {
+    ttl = MaxInvitationTTL
+  }
+  code := make([]byte, inviteCodeLength)
+  max := big.NewInt(int64(len(inviteCodeAlphabet)))
+  for i := range code {
+    n, err := rand.Int(rand.Reader, max)
+    if err != nil {
+      return InviteLink{}, err
+    }
+    code[i] = inviteCodeAlphabet[n.Int64()]
+  }
+
+  now := time.Now()
+  return InviteLink{
+    Code:      string(code),
+    RoomID:    chatroom.RoomID,
+    Chatroom:  chatroom.RoomName,
+    CreatedBy: createdBy,
+    CreatedAt: now,
+    ExpiresAt: now.Add(ttl),
+    MaxUses:   maxUses,
+  }, nil
+}
+
+// Usable :: Whether the InviteLink can still be redeemed at now.
+func(l *InviteLink)Usable(now time.Time) bool {
+  return now.Before(l.ExpiresAt) && (l.MaxUses == 0 || l.Uses < l.MaxUses)
+}
+
+// redeemFor :: Shared by every RedeemInviteLink. status is the redeeming User's
+//    current MemberType, or nil if they aren't a Member, and members are the
+//    Chatroom's current Members. Counts a use of link once they're free to join.
+func(l *InviteLink)redeemFor(chatroom *Chatroom, status *MemberType, members map[UUID]MemberType) error {
+  if done, err := checkRejoin(status); done {
+    if err == nil {
+      err = AlreadyMemberError{chatroom.RoomName}
+    }
+    return err
+  }
+  if err := checkCapacity(chatroom, members); err != nil {
+    return err
+  }
+  return l.redeem()
+}
+
+// redeem :: Counts a use of link.
+func(l *InviteLink)redeem() error {
+  if !l.Usable(time.Now()) {
+    return FailedSecurityCheckError{"InviteLink", "Invite link has expired or been used up"}
+  }
+  l.Uses++
+  return nil
+}
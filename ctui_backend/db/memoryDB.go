@@ -0,0 +1,1291 @@
+package db
+
+import (
+	"chatatui_backend/token"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryDB -> Implements 'ChatatuiDatabase'. Everything lives in Go maps guarded by
+//    a single RWMutex, nothing is written to disk. Mirrors the semantics and error
+//    types of BBoltDB so it can stand in for it within tests and throwaway servers.
+type MemoryDB struct {
+  mu                sync.RWMutex
+  chatrooms         map[RoomName]Chatroom
+  inactiveChatrooms map[RoomName]Chatroom
+  chatroomMembers   map[RoomName]map[UUID]MemberType
+  memberRoles       map[memberKey]string
+  roles             map[RoomName]map[string]Role
+  liveMembers       map[RoomName]map[UserName]string
+  messages          map[RoomName][]Message
+  sequences         map[RoomName]uint64
+  searchIndex       map[string]map[searchHit]bool
+  messageIDs        map[messageKey]uint64
+  revisions         map[messageKey][]MessageRevision
+  threads           map[messageKey][]uint64
+  readMarkers       map[memberKey]uint64
+  users             map[UUID]User
+  deactivatedUsers  map[UUID]User
+  usernames         map[UserName]UUID
+  usersOnline       map[UserName]bool
+  userTokens        map[UUID]token.Token
+  invitations       map[UUID]map[UUID]Invitation
+  inviteLinks       map[string]InviteLink
+  passwordResets    map[string]PasswordReset
+  profiles          map[UUID]Profile
+}
+
+func NewMemoryDatabase() *MemoryDB {
+  log.Printf(" -> NewMemoryDatabase: Creating in-memory Database. Nothing will be persisted")
+  return &MemoryDB{
+    chatrooms:         make(map[RoomName]Chatroom),
+    inactiveChatrooms: make(map[RoomName]Chatroom),
+    chatroomMembers:   make(map[RoomName]map[UUID]MemberType),
+    memberRoles:       make(map[memberKey]string),
+    roles:             make(map[RoomName]map[string]Role),
+    liveMembers:       make(map[RoomName]map[UserName]string),
+    messages:          make(map[RoomName][]Message),
+    sequences:         make(map[RoomName]uint64),
+    searchIndex:       make(map[string]map[searchHit]bool),
+    messageIDs:        make(map[messageKey]uint64),
+    revisions:         make(map[messageKey][]MessageRevision),
+    threads:           make(map[messageKey][]uint64),
+    readMarkers:       make(map[memberKey]uint64),
+    users:             make(map[UUID]User),
+    deactivatedUsers:  make(map[UUID]User),
+    usernames:         make(map[UserName]UUID),
+    usersOnline:       make(map[UserName]bool),
+    userTokens:        make(map[UUID]token.Token),
+    invitations:       make(map[UUID]map[UUID]Invitation),
+    inviteLinks:       make(map[string]InviteLink),
+    passwordResets:    make(map[string]PasswordReset),
+    profiles:          make(map[UUID]Profile),
+  }
+}
+
+func(db *MemoryDB)GetChatroom(name string)( *Chatroom, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  chatroom, ok := db.chatrooms[name]
+  if !ok {
+    return nil, GetDataError{name, CHATROOMS}
+  }
+  return &chatroom, nil
+}
+
+// SaveChatroom : Creates /Chatrooms/{room_name} when update is false, storing the
+//    chatroom's Owner as a member. When update is true, the OwnerID must be allowed
+//    to change the existing chatroom's settings.
+func(db *MemoryDB)SaveChatroom(
+  chatroom *Chatroom,
+  update   bool,
+) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  if !update {
+    if db.doesChatroomExist(chatroom.RoomName) {
+      log.Printf(" -> SaveChatroom: Chatroom name already taken")
+      return fmt.Errorf("Chatroom name already taken")
+    }
+    db.saveChatroomMember(chatroom.RoomName, chatroom.OwnerID, Owner)
+    db.saveDefaultRoles(chatroom.RoomName)
+  } else {
+    member, _ := db.getChatroomMember(chatroom.RoomName, chatroom.OwnerID)
+    if err := Authorize(member, PermChangeSettings, nil); err != nil {
+      log.Printf(" -> SaveChatroom: Invalid Credentials for updating Chatroom: %s", err)
+      return err
+    }
+  }
+
+  db.chatrooms[chatroom.RoomName] = *chatroom
+  return nil
+}
+
+func(db *MemoryDB)UpdateChatroomSettings(
+  chatroomName string,
+  userID       UUID,
+  settings     *ChatroomSettings,
+)( *Chatroom, error ){
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  chatroom, ok := db.chatrooms[chatroomName]
+  if !ok {
+    return nil, GetDataError{chatroomName, CHATROOMS}
+  }
+  if chatroom.IsDirect() {
+    return nil, InvalidSettingsError{"Direct Chatrooms have no settings"}
+  }
+  member, _ := db.getChatroomMember(chatroomName, userID)
+  if err := Authorize(member, PermChangeSettings, nil); err != nil {
+    log.Printf(" -> UpdateChatroomSettings: Invalid Credentials for updating Chatroom: %s", err)
+    return nil, err
+  }
+  if err := settings.Apply(&chatroom); err != nil {
+    return nil, InvalidSettingsError{err.Error()}
+  }
+  db.chatrooms[chatroomName] = chatroom
+  return &chatroom, nil
+}
+
+func(db *MemoryDB)GetChatroomDirectory()( []DirectoryEntry, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  entries := []DirectoryEntry{}
+  for _, chatroom := range db.chatrooms {
+    if !chatroom.Public || chatroom.IsDirect() {
+      continue
+    }
+    entries = append(entries, db.directoryEntry(&chatroom))
+  }
+  return entries, nil
+}
+
+func(db *MemoryDB)GetDirectoryEntry(chatroomName string)( *DirectoryEntry, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  chatroom, ok := db.chatrooms[chatroomName]
+  if !ok {
+    return nil, GetDataError{chatroomName, CHATROOMS}
+  }
+  entry := db.directoryEntry(&chatroom)
+  return &entry, nil
+}
+
+// directoryEntry :: Expects db.mu to already be held.
+func(db *MemoryDB)directoryEntry(chatroom *Chatroom) DirectoryEntry {
+  entry := DirectoryEntry{
+    RoomID:      chatroom.RoomID,
+    RoomName:    chatroom.RoomName,
+    MemberCount: CountMembers(db.chatroomMembers[chatroom.RoomName]),
+  }
+  if msgs := db.messages[chatroom.RoomName]; len(msgs) != 0 {
+    entry.LastActivity = msgs[len(msgs)-1].TimeStamp
+  }
+  return entry
+}
+
+func(db *MemoryDB)GetChatrooms()( []Chatroom, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  rooms := []Chatroom{}
+  for _, chatroom := range db.chatrooms {
+    rooms = append(rooms, chatroom)
+  }
+  sort.Slice(rooms, func(i, j int) bool { return rooms[i].RoomName < rooms[j].RoomName })
+  return rooms, nil
+}
+
+func(db *MemoryDB)OpenDirectChatroom(userID, peerID UUID)( *Chatroom, error ){
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  chatroom := NewDirectChatroom(userID, peerID)
+  if existing, ok := db.chatrooms[chatroom.RoomName]; ok {
+    return &existing, nil
+  }
+  if db.doesChatroomExist(chatroom.RoomName) {
+    log.Printf(" -> OpenDirectChatroom: \"%s\" has been deactivated", chatroom.RoomName)
+    return nil, GetDataError{chatroom.RoomName, CHATROOMS}
+  }
+
+  db.chatrooms[chatroom.RoomName] = chatroom
+  db.saveChatroomMember(chatroom.RoomName, userID, Member)
+  db.saveChatroomMember(chatroom.RoomName, peerID, Member)
+  db.saveDefaultRoles(chatroom.RoomName)
+  return &chatroom, nil
+}
+
+func(db *MemoryDB)DeactivateChatroom(roomName string, userID UUID) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  cm, ok := db.chatrooms[roomName]
+  if !ok {
+    return fmt.Errorf("Chatroom Doesn't Exist")
+  }
+
+  member, _ := db.getChatroomMember(roomName, userID)
+  if err := Authorize(member, PermDeactivate, nil); err != nil {
+    log.Printf(" -> DeactivateChatroom: Invalid Credentials for deactivating Chatroom: %s", err)
+    return err
+  }
+
+  db.inactiveChatrooms[roomName] = cm
+  delete(db.chatrooms, roomName)
+  return nil
+}
+
+func(db *MemoryDB)JoinChatroom(
+  chatroom string,
+  username string,
+) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  cr, ok := db.chatrooms[chatroom]
+  if !ok {
+    log.Printf(" -> Error: JoinChatroom: Chatroom doesn't exist")
+    return GetDataError{chatroom, CHATROOMS}
+  }
+  if cr.IsDirect() {
+    return FailedSecurityCheckError{"Direct Chatroom", "Direct Chatrooms can't be joined"}
+  }
+  user, err := db.getUserbyUsername(username)
+  if err != nil {
+    return err
+  }
+
+  status, _ := db.getChatroomMemberStatus(chatroom, user.UserID)
+  if done, err := checkRejoin(status); done {
+    return err
+  }
+  if err := checkCapacity(&cr, db.chatroomMembers[chatroom]); err != nil {
+    return err
+  }
+
+  var invitation *Invitation
+  if inv, ok := db.invitations[user.UserID][cr.RoomID]; ok {
+    invitation = &inv
+  }
+  if !cr.Public {
+    if err := checkInvitation(invitation, chatroom, user.UserID); err != nil {
+      log.Printf(" -> JoinChatroom: Invitation was missing or expired.")
+      return err
+    }
+  }
+  // Invitation not needed anymore. Remove it.
+  delete(db.invitations[user.UserID], cr.RoomID)
+
+  db.saveChatroomMember(chatroom, user.UserID, Member)
+  return nil
+}
+
+func(db *MemoryDB)DoesChatroomExist(chatroom string)( bool,error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  return db.doesChatroomExist(chatroom), nil
+}
+
+func(db *MemoryDB)RemoveChatroomMember(chatroomName string, userID UUID) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  if _, err := db.getChatroomMemberStatus(chatroomName, userID); err != nil {
+    return err
+  }
+  delete(db.chatroomMembers[chatroomName], userID)
+  delete(db.memberRoles, memberKey{ chatroomName, userID })
+  delete(db.readMarkers, memberKey{ chatroomName, userID })
+  return nil
+}
+
+func(db *MemoryDB)GetChatroomMember(chatroomName string, userID UUID)( *ChatroomMember, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  return db.getChatroomMember(chatroomName, userID)
+}
+
+func(db *MemoryDB)SetChatroomMemberRole(chatroomName string, userID UUID, role string) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  if _, err := db.getChatroomMemberStatus(chatroomName, userID); err != nil {
+    return err
+  }
+  if _, ok := db.roles[chatroomName][role]; !ok {
+    return GetDataError{chatroomName + "/" + role, ROLES}
+  }
+  db.chatroomMembers[chatroomName][userID] = memberTypeForRole(role)
+  db.memberRoles[memberKey{ chatroomName, userID }] = role
+  return nil
+}
+
+func(db *MemoryDB)GetChatroomRoles(chatroomName string)( []Role, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  stored, ok := db.roles[chatroomName]
+  if !ok {
+    return nil, GetDataError{chatroomName, ROLES}
+  }
+  roles := make([]Role, 0, len(stored))
+  for _, role := range stored {
+    roles = append(roles, role)
+  }
+  sortRoles(roles)
+  return roles, nil
+}
+
+func(db *MemoryDB)SaveChatroomRole(chatroomName string, role *Role) error {
+  if err := ValidateRole(role); err != nil {
+    return err
+  }
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  if _, ok := db.chatrooms[chatroomName]; !ok {
+    return GetDataError{chatroomName, CHATROOMS}
+  }
+  if _, ok := db.roles[chatroomName]; !ok {
+    db.roles[chatroomName] = make(map[string]Role)
+  }
+  db.roles[chatroomName][role.Name] = *role
+  return nil
+}
+
+func(db *MemoryDB)DeleteChatroomRole(chatroomName, name string) error {
+  if IsDefaultRole(name) {
+    return FailedSecurityCheckError{"Role", "Default Roles can't be deleted"}
+  }
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  if _, ok := db.roles[chatroomName][name]; !ok {
+    return GetDataError{chatroomName + "/" + name, ROLES}
+  }
+  delete(db.roles[chatroomName], name)
+  for key, role := range db.memberRoles {
+    if key.chatroom == chatroomName && role == name {
+      delete(db.memberRoles, key)
+    }
+  }
+  return nil
+}
+
+func(db *MemoryDB)GetChatroomMembers(chatroomName string)( map[UUID]MemberType, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  members := make(map[UUID]MemberType)
+  for userID, memberType := range db.chatroomMembers[chatroomName] {
+    members[userID] = memberType
+  }
+  return members, nil
+}
+
+func(db *MemoryDB)SaveInvitation(invitation *Invitation) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  if db.invitations[invitation.UserID] == nil {
+    db.invitations[invitation.UserID] = make(map[UUID]Invitation)
+  }
+  db.invitations[invitation.UserID][invitation.RoomID] = *invitation
+  return nil
+}
+
+func(db *MemoryDB)GetInvitation(roomID UUID, userID UUID)( *Invitation, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  invitation, ok := db.invitations[userID][roomID]
+  if !ok {
+    return nil, GetDataError{inviteKey(&roomID, &userID), INVITATIONS}
+  }
+  return &invitation, nil
+}
+
+func(db *MemoryDB)GetPendingInvitations(userID UUID)( []Invitation, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  now := time.Now()
+  invitations := []Invitation{}
+  for _, invitation := range db.invitations[userID] {
+    if _, active := db.chatrooms[invitation.Chatroom]; !active || invitation.Expired(now) {
+      continue
+    }
+    invitations = append(invitations, invitation)
+  }
+  sortInvitations(invitations)
+  return invitations, nil
+}
+
+func(db *MemoryDB)RemoveInvitation(roomID UUID, userID UUID) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  delete(db.invitations[userID], roomID)
+  return nil
+}
+
+func(db *MemoryDB)SaveInviteLink(link *InviteLink) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  db.inviteLinks[link.Code] = *link
+  return nil
+}
+
+func(db *MemoryDB)GetInviteLink(code string)( *InviteLink, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  link, ok := db.inviteLinks[code]
+  if !ok {
+    return nil, GetDataError{code, INVITELINKS}
+  }
+  return &link, nil
+}
+
+func(db *MemoryDB)RedeemInviteLink(code string, userID UUID)( *InviteLink, error ){
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  link, ok := db.inviteLinks[code]
+  if !ok {
+    return nil, GetDataError{code, INVITELINKS}
+  }
+  chatroom, active := db.chatrooms[link.Chatroom]
+  if !active {
+    return nil, GetDataError{link.Chatroom, CHATROOMS}
+  }
+  status, _ := db.getChatroomMemberStatus(link.Chatroom, userID)
+  if err := link.redeemFor(&chatroom, status, db.chatroomMembers[link.Chatroom]); err != nil {
+    return nil, err
+  }
+  db.inviteLinks[code] = link
+  db.saveChatroomMember(link.Chatroom, userID, Member)
+  return &link, nil
+}
+
+func(db *MemoryDB)HandleRawMessage(chatroomName string, userID UUID, raw []byte)( []byte, error ){
+  extraction, err := decodeChatroomMessage(raw, chatroomName, userID)
+  if err != nil {
+    return nil, err
+  }
+
+  db.mu.Lock()
+  chatroom, ok := db.chatrooms[extraction.Chatroom]
+  if !ok {
+    err = GetDataError{extraction.Chatroom, CHATROOMS}
+  } else {
+    member, _ := db.getChatroomMember(extraction.Chatroom, extraction.Message.UserID)
+    if err = authorizePost(&chatroom, member); err == nil {
+      err = db.putMessage(extraction.Chatroom, &extraction.Message)
+    }
+  }
+  db.mu.Unlock()
+  if err != nil {
+    return nil, err
+  }
+
+  return encodeChatroomMessage(extraction)
+}
+
+func(db *MemoryDB)SaveMessage(chatroom string, message *Message) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  if !db.doesChatroomExist(chatroom) {
+    log.Printf(" -> SaveMessage: Error - Chatroom Does't exist.")
+    return fmt.Errorf("Error: Received Message for a chatroom that doesn't exist")
+  }
+  return db.putMessage(chatroom, message)
+}
+
+// SaveMessages :: Everything is checked up front, since there's no transaction to
+//    roll back. Replies to Messages within the batch are checked against the batch.
+func(db *MemoryDB)SaveMessages(chatroom string, messages []Message) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  if !db.doesChatroomExist(chatroom) {
+    log.Printf(" -> SaveMessages: Error - Chatroom Does't exist.")
+    return fmt.Errorf("Error: Received Messages for a chatroom that doesn't exist")
+  }
+  batch := make(map[UUID]bool, len(messages))
+  for _, message := range messages {
+    if message.IsReply() && !batch[message.ReplyTo] {
+      parent := db.getMessage(chatroom, message.ReplyTo)
+      if parent == nil {
+        return GetDataError{message.ReplyTo.String(), MESSAGEIDS}
+      }
+      if parent.IsDeleted() {
+        return MessageDeletedError{parent.ID.String()}
+      }
+    }
+    batch[message.ID] = true
+  }
+  for i := range messages {
+    if err := db.putMessage(chatroom, &messages[i]); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// PurgeMessages :: Expired Messages are always the oldest, so they're cut off the
+//    front of the Chatroom's Messages. db.sequences is left alone.
+func(db *MemoryDB)PurgeMessages(
+  chatroomName string,
+  retention    Retention,
+  now          time.Time,
+  limit        int,
+)( int, error ){
+  if retention.Forever() {
+    return 0, nil
+  }
+  if limit <= 0 {
+    limit = DefaultPurgeBatchSize
+  }
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  msgs := db.messages[chatroomName]
+  n := 0
+  for n < len(msgs) && n < limit && retention.expired(msgs[n].TimeStamp, len(msgs)-n-1, now) {
+    message := &msgs[n]
+    db.unindexMessage(chatroomName, message)
+    delete(db.messageIDs, messageKey{ chatroomName, message.ID })
+    delete(db.revisions, messageKey{ chatroomName, message.ID })
+    if message.ThreadID != (UUID{}) {
+      key := messageKey{ chatroomName, message.ThreadID }
+      replies := db.threads[key]
+      for i, seq := range replies {
+        if seq == message.Seq {
+          db.threads[key] = append(replies[:i:i], replies[i+1:]...)
+          break
+        }
+      }
+    } else {
+      delete(db.threads, messageKey{ chatroomName, message.ID })
+    }
+    n++
+  }
+  if n > 0 {
+    db.messages[chatroomName] = append([]Message{}, msgs[n:]...)
+  }
+  return n, nil
+}
+
+// Paginate :: Each Chatroom's Messages are already sorted by Seq, so the bounds
+//    are found with a binary search.
+func(db *MemoryDB)Paginate(
+  chatroomName string,
+  before, after uint64,
+  limit int,
+)( *MessagePage, error ){
+  limit = ClampPageSize(limit)
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  return paginateMessages(db.messages[chatroomName], before, after, limit), nil
+}
+
+// ScanMessages :: Copies the batch out, so it's safe to use once the lock is released.
+func(db *MemoryDB)ScanMessages(chatroomName string, after uint64, limit int)( []Message, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  msgs := db.messages[chatroomName]
+  lo := sort.Search(len(msgs), func(i int) bool { return msgs[i].Seq > after })
+  hi := len(msgs)
+  if hi-lo > limit {
+    hi = lo + limit
+  }
+  return append([]Message{}, msgs[lo:hi]...), nil
+}
+
+// PaginateThread :: Threads only hold the seq of each reply, which are looked up
+//    within the Chatroom's Messages before paging through them.
+func(db *MemoryDB)PaginateThread(
+  chatroomName string,
+  threadID     UUID,
+  before, after uint64,
+  limit int,
+)( *MessagePage, error ){
+  limit = ClampPageSize(limit)
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  seqs := db.threads[messageKey{ chatroomName, threadID }]
+  replies := make([]Message, 0, len(seqs))
+  msgs := db.messages[chatroomName]
+  for _, seq := range seqs {
+    i := sort.Search(len(msgs), func(i int) bool { return msgs[i].Seq >= seq })
+    if i < len(msgs) && msgs[i].Seq == seq {
+      replies = append(replies, msgs[i])
+    }
+  }
+  return paginateMessages(replies, before, after, limit), nil
+}
+
+// paginateMessages :: msgs must already be sorted by Seq. The bounds are found
+//    with a binary search.
+func paginateMessages(msgs []Message, before, after uint64, limit int) *MessagePage {
+  // lo is the first Message with Seq > after, hi the first Message with Seq >= before.
+  lo := sort.Search(len(msgs), func(i int) bool { return msgs[i].Seq > after })
+  hi := len(msgs)
+  if before > 0 {
+    hi = sort.Search(len(msgs), func(i int) bool { return msgs[i].Seq >= before })
+  }
+  if hi < lo {
+    hi = lo
+  }
+
+  if after > 0 && before == 0 {
+    if hi-lo > limit {
+      hi = lo + limit
+    }
+  } else if hi-lo > limit {
+    lo = hi - limit
+  }
+
+  page := append([]Message(nil), msgs[lo:hi]...)
+  return NewMessagePage(page, lo > 0, hi < len(msgs))
+}
+
+func(db *MemoryDB)GetMessage(chatroom string, messageID UUID)( *Message, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  message := db.getMessage(chatroom, messageID)
+  if message == nil {
+    return nil, GetDataError{messageID.String(), MESSAGEIDS}
+  }
+  msg := *message
+  return &msg, nil
+}
+
+func(db *MemoryDB)EditMessage(
+  chatroom  string,
+  messageID UUID,
+  editorID  UUID,
+  content   string,
+)( *Message, error ){
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  message := db.getMessage(chatroom, messageID)
+  if message == nil {
+    return nil, GetDataError{messageID.String(), MESSAGEIDS}
+  }
+  if message.IsDeleted() {
+    return nil, MessageDeletedError{messageID.String()}
+  }
+
+  now := time.Now()
+  key := messageKey{ chatroom, messageID }
+  db.revisions[key] = append(db.revisions[key], MessageRevision{ message.Content, now, editorID })
+
+  db.unindexMessage(chatroom, message)
+  message.Content = content
+  message.EditedAt = now
+  db.indexMessage(chatroom, message)
+
+  msg := *message
+  return &msg, nil
+}
+
+func(db *MemoryDB)DeleteMessage(chatroom string, messageID UUID)( *Message, error ){
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  message := db.getMessage(chatroom, messageID)
+  if message == nil {
+    return nil, GetDataError{messageID.String(), MESSAGEIDS}
+  }
+  if message.IsDeleted() {
+    return nil, MessageDeletedError{messageID.String()}
+  }
+
+  db.unindexMessage(chatroom, message)
+  delete(db.revisions, messageKey{ chatroom, messageID })
+  message.Content = ""
+  message.Reactions = nil
+  message.DeletedAt = time.Now()
+
+  msg := *message
+  return &msg, nil
+}
+
+func(db *MemoryDB)AddReaction(chatroom string, messageID UUID, userID UUID, reaction string)( *Message, error ){
+  return db.updateReaction(chatroom, messageID, func(message *Message) bool {
+    return message.addReaction(reaction, userID)
+  })
+}
+
+func(db *MemoryDB)RemoveReaction(chatroom string, messageID UUID, userID UUID, reaction string)( *Message, error ){
+  return db.updateReaction(chatroom, messageID, func(message *Message) bool {
+    return message.removeReaction(reaction, userID)
+  })
+}
+
+func(db *MemoryDB)updateReaction(
+  chatroom  string,
+  messageID UUID,
+  update    func(message *Message) bool,
+)( *Message, error ){
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  message := db.getMessage(chatroom, messageID)
+  if message == nil {
+    return nil, GetDataError{messageID.String(), MESSAGEIDS}
+  }
+  if message.IsDeleted() {
+    return nil, MessageDeletedError{messageID.String()}
+  }
+  // Copy the Reactions, so Messages already handed out by Paginate don't change underneath their callers.
+  message.Reactions = copyReactions(message.Reactions)
+  update(message)
+
+  msg := *message
+  msg.Reactions = copyReactions(message.Reactions)
+  return &msg, nil
+}
+
+func copyReactions(reactions []Reaction) []Reaction {
+  if reactions == nil {
+    return nil
+  }
+  copied := make([]Reaction, len(reactions))
+  for i, r := range reactions {
+    copied[i] = Reaction{ r.Reaction, r.Count, append([]UUID(nil), r.UserIDs...) }
+  }
+  return copied
+}
+
+func(db *MemoryDB)GetMessageRevisions(chatroom string, messageID UUID)( []MessageRevision, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  if db.getMessage(chatroom, messageID) == nil {
+    return nil, GetDataError{messageID.String(), MESSAGEIDS}
+  }
+  return append([]MessageRevision{}, db.revisions[messageKey{ chatroom, messageID }]...), nil
+}
+
+// SearchMessages :: Intersects the inverted index of every term, starting from
+//    the first term's hits.
+func(db *MemoryDB)SearchMessages(query SearchQuery)( []ChatroomMessage, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  results := []ChatroomMessage{}
+  if len(query.Terms) == 0 {
+    return results, nil
+  }
+  for hit := range db.searchIndex[query.Terms[0]] {
+    if !query.allows(hit.chatroom) {
+      continue
+    }
+    matched := true
+    for _, term := range query.Terms[1:] {
+      if !db.searchIndex[term][hit] {
+        matched = false
+        break
+      }
+    }
+    if !matched {
+      continue
+    }
+
+    msgs := db.messages[hit.chatroom]
+    i := sort.Search(len(msgs), func(i int) bool { return msgs[i].Seq >= hit.seq })
+    if i == len(msgs) || msgs[i].Seq != hit.seq {
+      continue
+    }
+    if query.matches(&msgs[i]) {
+      results = append(results, ChatroomMessage{ Chatroom: hit.chatroom, Message: msgs[i] })
+    }
+  }
+  return limitSearchResults(query, results), nil
+}
+
+func(db *MemoryDB)MarkRead(chatroom string, userID UUID, seq uint64)( uint64, error ){
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  status, err := db.getChatroomMemberStatus(chatroom, userID)
+  if err != nil {
+    return 0, err
+  }
+  if *status == Blocked {
+    return 0, GetDataError{chatroom + "-" + userID.String(), CHATROOMMEMBERS}
+  }
+
+  latest := db.sequences[chatroom]
+  if seq == 0 || seq > latest {
+    seq = latest
+  }
+  key := memberKey{ chatroom, userID }
+  if seq > db.readMarkers[key] {
+    db.readMarkers[key] = seq
+  }
+  return db.readMarkers[key], nil
+}
+
+// GetJoinedChatrooms :: There's no separate list of joined Chatrooms, every
+//    Chatroom's Members are checked instead.
+func(db *MemoryDB)GetJoinedChatrooms(userID UUID)( []JoinedChatroom, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  rooms := []JoinedChatroom{}
+  for chatroom, members := range db.chatroomMembers {
+    memberType, ok := members[userID]
+    if !ok || memberType == Blocked {
+      continue
+    }
+    cr, active := db.chatrooms[chatroom]
+    if !active {
+      continue
+    }
+    var peerID UUID
+    if cr.IsDirect() {
+      peerID = cr.DirectPeer(userID)
+    }
+
+    lastRead := db.readMarkers[memberKey{ chatroom, userID }]
+    msgs := db.messages[chatroom]
+    i := sort.Search(len(msgs), func(i int) bool { return msgs[i].Seq > lastRead })
+    var unread uint64
+    for _, msg := range msgs[i:] {
+      if !msg.IsDeleted() {
+        unread++
+      }
+    }
+    rooms = append(rooms, JoinedChatroom{
+      Chatroom:   chatroom,
+      MemberType: memberType,
+      LastRead:   lastRead,
+      Unread:     unread,
+      PeerID:     peerID,
+    })
+  }
+  sort.Slice(rooms, func(i, j int) bool { return rooms[i].Chatroom < rooms[j].Chatroom })
+  return rooms, nil
+}
+
+func(db *MemoryDB)UpdateChatroomUserStatus(chatroom, username string, status Status) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  user, err := db.getUserbyUsername(username)
+  if err != nil {
+    return err
+  }
+
+  memtype, err := db.getChatroomMemberStatus(chatroom, user.UserID)
+  if err != nil {
+    return FailedSecurityCheckError{"MemberType: nil", err.Error()}
+  }
+  if *memtype == Blocked {
+    return FailedSecurityCheckError{"MemberType: blocked", "user is blocked"}
+  }
+
+  users, ok := db.liveMembers[chatroom]
+  if !ok {
+    users = make(map[UserName]string)
+    db.liveMembers[chatroom] = users
+  }
+
+  switch status {
+  case Online:
+    users[username] = "Online"
+  case Background:
+    users[username] = "Background"
+  case Offline:
+    users[username] = "Offline"
+  case Delete:
+    delete(users, username)
+  default:
+    return fmt.Errorf("Unknonw Status Request")
+  }
+  return nil
+}
+
+func(db *MemoryDB)GetChatroomUserStatus(chatroom string)( map[UserName]string, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  users, ok := db.liveMembers[chatroom]
+  if !ok {
+    return nil, GetDataError{chatroom, LIVEMEMBER}
+  }
+  stats := make(map[UserName]string, len(users))
+  for username, status := range users {
+    stats[username] = status
+  }
+  return stats, nil
+}
+
+func(db *MemoryDB)SaveChatroomMember(
+  chatroomName string,
+  userID UUID,
+  memberType MemberType,
+) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  db.saveChatroomMember(chatroomName, userID, memberType)
+  return nil
+}
+
+func(db *MemoryDB)GetChatroomMemberStatus(
+  chatroomName string,
+  userID UUID,
+)( *MemberType, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  return db.getChatroomMemberStatus(chatroomName, userID)
+}
+
+func(db *MemoryDB)SaveUser(user User, token *token.Token) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  if uid, ok := db.usernames[user.Username]; ok && uid != user.UserID {
+    log.Printf(" -> Error: The Username \"%s\" is aldready taken", user.Username)
+    return PutDataError{user.Username, USERNAMES, "username already taken"}
+  }
+
+  db.users[user.UserID] = user
+  db.usernames[user.Username] = user.UserID
+  // Users are only Online while connected to a Chatroom.
+  if _, ok := db.usersOnline[user.Username]; !ok {
+    db.usersOnline[user.Username] = false
+  }
+  if token != nil {
+    db.userTokens[user.UserID] = *token
+  }
+  return nil
+}
+
+func(db *MemoryDB)GetUserByID(id UUID)( *User,error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  user, ok := db.users[id]
+  if !ok {
+    log.Printf(" -> GetUserById - User ID \"%s\" not found.", id)
+    return nil, GetDataError{id.String(), USERS}
+  }
+  return &user, nil
+}
+
+func(db *MemoryDB)ActivateUser(userID UUID) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  user, ok := db.deactivatedUsers[userID]
+  if !ok {
+    return GetDataError{userID.String(), DEACTIVATEDUSERS}
+  }
+
+  db.users[userID] = user
+  db.usernames[user.Username] = userID
+  delete(db.deactivatedUsers, userID)
+  return nil
+}
+
+func(db *MemoryDB)DeactivateUser(userID UUID) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  user, ok := db.users[userID]
+  if !ok {
+    return GetDataError{userID.String(), USERS}
+  }
+
+  // db.usernames keeps pointing at them, so their Username stays reserved.
+  db.deactivatedUsers[userID] = user
+  delete(db.users, userID)
+  delete(db.userTokens, userID)
+  db.usersOnline[user.Username] = false
+  return nil
+}
+
+func(db *MemoryDB)GetDeactivatedUser(username string)( *User, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  user, ok := db.deactivatedUsers[db.usernames[username]]
+  if !ok {
+    return nil, GetDataError{username, DEACTIVATEDUSERS}
+  }
+  return &user, nil
+}
+
+func(db *MemoryDB)GetDeactivatedUserByID(id UUID)( *User, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  user, ok := db.deactivatedUsers[id]
+  if !ok {
+    return nil, GetDataError{id.String(), DEACTIVATEDUSERS}
+  }
+  return &user, nil
+}
+
+func(db *MemoryDB)GetUserbyUsername(username string)( *User, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  return db.getUserbyUsername(username)
+}
+
+func(db *MemoryDB)SaveUsersOnlineStatus(username string, isOnline bool) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  db.usersOnline[username] = isOnline
+  return nil
+}
+
+func(db *MemoryDB)SaveUserToken(userID UUID, token *token.Token) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  db.userTokens[userID] = *token
+  return nil
+}
+
+func(db *MemoryDB)GetUserToken(userID UUID)( *token.Token, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  token, ok := db.userTokens[userID]
+  if !ok {
+    log.Printf(" -> GetUserToken: User Token for \"%s\" doesn't exist", userID.String())
+    return nil, GetDataError{userID.String(), USERTOKENS}
+  }
+  return &token, nil
+}
+
+func(db *MemoryDB)GetProfile(userID UUID)( *Profile, error ){
+  db.mu.RLock()
+  defer db.mu.RUnlock()
+
+  user, ok := db.users[userID]
+  if !ok {
+    return nil, GetDataError{userID.String(), USERS}
+  }
+  profile, ok := db.profiles[userID]
+  if !ok {
+    profile = Profile{ UserID: userID }
+  }
+  profile.Username = user.Username
+  return &profile, nil
+}
+
+func(db *MemoryDB)SaveProfile(profile *Profile) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  if _, ok := db.users[profile.UserID]; !ok {
+    return GetDataError{profile.UserID.String(), USERS}
+  }
+  stored := *profile
+  stored.Username = ""
+  db.profiles[profile.UserID] = stored
+  return nil
+}
+
+func(db *MemoryDB)UpdateUserPassword(userID UUID, hashedPassword []byte, token *token.Token) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  user, ok := db.users[userID]
+  if !ok {
+    return GetDataError{userID.String(), USERS}
+  }
+  user.HashedPassword = hashedPassword
+  db.users[userID] = user
+  if token != nil {
+    db.userTokens[userID] = *token
+  } else {
+    delete(db.userTokens, userID)
+  }
+  db.removePasswordResets(userID)
+  return nil
+}
+
+func(db *MemoryDB)SavePasswordReset(reset *PasswordReset) error {
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  db.removePasswordResets(reset.UserID)
+  db.passwordResets[reset.TokenHash] = *reset
+  return nil
+}
+
+func(db *MemoryDB)ConsumePasswordReset(resetToken string)( *PasswordReset, error ){
+  db.mu.Lock()
+  defer db.mu.Unlock()
+
+  hash := HashResetToken(resetToken)
+  reset, ok := db.passwordResets[hash]
+  if !ok {
+    return nil, GetDataError{"reset token", PASSWORDRESETS}
+  }
+  delete(db.passwordResets, hash)
+  if err := checkPasswordReset(&reset); err != nil {
+    return nil, err
+  }
+  return &reset, nil
+}
+
+func(db *MemoryDB)Close() {}
+
+// ----------------------- MemoryDB Helper Funcs -----------------------
+// The following helpers expect the caller to already be holding db.mu.
+
+// removePasswordResets :: Every PasswordReset for userID, and any that have expired.
+func(db *MemoryDB)removePasswordResets(userID UUID) {
+  now := time.Now()
+  for hash, reset := range db.passwordResets {
+    if reset.UserID == userID || reset.Expired(now) {
+      delete(db.passwordResets, hash)
+    }
+  }
+}
+
+func(db *MemoryDB)doesChatroomExist(chatroom string) bool {
+  if _, ok := db.chatrooms[chatroom]; ok {
+    return true
+  }
+  _, ok := db.inactiveChatrooms[chatroom]
+  return ok
+}
+
+func(db *MemoryDB)saveChatroomMember(chatroomName string, userID UUID, memberType MemberType) {
+  members, ok := db.chatroomMembers[chatroomName]
+  if !ok {
+    members = make(map[UUID]MemberType)
+    db.chatroomMembers[chatroomName] = members
+  }
+  members[userID] = memberType
+  delete(db.memberRoles, memberKey{ chatroomName, userID })
+}
+
+// getChatroomMember :: Returns a GetDataError, and a nil Member, for non-Members.
+func(db *MemoryDB)getChatroomMember(chatroomName string, userID UUID)( *ChatroomMember, error ){
+  memberType, err := db.getChatroomMemberStatus(chatroomName, userID)
+  if err != nil {
+    return nil, err
+  }
+  return resolveMember(userID, *memberType, db.memberRoles[memberKey{ chatroomName, userID }], func(name string)( *Role, error ){
+    if role, ok := db.roles[chatroomName][name]; ok {
+      return &role, nil
+    }
+    return nil, nil
+  })
+}
+
+func(db *MemoryDB)saveDefaultRoles(chatroomName string) {
+  roles := make(map[string]Role)
+  for _, role := range DefaultRoles() {
+    roles[role.Name] = role
+  }
+  db.roles[chatroomName] = roles
+}
+
+func(db *MemoryDB)getChatroomMemberStatus(chatroomName string, userID UUID)( *MemberType, error ){
+  stat, ok := db.chatroomMembers[chatroomName][userID]
+  if !ok {
+    return nil, GetDataError{chatroomName + "-" + userID.String(), CHATROOMMEMBERS}
+  }
+  return &stat, nil
+}
+
+func(db *MemoryDB)getUserbyUsername(username string)( *User, error ){
+  uid, ok := db.usernames[username]
+  if !ok {
+    log.Printf(" -> GetUserbyUsername - User ID \"%s\" not found.", username)
+    return nil, GetDataError{username, USERNAMES}
+  }
+  user, ok := db.users[uid]
+  if !ok {
+    return nil, GetDataError{username, USERS}
+  }
+  return &user, nil
+}
+
+// putMessage :: Appends message to the Chatroom's Messages, handing out the
+//    Chatroom's next sequence number. Sets message.Seq and message.ThreadID.
+func(db *MemoryDB)putMessage(chatroom string, message *Message) error {
+  message.ThreadID = UUID{}
+  if message.IsReply() {
+    parent := db.getMessage(chatroom, message.ReplyTo)
+    if parent == nil {
+      return GetDataError{message.ReplyTo.String(), MESSAGEIDS}
+    }
+    if err := message.joinThread(parent); err != nil {
+      return err
+    }
+  }
+
+  db.sequences[chatroom]++
+  message.Seq = db.sequences[chatroom]
+  db.messages[chatroom] = append(db.messages[chatroom], *message)
+  db.messageIDs[messageKey{ chatroom, message.ID }] = message.Seq
+  if message.ThreadID != (UUID{}) {
+    key := messageKey{ chatroom, message.ThreadID }
+    db.threads[key] = append(db.threads[key], message.Seq)
+  }
+  db.indexMessage(chatroom, message)
+  return nil
+}
+
+// getMessage :: Returns a pointer into the Chatroom's Messages, or nil.
+func(db *MemoryDB)getMessage(chatroom string, messageID UUID) *Message {
+  seq, ok := db.messageIDs[messageKey{ chatroom, messageID }]
+  if !ok {
+    return nil
+  }
+  msgs := db.messages[chatroom]
+  i := sort.Search(len(msgs), func(i int) bool { return msgs[i].Seq >= seq })
+  if i == len(msgs) || msgs[i].Seq != seq {
+    return nil
+  }
+  return &msgs[i]
+}
+
+func(db *MemoryDB)indexMessage(chatroom string, message *Message) {
+  for _, term := range Tokenize(message.Content) {
+    if db.searchIndex[term] == nil {
+      db.searchIndex[term] = make(map[searchHit]bool)
+    }
+    db.searchIndex[term][searchHit{ chatroom, message.Seq }] = true
+  }
+}
+
+func(db *MemoryDB)unindexMessage(chatroom string, message *Message) {
+  for _, term := range Tokenize(message.Content) {
+    delete(db.searchIndex[term], searchHit{ chatroom, message.Seq })
+  }
+}
+
+// memberKey :: A single User within a single Chatroom.
+type memberKey struct {
+  chatroom RoomName
+  userID   UUID
+}
+
+// messageKey :: Message IDs are only unique within a Chatroom.
+type messageKey struct {
+  chatroom RoomName
+  id       UUID
+}
+
+// searchHit :: A single Message within MemoryDB's inverted index.
+type searchHit struct {
+  chatroom RoomName
+  seq      uint64
+}
+
@@ -0,0 +1,584 @@
+package db
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ugorji/go/codec"
+	"go.etcd.io/bbolt"
+)
+
+// migration :: A single, ordered step of BBoltDB's schema. Every step runs within
+//    it's own write transaction, and bumps /Meta/SchemaVersion once it succeeds.
+type migration struct {
+  version     uint64
+  description string
+  migrate     func(tx *bbolt.Tx) error
+}
+
+// bboltMigrations :: Must stay sorted by version, with no gaps. Never edit a
+//    released migration. If a bucket or an encoded struct changes, append a new
+//    migration that creates the bucket or rewrites the old records.
+var bboltMigrations = []migration{
+  {
+    version:     1,
+    description: "Create every bucket up front",
+    migrate: func(tx *bbolt.Tx) error {
+      return createBuckets(tx,
+        CHATROOMS, INACTIVECHATROOMS, CHATROOMMEMBERS, LIVEMEMBER, MESSAGES,
+        USERS, DEACTIVATEDUSERS, USERNAMES, USERSONLINE, USERTOKENS,
+        JOINEDCHATROOMS, INVITATIONS,
+      )
+    },
+  },
+  {
+    version:     2,
+    description: "Move /Messages/{chatroom-timestamp} into /Messages/{chatroom}/{seq}",
+    migrate:     migrateFlatMessages,
+  },
+  {
+    version:     3,
+    description: "Build the /SearchIndex inverted index",
+    migrate:     migrateSearchIndex,
+  },
+  {
+    version:     4,
+    description: "Index Messages by ID, and keep their MessageRevisions",
+    migrate:     migrateMessageIDs,
+  },
+  {
+    version:     5,
+    description: "Create the /Threads bucket",
+    migrate: func(tx *bbolt.Tx) error {
+      return createBuckets(tx, THREADS)
+    },
+  },
+  {
+    version:     6,
+    description: "Fill /JoinedChatrooms from /ChatroomMembers, and create /ReadMarkers",
+    migrate:     migrateJoinedChatrooms,
+  },
+  {
+    version:     7,
+    description: "Drop secret based /Invitations/{room_id-user_id}",
+    migrate:     migrateSecretInvitations,
+  },
+  {
+    version:     8,
+    description: "Create the /InviteLinks bucket",
+    migrate: func(tx *bbolt.Tx) error {
+      return createBuckets(tx, INVITELINKS)
+    },
+  },
+  {
+    version:     9,
+    description: "Give every Chatroom the default Roles within /Roles",
+    migrate:     migrateDefaultRoles,
+  },
+  {
+    version:     10,
+    description: "Key /DeactivatedUsers by UserID strings, keep their Usernames reserved and revoke their Access Tokens",
+    migrate:     migrateDeactivatedUsers,
+  },
+  {
+    version:     11,
+    description: "Create the /PasswordResets bucket",
+    migrate: func(tx *bbolt.Tx) error {
+      return createBuckets(tx, PASSWORDRESETS)
+    },
+  },
+  {
+    version:     12,
+    description: "Create the /Profiles bucket",
+    migrate: func(tx *bbolt.Tx) error {
+      return createBuckets(tx, PROFILES)
+    },
+  },
+  {
+    version:     13,
+    description: "Index every tombstone within /DeletedMessages",
+    migrate:     migrateDeletedMessages,
+  },
+}
+
+// LatestSchemaVersion :: The schema version this binary knows how to work with.
+func LatestSchemaVersion() uint64 {
+  return bboltMigrations[len(bboltMigrations)-1].version
+}
+
+// migrate :: Reads the current schema version from /Meta, then runs every
+//    migration newer than it in order. Refuses to run on a Database whose
+//    schema is newer than LatestSchemaVersion.
+func(db *BBoltDB)migrate() error {
+  var current uint64
+  err := db.db.Update(func(tx *bbolt.Tx) error {
+    meta, err := tx.CreateBucketIfNotExists([]byte(META))
+    if err != nil {
+      return BucketNotFoundError{META}
+    }
+    if data := meta.Get([]byte(SCHEMAVERSION)); data != nil {
+      current = btoi(data)
+    }
+    return nil
+  })
+  if err != nil {
+    return err
+  }
+
+  if current > LatestSchemaVersion() {
+    log.Printf(" -> migrate: Schema version %d is newer than %d", current, LatestSchemaVersion())
+    return SchemaVersionError{current, LatestSchemaVersion()}
+  }
+
+  for _, m := range bboltMigrations {
+    if m.version <= current {
+      continue
+    }
+    err := db.db.Update(func(tx *bbolt.Tx) error {
+      if err := m.migrate(tx); err != nil {
+        return err
+      }
+      meta := tx.Bucket([]byte(META))
+      if err := meta.Put([]byte(SCHEMAVERSION), itob(m.version)); err != nil {
+        return PutDataError{SCHEMAVERSION, META, err.Error()}
+      }
+      return nil
+    })
+    if err != nil {
+      log.Printf(" -> migrate: Migration %d \"%s\" FAILURE: %s", m.version, m.description, err)
+      return fmt.Errorf("Failed to apply migration %d: %s", m.version, err)
+    }
+    log.Printf(" -> migrate: Applied migration %d \"%s\"", m.version, m.description)
+  }
+  return nil
+}
+
+// migrateFlatMessages :: Version 1 stored every Message flat within /Messages,
+//    keyed by "{chatroom}-{timestamp}". Since DATEFMT sorts chronologically, and
+//    never contains a '-', walking the keys in order lets us hand out each room's
+//    sequence numbers in the order the Messages were sent.
+func migrateFlatMessages(tx *bbolt.Tx) error {
+  messages := tx.Bucket([]byte(MESSAGES))
+  if messages == nil {
+    return BucketNotFoundError{MESSAGES}
+  }
+
+  type flatMessage struct{ key, chatroom string; value []byte }
+  var flat []flatMessage
+  err := messages.ForEach(func(k, v []byte) error {
+    if v == nil {
+      // Already a nested Chatroom bucket.
+      return nil
+    }
+    key := string(k)
+    i := strings.LastIndex(key, "-")
+    if i <= 0 {
+      log.Printf(" -> migrateFlatMessages: Skipping malformed key \"%s\"", key)
+      return nil
+    }
+    flat = append(flat, flatMessage{ key, key[:i], append([]byte(nil), v...) })
+    return nil
+  })
+  if err != nil {
+    return err
+  }
+
+  for _, fm := range flat {
+    if err := messages.Delete([]byte(fm.key)); err != nil {
+      return DeleteDataError{fm.key, MESSAGES, err.Error()}
+    }
+  }
+  for _, fm := range flat {
+    var message Message
+    dec := codec.NewDecoderBytes(fm.value, &JSONHandle)
+    if err := dec.Decode(&message); err != nil {
+      return DecoderError{err.Error()}
+    }
+    // Written out by hand, rather than with boltPutMessage, so that later
+    // changes to how Messages are stored can't change what this step does.
+    room, err := messages.CreateBucketIfNotExists([]byte(fm.chatroom))
+    if err != nil {
+      return BucketNotFoundError{MESSAGES + "/" + fm.chatroom}
+    }
+    if message.Seq, err = room.NextSequence(); err != nil {
+      return PutDataError{fm.chatroom, MESSAGES, err.Error()}
+    }
+    var data []byte
+    enc := codec.NewEncoderBytes(&data, &JSONHandle)
+    if err := enc.Encode(message); err != nil {
+      return EncoderError{err.Error()}
+    }
+    if err := room.Put(itob(message.Seq), data); err != nil {
+      return PutDataError{fm.chatroom, MESSAGES, err.Error()}
+    }
+  }
+  log.Printf(" -> migrateFlatMessages: Moved %d Messages", len(flat))
+  return nil
+}
+
+// migrateSearchIndex :: Indexes every Message stored before /SearchIndex existed.
+func migrateSearchIndex(tx *bbolt.Tx) error {
+  if err := createBuckets(tx, SEARCHINDEX); err != nil {
+    return err
+  }
+  messages := tx.Bucket([]byte(MESSAGES))
+  if messages == nil {
+    return BucketNotFoundError{MESSAGES}
+  }
+  index := tx.Bucket([]byte(SEARCHINDEX))
+
+  indexed := 0
+  err := messages.ForEach(func(chatroom, v []byte) error {
+    room := messages.Bucket(chatroom)
+    if v != nil || room == nil {
+      return nil
+    }
+    return room.ForEach(func(seq, data []byte) error {
+      var message Message
+      dec := codec.NewDecoderBytes(data, &JSONHandle)
+      if err := dec.Decode(&message); err != nil {
+        return DecoderError{err.Error()}
+      }
+      indexed++
+      // Written out by hand, rather than with boltIndexMessage. Terms still come
+      // from Tokenize, since they have to match the ones searches look up.
+      for _, term := range Tokenize(message.Content) {
+        key := make([]byte, 0, len(term)+len(chatroom)+10)
+        key = append(key, term...)
+        key = append(key, 0)
+        key = append(key, chatroom...)
+        key = append(key, 0)
+        key = append(key, seq...)
+        if err := index.Put(key, []byte{}); err != nil {
+          return PutDataError{term, SEARCHINDEX, err.Error()}
+        }
+      }
+      return nil
+    })
+  })
+  if err != nil {
+    return err
+  }
+  log.Printf(" -> migrateSearchIndex: Indexed %d Messages", indexed)
+  return nil
+}
+
+// migrateMessageIDs :: Builds /MessageIDs/{chatroom}/{message_id} -> seq for every
+//    existing Message, so Messages can be edited and deleted by ID.
+func migrateMessageIDs(tx *bbolt.Tx) error {
+  if err := createBuckets(tx, MESSAGEIDS, MESSAGEREVISIONS); err != nil {
+    return err
+  }
+  messages := tx.Bucket([]byte(MESSAGES))
+  if messages == nil {
+    return BucketNotFoundError{MESSAGES}
+  }
+  ids := tx.Bucket([]byte(MESSAGEIDS))
+
+  indexed := 0
+  err := messages.ForEach(func(chatroom, v []byte) error {
+    room := messages.Bucket(chatroom)
+    if v != nil || room == nil {
+      return nil
+    }
+    roomIDs, err := ids.CreateBucketIfNotExists(chatroom)
+    if err != nil {
+      return BucketNotFoundError{MESSAGEIDS + "/" + string(chatroom)}
+    }
+    return room.ForEach(func(seq, data []byte) error {
+      var message Message
+      dec := codec.NewDecoderBytes(data, &JSONHandle)
+      if err := dec.Decode(&message); err != nil {
+        return DecoderError{err.Error()}
+      }
+      indexed++
+      if err := roomIDs.Put([]byte(message.ID.String()), seq); err != nil {
+        return PutDataError{message.ID.String(), MESSAGEIDS, err.Error()}
+      }
+      return nil
+    })
+  })
+  if err != nil {
+    return err
+  }
+  log.Printf(" -> migrateMessageIDs: Indexed %d Messages", indexed)
+  return nil
+}
+
+// migrateJoinedChatrooms :: /JoinedChatrooms was never written to before version 6.
+//    /ChatroomMembers keys are "{chatroom}-{user_id}", and a UUID string is always
+//    36 characters long, so the Chatroom name is everything before it.
+func migrateJoinedChatrooms(tx *bbolt.Tx) error {
+  if err := createBuckets(tx, READMARKERS); err != nil {
+    return err
+  }
+  members := tx.Bucket([]byte(CHATROOMMEMBERS))
+  if members == nil {
+    return BucketNotFoundError{CHATROOMMEMBERS}
+  }
+
+  const uuidLength = 36
+  type membership struct{ chatroom string; userID UUID; memberType MemberType }
+  var memberships []membership
+  err := members.ForEach(func(k, v []byte) error {
+    key := string(k)
+    if len(key) < uuidLength+2 || key[len(key)-uuidLength-1] != '-' || len(v) == 0 {
+      log.Printf(" -> migrateJoinedChatrooms: Skipping malformed key \"%s\"", key)
+      return nil
+    }
+    userID, err := uuid.Parse(key[len(key)-uuidLength:])
+    if err != nil {
+      log.Printf(" -> migrateJoinedChatrooms: Skipping malformed key \"%s\"", key)
+      return nil
+    }
+    memberships = append(memberships, membership{ key[:len(key)-uuidLength-1], userID, MemberType(v[0]) })
+    return nil
+  })
+  if err != nil {
+    return err
+  }
+
+  // Written out by hand, rather than with boltSaveJoinedChatroom.
+  joinedChatrooms := tx.Bucket([]byte(JOINEDCHATROOMS))
+  if joinedChatrooms == nil {
+    return BucketNotFoundError{JOINEDCHATROOMS}
+  }
+  for _, m := range memberships {
+    joined, err := joinedChatrooms.CreateBucketIfNotExists([]byte(m.userID.String()))
+    if err != nil {
+      return BucketNotFoundError{JOINEDCHATROOMS + "/" + m.userID.String()}
+    }
+    if m.memberType == Blocked {
+      if err := joined.Delete([]byte(m.chatroom)); err != nil {
+        return DeleteDataError{m.chatroom, JOINEDCHATROOMS, err.Error()}
+      }
+      continue
+    }
+    var data []byte
+    enc := codec.NewEncoderBytes(&data, &JSONHandle)
+    if err := enc.Encode(JoinedChatroom{ Chatroom: m.chatroom, MemberType: m.memberType }); err != nil {
+      return EncoderError{err.Error()}
+    }
+    if err := joined.Put([]byte(m.chatroom), data); err != nil {
+      return PutDataError{m.chatroom, JOINEDCHATROOMS, err.Error()}
+    }
+  }
+  log.Printf(" -> migrateJoinedChatrooms: Added %d Chatroom Members", len(memberships))
+  return nil
+}
+
+// migrateSecretInvitations :: Before version 7, /Invitations held a salted secret
+//    per "{room_id}-{user_id}" key, which the invitee had to hand back when joining.
+//    Nothing could create them, and they carry no inviter or expiry to convert, so
+//    they're dropped. Invitations now live in nested /Invitations/{user_id} Buckets.
+func migrateSecretInvitations(tx *bbolt.Tx) error {
+  invitations := tx.Bucket([]byte(INVITATIONS))
+  if invitations == nil {
+    return BucketNotFoundError{INVITATIONS}
+  }
+
+  var keys [][]byte
+  err := invitations.ForEach(func(k, v []byte) error {
+    if v != nil {
+      keys = append(keys, append([]byte(nil), k...))
+    }
+    return nil
+  })
+  if err != nil {
+    return err
+  }
+  for _, k := range keys {
+    if err := invitations.Delete(k); err != nil {
+      return DeleteDataError{string(k), INVITATIONS, err.Error()}
+    }
+  }
+  log.Printf(" -> migrateSecretInvitations: Dropped %d Invitations", len(keys))
+  return nil
+}
+
+// migrateDefaultRoles :: Members were only ever told apart by their MemberType before
+//    version 9. Existing /ChatroomMembers values are left alone, since a Member
+//    without a Role holds their MemberType's, and the default Roles match them.
+func migrateDefaultRoles(tx *bbolt.Tx) error {
+  if err := createBuckets(tx, ROLES); err != nil {
+    return err
+  }
+
+  var rooms []string
+  for _, name := range []string{ CHATROOMS, INACTIVECHATROOMS } {
+    bucket := tx.Bucket([]byte(name))
+    if bucket == nil {
+      return BucketNotFoundError{name}
+    }
+    err := bucket.ForEach(func(k, _ []byte) error {
+      rooms = append(rooms, string(k))
+      return nil
+    })
+    if err != nil {
+      return err
+    }
+  }
+  // Written out by hand, rather than with boltSaveDefaultRoles, so later changes to
+  // DefaultRoles don't reach back into this step.
+  roles := tx.Bucket([]byte(ROLES))
+  defaults := []Role{
+    { Name: OwnerRole,     Permissions: ownerPermissions, Rank: 0 },
+    { Name: ModeratorRole, Permissions: PermAll,          Rank: 10 },
+    { Name: MemberRole,    Permissions: PermPost,         Rank: 100 },
+  }
+  for _, room := range rooms {
+    bucket, err := roles.CreateBucketIfNotExists([]byte(room))
+    if err != nil {
+      return BucketNotFoundError{ROLES + "/" + room}
+    }
+    for _, role := range defaults {
+      var data []byte
+      enc := codec.NewEncoderBytes(&data, &JSONHandle)
+      if err := enc.Encode(role); err != nil {
+        return EncoderError{err.Error()}
+      }
+      if err := bucket.Put([]byte(role.Name), data); err != nil {
+        return PutDataError{room + "/" + role.Name, ROLES, err.Error()}
+      }
+    }
+  }
+  log.Printf(" -> migrateDefaultRoles: Added default Roles to %d Chatrooms", len(rooms))
+  return nil
+}
+
+// migrateDeactivatedUsers :: Before version 10, DeactivateUser keyed /DeactivatedUsers
+//    by the raw 16 byte UserID, and ActivateUser wrote raw UserIDs into /Usernames,
+//    while everything else used the UserID's string. Deactivating also freed the
+//    User's Username, and left their Access Token valid.
+func migrateDeactivatedUsers(tx *bbolt.Tx) error {
+  deactivated := tx.Bucket([]byte(DEACTIVATEDUSERS))
+  usernames := tx.Bucket([]byte(USERNAMES))
+  tokens := tx.Bucket([]byte(USERTOKENS))
+  for name, bucket := range map[string]*bbolt.Bucket{ DEACTIVATEDUSERS: deactivated, USERNAMES: usernames, USERTOKENS: tokens } {
+    if bucket == nil {
+      return BucketNotFoundError{name}
+    }
+  }
+
+  // Raw UserIDs within /Usernames become strings.
+  raw := map[string][]byte{}
+  err := usernames.ForEach(func(k, v []byte) error {
+    if len(v) == len(uuid.UUID{}) {
+      raw[string(k)] = v
+    }
+    return nil
+  })
+  if err != nil {
+    return err
+  }
+  for username, v := range raw {
+    if err := usernames.Put([]byte(username), []byte(uuid.UUID(v).String())); err != nil {
+      return PutDataError{username, USERNAMES, err.Error()}
+    }
+  }
+
+  users := map[string][]byte{}
+  err = deactivated.ForEach(func(k, v []byte) error {
+    users[string(k)] = append([]byte(nil), v...)
+    return nil
+  })
+  if err != nil {
+    return err
+  }
+  for k, data := range users {
+    var user User
+    if err := codec.NewDecoderBytes(data, &JSONHandle).Decode(&user); err != nil {
+      return DecoderError{err.Error()}
+    }
+    uid := []byte(user.UserID.String())
+    if k != string(uid) {
+      if err := deactivated.Delete([]byte(k)); err != nil {
+        return DeleteDataError{k, DEACTIVATEDUSERS, err.Error()}
+      }
+      if err := deactivated.Put(uid, data); err != nil {
+        return PutDataError{user.UserID.String(), DEACTIVATEDUSERS, err.Error()}
+      }
+    }
+    // Whoever took a freed Username since keeps it.
+    if usernames.Get([]byte(user.Username)) == nil {
+      if err := usernames.Put([]byte(user.Username), uid); err != nil {
+        return PutDataError{user.Username, USERNAMES, err.Error()}
+      }
+    }
+    if err := tokens.Delete(uid); err != nil {
+      return DeleteDataError{user.UserID.String(), USERTOKENS, err.Error()}
+    }
+  }
+  log.Printf(" -> migrateDeactivatedUsers: Rewrote %d Usernames and %d deactivated Users", len(raw), len(users))
+  return nil
+}
+
+// migrateDeletedMessages :: Unread counts subtract the tombstones after a User's
+//    read marker, rather than walking every Message after it. So every Message
+//    deleted before version 13 needs to be found once.
+func migrateDeletedMessages(tx *bbolt.Tx) error {
+  if err := createBuckets(tx, DELETEDMESSAGES); err != nil {
+    return err
+  }
+  messages := tx.Bucket([]byte(MESSAGES))
+  if messages == nil {
+    return BucketNotFoundError{MESSAGES}
+  }
+  deleted := tx.Bucket([]byte(DELETEDMESSAGES))
+
+  indexed := 0
+  err := messages.ForEach(func(chatroom, v []byte) error {
+    room := messages.Bucket(chatroom)
+    if v != nil || room == nil {
+      return nil
+    }
+    return room.ForEach(func(seq, data []byte) error {
+      var message Message
+      dec := codec.NewDecoderBytes(data, &JSONHandle)
+      if err := dec.Decode(&message); err != nil {
+        return DecoderError{err.Error()}
+      }
+      if !message.IsDeleted() {
+        return nil
+      }
+      roomDeleted, err := deleted.CreateBucketIfNotExists(chatroom)
+      if err != nil {
+        return BucketNotFoundError{DELETEDMESSAGES + "/" + string(chatroom)}
+      }
+      indexed++
+      if err := roomDeleted.Put(seq, []byte{}); err != nil {
+        return PutDataError{message.ID.String(), DELETEDMESSAGES, err.Error()}
+      }
+      return nil
+    })
+  })
+  if err != nil {
+    return err
+  }
+  log.Printf(" -> migrateDeletedMessages: Indexed %d tombstones", indexed)
+  return nil
+}
+
+func createBuckets(tx *bbolt.Tx, buckets ...string) error {
+  for _, name := range buckets {
+    if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+      log.Printf(" -> createBuckets: Failed to create \"%s\" Bucket: %s", name, err)
+      return BucketNotFoundError{name}
+    }
+  }
+  return nil
+}
+
+// itob :: Big-endian encoding, so that bbolt's byte ordering matches numeric ordering.
+func itob(v uint64) []byte {
+  b := make([]byte, 8)
+  binary.BigEndian.PutUint64(b, v)
+  return b
+}
+
+func btoi(b []byte) uint64 {
+  return binary.BigEndian.Uint64(b)
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ugorji/go/codec"
+	"go.etcd.io/bbolt"
+)
+
+func TestMigrations(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "chatatui_migrations.db")
+
+  t.Run("Fresh Database is fully migrated", func(t *testing.T){
+    database, err := NewDatabase(path)
+    if err != nil {
+      t.Errorf("FAILED: Failed to open Database: %v", err)
+      return
+    }
+    defer database.Close()
+
+    exists, err := database.DoesChatroomExist("nosuchroom")
+    if err != nil || exists {
+      t.Errorf("FAILED: Got %v, %v Want false, nil", exists, err)
+    }
+  })
+
+  t.Run("Reopening keeps the Schema Version", func(t *testing.T){
+    database, err := NewDatabase(path)
+    if err != nil {
+      t.Errorf("FAILED: Failed to reopen Database: %v", err)
+      return
+    }
+    defer database.Close()
+
+    database.db.View(func(tx *bbolt.Tx) error {
+      got := btoi(tx.Bucket([]byte(META)).Get([]byte(SCHEMAVERSION)))
+      if got != LatestSchemaVersion() {
+        t.Errorf("FAILED: Got schema version %d Want %d", got, LatestSchemaVersion())
+      }
+      return nil
+    })
+  })
+
+  t.Run("Flat Messages move into per Chatroom Buckets", func(t *testing.T){
+    flatPath := filepath.Join(t.TempDir(), "chatatui_flat.db")
+    raw, err := bbolt.Open(flatPath, 0600, nil)
+    if err != nil {
+      t.Errorf("FAILED: Failed to open bbolt file: %v", err)
+      return
+    }
+    now := time.Now()
+    memberID := uuid.New()
+    raw.Update(func(tx *bbolt.Tx) error {
+      meta, _ := tx.CreateBucketIfNotExists([]byte(META))
+      meta.Put([]byte(SCHEMAVERSION), itob(1))
+      // Every bucket a version 1 Database had.
+      bboltMigrations[0].migrate(tx)
+      var room []byte
+      codec.NewEncoderBytes(&room, &JSONHandle).Encode(Chatroom{ RoomID: uuid.New(), RoomName: "room", Public: true })
+      tx.Bucket([]byte(CHATROOMS)).Put([]byte("room"), room)
+      tx.Bucket([]byte(CHATROOMMEMBERS)).Put([]byte("room-"+memberID.String()), []byte{ byte(Member) })
+      tx.Bucket([]byte(INVITATIONS)).Put([]byte(uuid.NewString()+"-"+memberID.String()), []byte("salted secret"))
+      messages := tx.Bucket([]byte(MESSAGES))
+      for i, room := range []string{ "room", "room", "room2", "room" } {
+        msg := Message{ ID: uuid.New(), TimeStamp: now.Add(time.Duration(i) * time.Second), Content: "legacy message" }
+        if i == 3 {
+          msg.Content = ""
+          msg.DeletedAt = now
+        }
+        var data []byte
+        codec.NewEncoderBytes(&data, &JSONHandle).Encode(msg)
+        messages.Put([]byte(room+"-"+msg.TimeStamp.Format(DATEFMT)), data)
+      }
+      return nil
+    })
+    raw.Close()
+
+    database, err := NewDatabase(flatPath)
+    if err != nil {
+      t.Errorf("FAILED: Failed to migrate Database: %v", err)
+      return
+    }
+    defer database.Close()
+
+    database.db.View(func(tx *bbolt.Tx) error {
+      for room, want := range map[string]uint64{ "room": 3, "room2": 1 } {
+        b, _ := boltRoomMessages(tx, room, false)
+        if b == nil || b.Sequence() != want {
+          t.Errorf("FAILED: /%s/%s should hold %d Messages", MESSAGES, room, want)
+        }
+      }
+      return nil
+    })
+
+    results, err := database.SearchMessages(NewSearchQuery("legacy"))
+    if err != nil || len(results) != 3 {
+      t.Errorf("FAILED: Got %d results, %v Want 3 backfilled results", len(results), err)
+    }
+
+    joined, err := database.GetJoinedChatrooms(memberID)
+    if err != nil || len(joined) != 1 || joined[0].Chatroom != "room" || joined[0].Unread != 2 {
+      t.Errorf("FAILED: Got %+v, %v Want \"room\" with 2 unread Messages and its tombstone left out", joined, err)
+    }
+
+    database.db.View(func(tx *bbolt.Tx) error {
+      if k, v := tx.Bucket([]byte(INVITATIONS)).Cursor().First(); k != nil {
+        t.Errorf("FAILED: Got /%s/%s = %q Want secret Invitations dropped", INVITATIONS, k, v)
+      }
+      return nil
+    })
+
+    if roles, err := database.GetChatroomRoles("room"); err != nil || len(roles) != len(DefaultRoles()) {
+      t.Errorf("FAILED: Got %+v, %v Want the default Roles", roles, err)
+    }
+    if member, err := database.GetChatroomMember("room", memberID); err != nil || member.Role.Name != MemberRole {
+      t.Errorf("FAILED: Got %+v, %v Want a Member holding \"%s\"", member, err, MemberRole)
+    }
+  })
+
+  t.Run("Deactivated Users are keyed by UserID strings", func(t *testing.T){
+    oldPath := filepath.Join(t.TempDir(), "chatatui_deactivated.db")
+    raw, err := bbolt.Open(oldPath, 0600, nil)
+    if err != nil {
+      t.Errorf("FAILED: Failed to open bbolt file: %v", err)
+      return
+    }
+    ghost := User{ UserID: uuid.New(), Username: "ghost" }
+    revived := User{ UserID: uuid.New(), Username: "revived" }
+    raw.Update(func(tx *bbolt.Tx) error {
+      meta, _ := tx.CreateBucketIfNotExists([]byte(META))
+      meta.Put([]byte(SCHEMAVERSION), itob(1))
+      bboltMigrations[0].migrate(tx)
+      // What DeactivateUser and ActivateUser used to write.
+      var ghostData, revivedData []byte
+      codec.NewEncoderBytes(&ghostData, &JSONHandle).Encode(ghost)
+      tx.Bucket([]byte(DEACTIVATEDUSERS)).Put(ghost.UserID[:], ghostData)
+      tx.Bucket([]byte(USERTOKENS)).Put([]byte(ghost.UserID.String()), []byte("stale"))
+      codec.NewEncoderBytes(&revivedData, &JSONHandle).Encode(revived)
+      tx.Bucket([]byte(USERS)).Put([]byte(revived.UserID.String()), revivedData)
+      tx.Bucket([]byte(USERNAMES)).Put([]byte(revived.Username), revived.UserID[:])
+      return nil
+    })
+    raw.Close()
+
+    database, err := NewDatabase(oldPath)
+    if err != nil {
+      t.Errorf("FAILED: Failed to migrate Database: %v", err)
+      return
+    }
+    defer database.Close()
+
+    if got, err := database.GetDeactivatedUser(ghost.Username); err != nil || got.UserID != ghost.UserID {
+      t.Errorf("FAILED: Got %+v, %v Want ghost reserved and deactivated", got, err)
+    }
+    if _, err := database.GetUserToken(ghost.UserID); err == nil {
+      t.Errorf("FAILED: Deactivated User's Access Token wasn't revoked")
+    }
+    if err := database.ActivateUser(ghost.UserID); err != nil {
+      t.Errorf("FAILED: Failed to reactivate migrated User: %v", err)
+    }
+    if got, err := database.GetUserbyUsername(revived.Username); err != nil || got.UserID != revived.UserID {
+      t.Errorf("FAILED: Got %+v, %v Want revived found by Username", got, err)
+    }
+  })
+
+  t.Run("Refuse newer Schema Version", func(t *testing.T){
+    raw, err := bbolt.Open(path, 0600, nil)
+    if err != nil {
+      t.Errorf("FAILED: Failed to open bbolt file: %v", err)
+      return
+    }
+    raw.Update(func(tx *bbolt.Tx) error {
+      return tx.Bucket([]byte(META)).Put([]byte(SCHEMAVERSION), itob(LatestSchemaVersion()+1))
+    })
+    raw.Close()
+
+    database, err := NewDatabase(path)
+    if err == nil {
+      database.Close()
+      t.Errorf("FAILED: Opened a Database with a newer Schema Version")
+      return
+    }
+    if _, ok := err.(SchemaVersionError); !ok {
+      t.Errorf("FAILED: Got %T Want SchemaVersionError", err)
+    }
+  })
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+const (
+  DefaultPasswordResetTTL = time.Hour
+  MaxPasswordResetTTL     = 24 * time.Hour
+  resetTokenBytes         = 32
+)
+
+// PasswordReset :: Lets UserID choose a new password without knowing their old one.
+//    Only a hash of the reset token is stored, the token itself is handed to the
+//    User once, through a Notifier. Used up by the first ConsumePasswordReset.
+//    Stored under /PasswordResets/{token_hash}.
+type PasswordReset struct {
+  TokenHash string    `codec:"token_hash"`
+  UserID    UUID      `codec:"user_id"`
+  CreatedAt time.Time `codec:"created_at"`
+  ExpiresAt time.Time `codec:"expires_at"`
+}
+
+// NewPasswordReset :: Returns the PasswordReset to store, along with the reset token
+//    to deliver. A ttl of 0 falls back onto DefaultPasswordResetTTL, and never
+//    exceeds MaxPasswordResetTTL.
+func NewPasswordReset(userID UUID, ttl time.Duration)( PasswordReset, string, error ){
+  if ttl <= 0 {
+    ttl = DefaultPasswordResetTTL
+  }
+  if ttl > MaxPasswordResetTTL {
+    ttl = MaxPasswordResetTTL
+  }
+  secret := make([]byte, resetTokenBytes)
+  if _, err := rand.Read(secret); err != nil {
+    return PasswordReset{}, "", err
+  }
+  resetToken := base64.RawURLEncoding.EncodeToString(secret)
+
+  now := time.Now()
+  return PasswordReset{
+    TokenHash: HashResetToken(resetToken),
+    UserID:    userID,
+    CreatedAt: now,
+    ExpiresAt: now.Add(ttl),
+  }, resetToken, nil
+}
+
+// HashResetToken :: Reset tokens are random enough that an unsalted SHA-256 is all
+//    it takes to keep a leaked Database from being used to reset passwords.
+func HashResetToken(resetToken string) string {
+  sum := sha256.Sum256([]byte(resetToken))
+  return hex.EncodeToString(sum[:])
+}
+
+func(r *PasswordReset)Expired(now time.Time) bool {
+  return !now.Before(r.ExpiresAt)
+}
+
+// checkPasswordReset :: Shared by every ConsumePasswordReset, once the PasswordReset
+//    has already been removed.
+func checkPasswordReset(reset *PasswordReset) error {
+  if reset.Expired(time.Now()) {
+    return FailedSecurityCheckError{"PasswordReset", "Password reset has expired"}
+  }
+  return nil
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	// Servers without a zoneinfo database still need to check Timezones.
+	_ "time/tzdata"
+)
+
+const (
+  MaxDisplayNameLength = 64
+  MaxBioLength         = 500
+  MaxLocationLength    = 100
+  MaxCompanyLength     = 100
+  MaxAvatarWidth       = 32
+  MaxAvatarHeight      = 16
+  // ANSI colours take up far more bytes than the characters they colour.
+  MaxAvatarBytes = 8192
+)
+
+// ansiSGR :: The only escape sequences an Avatar may hold. Colours and text styles,
+//    nothing that moves the cursor or clears the screen.
+var ansiSGR = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// Profile :: What a User shares about themselves. Holds nothing secret, so it can be
+//    handed to anyone signed in as is. Username is always filled in from the User,
+//    and Users who never saved a Profile have an empty one. Stored under
+//    /Profiles/{user_id}.
+type Profile struct {
+  UserID      UUID      `codec:"user_id"`
+  Username    UserName  `codec:"username"`
+  DisplayName string    `codec:"display_name"`
+  Bio         string    `codec:"bio"`
+  Location    string    `codec:"location"`
+  Company     string    `codec:"company"`
+  Timezone    string    `codec:"timezone"`
+  Avatar      string    `codec:"avatar"`
+  UpdatedAt   time.Time `codec:"updated_at,omitempty"`
+}
+
+// ProfileSettings :: A partial update to a Profile, sent with PUT /user/me. Fields
+//    left out are kept as they are.
+type ProfileSettings struct {
+  DisplayName *string `codec:"display_name,omitempty"`
+  Bio         *string `codec:"bio,omitempty"`
+  Location    *string `codec:"location,omitempty"`
+  Company     *string `codec:"company,omitempty"`
+  Timezone    *string `codec:"timezone,omitempty"`
+  Avatar      *string `codec:"avatar,omitempty"`
+}
+
+// Apply :: Copies settings onto profile, and validates the result.
+func(s *ProfileSettings)Apply(profile *Profile) error {
+  if s.DisplayName != nil {
+    profile.DisplayName = *s.DisplayName
+  }
+  if s.Bio != nil {
+    profile.Bio = *s.Bio
+  }
+  if s.Location != nil {
+    profile.Location = *s.Location
+  }
+  if s.Company != nil {
+    profile.Company = *s.Company
+  }
+  if s.Timezone != nil {
+    profile.Timezone = *s.Timezone
+  }
+  if s.Avatar != nil {
+    // Avatars drawn on Windows come with carriage returns.
+    profile.Avatar = strings.ReplaceAll(*s.Avatar, "\r\n", "\n")
+  }
+  return ValidateProfile(profile)
+}
+
+// ValidateProfile :: Checks everything about a Profile a User can change. Timezones
+//    are IANA names, like "Europe/Berlin", or empty.
+func ValidateProfile(profile *Profile) error {
+  singleLines := []struct{ field, value string; max int }{
+    { "Display names", profile.DisplayName, MaxDisplayNameLength },
+    { "Locations",     profile.Location,    MaxLocationLength },
+    { "Companies",     profile.Company,     MaxCompanyLength },
+  }
+  for _, line := range singleLines {
+    if utf8.RuneCountInString(line.value) > line.max || !utf8.ValidString(line.value) {
+      return fmt.Errorf("%s can be at most %d characters long", line.field, line.max)
+    }
+    for _, r := range line.value {
+      if unicode.IsControl(r) {
+        return fmt.Errorf("%s must fit on a single line", line.field)
+      }
+    }
+  }
+  if utf8.RuneCountInString(profile.Bio) > MaxBioLength || !utf8.ValidString(profile.Bio) {
+    return fmt.Errorf("Bios can be at most %d characters long", MaxBioLength)
+  }
+  if profile.Timezone != "" {
+    // LoadLocation also accepts "Local", which means nothing to anyone else.
+    if _, err := time.LoadLocation(profile.Timezone); err != nil || profile.Timezone == "Local" {
+      return fmt.Errorf("Unknown timezone \"%s\"", profile.Timezone)
+    }
+  }
+  return validateAvatar(profile.Avatar)
+}
+
+// validateAvatar :: Avatars are ASCII, or Unicode, art of at most MaxAvatarHeight lines
+//    of MaxAvatarWidth characters each, optionally coloured in with ANSI SGR escape
+//    sequences. Any other escape sequence or control character is refused.
+func validateAvatar(avatar string) error {
+  if len(avatar) > MaxAvatarBytes || !utf8.ValidString(avatar) {
+    return fmt.Errorf("Avatars can be at most %d bytes long", MaxAvatarBytes)
+  }
+  lines := strings.Split(strings.TrimSuffix(avatar, "\n"), "\n")
+  if len(lines) > MaxAvatarHeight {
+    return fmt.Errorf("Avatars can be at most %d lines tall", MaxAvatarHeight)
+  }
+  for _, line := range lines {
+    visible := ansiSGR.ReplaceAllString(line, "")
+    if utf8.RuneCountInString(visible) > MaxAvatarWidth {
+      return fmt.Errorf("Avatars can be at most %d characters wide", MaxAvatarWidth)
+    }
+    for _, r := range visible {
+      if unicode.IsControl(r) {
+        return fmt.Errorf("Avatars may only hold ANSI colour escape sequences")
+      }
+    }
+  }
+  return nil
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"log"
+	"time"
+)
+
+const (
+  DefaultJanitorInterval = 10 * time.Minute
+  DefaultPurgeBatchSize  = 256
+)
+
+// Retention :: How long a Chatroom's Messages are kept. A Message expires once it's
+//    older than MaxAge, or once more than MaxMessages Messages have been sent after
+//    it. Zero turns either off.
+type Retention struct {
+  MaxAge      time.Duration
+  MaxMessages int
+}
+
+func(r Retention)Forever() bool {
+  return r.MaxAge <= 0 && r.MaxMessages <= 0
+}
+
+// expired :: newer is how many Messages were sent after the one sent at timeStamp.
+func(r Retention)expired(timeStamp time.Time, newer int, now time.Time) bool {
+  if r.MaxMessages > 0 && newer >= r.MaxMessages {
+    return true
+  }
+  return r.MaxAge > 0 && now.Sub(timeStamp) > r.MaxAge
+}
+
+// Retention :: The Chatroom's own retention, falling back onto defaults for
+//    anything it leaves unset.
+func(c *Chatroom)Retention(defaults Retention) Retention {
+  retention := defaults
+  if c.MaxMessageAge > 0 {
+    retention.MaxAge = time.Duration(c.MaxMessageAge) * time.Second
+  }
+  if c.MaxMessages > 0 {
+    retention.MaxMessages = c.MaxMessages
+  }
+  return retention
+}
+
+// Janitor :: Deletes expired Messages in the background. Each Chatroom is purged in
+//    batches of BatchSize, every batch within it's own short transaction, so
+//    Messages coming in are never held up for long.
+type Janitor struct {
+  database  ChatatuiDatabase
+  Defaults  Retention
+  Interval  time.Duration
+  BatchSize int
+}
+
+func NewJanitor(database ChatatuiDatabase, defaults Retention) *Janitor {
+  return &Janitor{
+    database:  database,
+    Defaults:  defaults,
+    Interval:  DefaultJanitorInterval,
+    BatchSize: DefaultPurgeBatchSize,
+  }
+}
+
+// Run :: Sweeps every Interval, until stop is closed.
+func(j *Janitor)Run(stop <-chan struct{}) {
+  if j.Interval <= 0 {
+    j.Interval = DefaultJanitorInterval
+  }
+  ticker := time.NewTicker(j.Interval)
+  defer ticker.Stop()
+  for {
+    if purged, err := j.Sweep(time.Now(), stop); err != nil {
+      log.Printf(" -> Janitor: Sweep failed: %s", err)
+    } else if purged > 0 {
+      log.Printf(" -> Janitor: Purged %d expired Messages", purged)
+    }
+    select {
+    case <-stop:
+      return
+    case <-ticker.C:
+    }
+  }
+}
+
+// Sweep :: A single pass over every Chatroom, returning how many Messages were
+//    purged. A Chatroom that fails to purge is logged and skipped, rather than
+//    holding up the rest.
+func(j *Janitor)Sweep(now time.Time, stop <-chan struct{})( int, error ){
+  rooms, err := j.database.GetChatrooms()
+  if err != nil {
+    return 0, err
+  }
+
+  purged := 0
+  for _, room := range rooms {
+    retention := room.Retention(j.Defaults)
+    if retention.Forever() {
+      continue
+    }
+    for {
+      select {
+      case <-stop:
+        return purged, nil
+      default:
+      }
+      n, err := j.database.PurgeMessages(room.RoomName, retention, now, j.BatchSize)
+      if err != nil {
+        log.Printf(" -> Janitor: Failed to purge \"%s\": %s", room.RoomName, err)
+        break
+      }
+      purged += n
+      if n < j.BatchSize {
+        break
+      }
+    }
+  }
+  return purged, nil
+}
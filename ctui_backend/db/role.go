@@ -0,0 +1,207 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Permission :: A bitset of what a Role allows it's Members to do within a Chatroom.
+type Permission uint32
+const (
+  PermPost Permission = 1 << iota
+  PermEditOthers
+  PermDeleteOthers
+  PermInvite
+  PermManageMembers
+  PermChangeSettings
+  PermPin
+
+  // PermManageRoles and PermDeactivate only ever belong to the Owner Role. They
+  //    can't be granted to any other Role.
+  PermManageRoles
+  PermDeactivate
+)
+
+// PermAll :: Every Permission that can be granted to a Role.
+const PermAll = PermPost | PermEditOthers | PermDeleteOthers | PermInvite | PermManageMembers | PermChangeSettings | PermPin
+
+const ownerPermissions = PermAll | PermManageRoles | PermDeactivate
+
+var permissionNames = []string{
+  "post", "edit_others", "delete_others", "invite", "manage_members",
+  "change_settings", "pin", "manage_roles", "deactivate",
+}
+
+func(p Permission)String() string {
+  var names []string
+  for i, name := range permissionNames {
+    if p&(1<<i) != 0 {
+      names = append(names, name)
+    }
+  }
+  return strings.Join(names, "|")
+}
+
+// --> Default Roles. Every Chatroom starts out with these, matching the MemberType
+//    of the same name. Members that were never given a Role hold their MemberType's.
+const (
+  OwnerRole     = "owner"
+  ModeratorRole = "moderator"
+  MemberRole    = "member"
+  BlockedRole   = "blocked"
+  MaxRoleRank   = 1000
+  maxRoleName   = 32
+)
+
+// Role :: A named set of Permissions within a single Chatroom. A lower Rank
+//    outranks a higher one. Stored under /Roles/{chatroom}/{name}.
+type Role struct {
+  Name        string     `codec:"name"`
+  Permissions Permission `codec:"permissions"`
+  Rank        int        `codec:"rank"`
+}
+
+func DefaultRoles() []Role {
+  return []Role{
+    { Name: OwnerRole,     Permissions: ownerPermissions, Rank: 0 },
+    { Name: ModeratorRole, Permissions: PermAll,          Rank: 10 },
+    { Name: MemberRole,    Permissions: PermPost,         Rank: 100 },
+  }
+}
+
+// DefaultRole :: nil unless name is one of DefaultRoles, or BlockedRole. Nobody
+//    holds BlockedRole, and every other Role outranks it.
+func DefaultRole(name string) *Role {
+  if name == BlockedRole {
+    return &Role{ Name: BlockedRole, Rank: math.MaxInt32 }
+  }
+  for _, role := range DefaultRoles() {
+    if role.Name == name {
+      return &role
+    }
+  }
+  return nil
+}
+
+// IsDefaultRole :: Default Roles can be edited, except for the Owner's, but never deleted.
+func IsDefaultRole(name string) bool {
+  return DefaultRole(name) != nil
+}
+
+func(r *Role)Has(perm Permission) bool {
+  return r.Permissions&perm == perm
+}
+
+// Outranks :: Owners outrank everyone, other Owners included.
+func(r *Role)Outranks(other *Role) bool {
+  return r.Name == OwnerRole || r.Rank < other.Rank
+}
+
+// ValidateRole :: Checks a Role an Owner wants to create or update. The Owner's Role
+//    is fixed, and nothing can rank alongside it.
+func ValidateRole(role *Role) error {
+  if role.Name == "" || len(role.Name) > maxRoleName {
+    return fmt.Errorf("Role names must be between 1 and %d characters long", maxRoleName)
+  }
+  for _, r := range role.Name {
+    if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+      return fmt.Errorf("Role names can only consist of lowercase letters, numbers, '-' and '_'")
+    }
+  }
+  if role.Name == OwnerRole || role.Name == BlockedRole {
+    return fmt.Errorf("The \"%s\" Role can't be changed", role.Name)
+  }
+  if role.Rank < 1 || role.Rank > MaxRoleRank {
+    return fmt.Errorf("Role ranks must be between 1 and %d", MaxRoleRank)
+  }
+  if role.Permissions&^PermAll != 0 {
+    return fmt.Errorf("Unknown Permissions %d", role.Permissions&^PermAll)
+  }
+  return nil
+}
+
+// sortRoles :: Highest ranking first.
+func sortRoles(roles []Role) {
+  sort.Slice(roles, func(i, j int) bool {
+    if roles[i].Rank != roles[j].Rank {
+      return roles[i].Rank < roles[j].Rank
+    }
+    return roles[i].Name < roles[j].Name
+  })
+}
+
+// ChatroomMember :: A Member of a Chatroom along with the Role they hold.
+type ChatroomMember struct {
+  UserID     UUID       `codec:"user_id"`
+  MemberType MemberType `codec:"member_type"`
+  Role       Role       `codec:"role"`
+}
+
+// MemberStatus :: A Member as listed by GET /chatrooms/{room_name}/members. Status
+//    is their live status within the Chatroom, "Offline" unless they're connected.
+type MemberStatus struct {
+  UserID   UUID     `codec:"user_id"`
+  Username UserName `codec:"username"`
+  Role     string   `codec:"role"`
+  Status   string   `codec:"status"`
+}
+
+// memberRoleName :: The Role a Member holds. role is empty for Members that were
+//    never given one, and Blocked Members never hold one.
+func memberRoleName(memberType MemberType, role string) string {
+  if role == "" || memberType == Blocked {
+    return memberType.String()
+  }
+  return role
+}
+
+// memberTypeForRole :: Keeps a Member's MemberType in step with their Role. Custom
+//    Roles are held by Members.
+func memberTypeForRole(role string) MemberType {
+  switch role {
+  case OwnerRole:
+    return Owner
+  case ModeratorRole:
+    return Moderator
+  }
+  return Member
+}
+
+// resolveMember :: Shared by every GetChatroomMember. lookup returns nil for a Role
+//    that doesn't exist within the Chatroom, in which case a default Role by the same
+//    name is used. Members left holding neither fall back onto MemberRole.
+func resolveMember(userID UUID, memberType MemberType, role string, lookup func(name string)( *Role, error ))( *ChatroomMember, error ){
+  name := memberRoleName(memberType, role)
+  found, err := lookup(name)
+  if err != nil {
+    return nil, err
+  }
+  if found == nil {
+    if found = DefaultRole(name); found == nil {
+      found = DefaultRole(MemberRole)
+    }
+  }
+  return &ChatroomMember{ UserID: userID, MemberType: memberType, Role: *found }, nil
+}
+
+// Authorize :: The one place deciding whether member may do something within a
+//    Chatroom. member is nil when the User isn't a Member. target is the Member being
+//    acted upon, if any, whom member must outrank. Passing a target with only a Role
+//    checks that member outranks that Role, like when handing it out.
+func Authorize(member *ChatroomMember, perm Permission, target *ChatroomMember) error {
+  if member == nil {
+    return FailedSecurityCheckError{"Authorize", "not a Member of the Chatroom"}
+  }
+  if member.MemberType == Blocked {
+    return FailedSecurityCheckError{"MemberType: blocked", "user is blocked"}
+  }
+  if !member.Role.Has(perm) {
+    return FailedSecurityCheckError{"Authorize", fmt.Sprintf("the \"%s\" Role lacks %s", member.Role.Name, perm&^member.Role.Permissions)}
+  }
+  if target != nil && !member.Role.Outranks(&target.Role) {
+    return FailedSecurityCheckError{"Authorize", fmt.Sprintf("the \"%s\" Role doesn't outrank \"%s\"", member.Role.Name, target.Role.Name)}
+  }
+  return nil
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+  minSearchTermLength = 2
+  maxSearchTermLength = 64
+)
+
+// SearchQuery :: Every term must be present within a Message for it to match. The
+//    rest of the fields are optional filters, where their zero value means "any".
+//    Chatrooms holds every Chatroom the searching User may read, so a non-nil but
+//    empty Chatrooms matches nothing. Limit caps the number of results returned.
+type SearchQuery struct {
+  Terms     []string
+  Chatroom  RoomName
+  Chatrooms []RoomName
+  UserID    UUID
+  Before    time.Time
+  After     time.Time
+  Limit     int
+}
+
+// SearchResults :: The response body of GET /search/messages.
+type SearchResults struct {
+  Results []ChatroomMessage `codec:"results"`
+}
+
+// NewSearchQuery :: Tokenizes q the same way Message content is tokenized when indexed.
+func NewSearchQuery(q string) SearchQuery {
+  return SearchQuery{ Terms: Tokenize(q) }
+}
+
+// Tokenize :: Splits content into a set of lowercase search terms. Anything that
+//    isn't a letter or a number is a separator. Terms too short, or too long, to
+//    be useful are dropped.
+func Tokenize(content string) []string {
+  fields := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+    return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+  })
+
+  seen := make(map[string]bool, len(fields))
+  terms := make([]string, 0, len(fields))
+  for _, f := range fields {
+    if n := len([]rune(f)); n < minSearchTermLength || n > maxSearchTermLength {
+      continue
+    }
+    if seen[f] {
+      continue
+    }
+    seen[f] = true
+    terms = append(terms, f)
+  }
+  return terms
+}
+
+// scoped :: Whether the search is held to a known set of Chatrooms.
+func(q *SearchQuery)scoped() bool {
+  return q.Chatroom != "" || q.Chatrooms != nil
+}
+
+// rooms :: Every Chatroom a scoped search has to look within.
+func(q *SearchQuery)rooms() []RoomName {
+  if q.Chatroom == "" {
+    return q.Chatrooms
+  }
+  if q.allows(q.Chatroom) {
+    return []RoomName{ q.Chatroom }
+  }
+  return nil
+}
+
+// allows :: Applies the Chatroom and Chatrooms filters.
+func(q *SearchQuery)allows(chatroom string) bool {
+  if q.Chatroom != "" && chatroom != q.Chatroom {
+    return false
+  }
+  if q.Chatrooms == nil {
+    return true
+  }
+  for _, room := range q.Chatrooms {
+    if room == chatroom {
+      return true
+    }
+  }
+  return false
+}
+
+// matches :: Applies the UserID, Before and After filters. The terms are matched
+//    by each Database's own index.
+func(q *SearchQuery)matches(msg *Message) bool {
+  if q.UserID != (UUID{}) && msg.UserID != q.UserID {
+    return false
+  }
+  if !q.Before.IsZero() && !msg.TimeStamp.Before(q.Before) {
+    return false
+  }
+  if !q.After.IsZero() && !msg.TimeStamp.After(q.After) {
+    return false
+  }
+  return true
+}
+
+// sortSearchResults :: Newest first. Ties are broken by Chatroom and Seq so the
+//    order is stable between backends.
+func sortSearchResults(results []ChatroomMessage) {
+  sort.Slice(results, func(i, j int) bool {
+    a, b := results[i], results[j]
+    if !a.Message.TimeStamp.Equal(b.Message.TimeStamp) {
+      return a.Message.TimeStamp.After(b.Message.TimeStamp)
+    }
+    if a.Chatroom != b.Chatroom {
+      return a.Chatroom < b.Chatroom
+    }
+    return a.Message.Seq > b.Message.Seq
+  })
+}
+
+// limitSearchResults :: Sorts results, then cuts them down to the query's Limit.
+func limitSearchResults(query SearchQuery, results []ChatroomMessage) []ChatroomMessage {
+  sortSearchResults(results)
+  if query.Limit > 0 && len(results) > query.Limit {
+    results = results[:query.Limit]
+  }
+  return results
+}
@@ -0,0 +1,2003 @@
+package db
+
+import (
+	"chatatui_backend/token"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteDB -> Implements 'ChatatuiDatabase'. A relational Database stored within a
+//    single embedded SQLite file. Unlike BBoltDB, Chatrooms, Members, Messages and
+//    Invitations are seperate tables tied together with foreign keys and indexes,
+//    so cross-entity queries no longer require manual cursor scans.
+type SQLiteDB struct {
+  db *sql.DB
+}
+
+// sqlQuerier :: Satisfied by both *sql.DB and *sql.Tx, so helpers can be shared
+//    between one-off queries and transactions.
+type sqlQuerier interface {
+  Exec(query string, args ...interface{})( sql.Result, error )
+  Query(query string, args ...interface{})( *sql.Rows, error )
+  QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// NewSQLiteDatabase :: Opens(or creates) the SQLite file at path, and runs every
+//    pending migration from 'sqliteMigrations'.
+func NewSQLiteDatabase(path string)( *SQLiteDB, error ){
+  log.Printf(" -> NewSQLiteDatabase: Opening %s", path)
+  db, err := sql.Open("sqlite3", path+"?_foreign_keys=on&_busy_timeout=5000")
+  if err != nil {
+    log.Printf(" -> NewSQLiteDatabase: sql.Open(%s) FAILURE: %s", path, err.Error())
+    return nil, err
+  }
+  // SQLite only allows a single writer. Funneling everything through a single
+  // connection keeps ":memory:" databases shared, and avoids "database is locked".
+  db.SetMaxOpenConns(1)
+
+  database := &SQLiteDB{ db }
+  if err := database.migrate(); err != nil {
+    log.Printf(" -> NewSQLiteDatabase: Migration FAILURE: %s", err.Error())
+    db.Close()
+    return nil, err
+  }
+  return database, nil
+}
+
+// migrate :: Applies every migration newer than the version recorded within
+//    /schema_migrations, each within its own transaction. Refuses to touch a
+//    database whose schema is newer than this binary knows about.
+func(db *SQLiteDB)migrate() error {
+  if _, err := db.db.Exec(fmt.Sprintf(`
+    CREATE TABLE IF NOT EXISTS %s (
+      version    INTEGER PRIMARY KEY,
+      applied_at INTEGER NOT NULL
+    )`, SQLMIGRATIONS,
+  )); err != nil {
+    return BucketNotFoundError{SQLMIGRATIONS}
+  }
+
+  var version int
+  if err := db.db.QueryRow(
+    fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", SQLMIGRATIONS),
+  ).Scan(&version); err != nil {
+    return GetDataError{"version", SQLMIGRATIONS}
+  }
+  if version > len(sqliteMigrations) {
+    return fmt.Errorf(
+      "Database schema version %d is newer than the latest known version %d",
+      version, len(sqliteMigrations),
+    )
+  }
+
+  for i := version; i < len(sqliteMigrations); i++ {
+    err := db.update(func(tx *sql.Tx) error {
+      if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+        return err
+      }
+      if backfill, ok := sqliteBackfills[i+1]; ok {
+        if err := backfill(tx); err != nil {
+          return err
+        }
+      }
+      _, err := tx.Exec(
+        fmt.Sprintf("INSERT INTO %s (version, applied_at) VALUES (?, ?)", SQLMIGRATIONS),
+        i+1, time.Now().Unix(),
+      )
+      return err
+    })
+    if err != nil {
+      return fmt.Errorf("Failed to apply migration %d: %s", i+1, err)
+    }
+    log.Printf(" -> SQLiteDB: Applied schema migration %d", i+1)
+  }
+  return nil
+}
+
+func(db *SQLiteDB)GetChatroom(name string)( *Chatroom, error ){
+  return sqlGetChatroom(db.db, name)
+}
+
+func(db *SQLiteDB)GetChatroomDirectory()( []DirectoryEntry, error ){
+  return sqlDirectoryEntries(db.db, "c.public = 1 AND c.active = 1 AND c.peer_id IS NULL")
+}
+
+func(db *SQLiteDB)GetDirectoryEntry(chatroomName string)( *DirectoryEntry, error ){
+  entries, err := sqlDirectoryEntries(db.db, "c.room_name = ? AND c.active = 1", chatroomName)
+  if err != nil {
+    return nil, err
+  }
+  if len(entries) == 0 {
+    return nil, GetDataError{chatroomName, SQLCHATROOMS}
+  }
+  return &entries[0], nil
+}
+
+// sqlDirectoryEntries :: Builds a DirectoryEntry for every Chatroom matching filter,
+//    a WHERE clause over chatrooms c using filterArgs.
+func sqlDirectoryEntries(q sqlQuerier, filter string, filterArgs ...interface{})( []DirectoryEntry, error ){
+  rows, err := q.Query(
+    `SELECT c.room_id, c.room_name,
+       (SELECT COUNT(*) FROM chatroom_members WHERE room_id = c.room_id AND member_type != ?),
+       COALESCE((SELECT time_stamp FROM messages WHERE room_id = c.room_id ORDER BY seq DESC LIMIT 1), 0)
+     FROM chatrooms c
+     WHERE `+filter,
+    append([]interface{}{ Blocked }, filterArgs...)...,
+  )
+  if err != nil {
+    log.Printf(" -> sqlDirectoryEntries: Query FAILURE: %s", err.Error())
+    return nil, GetDataError{"directory", SQLCHATROOMS}
+  }
+  defer rows.Close()
+
+  entries := []DirectoryEntry{}
+  for rows.Next() {
+    var entry DirectoryEntry
+    var lastActivity int64
+    if err := rows.Scan(&entry.RoomID, &entry.RoomName, &entry.MemberCount, &lastActivity); err != nil {
+      return nil, DecoderError{err.Error()}
+    }
+    entry.LastActivity = sqlTime(lastActivity)
+    entries = append(entries, entry)
+  }
+  return entries, rows.Err()
+}
+
+func(db *SQLiteDB)GetChatrooms()( []Chatroom, error ){
+  rows, err := db.db.Query(
+    `SELECT ` + sqlChatroomColumns + ` FROM chatrooms WHERE active = 1 ORDER BY room_name`,
+  )
+  if err != nil {
+    log.Printf(" -> GetChatrooms: Query FAILURE: %s", err.Error())
+    return nil, GetDataError{"all", SQLCHATROOMS}
+  }
+  defer rows.Close()
+
+  rooms := []Chatroom{}
+  for rows.Next() {
+    chatroom, err := sqlScanChatroom(rows)
+    if err != nil {
+      return nil, DecoderError{err.Error()}
+    }
+    rooms = append(rooms, *chatroom)
+  }
+  return rooms, rows.Err()
+}
+
+// OpenDirectChatroom :: Looks up, and creates, the Chatroom within one transaction.
+//    SQLite only has a single writer, so two Users opening it at once can't race.
+func(db *SQLiteDB)OpenDirectChatroom(userID, peerID UUID)( *Chatroom, error ){
+  chatroom := NewDirectChatroom(userID, peerID)
+  err := db.update(func(tx *sql.Tx) error {
+    existing, err := sqlGetChatroom(tx, chatroom.RoomName)
+    if err == nil {
+      chatroom = *existing
+      return nil
+    }
+    if _, ok := err.(GetDataError); !ok {
+      return err
+    }
+    exist, err := sqlDoesChatroomExist(tx, chatroom.RoomName)
+    if err != nil {
+      return err
+    }
+    if exist {
+      log.Printf(" -> OpenDirectChatroom: \"%s\" has been deactivated", chatroom.RoomName)
+      return GetDataError{chatroom.RoomName, SQLCHATROOMS}
+    }
+
+    if _, err := tx.Exec(
+      `INSERT INTO chatrooms (room_id, room_name, owner_id, public, peer_id) VALUES (?, ?, ?, ?, ?)`,
+      chatroom.RoomID, chatroom.RoomName, chatroom.OwnerID, chatroom.Public, chatroom.PeerID,
+    ); err != nil {
+      return PutDataError{chatroom.RoomName, SQLCHATROOMS, err.Error()}
+    }
+    for _, id := range []UUID{ userID, peerID } {
+      if err := sqlSaveChatroomMember(tx, chatroom.RoomName, id, Member); err != nil {
+        return err
+      }
+    }
+    return sqlSaveDefaultRoles(tx, chatroom.RoomID)
+  })
+  if err != nil {
+    return nil, err
+  }
+  return &chatroom, nil
+}
+
+// SaveChatroom : Creates a new row in /chatrooms along with it's Owner in
+//    /chatroom_members when update is false. When update is true, the OwnerID
+//    must be allowed to change the existing Chatroom's settings.
+func(db *SQLiteDB)SaveChatroom(
+  chatroom *Chatroom,
+  update   bool,
+) error {
+  return db.update(func(tx *sql.Tx) error {
+    if !update {
+      exist, err := sqlDoesChatroomExist(tx, chatroom.RoomName)
+      if err != nil {
+        return err
+      }
+      if exist {
+        log.Printf(" -> SaveChatroom: Chatroom name already taken")
+        return fmt.Errorf("Chatroom name already taken")
+      }
+      if _, err := tx.Exec(
+        `INSERT INTO chatrooms (room_id, room_name, owner_id, public, topic, description, slow_mode, max_members, read_only,
+           max_message_age, max_messages)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        chatroom.RoomID, chatroom.RoomName, chatroom.OwnerID, chatroom.Public,
+        chatroom.Topic, chatroom.Description, chatroom.SlowMode, chatroom.MaxMembers, chatroom.ReadOnly,
+        chatroom.MaxMessageAge, chatroom.MaxMessages,
+      ); err != nil {
+        return PutDataError{chatroom.RoomName, SQLCHATROOMS, err.Error()}
+      }
+      if err := sqlSaveChatroomMember(tx, chatroom.RoomName, chatroom.OwnerID, Owner); err != nil {
+        return err
+      }
+      return sqlSaveDefaultRoles(tx, chatroom.RoomID)
+    }
+
+    member, err := sqlGetChatroomMember(tx, chatroom.RoomName, chatroom.OwnerID)
+    if _, ok := err.(GetDataError); err != nil && !ok {
+      return err
+    }
+    if err := Authorize(member, PermChangeSettings, nil); err != nil {
+      log.Printf(" -> SaveChatroom: Invalid Credentials for updating Chatroom: %s", err)
+      return err
+    }
+    return sqlPutChatroomSettings(tx, chatroom)
+  })
+}
+
+func(db *SQLiteDB)UpdateChatroomSettings(
+  chatroomName string,
+  userID       UUID,
+  settings     *ChatroomSettings,
+)( *Chatroom, error ){
+  var chatroom *Chatroom
+  err := db.update(func(tx *sql.Tx) error {
+    var err error
+    if chatroom, err = sqlGetChatroom(tx, chatroomName); err != nil {
+      return err
+    }
+    if chatroom.IsDirect() {
+      return InvalidSettingsError{"Direct Chatrooms have no settings"}
+    }
+    member, err := sqlGetChatroomMember(tx, chatroomName, userID)
+    if _, ok := err.(GetDataError); err != nil && !ok {
+      return err
+    }
+    if err := Authorize(member, PermChangeSettings, nil); err != nil {
+      log.Printf(" -> UpdateChatroomSettings: Invalid Credentials for updating Chatroom: %s", err)
+      return err
+    }
+    if err := settings.Apply(chatroom); err != nil {
+      return InvalidSettingsError{err.Error()}
+    }
+    return sqlPutChatroomSettings(tx, chatroom)
+  })
+  if err != nil {
+    return nil, err
+  }
+  return chatroom, nil
+}
+
+func(db *SQLiteDB)DeactivateChatroom(roomName string, userID UUID) error {
+  return db.update(func(tx *sql.Tx) error {
+    if _, err := sqlGetRoomID(tx, roomName, true); err != nil {
+      return fmt.Errorf("Chatroom Doesn't Exist")
+    }
+
+    member, err := sqlGetChatroomMember(tx, roomName, userID)
+    if _, ok := err.(GetDataError); err != nil && !ok {
+      return err
+    }
+    if err := Authorize(member, PermDeactivate, nil); err != nil {
+      log.Printf(" -> DeactivateChatroom: Invalid Credentials for deactivating Chatroom: %s", err)
+      return err
+    }
+
+    if _, err := tx.Exec(
+      `UPDATE chatrooms SET active = 0 WHERE room_name = ?`, roomName,
+    ); err != nil {
+      return PutDataError{roomName, SQLCHATROOMS, err.Error()}
+    }
+    return nil
+  })
+}
+
+func(db *SQLiteDB)JoinChatroom(
+  chatroom string,
+  username string,
+) error {
+  return db.update(func(tx *sql.Tx) error {
+    cr, err := sqlGetChatroom(tx, chatroom)
+    if err != nil {
+      log.Printf(" -> Error: JoinChatroom: Chatroom doesn't exist")
+      return err
+    }
+    roomID := cr.RoomID
+    if cr.IsDirect() {
+      return FailedSecurityCheckError{"Direct Chatroom", "Direct Chatrooms can't be joined"}
+    }
+    user, err := sqlGetUserbyUsername(tx, username)
+    if err != nil {
+      return err
+    }
+
+    status, err := sqlGetChatroomMemberStatus(tx, chatroom, user.UserID)
+    if _, ok := err.(GetDataError); err != nil && !ok {
+      return err
+    }
+    if done, err := checkRejoin(status); done {
+      return err
+    }
+    members, err := sqlGetChatroomMembers(tx, chatroom)
+    if err != nil {
+      return err
+    }
+    if err := checkCapacity(cr, members); err != nil {
+      return err
+    }
+
+    invitation, err := sqlGetInvitation(tx, roomID, user.UserID)
+    if err != nil {
+      return err
+    }
+    if !cr.Public {
+      if err := checkInvitation(invitation, chatroom, user.UserID); err != nil {
+        log.Printf(" -> JoinChatroom: Invitation was missing or expired.")
+        return err
+      }
+    }
+    // Invitation not needed anymore. Remove it.
+    if _, err := tx.Exec(
+      `DELETE FROM invitations WHERE room_id = ? AND user_id = ?`,
+      roomID, user.UserID,
+    ); err != nil {
+      return DeleteDataError{inviteKey(&roomID, &user.UserID), SQLINVITATIONS, err.Error()}
+    }
+
+    return sqlSaveChatroomMember(tx, chatroom, user.UserID, Member)
+  })
+}
+
+func(db *SQLiteDB)DoesChatroomExist(chatroom string)( bool,error ){
+  return sqlDoesChatroomExist(db.db, chatroom)
+}
+
+// RemoveChatroomMember :: Deleting the row from /chatroom_members takes the User's
+//    read marker and live status along with it.
+func(db *SQLiteDB)RemoveChatroomMember(chatroomName string, userID UUID) error {
+  roomID, err := sqlGetRoomID(db.db, chatroomName, false)
+  if err != nil {
+    return err
+  }
+  res, err := db.db.Exec(
+    `DELETE FROM chatroom_members WHERE room_id = ? AND user_id = ?`,
+    roomID, userID,
+  )
+  key := chatroomName + "-" + userID.String()
+  if err != nil {
+    return DeleteDataError{key, SQLCHATROOMMEMBERS, err.Error()}
+  }
+  if n, _ := res.RowsAffected(); n == 0 {
+    return GetDataError{key, SQLCHATROOMMEMBERS}
+  }
+  return nil
+}
+
+func(db *SQLiteDB)GetChatroomMember(chatroomName string, userID UUID)( *ChatroomMember, error ){
+  return sqlGetChatroomMember(db.db, chatroomName, userID)
+}
+
+func(db *SQLiteDB)SetChatroomMemberRole(chatroomName string, userID UUID, role string) error {
+  return db.update(func(tx *sql.Tx) error {
+    roomID, err := sqlGetRoomID(tx, chatroomName, false)
+    if err != nil {
+      return err
+    }
+    found, err := sqlGetRole(tx, roomID, role)
+    if err != nil {
+      return err
+    }
+    if found == nil {
+      return GetDataError{chatroomName + "/" + role, SQLCHATROOMROLES}
+    }
+    res, err := tx.Exec(
+      `UPDATE chatroom_members SET member_type = ?, role = ? WHERE room_id = ? AND user_id = ?`,
+      memberTypeForRole(role), role, roomID, userID,
+    )
+    key := chatroomName + "-" + userID.String()
+    if err != nil {
+      return PutDataError{key, SQLCHATROOMMEMBERS, err.Error()}
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+      return GetDataError{key, SQLCHATROOMMEMBERS}
+    }
+    return nil
+  })
+}
+
+func(db *SQLiteDB)GetChatroomRoles(chatroomName string)( []Role, error ){
+  roomID, err := sqlGetRoomID(db.db, chatroomName, false)
+  if err != nil {
+    return nil, err
+  }
+  rows, err := db.db.Query(
+    `SELECT name, permissions, rank FROM chatroom_roles WHERE room_id = ?`, roomID,
+  )
+  if err != nil {
+    return nil, GetDataError{chatroomName, SQLCHATROOMROLES}
+  }
+  defer rows.Close()
+
+  roles := []Role{}
+  for rows.Next() {
+    var role Role
+    if err := rows.Scan(&role.Name, &role.Permissions, &role.Rank); err != nil {
+      return nil, DecoderError{err.Error()}
+    }
+    roles = append(roles, role)
+  }
+  if err := rows.Err(); err != nil {
+    return nil, GetDataError{chatroomName, SQLCHATROOMROLES}
+  }
+  sortRoles(roles)
+  return roles, nil
+}
+
+func(db *SQLiteDB)SaveChatroomRole(chatroomName string, role *Role) error {
+  if err := ValidateRole(role); err != nil {
+    return err
+  }
+  roomID, err := sqlGetRoomID(db.db, chatroomName, true)
+  if err != nil {
+    return err
+  }
+  return sqlPutRole(db.db, roomID, role)
+}
+
+func(db *SQLiteDB)DeleteChatroomRole(chatroomName, name string) error {
+  if IsDefaultRole(name) {
+    return FailedSecurityCheckError{"Role", "Default Roles can't be deleted"}
+  }
+  return db.update(func(tx *sql.Tx) error {
+    roomID, err := sqlGetRoomID(tx, chatroomName, false)
+    if err != nil {
+      return err
+    }
+    key := chatroomName + "/" + name
+    res, err := tx.Exec(`DELETE FROM chatroom_roles WHERE room_id = ? AND name = ?`, roomID, name)
+    if err != nil {
+      return DeleteDataError{key, SQLCHATROOMROLES, err.Error()}
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+      return GetDataError{key, SQLCHATROOMROLES}
+    }
+    if _, err := tx.Exec(
+      `UPDATE chatroom_members SET role = '' WHERE room_id = ? AND role = ?`, roomID, name,
+    ); err != nil {
+      return PutDataError{key, SQLCHATROOMMEMBERS, err.Error()}
+    }
+    return nil
+  })
+}
+
+func(db *SQLiteDB)GetChatroomMembers(chatroomName string)( map[UUID]MemberType, error ){
+  return sqlGetChatroomMembers(db.db, chatroomName)
+}
+
+func(db *SQLiteDB)SaveInvitation(invitation *Invitation) error {
+  if _, err := db.db.Exec(
+    `INSERT INTO invitations (room_id, user_id, invited_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?)
+     ON CONFLICT(room_id, user_id) DO UPDATE SET
+       invited_by = excluded.invited_by,
+       created_at = excluded.created_at,
+       expires_at = excluded.expires_at`,
+    invitation.RoomID, invitation.UserID, invitation.InvitedBy,
+    invitation.CreatedAt.UnixNano(), invitation.ExpiresAt.UnixNano(),
+  ); err != nil {
+    log.Printf(" -> SaveInvitation: Failed to store Chatroom Invitation.")
+    return PutDataError{inviteKey(&invitation.RoomID, &invitation.UserID), SQLINVITATIONS, err.Error()}
+  }
+  return nil
+}
+
+func(db *SQLiteDB)GetInvitation(roomID UUID, userID UUID)( *Invitation, error ){
+  invitation, err := sqlGetInvitation(db.db, roomID, userID)
+  if err != nil {
+    return nil, err
+  }
+  if invitation == nil {
+    return nil, GetDataError{inviteKey(&roomID, &userID), SQLINVITATIONS}
+  }
+  return invitation, nil
+}
+
+func(db *SQLiteDB)GetPendingInvitations(userID UUID)( []Invitation, error ){
+  rows, err := db.db.Query(
+    `SELECT `+sqlInvitationColumns+`
+     FROM invitations i JOIN chatrooms c ON c.room_id = i.room_id
+     WHERE i.user_id = ? AND i.expires_at > ? AND c.active = 1`,
+    userID, time.Now().UnixNano(),
+  )
+  if err != nil {
+    log.Printf(" -> GetPendingInvitations: Query FAILURE: %s", err.Error())
+    return nil, GetDataError{userID.String(), SQLINVITATIONS}
+  }
+  defer rows.Close()
+
+  invitations := []Invitation{}
+  for rows.Next() {
+    invitation, err := sqlScanInvitation(rows)
+    if err != nil {
+      return nil, DecoderError{err.Error()}
+    }
+    invitations = append(invitations, *invitation)
+  }
+  if err := rows.Err(); err != nil {
+    return nil, err
+  }
+  sortInvitations(invitations)
+  return invitations, nil
+}
+
+func(db *SQLiteDB)RemoveInvitation(roomID UUID, userID UUID) error {
+  if _, err := db.db.Exec(
+    `DELETE FROM invitations WHERE room_id = ? AND user_id = ?`,
+    roomID, userID,
+  ); err != nil {
+    return DeleteDataError{inviteKey(&roomID, &userID), SQLINVITATIONS, err.Error()}
+  }
+  return nil
+}
+
+func(db *SQLiteDB)SaveInviteLink(link *InviteLink) error {
+  if _, err := db.db.Exec(
+    `INSERT INTO invite_links (code, room_id, created_by, created_at, expires_at, max_uses, uses)
+     VALUES (?, ?, ?, ?, ?, ?, ?)`,
+    link.Code, link.RoomID, link.CreatedBy,
+    link.CreatedAt.UnixNano(), link.ExpiresAt.UnixNano(), link.MaxUses, link.Uses,
+  ); err != nil {
+    return PutDataError{link.Code, SQLINVITELINKS, err.Error()}
+  }
+  return nil
+}
+
+func(db *SQLiteDB)GetInviteLink(code string)( *InviteLink, error ){
+  return sqlGetInviteLink(db.db, code, false)
+}
+
+func(db *SQLiteDB)RedeemInviteLink(code string, userID UUID)( *InviteLink, error ){
+  var link *InviteLink
+  err := db.update(func(tx *sql.Tx) error {
+    var err error
+    if link, err = sqlGetInviteLink(tx, code, true); err != nil {
+      return err
+    }
+    chatroom, err := sqlGetChatroom(tx, link.Chatroom)
+    if err != nil {
+      return err
+    }
+    status, err := sqlGetChatroomMemberStatus(tx, link.Chatroom, userID)
+    if _, ok := err.(GetDataError); err != nil && !ok {
+      return err
+    }
+    members, err := sqlGetChatroomMembers(tx, link.Chatroom)
+    if err != nil {
+      return err
+    }
+    if err := link.redeemFor(chatroom, status, members); err != nil {
+      return err
+    }
+    if _, err := tx.Exec(`UPDATE invite_links SET uses = ? WHERE code = ?`, link.Uses, code); err != nil {
+      return PutDataError{code, SQLINVITELINKS, err.Error()}
+    }
+    return sqlSaveChatroomMember(tx, link.Chatroom, userID, Member)
+  })
+  if err != nil {
+    return nil, err
+  }
+  return link, nil
+}
+
+func(db *SQLiteDB)HandleRawMessage(chatroomName string, userID UUID, raw []byte)( []byte, error ){
+  extraction, err := decodeChatroomMessage(raw, chatroomName, userID)
+  if err != nil {
+    return nil, err
+  }
+
+  err = db.update(func(tx *sql.Tx) error {
+    chatroom, err := sqlGetChatroom(tx, extraction.Chatroom)
+    if err != nil {
+      return err
+    }
+    member, err := sqlGetChatroomMember(tx, extraction.Chatroom, extraction.Message.UserID)
+    if _, ok := err.(GetDataError); err != nil && !ok {
+      return err
+    }
+    if err := authorizePost(chatroom, member); err != nil {
+      return err
+    }
+    return sqlSaveMessage(tx, extraction.Chatroom, &extraction.Message)
+  })
+  if err != nil {
+    return nil, err
+  }
+  return encodeChatroomMessage(extraction)
+}
+
+func(db *SQLiteDB)SaveMessage(chatroom string, message *Message) error {
+  return db.update(func(tx *sql.Tx) error {
+    exist, err := sqlDoesChatroomExist(tx, chatroom)
+    if err != nil {
+      return err
+    }
+    if !exist {
+      log.Printf(" -> SaveMessage: Error - Chatroom Does't exist.")
+      return fmt.Errorf("Error: Received Message for a chatroom that doesn't exist")
+    }
+    return sqlSaveMessage(tx, chatroom, message)
+  })
+}
+
+func(db *SQLiteDB)SaveMessages(chatroom string, messages []Message) error {
+  return db.update(func(tx *sql.Tx) error {
+    exist, err := sqlDoesChatroomExist(tx, chatroom)
+    if err != nil {
+      return err
+    }
+    if !exist {
+      log.Printf(" -> SaveMessages: Error - Chatroom Does't exist.")
+      return fmt.Errorf("Error: Received Messages for a chatroom that doesn't exist")
+    }
+    for i := range messages {
+      if err := sqlSaveMessage(tx, chatroom, &messages[i]); err != nil {
+        return err
+      }
+    }
+    return nil
+  })
+}
+
+// PurgeMessages :: Deleting from /messages cascades onto the Message's search terms,
+//    revisions and reactions.
+func(db *SQLiteDB)PurgeMessages(
+  chatroomName string,
+  retention    Retention,
+  now          time.Time,
+  limit        int,
+)( int, error ){
+  if retention.Forever() {
+    return 0, nil
+  }
+  if limit <= 0 {
+    limit = DefaultPurgeBatchSize
+  }
+
+  purged := 0
+  err := db.update(func(tx *sql.Tx) error {
+    roomID, err := sqlGetRoomID(tx, chatroomName, false)
+    if err != nil {
+      return err
+    }
+    var total int
+    if err := tx.QueryRow(
+      `SELECT COUNT(*) FROM messages WHERE room_id = ?`, roomID,
+    ).Scan(&total); err != nil {
+      return GetDataError{chatroomName, SQLMESSAGES}
+    }
+
+    rows, err := tx.Query(
+      `SELECT seq, time_stamp FROM messages WHERE room_id = ? ORDER BY seq LIMIT ?`,
+      roomID, limit,
+    )
+    if err != nil {
+      return GetDataError{chatroomName, SQLMESSAGES}
+    }
+    var last uint64
+    for rows.Next() {
+      var seq uint64
+      var timeStamp int64
+      if err := rows.Scan(&seq, &timeStamp); err != nil {
+        rows.Close()
+        return DecoderError{err.Error()}
+      }
+      if !retention.expired(time.Unix(0, timeStamp), total-purged-1, now) {
+        break
+      }
+      last = seq
+      purged++
+    }
+    rows.Close()
+    if purged == 0 {
+      return nil
+    }
+
+    if _, err := tx.Exec(
+      `DELETE FROM messages WHERE room_id = ? AND seq <= ?`, roomID, last,
+    ); err != nil {
+      return DeleteDataError{chatroomName, SQLMESSAGES, err.Error()}
+    }
+    return nil
+  })
+  if err != nil {
+    return 0, err
+  }
+  return purged, nil
+}
+
+// Paginate :: Lets the messages_room_seq index do the seeking for us.
+func(db *SQLiteDB)Paginate(
+  chatroomName string,
+  before, after uint64,
+  limit int,
+)( *MessagePage, error ){
+  roomID, err := sqlGetRoomID(db.db, chatroomName, false)
+  if err != nil {
+    return nil, err
+  }
+  return sqlPaginate(db.db, chatroomName, "room_id = ?", []interface{}{ roomID }, before, after, limit)
+}
+
+// ScanMessages :: Lets the messages_room_seq index do the seeking for us.
+func(db *SQLiteDB)ScanMessages(chatroomName string, after uint64, limit int)( []Message, error ){
+  roomID, err := sqlGetRoomID(db.db, chatroomName, false)
+  if err != nil {
+    return nil, err
+  }
+  rows, err := db.db.Query(
+    `SELECT `+sqlMessageColumns+` FROM messages
+     WHERE room_id = ? AND seq > ?
+     ORDER BY seq ASC LIMIT ?`,
+    roomID, after, limit,
+  )
+  if err != nil {
+    return nil, GetDataError{chatroomName, SQLMESSAGES}
+  }
+  msgs, err := sqlScanMessages(rows)
+  if err != nil {
+    return nil, err
+  }
+  if err := sqlAttachReactions(db.db, msgs); err != nil {
+    return nil, err
+  }
+  return msgs, nil
+}
+
+// PaginateThread :: Lets the messages_room_thread index do the seeking for us.
+func(db *SQLiteDB)PaginateThread(
+  chatroomName string,
+  threadID     UUID,
+  before, after uint64,
+  limit int,
+)( *MessagePage, error ){
+  roomID, err := sqlGetRoomID(db.db, chatroomName, false)
+  if err != nil {
+    return nil, err
+  }
+  // Replies keep their thread_id after PurgeMessages takes the root, but the
+  //    thread goes with it's root, as it does for every other backend.
+  return sqlPaginate(
+    db.db, chatroomName,
+    "room_id = ? AND thread_id = ? AND EXISTS (SELECT 1 FROM messages root WHERE root.room_id = ? AND root.message_id = ?)",
+    []interface{}{ roomID, threadID, roomID, threadID },
+    before, after, limit,
+  )
+}
+
+// sqlPaginate :: Pages through the Messages matching filter, a WHERE clause using
+//    filterArgs, the same way for both Paginate and PaginateThread.
+func sqlPaginate(
+  q            sqlQuerier,
+  chatroomName string,
+  filter       string,
+  filterArgs   []interface{},
+  before, after uint64,
+  limit int,
+)( *MessagePage, error ){
+  limit = ClampPageSize(limit)
+
+  upper := before
+  if upper == 0 {
+    upper = math.MaxInt64
+  }
+  order := "DESC"
+  if after > 0 && before == 0 {
+    order = "ASC"
+  }
+
+  args := append(append([]interface{}{}, filterArgs...), after, upper, limit)
+  rows, err := q.Query(
+    `SELECT `+sqlMessageColumns+` FROM messages
+     WHERE `+filter+` AND seq > ? AND seq < ?
+     ORDER BY seq `+order+` LIMIT ?`,
+    args...,
+  )
+  if err != nil {
+    return nil, GetDataError{chatroomName, SQLMESSAGES}
+  }
+  msgs, err := sqlScanMessages(rows)
+  if err != nil {
+    return nil, err
+  }
+  if order == "DESC" {
+    for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+      msgs[i], msgs[j] = msgs[j], msgs[i]
+    }
+  }
+  if err := sqlAttachReactions(q, msgs); err != nil {
+    return nil, err
+  }
+
+  var hasOlder, hasNewer bool
+  if len(msgs) > 0 {
+    args := append(append([]interface{}{}, filterArgs...), msgs[0].Seq)
+    args = append(append(args, filterArgs...), msgs[len(msgs)-1].Seq)
+    if err := q.QueryRow(
+      `SELECT
+         EXISTS(SELECT 1 FROM messages WHERE `+filter+` AND seq < ?),
+         EXISTS(SELECT 1 FROM messages WHERE `+filter+` AND seq > ?)`,
+      args...,
+    ).Scan(&hasOlder, &hasNewer); err != nil {
+      return nil, GetDataError{chatroomName, SQLMESSAGES}
+    }
+  }
+  return NewMessagePage(msgs, hasOlder, hasNewer), nil
+}
+
+func(db *SQLiteDB)GetMessage(chatroom string, messageID UUID)( *Message, error ){
+  return sqlGetMessage(db.db, chatroom, messageID)
+}
+
+func(db *SQLiteDB)EditMessage(
+  chatroom  string,
+  messageID UUID,
+  editorID  UUID,
+  content   string,
+)( *Message, error ){
+  var message *Message
+  err := db.update(func(tx *sql.Tx) error {
+    var err error
+    if message, err = sqlGetMessage(tx, chatroom, messageID); err != nil {
+      return err
+    }
+    if message.IsDeleted() {
+      return MessageDeletedError{messageID.String()}
+    }
+
+    now := time.Now()
+    if _, err := tx.Exec(
+      `INSERT INTO message_revisions (message_id, revision, content, replaced_at, replaced_by)
+       SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ?, ? FROM message_revisions WHERE message_id = ?`,
+      messageID, message.Content, now.UnixNano(), editorID, messageID,
+    ); err != nil {
+      return PutDataError{messageID.String(), SQLMESSAGEREVISIONS, err.Error()}
+    }
+    if _, err := tx.Exec(
+      `UPDATE messages SET content = ?, edited_at = ? WHERE message_id = ?`,
+      content, now.UnixNano(), messageID,
+    ); err != nil {
+      return PutDataError{messageID.String(), SQLMESSAGES, err.Error()}
+    }
+    if _, err := tx.Exec(`DELETE FROM message_terms WHERE message_id = ?`, messageID); err != nil {
+      return DeleteDataError{messageID.String(), SQLMESSAGETERMS, err.Error()}
+    }
+    message.Content = content
+    message.EditedAt = now
+    return sqlIndexMessage(tx, messageID, content)
+  })
+  if err != nil {
+    log.Printf(" -> EditMessage: Failed to edit Message \"%s\": %s", messageID, err)
+    return nil, err
+  }
+  return message, nil
+}
+
+func(db *SQLiteDB)DeleteMessage(chatroom string, messageID UUID)( *Message, error ){
+  var message *Message
+  err := db.update(func(tx *sql.Tx) error {
+    var err error
+    if message, err = sqlGetMessage(tx, chatroom, messageID); err != nil {
+      return err
+    }
+    if message.IsDeleted() {
+      return MessageDeletedError{messageID.String()}
+    }
+
+    now := time.Now()
+    if _, err := tx.Exec(
+      `UPDATE messages SET content = '', deleted_at = ? WHERE message_id = ?`,
+      now.UnixNano(), messageID,
+    ); err != nil {
+      return PutDataError{messageID.String(), SQLMESSAGES, err.Error()}
+    }
+    for _, table := range []string{ SQLMESSAGETERMS, SQLMESSAGEREVISIONS, SQLMESSAGEREACTIONS } {
+      if _, err := tx.Exec(`DELETE FROM `+table+` WHERE message_id = ?`, messageID); err != nil {
+        return DeleteDataError{messageID.String(), table, err.Error()}
+      }
+    }
+    message.Content = ""
+    message.DeletedAt = now
+    return nil
+  })
+  if err != nil {
+    log.Printf(" -> DeleteMessage: Failed to delete Message \"%s\": %s", messageID, err)
+    return nil, err
+  }
+  return message, nil
+}
+
+func(db *SQLiteDB)AddReaction(chatroom string, messageID UUID, userID UUID, reaction string)( *Message, error ){
+  return db.updateReaction(chatroom, messageID,
+    `INSERT OR IGNORE INTO message_reactions (message_id, reaction, user_id, reacted_at) VALUES (?, ?, ?, ?)`,
+    messageID, reaction, userID, time.Now().UnixNano(),
+  )
+}
+
+func(db *SQLiteDB)RemoveReaction(chatroom string, messageID UUID, userID UUID, reaction string)( *Message, error ){
+  return db.updateReaction(chatroom, messageID,
+    `DELETE FROM message_reactions WHERE message_id = ? AND reaction = ? AND user_id = ?`,
+    messageID, reaction, userID,
+  )
+}
+
+// updateReaction :: Runs stmt against /message_reactions, then reloads the Message
+//    along with it's Reactions.
+func(db *SQLiteDB)updateReaction(
+  chatroom  string,
+  messageID UUID,
+  stmt      string,
+  args      ...interface{},
+)( *Message, error ){
+  var message *Message
+  err := db.update(func(tx *sql.Tx) error {
+    var err error
+    if message, err = sqlGetMessage(tx, chatroom, messageID); err != nil {
+      return err
+    }
+    if message.IsDeleted() {
+      return MessageDeletedError{messageID.String()}
+    }
+    if _, err := tx.Exec(stmt, args...); err != nil {
+      return PutDataError{messageID.String(), SQLMESSAGEREACTIONS, err.Error()}
+    }
+    message.Reactions = nil
+    msgs := []Message{ *message }
+    if err := sqlAttachReactions(tx, msgs); err != nil {
+      return err
+    }
+    message = &msgs[0]
+    return nil
+  })
+  if err != nil {
+    return nil, err
+  }
+  return message, nil
+}
+
+func(db *SQLiteDB)GetMessageRevisions(chatroom string, messageID UUID)( []MessageRevision, error ){
+  if _, err := sqlGetMessage(db.db, chatroom, messageID); err != nil {
+    return nil, err
+  }
+  rows, err := db.db.Query(
+    `SELECT content, replaced_at, replaced_by FROM message_revisions
+     WHERE message_id = ? ORDER BY revision`,
+    messageID,
+  )
+  if err != nil {
+    return nil, GetDataError{messageID.String(), SQLMESSAGEREVISIONS}
+  }
+  defer rows.Close()
+
+  revisions := []MessageRevision{}
+  for rows.Next() {
+    var revision MessageRevision
+    var replacedAt int64
+    if err := rows.Scan(&revision.Content, &replacedAt, &revision.ReplacedBy); err != nil {
+      return nil, DecoderError{err.Error()}
+    }
+    revision.ReplacedAt = time.Unix(0, replacedAt)
+    revisions = append(revisions, revision)
+  }
+  return revisions, rows.Err()
+}
+
+// SearchMessages :: A Message matches when /message_terms holds a row for every term.
+func(db *SQLiteDB)SearchMessages(query SearchQuery)( []ChatroomMessage, error ){
+  results := []ChatroomMessage{}
+  if len(query.Terms) == 0 {
+    return results, nil
+  }
+  rooms := query.rooms()
+  if query.scoped() && len(rooms) == 0 {
+    return results, nil
+  }
+
+  args := make([]interface{}, 0, len(query.Terms)+len(rooms)+6)
+  for _, term := range query.Terms {
+    args = append(args, term)
+  }
+  args = append(args, len(query.Terms))
+
+  stmt := `SELECT c.room_name, ` + sqlMessageColumns + `
+    FROM messages m JOIN chatrooms c ON c.room_id = m.room_id
+    WHERE m.message_id IN (
+      SELECT message_id FROM message_terms
+      WHERE term IN (?` + strings.Repeat(", ?", len(query.Terms)-1) + `)
+      GROUP BY message_id HAVING COUNT(*) = ?
+    )`
+  if query.scoped() {
+    stmt += ` AND c.room_name IN (?` + strings.Repeat(", ?", len(rooms)-1) + `)`
+    for _, room := range rooms {
+      args = append(args, room)
+    }
+  }
+  if query.UserID != (UUID{}) {
+    stmt += ` AND m.user_id = ?`
+    args = append(args, query.UserID)
+  }
+  if !query.Before.IsZero() {
+    stmt += ` AND m.time_stamp < ?`
+    args = append(args, query.Before.UnixNano())
+  }
+  if !query.After.IsZero() {
+    stmt += ` AND m.time_stamp > ?`
+    args = append(args, query.After.UnixNano())
+  }
+  stmt += ` ORDER BY m.time_stamp DESC, c.room_name, m.seq DESC`
+  if query.Limit > 0 {
+    stmt += ` LIMIT ?`
+    args = append(args, query.Limit)
+  }
+
+  rows, err := db.db.Query(stmt, args...)
+  if err != nil {
+    log.Printf(" -> SearchMessages: Query FAILURE: %s", err.Error())
+    return nil, GetDataError{strings.Join(query.Terms, " "), SQLMESSAGETERMS}
+  }
+  defer rows.Close()
+
+  for rows.Next() {
+    var chatroom string
+    msg, err := sqlScanMessage(rows, &chatroom)
+    if err != nil {
+      return nil, DecoderError{err.Error()}
+    }
+    results = append(results, ChatroomMessage{ Chatroom: chatroom, Message: *msg })
+  }
+  if err := rows.Err(); err != nil {
+    return nil, err
+  }
+  msgs := make([]Message, len(results))
+  for i := range results {
+    msgs[i] = results[i].Message
+  }
+  if err := sqlAttachReactions(db.db, msgs); err != nil {
+    return nil, err
+  }
+  for i := range results {
+    results[i].Message = msgs[i]
+  }
+  sortSearchResults(results)
+  return results, nil
+}
+
+func(db *SQLiteDB)MarkRead(chatroom string, userID UUID, seq uint64)( uint64, error ){
+  var marker uint64
+  err := db.update(func(tx *sql.Tx) error {
+    roomID, err := sqlGetRoomID(tx, chatroom, false)
+    if err != nil {
+      return err
+    }
+    var latest uint64
+    if err := tx.QueryRow(
+      `SELECT last_seq FROM chatrooms WHERE room_id = ?`, roomID,
+    ).Scan(&latest); err != nil {
+      return GetDataError{chatroom, SQLMESSAGES}
+    }
+    if seq == 0 || seq > latest {
+      seq = latest
+    }
+
+    if _, err := tx.Exec(
+      `UPDATE chatroom_members SET last_read = MAX(last_read, ?)
+       WHERE room_id = ? AND user_id = ? AND member_type != ?`,
+      seq, roomID, userID, Blocked,
+    ); err != nil {
+      return PutDataError{chatroom + "-" + userID.String(), SQLCHATROOMMEMBERS, err.Error()}
+    }
+    if err := tx.QueryRow(
+      `SELECT last_read FROM chatroom_members
+       WHERE room_id = ? AND user_id = ? AND member_type != ?`,
+      roomID, userID, Blocked,
+    ).Scan(&marker); err != nil {
+      return sqlGetError(err, chatroom+"-"+userID.String(), SQLCHATROOMMEMBERS)
+    }
+    return nil
+  })
+  if err != nil {
+    return 0, err
+  }
+  return marker, nil
+}
+
+func(db *SQLiteDB)GetJoinedChatrooms(userID UUID)( []JoinedChatroom, error ){
+  rows, err := db.db.Query(
+    `SELECT c.room_name, m.member_type, m.last_read,
+       (SELECT COUNT(*) FROM messages WHERE room_id = m.room_id AND seq > m.last_read AND deleted_at = 0),
+       c.owner_id, c.peer_id
+     FROM chatroom_members m JOIN chatrooms c ON c.room_id = m.room_id
+     WHERE m.user_id = ? AND m.member_type != ? AND c.active = 1
+     ORDER BY c.room_name`,
+    userID, Blocked,
+  )
+  if err != nil {
+    log.Printf(" -> GetJoinedChatrooms: Query FAILURE: %s", err.Error())
+    return nil, GetDataError{userID.String(), SQLCHATROOMMEMBERS}
+  }
+  defer rows.Close()
+
+  rooms := []JoinedChatroom{}
+  for rows.Next() {
+    var room JoinedChatroom
+    var chatroom Chatroom
+    if err := rows.Scan(
+      &room.Chatroom, &room.MemberType, &room.LastRead, &room.Unread,
+      &chatroom.OwnerID, &chatroom.PeerID,
+    ); err != nil {
+      return nil, DecoderError{err.Error()}
+    }
+    if chatroom.IsDirect() {
+      room.PeerID = chatroom.DirectPeer(userID)
+    }
+    rooms = append(rooms, room)
+  }
+  return rooms, rows.Err()
+}
+
+func(db *SQLiteDB)UpdateChatroomUserStatus(chatroom, username string, status Status) error {
+  return db.update(func(tx *sql.Tx) error {
+    user, err := sqlGetUserbyUsername(tx, username)
+    if err != nil {
+      return err
+    }
+    memtype, err := sqlGetChatroomMemberStatus(tx, chatroom, user.UserID)
+    if err != nil {
+      return FailedSecurityCheckError{"MemberType: nil", err.Error()}
+    }
+    if *memtype == Blocked {
+      return FailedSecurityCheckError{"MemberType: blocked", "user is blocked"}
+    }
+
+    var liveStatus interface{}
+    switch status {
+    case Online:
+      liveStatus = "Online"
+    case Background:
+      liveStatus = "Background"
+    case Offline:
+      liveStatus = "Offline"
+    case Delete:
+      liveStatus = nil
+    default:
+      return fmt.Errorf("Unknonw Status Request")
+    }
+
+    if _, err := tx.Exec(
+      `UPDATE chatroom_members SET live_status = ?
+       WHERE user_id = ? AND room_id = (SELECT room_id FROM chatrooms WHERE room_name = ?)`,
+      liveStatus, user.UserID, chatroom,
+    ); err != nil {
+      return PutDataError{chatroom, SQLCHATROOMMEMBERS, err.Error()}
+    }
+    return nil
+  })
+}
+
+func(db *SQLiteDB)GetChatroomUserStatus(chatroom string)( map[UserName]string, error ){
+  rows, err := db.db.Query(
+    `SELECT u.username, m.live_status FROM chatroom_members m
+     JOIN chatrooms c ON c.room_id = m.room_id
+     JOIN users u ON u.user_id = m.user_id
+     WHERE c.room_name = ? AND m.live_status IS NOT NULL`,
+    chatroom,
+  )
+  if err != nil {
+    return nil, GetDataError{chatroom, SQLCHATROOMMEMBERS}
+  }
+  defer rows.Close()
+
+  stats := make(map[UserName]string)
+  for rows.Next() {
+    var username, status string
+    if err := rows.Scan(&username, &status); err != nil {
+      return nil, DecoderError{err.Error()}
+    }
+    stats[username] = status
+  }
+  if err := rows.Err(); err != nil {
+    return nil, err
+  }
+  if len(stats) == 0 {
+    return nil, GetDataError{chatroom, SQLCHATROOMMEMBERS}
+  }
+  return stats, nil
+}
+
+func(db *SQLiteDB)SaveChatroomMember(
+  chatroomName string,
+  userID UUID,
+  memberType MemberType,
+) error {
+  return sqlSaveChatroomMember(db.db, chatroomName, userID, memberType)
+}
+
+func(db *SQLiteDB)GetChatroomMemberStatus(
+  chatroomName string,
+  userID UUID,
+)( *MemberType, error ){
+  return sqlGetChatroomMemberStatus(db.db, chatroomName, userID)
+}
+
+// SaveUser :: Creates or updates a row in /users. New users start out offline,
+//    and when a token is provided, it's stored within /tokens.
+func(db *SQLiteDB)SaveUser(user User, token *token.Token) error {
+  return db.update(func(tx *sql.Tx) error {
+    if _, err := tx.Exec(
+      `INSERT INTO users (user_id, username, hashed_password, online) VALUES (?, ?, ?, 0)
+       ON CONFLICT(user_id) DO UPDATE SET
+         username = excluded.username,
+         hashed_password = excluded.hashed_password`,
+      user.UserID, user.Username, user.HashedPassword,
+    ); err != nil {
+      log.Printf(" -> Error: SaveUser - Failed to save User \"%s\": %s", user.Username, err)
+      return PutDataError{user.UserID.String(), SQLUSERS, err.Error()}
+    }
+    if token != nil {
+      return sqlSaveUserToken(tx, user.UserID, token)
+    }
+    return nil
+  })
+}
+
+func(db *SQLiteDB)GetUserByID(id UUID)( *User,error ){
+  user := User{ UserID: id }
+  err := db.db.QueryRow(
+    `SELECT username, hashed_password FROM users WHERE user_id = ? AND deactivated = 0`,
+    id,
+  ).Scan(&user.Username, &user.HashedPassword)
+  if err != nil {
+    log.Printf(" -> GetUserById - User ID \"%s\" not found.", id)
+    return nil, sqlGetError(err, id.String(), SQLUSERS)
+  }
+  return &user, nil
+}
+
+func(db *SQLiteDB)ActivateUser(userID UUID) error {
+  return sqlSetUserDeactivated(db.db, userID, false)
+}
+
+// DeactivateUser :: The users row, and so the Username, is kept. Only it's Access
+//    Token is deleted.
+func(db *SQLiteDB)DeactivateUser(userID UUID) error {
+  return db.update(func(tx *sql.Tx) error {
+    if err := sqlSetUserDeactivated(tx, userID, true); err != nil {
+      return err
+    }
+    if _, err := tx.Exec(`UPDATE users SET online = 0 WHERE user_id = ?`, userID); err != nil {
+      return PutDataError{userID.String(), SQLUSERS, err.Error()}
+    }
+    if _, err := tx.Exec(`DELETE FROM tokens WHERE user_id = ?`, userID); err != nil {
+      return DeleteDataError{userID.String(), SQLTOKENS, err.Error()}
+    }
+    return nil
+  })
+}
+
+func(db *SQLiteDB)GetDeactivatedUser(username string)( *User, error ){
+  user := User{ Username: username }
+  err := db.db.QueryRow(
+    `SELECT user_id, hashed_password FROM users WHERE username = ? AND deactivated = 1`,
+    username,
+  ).Scan(&user.UserID, &user.HashedPassword)
+  if err != nil {
+    return nil, sqlGetError(err, username, SQLUSERS)
+  }
+  return &user, nil
+}
+
+func(db *SQLiteDB)GetDeactivatedUserByID(id UUID)( *User, error ){
+  user := User{ UserID: id }
+  err := db.db.QueryRow(
+    `SELECT username, hashed_password FROM users WHERE user_id = ? AND deactivated = 1`,
+    id,
+  ).Scan(&user.Username, &user.HashedPassword)
+  if err != nil {
+    return nil, sqlGetError(err, id.String(), SQLUSERS)
+  }
+  return &user, nil
+}
+
+func(db *SQLiteDB)GetUserbyUsername(username string)( *User, error ){
+  return sqlGetUserbyUsername(db.db, username)
+}
+
+func(db *SQLiteDB)SaveUsersOnlineStatus(username string, isOnline bool) error {
+  res, err := db.db.Exec(`UPDATE users SET online = ? WHERE username = ?`, isOnline, username)
+  if err != nil {
+    return PutDataError{username, SQLUSERS, err.Error()}
+  }
+  if n, _ := res.RowsAffected(); n == 0 {
+    return GetDataError{username, SQLUSERS}
+  }
+  return nil
+}
+
+func(db *SQLiteDB)SaveUserToken(userID UUID, token *token.Token) error {
+  return sqlSaveUserToken(db.db, userID, token)
+}
+
+func(db *SQLiteDB)GetUserToken(userID UUID)( *token.Token, error ){
+  var userToken token.Token
+  err := db.db.QueryRow(
+    `SELECT token FROM tokens WHERE user_id = ?`, userID,
+  ).Scan(&userToken.Token)
+  if err != nil {
+    log.Printf(" -> GetUserToken: User Token for \"%s\" doesn't exist", userID.String())
+    return nil, sqlGetError(err, userID.String(), SQLTOKENS)
+  }
+  return &userToken, nil
+}
+
+func(db *SQLiteDB)GetProfile(userID UUID)( *Profile, error ){
+  profile := Profile{ UserID: userID }
+  var updatedAt int64
+  err := db.db.QueryRow(
+    `SELECT u.username, COALESCE(p.display_name, ''), COALESCE(p.bio, ''), COALESCE(p.location, ''),
+            COALESCE(p.company, ''), COALESCE(p.timezone, ''), COALESCE(p.avatar, ''), COALESCE(p.updated_at, 0)
+     FROM users u LEFT JOIN profiles p ON p.user_id = u.user_id
+     WHERE u.user_id = ? AND u.deactivated = 0`,
+    userID,
+  ).Scan(
+    &profile.Username, &profile.DisplayName, &profile.Bio, &profile.Location,
+    &profile.Company, &profile.Timezone, &profile.Avatar, &updatedAt,
+  )
+  if err != nil {
+    return nil, sqlGetError(err, userID.String(), SQLUSERS)
+  }
+  if updatedAt != 0 {
+    profile.UpdatedAt = time.Unix(0, updatedAt)
+  }
+  return &profile, nil
+}
+
+func(db *SQLiteDB)SaveProfile(profile *Profile) error {
+  return db.update(func(tx *sql.Tx) error {
+    var found int
+    if err := tx.QueryRow(
+      `SELECT 1 FROM users WHERE user_id = ? AND deactivated = 0`, profile.UserID,
+    ).Scan(&found); err != nil {
+      return sqlGetError(err, profile.UserID.String(), SQLUSERS)
+    }
+
+    var updatedAt int64
+    if !profile.UpdatedAt.IsZero() {
+      updatedAt = profile.UpdatedAt.UnixNano()
+    }
+    if _, err := tx.Exec(
+      `INSERT INTO profiles (user_id, display_name, bio, location, company, timezone, avatar, updated_at)
+       VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+       ON CONFLICT(user_id) DO UPDATE SET
+         display_name = excluded.display_name,
+         bio = excluded.bio,
+         location = excluded.location,
+         company = excluded.company,
+         timezone = excluded.timezone,
+         avatar = excluded.avatar,
+         updated_at = excluded.updated_at`,
+      profile.UserID, profile.DisplayName, profile.Bio, profile.Location,
+      profile.Company, profile.Timezone, profile.Avatar, updatedAt,
+    ); err != nil {
+      return PutDataError{profile.UserID.String(), SQLPROFILES, err.Error()}
+    }
+    return nil
+  })
+}
+
+func(db *SQLiteDB)UpdateUserPassword(userID UUID, hashedPassword []byte, token *token.Token) error {
+  return db.update(func(tx *sql.Tx) error {
+    res, err := tx.Exec(
+      `UPDATE users SET hashed_password = ? WHERE user_id = ? AND deactivated = 0`,
+      hashedPassword, userID,
+    )
+    if err != nil {
+      return PutDataError{userID.String(), SQLUSERS, err.Error()}
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+      return GetDataError{userID.String(), SQLUSERS}
+    }
+
+    if token != nil {
+      if err := sqlSaveUserToken(tx, userID, token); err != nil {
+        return err
+      }
+    } else if _, err := tx.Exec(`DELETE FROM tokens WHERE user_id = ?`, userID); err != nil {
+      return DeleteDataError{userID.String(), SQLTOKENS, err.Error()}
+    }
+    return sqlRemovePasswordResets(tx, userID)
+  })
+}
+
+func(db *SQLiteDB)SavePasswordReset(reset *PasswordReset) error {
+  return db.update(func(tx *sql.Tx) error {
+    if err := sqlRemovePasswordResets(tx, reset.UserID); err != nil {
+      return err
+    }
+    if _, err := tx.Exec(
+      `INSERT INTO password_resets (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)`,
+      reset.TokenHash, reset.UserID, reset.CreatedAt.UnixNano(), reset.ExpiresAt.UnixNano(),
+    ); err != nil {
+      return PutDataError{reset.UserID.String(), SQLPASSWORDRESETS, err.Error()}
+    }
+    return nil
+  })
+}
+
+func(db *SQLiteDB)ConsumePasswordReset(resetToken string)( *PasswordReset, error ){
+  reset := PasswordReset{ TokenHash: HashResetToken(resetToken) }
+  err := db.update(func(tx *sql.Tx) error {
+    var createdAt, expiresAt int64
+    if err := tx.QueryRow(
+      `SELECT user_id, created_at, expires_at FROM password_resets WHERE token_hash = ?`,
+      reset.TokenHash,
+    ).Scan(&reset.UserID, &createdAt, &expiresAt); err != nil {
+      return sqlGetError(err, "reset token", SQLPASSWORDRESETS)
+    }
+    reset.CreatedAt = time.Unix(0, createdAt)
+    reset.ExpiresAt = time.Unix(0, expiresAt)
+    if _, err := tx.Exec(`DELETE FROM password_resets WHERE token_hash = ?`, reset.TokenHash); err != nil {
+      return DeleteDataError{reset.UserID.String(), SQLPASSWORDRESETS, err.Error()}
+    }
+    return nil
+  })
+  if err != nil {
+    return nil, err
+  }
+  if err := checkPasswordReset(&reset); err != nil {
+    return nil, err
+  }
+  return &reset, nil
+}
+
+func(db *SQLiteDB)Close() {
+  db.db.Close()
+}
+
+// ----------------------- SQLiteDB Helper Funcs -----------------------
+
+// update :: Runs fn within a transaction. Commits if fn returns nil, otherwise
+//    everything is rolled back. Much like bbolt.DB.Update.
+func(db *SQLiteDB)update(fn func(tx *sql.Tx) error) error {
+  tx, err := db.db.Begin()
+  if err != nil {
+    return err
+  }
+  if err := fn(tx); err != nil {
+    tx.Rollback()
+    return err
+  }
+  return tx.Commit()
+}
+
+// sqlGetError :: Converts sql.ErrNoRows into our GetDataError.
+func sqlGetError(err error, item, table string) error {
+  if errors.Is(err, sql.ErrNoRows) {
+    return GetDataError{item, table}
+  }
+  return DecoderError{err.Error()}
+}
+
+func sqlGetChatroom(q sqlQuerier, name string)( *Chatroom, error ){
+  chatroom, err := sqlScanChatroom(q.QueryRow(
+    `SELECT ` + sqlChatroomColumns + ` FROM chatrooms
+     WHERE room_name = ? AND active = 1`,
+    name,
+  ))
+  if err != nil {
+    return nil, sqlGetError(err, name, SQLCHATROOMS)
+  }
+  return chatroom, nil
+}
+
+func sqlScanChatroom(row sqlScanner)( *Chatroom, error ){
+  var chatroom Chatroom
+  if err := row.Scan(
+    &chatroom.RoomID, &chatroom.RoomName, &chatroom.OwnerID, &chatroom.Public, &chatroom.PeerID,
+    &chatroom.Topic, &chatroom.Description, &chatroom.SlowMode, &chatroom.MaxMembers, &chatroom.ReadOnly,
+    &chatroom.MaxMessageAge, &chatroom.MaxMessages,
+  ); err != nil {
+    return nil, err
+  }
+  return &chatroom, nil
+}
+
+// sqlPutChatroomSettings :: Writes every setting of an existing Chatroom.
+func sqlPutChatroomSettings(tx *sql.Tx, chatroom *Chatroom) error {
+  if _, err := tx.Exec(
+    `UPDATE chatrooms SET public = ?, topic = ?, description = ?, slow_mode = ?, max_members = ?, read_only = ?,
+       max_message_age = ?, max_messages = ?
+     WHERE room_name = ? AND active = 1`,
+    chatroom.Public, chatroom.Topic, chatroom.Description, chatroom.SlowMode, chatroom.MaxMembers, chatroom.ReadOnly,
+    chatroom.MaxMessageAge, chatroom.MaxMessages, chatroom.RoomName,
+  ); err != nil {
+    return PutDataError{chatroom.RoomName, SQLCHATROOMS, err.Error()}
+  }
+  return nil
+}
+
+func sqlGetChatroomMembers(q sqlQuerier, chatroomName string)( map[UUID]MemberType, error ){
+  rows, err := q.Query(
+    `SELECT m.user_id, m.member_type FROM chatroom_members m
+     JOIN chatrooms c ON c.room_id = m.room_id
+     WHERE c.room_name = ?`,
+    chatroomName,
+  )
+  if err != nil {
+    log.Printf(" -> GetChatroomMembers: Failed to retreive all the Members of \"%s\"", chatroomName)
+    return nil, GetDataError{chatroomName, SQLCHATROOMMEMBERS}
+  }
+  defer rows.Close()
+
+  members := make(map[UUID]MemberType)
+  for rows.Next() {
+    var userID UUID
+    var memberType MemberType
+    if err := rows.Scan(&userID, &memberType); err != nil {
+      return nil, DecoderError{err.Error()}
+    }
+    members[userID] = memberType
+  }
+  return members, rows.Err()
+}
+
+const sqlInvitationColumns = "i.room_id, c.room_name, i.user_id, i.invited_by, i.created_at, i.expires_at"
+
+func sqlScanInvitation(row sqlScanner)( *Invitation, error ){
+  var invitation Invitation
+  var createdAt, expiresAt int64
+  if err := row.Scan(
+    &invitation.RoomID, &invitation.Chatroom, &invitation.UserID, &invitation.InvitedBy,
+    &createdAt, &expiresAt,
+  ); err != nil {
+    return nil, err
+  }
+  invitation.CreatedAt = time.Unix(0, createdAt)
+  invitation.ExpiresAt = time.Unix(0, expiresAt)
+  return &invitation, nil
+}
+
+// sqlGetInvitation :: Returns nil, without an error, if userID was never invited.
+func sqlGetInvitation(q sqlQuerier, roomID, userID UUID)( *Invitation, error ){
+  invitation, err := sqlScanInvitation(q.QueryRow(
+    `SELECT `+sqlInvitationColumns+`
+     FROM invitations i JOIN chatrooms c ON c.room_id = i.room_id
+     WHERE i.room_id = ? AND i.user_id = ?`,
+    roomID, userID,
+  ))
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil, nil
+  }
+  if err != nil {
+    return nil, DecoderError{err.Error()}
+  }
+  return invitation, nil
+}
+
+// sqlGetInviteLink :: With activeOnly, links to deactivated Chatrooms aren't found.
+func sqlGetInviteLink(q sqlQuerier, code string, activeOnly bool)( *InviteLink, error ){
+  query := `SELECT l.code, l.room_id, c.room_name, l.created_by, l.created_at, l.expires_at, l.max_uses, l.uses
+     FROM invite_links l JOIN chatrooms c ON c.room_id = l.room_id
+     WHERE l.code = ?`
+  if activeOnly {
+    query += ` AND c.active = 1`
+  }
+  var link InviteLink
+  var createdAt, expiresAt int64
+  if err := q.QueryRow(query, code).Scan(
+    &link.Code, &link.RoomID, &link.Chatroom, &link.CreatedBy,
+    &createdAt, &expiresAt, &link.MaxUses, &link.Uses,
+  ); err != nil {
+    return nil, sqlGetError(err, code, SQLINVITELINKS)
+  }
+  link.CreatedAt = time.Unix(0, createdAt)
+  link.ExpiresAt = time.Unix(0, expiresAt)
+  return &link, nil
+}
+
+func sqlGetRoomID(q sqlQuerier, roomName string, activeOnly bool)( UUID, error ){
+  var roomID UUID
+  query := `SELECT room_id FROM chatrooms WHERE room_name = ?`
+  if activeOnly {
+    query += ` AND active = 1`
+  }
+  if err := q.QueryRow(query, roomName).Scan(&roomID); err != nil {
+    return roomID, sqlGetError(err, roomName, SQLCHATROOMS)
+  }
+  return roomID, nil
+}
+
+func sqlDoesChatroomExist(q sqlQuerier, chatroom string)( bool,error ){
+  var count int
+  if err := q.QueryRow(
+    `SELECT COUNT(*) FROM chatrooms WHERE room_name = ?`, chatroom,
+  ).Scan(&count); err != nil {
+    log.Printf(" -> DoesChatroomExist: Failed to query \"%s\"", SQLCHATROOMS)
+    return false, GetDataError{chatroom, SQLCHATROOMS}
+  }
+  return count > 0, nil
+}
+
+func sqlSaveChatroomMember(q sqlQuerier, chatroomName string, userID UUID, memberType MemberType) error {
+  roomID, err := sqlGetRoomID(q, chatroomName, false)
+  if err != nil {
+    return err
+  }
+  if _, err := q.Exec(
+    `INSERT INTO chatroom_members (room_id, user_id, member_type) VALUES (?, ?, ?)
+     ON CONFLICT(room_id, user_id) DO UPDATE SET member_type = excluded.member_type, role = excluded.role`,
+    roomID, userID, memberType,
+  ); err != nil {
+    log.Printf(" -> SaveChatroomMember: Failed to update/save Chatroom Member")
+    return PutDataError{chatroomName + "-" + userID.String(), SQLCHATROOMMEMBERS, err.Error()}
+  }
+  return nil
+}
+
+func sqlGetChatroomMemberStatus(q sqlQuerier, chatroomName string, userID UUID)( *MemberType, error ){
+  var stat MemberType
+  err := q.QueryRow(
+    `SELECT m.member_type FROM chatroom_members m
+     JOIN chatrooms c ON c.room_id = m.room_id
+     WHERE c.room_name = ? AND m.user_id = ?`,
+    chatroomName, userID,
+  ).Scan(&stat)
+  if err != nil {
+    return nil, sqlGetError(err, chatroomName+"-"+userID.String(), SQLCHATROOMMEMBERS)
+  }
+  return &stat, nil
+}
+
+// sqlGetChatroomMember :: Resolves the Role held by a row of /chatroom_members.
+func sqlGetChatroomMember(q sqlQuerier, chatroomName string, userID UUID)( *ChatroomMember, error ){
+  var roomID UUID
+  var memberType MemberType
+  var role string
+  err := q.QueryRow(
+    `SELECT m.room_id, m.member_type, m.role FROM chatroom_members m
+     JOIN chatrooms c ON c.room_id = m.room_id
+     WHERE c.room_name = ? AND m.user_id = ?`,
+    chatroomName, userID,
+  ).Scan(&roomID, &memberType, &role)
+  if err != nil {
+    return nil, sqlGetError(err, chatroomName+"-"+userID.String(), SQLCHATROOMMEMBERS)
+  }
+  return resolveMember(userID, memberType, role, func(name string)( *Role, error ){
+    return sqlGetRole(q, roomID, name)
+  })
+}
+
+// sqlGetRole :: nil if the Role doesn't exist.
+func sqlGetRole(q sqlQuerier, roomID UUID, name string)( *Role, error ){
+  role := Role{ Name: name }
+  err := q.QueryRow(
+    `SELECT permissions, rank FROM chatroom_roles WHERE room_id = ? AND name = ?`, roomID, name,
+  ).Scan(&role.Permissions, &role.Rank)
+  if err == sql.ErrNoRows {
+    return nil, nil
+  }
+  if err != nil {
+    return nil, GetDataError{roomID.String() + "/" + name, SQLCHATROOMROLES}
+  }
+  return &role, nil
+}
+
+func sqlPutRole(q sqlQuerier, roomID UUID, role *Role) error {
+  if _, err := q.Exec(
+    `INSERT INTO chatroom_roles (room_id, name, permissions, rank) VALUES (?, ?, ?, ?)
+     ON CONFLICT(room_id, name) DO UPDATE SET permissions = excluded.permissions, rank = excluded.rank`,
+    roomID, role.Name, role.Permissions, role.Rank,
+  ); err != nil {
+    return PutDataError{roomID.String() + "/" + role.Name, SQLCHATROOMROLES, err.Error()}
+  }
+  return nil
+}
+
+func sqlSaveDefaultRoles(q sqlQuerier, roomID UUID) error {
+  for _, role := range DefaultRoles() {
+    if err := sqlPutRole(q, roomID, &role); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// sqlSaveMessage :: Stores message with the Chatroom's next sequence number. Must
+//    be called within a transaction, so two writers can't hand out the same seq.
+func sqlSaveMessage(q sqlQuerier, chatroom string, message *Message) error {
+  message.ThreadID = UUID{}
+  if message.IsReply() {
+    parent, err := sqlGetMessage(q, chatroom, message.ReplyTo)
+    if err != nil {
+      return err
+    }
+    if err := message.joinThread(parent); err != nil {
+      return err
+    }
+  }
+
+  roomID, err := sqlGetRoomID(q, chatroom, false)
+  if err != nil {
+    return err
+  }
+  var seq uint64
+  if _, err := q.Exec(
+    `UPDATE chatrooms SET last_seq = last_seq + 1 WHERE room_id = ?`, roomID,
+  ); err != nil {
+    return PutDataError{chatroom, SQLCHATROOMS, err.Error()}
+  }
+  if err := q.QueryRow(
+    `SELECT last_seq FROM chatrooms WHERE room_id = ?`, roomID,
+  ).Scan(&seq); err != nil {
+    return GetDataError{chatroom, SQLMESSAGES}
+  }
+  if _, err := q.Exec(
+    `INSERT INTO messages (message_id, room_id, seq, user_id, content, time_stamp, reply_to, thread_id)
+     VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+    message.ID, roomID, seq, message.UserID, message.Content, message.TimeStamp.UnixNano(),
+    sqlNullUUID(message.ReplyTo), sqlNullUUID(message.ThreadID),
+  ); err != nil {
+    return PutDataError{message.ID.String(), SQLMESSAGES, err.Error()}
+  }
+  message.Seq = seq
+  return sqlIndexMessage(q, message.ID, message.Content)
+}
+
+// sqlIndexMessage :: Stores every search term within content in /message_terms.
+func sqlIndexMessage(q sqlQuerier, messageID UUID, content string) error {
+  for _, term := range Tokenize(content) {
+    if _, err := q.Exec(
+      `INSERT OR IGNORE INTO message_terms (term, message_id) VALUES (?, ?)`,
+      term, messageID,
+    ); err != nil {
+      return PutDataError{term, SQLMESSAGETERMS, err.Error()}
+    }
+  }
+  return nil
+}
+
+// sqlBackfillMessageTerms :: Indexes every Message stored before /message_terms existed.
+// sqlBackfillDefaultRoles :: Gives every Chatroom, deactivated or not, DefaultRoles.
+func sqlBackfillDefaultRoles(tx *sql.Tx) error {
+  rows, err := tx.Query(`SELECT room_id FROM chatrooms`)
+  if err != nil {
+    return GetDataError{"room_id", SQLCHATROOMS}
+  }
+  var rooms []UUID
+  for rows.Next() {
+    var roomID UUID
+    if err := rows.Scan(&roomID); err != nil {
+      rows.Close()
+      return DecoderError{err.Error()}
+    }
+    rooms = append(rooms, roomID)
+  }
+  rows.Close()
+  if err := rows.Err(); err != nil {
+    return GetDataError{"room_id", SQLCHATROOMS}
+  }
+
+  for _, roomID := range rooms {
+    if err := sqlSaveDefaultRoles(tx, roomID); err != nil {
+      return err
+    }
+  }
+  log.Printf(" -> sqlBackfillDefaultRoles: Added default Roles to %d Chatrooms", len(rooms))
+  return nil
+}
+
+func sqlBackfillMessageTerms(tx *sql.Tx) error {
+  rows, err := tx.Query(`SELECT message_id, content FROM messages`)
+  if err != nil {
+    return GetDataError{"content", SQLMESSAGES}
+  }
+  type stored struct{ id UUID; content string }
+  var msgs []stored
+  for rows.Next() {
+    var m stored
+    if err := rows.Scan(&m.id, &m.content); err != nil {
+      rows.Close()
+      return DecoderError{err.Error()}
+    }
+    msgs = append(msgs, m)
+  }
+  rows.Close()
+  if err := rows.Err(); err != nil {
+    return err
+  }
+
+  for _, m := range msgs {
+    if err := sqlIndexMessage(tx, m.id, m.content); err != nil {
+      return err
+    }
+  }
+  log.Printf(" -> sqlBackfillMessageTerms: Indexed %d Messages", len(msgs))
+  return nil
+}
+
+// sqlScanMessages :: Expects rows of sqlMessageColumns. Closes rows.
+func sqlScanMessages(rows *sql.Rows)( []Message, error ){
+  defer rows.Close()
+
+  var msgs []Message
+  for rows.Next() {
+    msg, err := sqlScanMessage(rows)
+    if err != nil {
+      return nil, DecoderError{err.Error()}
+    }
+    msgs = append(msgs, *msg)
+  }
+  return msgs, rows.Err()
+}
+
+type sqlScanner interface {
+  Scan(dest ...interface{}) error
+}
+
+// sqlScanMessage :: Scans a single row of sqlMessageColumns, after any leading columns.
+func sqlScanMessage(row sqlScanner, leading ...interface{})( *Message, error ){
+  var msg Message
+  var ts, editedAt, deletedAt int64
+  dest := append(leading,
+    &msg.ID, &msg.Seq, &ts, &msg.UserID, &msg.Content,
+    &editedAt, &deletedAt, &msg.ReplyTo, &msg.ThreadID,
+  )
+  if err := row.Scan(dest...); err != nil {
+    return nil, err
+  }
+  msg.TimeStamp = time.Unix(0, ts)
+  msg.EditedAt = sqlTime(editedAt)
+  msg.DeletedAt = sqlTime(deletedAt)
+  return &msg, nil
+}
+
+// sqlNullUUID :: Optional UUIDs are stored as NULL, rather than as the zero UUID.
+func sqlNullUUID(id UUID) interface{} {
+  if id == (UUID{}) {
+    return nil
+  }
+  return id
+}
+
+// sqlTime :: Timestamps that may not have happened yet are stored as 0.
+func sqlTime(ns int64) time.Time {
+  if ns == 0 {
+    return time.Time{}
+  }
+  return time.Unix(0, ns)
+}
+
+// sqlGetMessage :: Message IDs are only looked up within their own Chatroom.
+func sqlGetMessage(q sqlQuerier, chatroom string, messageID UUID)( *Message, error ){
+  roomID, err := sqlGetRoomID(q, chatroom, false)
+  if err != nil {
+    return nil, err
+  }
+  msg, err := sqlScanMessage(q.QueryRow(
+    `SELECT `+sqlMessageColumns+` FROM messages WHERE message_id = ? AND room_id = ?`,
+    messageID, roomID,
+  ))
+  if err != nil {
+    return nil, sqlGetError(err, messageID.String(), SQLMESSAGES)
+  }
+  msgs := []Message{ *msg }
+  if err := sqlAttachReactions(q, msgs); err != nil {
+    return nil, err
+  }
+  return &msgs[0], nil
+}
+
+// sqlAttachReactions :: Loads the Reactions of every Message within msgs with a
+//    single query. Reactions are ordered by when they were first used.
+func sqlAttachReactions(q sqlQuerier, msgs []Message) error {
+  if len(msgs) == 0 {
+    return nil
+  }
+  byID := make(map[UUID]*Message, len(msgs))
+  args := make([]interface{}, 0, len(msgs))
+  for i := range msgs {
+    byID[msgs[i].ID] = &msgs[i]
+    args = append(args, msgs[i].ID)
+  }
+
+  rows, err := q.Query(
+    `SELECT message_id, reaction, user_id FROM message_reactions
+     WHERE message_id IN (?`+strings.Repeat(", ?", len(msgs)-1)+`)
+     ORDER BY reacted_at, rowid`,
+    args...,
+  )
+  if err != nil {
+    return GetDataError{"reactions", SQLMESSAGEREACTIONS}
+  }
+  defer rows.Close()
+
+  for rows.Next() {
+    var messageID, userID UUID
+    var reaction string
+    if err := rows.Scan(&messageID, &reaction, &userID); err != nil {
+      return DecoderError{err.Error()}
+    }
+    if msg, ok := byID[messageID]; ok {
+      msg.addReaction(reaction, userID)
+    }
+  }
+  return rows.Err()
+}
+
+func sqlGetUserbyUsername(q sqlQuerier, username string)( *User, error ){
+  user := User{ Username: username }
+  err := q.QueryRow(
+    `SELECT user_id, hashed_password FROM users WHERE username = ? AND deactivated = 0`,
+    username,
+  ).Scan(&user.UserID, &user.HashedPassword)
+  if err != nil {
+    log.Printf(" -> GetUserbyUsername - User ID \"%s\" not found.", username)
+    return nil, sqlGetError(err, username, SQLUSERS)
+  }
+  return &user, nil
+}
+
+func sqlSetUserDeactivated(q sqlQuerier, userID UUID, deactivated bool) error {
+  res, err := q.Exec(
+    `UPDATE users SET deactivated = ? WHERE user_id = ? AND deactivated = ?`,
+    deactivated, userID, !deactivated,
+  )
+  if err != nil {
+    return PutDataError{userID.String(), SQLUSERS, err.Error()}
+  }
+  if n, _ := res.RowsAffected(); n == 0 {
+    return GetDataError{userID.String(), SQLUSERS}
+  }
+  return nil
+}
+
+// sqlRemovePasswordResets :: Every PasswordReset for userID, and any that have expired.
+func sqlRemovePasswordResets(q sqlQuerier, userID UUID) error {
+  if _, err := q.Exec(
+    `DELETE FROM password_resets WHERE user_id = ? OR expires_at <= ?`,
+    userID, time.Now().UnixNano(),
+  ); err != nil {
+    return DeleteDataError{userID.String(), SQLPASSWORDRESETS, err.Error()}
+  }
+  return nil
+}
+
+func sqlSaveUserToken(q sqlQuerier, userID UUID, token *token.Token) error {
+  if _, err := q.Exec(
+    `INSERT INTO tokens (user_id, token) VALUES (?, ?)
+     ON CONFLICT(user_id) DO UPDATE SET token = excluded.token`,
+    userID, token.Token,
+  ); err != nil {
+    return PutDataError{userID.String(), SQLTOKENS, err.Error()}
+  }
+  return nil
+}
@@ -0,0 +1,252 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// --> SQL Tables
+const (
+  SQLUSERS            = "users"
+  SQLTOKENS           = "tokens"
+  SQLCHATROOMS        = "chatrooms"
+  SQLCHATROOMMEMBERS  = "chatroom_members"
+  SQLCHATROOMROLES    = "chatroom_roles"
+  SQLMESSAGES         = "messages"
+  SQLINVITATIONS      = "invitations"
+  SQLINVITELINKS      = "invite_links"
+  SQLPASSWORDRESETS   = "password_resets"
+  SQLPROFILES         = "profiles"
+  SQLMESSAGETERMS     = "message_terms"
+  SQLMESSAGEREVISIONS = "message_revisions"
+  SQLMESSAGEREACTIONS = "message_reactions"
+  SQLMIGRATIONS       = "schema_migrations"
+)
+
+// sqliteMigrations :: Ordered schema migrations for SQLiteDB. The index+1 of each
+//    entry is its schema version, which is recorded in /schema_migrations once
+//    applied. Never edit a released migration, append a new one instead.
+var sqliteMigrations = []string{
+  // 1 -> Initial schema.
+  `
+  CREATE TABLE users (
+    user_id         TEXT    PRIMARY KEY,
+    username        TEXT    NOT NULL UNIQUE,
+    hashed_password BLOB    NOT NULL,
+    online          INTEGER NOT NULL DEFAULT 0,
+    deactivated     INTEGER NOT NULL DEFAULT 0
+  );
+
+  CREATE TABLE tokens (
+    user_id TEXT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    token   TEXT NOT NULL
+  );
+
+  CREATE TABLE chatrooms (
+    room_id   TEXT    PRIMARY KEY,
+    room_name TEXT    NOT NULL UNIQUE,
+    owner_id  TEXT    NOT NULL REFERENCES users(user_id),
+    public    INTEGER NOT NULL DEFAULT 0,
+    active    INTEGER NOT NULL DEFAULT 1
+  );
+
+  CREATE TABLE chatroom_members (
+    room_id     TEXT    NOT NULL REFERENCES chatrooms(room_id) ON DELETE CASCADE,
+    user_id     TEXT    NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    member_type INTEGER NOT NULL,
+    live_status TEXT,
+    PRIMARY KEY (room_id, user_id)
+  );
+  CREATE INDEX chatroom_members_user ON chatroom_members(user_id);
+
+  CREATE TABLE messages (
+    message_id TEXT    PRIMARY KEY,
+    room_id    TEXT    NOT NULL REFERENCES chatrooms(room_id) ON DELETE CASCADE,
+    user_id    TEXT    NOT NULL,
+    content    TEXT    NOT NULL,
+    time_stamp INTEGER NOT NULL
+  );
+  CREATE INDEX messages_room_time ON messages(room_id, time_stamp);
+
+  CREATE TABLE invitations (
+    room_id    TEXT NOT NULL REFERENCES chatrooms(room_id) ON DELETE CASCADE,
+    user_id    TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    invitation BLOB NOT NULL,
+    PRIMARY KEY (room_id, user_id)
+  );
+  `,
+
+  // 2 -> Per-room monotonic message sequence numbers. Existing messages are
+  //      numbered in the order they were sent.
+  `
+  ALTER TABLE messages ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+
+  UPDATE messages SET seq = (
+    SELECT COUNT(*) FROM messages m
+    WHERE m.room_id = messages.room_id AND (
+      m.time_stamp < messages.time_stamp OR
+      (m.time_stamp = messages.time_stamp AND m.message_id <= messages.message_id)
+    )
+  );
+
+  CREATE UNIQUE INDEX messages_room_seq ON messages(room_id, seq);
+  `,
+
+  // 3 -> Inverted index of every Message's search terms. Filled in by
+  //      sqlBackfillMessageTerms, since SQLite can't run Tokenize itself.
+  `
+  CREATE TABLE message_terms (
+    term       TEXT NOT NULL,
+    message_id TEXT NOT NULL REFERENCES messages(message_id) ON DELETE CASCADE,
+    PRIMARY KEY (term, message_id)
+  ) WITHOUT ROWID;
+  CREATE INDEX message_terms_message ON message_terms(message_id);
+  `,
+
+  // 4 -> Editing and deleting Messages. A timestamp of 0 means never.
+  `
+  ALTER TABLE messages ADD COLUMN edited_at  INTEGER NOT NULL DEFAULT 0;
+  ALTER TABLE messages ADD COLUMN deleted_at INTEGER NOT NULL DEFAULT 0;
+
+  CREATE TABLE message_revisions (
+    message_id  TEXT    NOT NULL REFERENCES messages(message_id) ON DELETE CASCADE,
+    revision    INTEGER NOT NULL,
+    content     TEXT    NOT NULL,
+    replaced_at INTEGER NOT NULL,
+    replaced_by TEXT    NOT NULL,
+    PRIMARY KEY (message_id, revision)
+  );
+  `,
+
+  // 5 -> Threaded replies. Both columns are NULL outside of a thread.
+  `
+  ALTER TABLE messages ADD COLUMN reply_to  TEXT;
+  ALTER TABLE messages ADD COLUMN thread_id TEXT;
+  CREATE INDEX messages_room_thread ON messages(room_id, thread_id, seq) WHERE thread_id IS NOT NULL;
+  `,
+
+  // 6 -> Reactions. One row per User per Reaction.
+  `
+  CREATE TABLE message_reactions (
+    message_id TEXT    NOT NULL REFERENCES messages(message_id) ON DELETE CASCADE,
+    reaction   TEXT    NOT NULL,
+    user_id    TEXT    NOT NULL,
+    reacted_at INTEGER NOT NULL,
+    PRIMARY KEY (message_id, reaction, user_id)
+  );
+  `,
+
+  // 7 -> Read markers. The Seq of the last Message each Member has read.
+  `
+  ALTER TABLE chatroom_members ADD COLUMN last_read INTEGER NOT NULL DEFAULT 0;
+  `,
+
+  // 8 -> Direct Chatrooms. NULL for every other Chatroom.
+  `
+  ALTER TABLE chatrooms ADD COLUMN peer_id TEXT;
+  `,
+
+  // 9 -> Invitations with an inviter and an expiry, instead of a salted secret.
+  //      Nothing could create the old ones, so they aren't carried over.
+  `
+  DROP TABLE invitations;
+  CREATE TABLE invitations (
+    room_id    TEXT    NOT NULL REFERENCES chatrooms(room_id) ON DELETE CASCADE,
+    user_id    TEXT    NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    invited_by TEXT    NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    PRIMARY KEY (room_id, user_id)
+  );
+  CREATE INDEX invitations_user ON invitations(user_id, expires_at);
+  `,
+
+  // 10 -> Invite links. A max_uses of 0 is unlimited.
+  `
+  CREATE TABLE invite_links (
+    code       TEXT    PRIMARY KEY,
+    room_id    TEXT    NOT NULL REFERENCES chatrooms(room_id) ON DELETE CASCADE,
+    created_by TEXT    NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    max_uses   INTEGER NOT NULL,
+    uses       INTEGER NOT NULL DEFAULT 0
+  );
+  `,
+
+  // 11 -> Per-room Roles. An empty role is the default Role of the Member's
+  //       member_type. Every existing Chatroom is given DefaultRoles by
+  //       sqlBackfillDefaultRoles.
+  `
+  ALTER TABLE chatroom_members ADD COLUMN role TEXT NOT NULL DEFAULT '';
+
+  CREATE TABLE chatroom_roles (
+    room_id     TEXT    NOT NULL REFERENCES chatrooms(room_id) ON DELETE CASCADE,
+    name        TEXT    NOT NULL,
+    permissions INTEGER NOT NULL,
+    rank        INTEGER NOT NULL,
+    PRIMARY KEY (room_id, name)
+  );
+  `,
+
+  // 12 -> Chatroom settings. A slow_mode or max_members of 0 is off.
+  `
+  ALTER TABLE chatrooms ADD COLUMN topic       TEXT    NOT NULL DEFAULT '';
+  ALTER TABLE chatrooms ADD COLUMN description TEXT    NOT NULL DEFAULT '';
+  ALTER TABLE chatrooms ADD COLUMN slow_mode   INTEGER NOT NULL DEFAULT 0;
+  ALTER TABLE chatrooms ADD COLUMN max_members INTEGER NOT NULL DEFAULT 0;
+  ALTER TABLE chatrooms ADD COLUMN read_only   INTEGER NOT NULL DEFAULT 0;
+  `,
+
+  // 13 -> Message retention, in seconds and Messages. 0 falls back onto the
+  //       server's default. last_seq keeps seq numbers climbing once the newest
+  //       Messages have been purged, where MAX(seq) would start over.
+  `
+  ALTER TABLE chatrooms ADD COLUMN max_message_age INTEGER NOT NULL DEFAULT 0;
+  ALTER TABLE chatrooms ADD COLUMN max_messages    INTEGER NOT NULL DEFAULT 0;
+  ALTER TABLE chatrooms ADD COLUMN last_seq        INTEGER NOT NULL DEFAULT 0;
+
+  UPDATE chatrooms SET last_seq = (
+    SELECT COALESCE(MAX(seq), 0) FROM messages WHERE messages.room_id = chatrooms.room_id
+  );
+  `,
+
+  // 14 -> Password resets. Only the SHA-256 hash of each reset token is kept.
+  `
+  CREATE TABLE password_resets (
+    token_hash TEXT    PRIMARY KEY,
+    user_id    TEXT    NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL
+  );
+  CREATE INDEX password_resets_user ON password_resets(user_id);
+  `,
+
+  // 15 -> Profiles. Users without a row have an empty Profile.
+  `
+  CREATE TABLE profiles (
+    user_id      TEXT    PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    display_name TEXT    NOT NULL DEFAULT '',
+    bio          TEXT    NOT NULL DEFAULT '',
+    location     TEXT    NOT NULL DEFAULT '',
+    company      TEXT    NOT NULL DEFAULT '',
+    timezone     TEXT    NOT NULL DEFAULT '',
+    avatar       TEXT    NOT NULL DEFAULT '',
+    updated_at   INTEGER NOT NULL DEFAULT 0
+  );
+  `,
+}
+
+// sqlChatroomColumns :: Every column of /chatrooms that makes up a Chatroom, in the
+//    order sqlScanChatroom expects them.
+const sqlChatroomColumns = "room_id, room_name, owner_id, public, peer_id, topic, description, slow_mode, max_members, read_only, max_message_age, max_messages"
+
+// sqlMessageColumns :: Every column of /messages that makes up a Message, in the
+//    order sqlScanMessage expects them.
+const sqlMessageColumns = "message_id, seq, time_stamp, user_id, content, edited_at, deleted_at, reply_to, thread_id"
+
+// sqliteBackfills :: Go code to run, by schema version, right after that version's
+//    migration within the same transaction.
+var sqliteBackfills = map[int]func(tx *sql.Tx) error{
+  3:  sqlBackfillMessageTerms,
+  11: sqlBackfillDefaultRoles,
+}
@@ -11,6 +11,7 @@ import (
 
 const (
   DefaultPageSize = 128
+  MaxPageSize     = 512
 )
 
 type MemberType int
@@ -21,6 +22,28 @@ const (
   Blocked
 )
 
+var memberTypeNames = []string{ "owner", "moderator", "member", "blocked" }
+
+func(m MemberType)String() string {
+  if m < Owner || int(m) >= len(memberTypeNames) {
+    return "unknown"
+  }
+  return memberTypeNames[m]
+}
+
+// checkRejoin :: Shared by every JoinChatroom. status is the User's current MemberType,
+//    or nil if they aren't a Member. Blocked Users can't rejoin, and rejoining never
+//    changes an existing Member's MemberType. Returns true if there's nothing left to do.
+func checkRejoin(status *MemberType)( bool, error ){
+  if status == nil {
+    return false, nil
+  }
+  if *status == Blocked {
+    return true, FailedSecurityCheckError{"MemberType: blocked", "user is blocked"}
+  }
+  return true, nil
+}
+
 type (
   UUID       = uuid.UUID
   RoomName   = string
@@ -38,8 +61,14 @@ type User struct {
 //   When a user Joins a chatroom, either Public or Private. That user will
 //   receive, upon approval, a JWT token of authenticity. This token will be k
 //   used for Authenticating their Chatroom Access.
+//   LastRead is the Seq of the last Message the User has read, and Unread the
+//   number of Messages after it. Both are filled in by GetJoinedChatrooms, along
+//   with PeerID, the other User of a Direct Chatroom.
 type JoinedChatroom struct {
   Chatroom   string      `codec:"chatroom"`
-  RoomToken  token.Token `codec:"room_token"`
-  MemberType MemberType  `code:"member_type"`
+  RoomToken  token.Token `codec:"room_token,omitempty"`
+  MemberType MemberType  `codec:"member_type"`
+  LastRead   uint64      `codec:"last_read"`
+  Unread     uint64      `codec:"unread"`
+  PeerID     UUID        `codec:"peer_id,omitempty"`
 }
@@ -11,6 +11,7 @@ require (
 require (
 	github.com/golang-jwt/jwt/v5 v5.0.0
 	github.com/gorilla/websocket v1.5.0
+	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/ugorji/go/codec v1.2.11
 	go.etcd.io/bbolt v1.3.7
 )
@@ -0,0 +1,205 @@
+package importer
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"chatatui_backend/db"
+
+	"github.com/google/uuid"
+)
+
+const (
+  DefaultBatchSize = 500
+
+  // How many suffixed Usernames are tried for a placeholder, before giving up.
+  maxPlaceholderAttempts = 100
+)
+
+// Message :: A single Message parsed out of a log or export, before it's Author
+//    has been given a placeholder User. Key identifies the Message within it's
+//    Channel, and is only needed when other Messages reply to it.
+type Message struct {
+  Key       string
+  ReplyTo   string
+  Author    string
+  TimeStamp time.Time
+  Content   string
+}
+
+// Channel :: Everything imported into a single Chatroom.
+type Channel struct {
+  Name     string
+  Public   bool
+  Topic    string
+  Messages []Message
+}
+
+// Result :: What importing a single Channel created.
+type Result struct {
+  Chatroom string
+  Users    int
+  Messages int
+}
+
+// Importer :: Creates a Chatroom per Channel, owned by OwnerID, and a deactivated
+//    placeholder User per Author. Placeholders have no password, so nobody can
+//    sign in as them. The same Author shares a placeholder across every Channel
+//    imported by the same Importer.
+type Importer struct {
+  database  db.ChatatuiDatabase
+  OwnerID   db.UUID
+  BatchSize int
+  users     map[string]db.UUID
+}
+
+func NewImporter(database db.ChatatuiDatabase, ownerID db.UUID) *Importer {
+  return &Importer{
+    database:  database,
+    OwnerID:   ownerID,
+    BatchSize: DefaultBatchSize,
+    users:     map[string]db.UUID{},
+  }
+}
+
+// ChatroomName :: Chatroom names only consist of letters and numbers. Everything
+//    else is dropped. The result still has to pass db.ValidateRoomName.
+func ChatroomName(name string) string {
+  return strings.Map(func(r rune) rune {
+    if unicode.IsLetter(r) || unicode.IsNumber(r) {
+      return r
+    }
+    return -1
+  }, name)
+}
+
+// Import :: Creates the Channel's Chatroom, which mustn't exist yet, and saves it's
+//    Messages oldest first, BatchSize at a time, each batch within a single
+//    transaction. Replies to Messages that weren't imported become plain Messages.
+//    Nothing is written unless OwnerID is an existing User, and the Chatroom's
+//    name is one the API accepts.
+func(imp *Importer)Import(channel *Channel)( *Result, error ){
+  if _, err := imp.database.GetUserByID(imp.OwnerID); err != nil {
+    return nil, fmt.Errorf("Invalid OwnerID \"%s\"", imp.OwnerID)
+  }
+  name := ChatroomName(channel.Name)
+  if err := db.ValidateRoomName(name); err != nil {
+    return nil, fmt.Errorf("Can't name a Chatroom after \"%s\": %s", channel.Name, err)
+  }
+  if _, err := imp.database.GetChatroom(name); err == nil {
+    return nil, fmt.Errorf("Chatroom \"%s\" already exists", name)
+  }
+
+  chatroom := db.Chatroom{
+    RoomID:   uuid.New(),
+    RoomName: name,
+    OwnerID:  imp.OwnerID,
+    Public:   channel.Public,
+    Topic:    channel.Topic,
+  }
+  if db.ValidateChatroomSettings(&chatroom) != nil {
+    // Topics are a nicety. Long or multi-line ones aren't worth failing over.
+    chatroom.Topic = ""
+  }
+  if err := imp.database.SaveChatroom(&chatroom, false); err != nil {
+    return nil, err
+  }
+
+  result := &Result{ Chatroom: name }
+  msgs := append([]Message{}, channel.Messages...)
+  sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].TimeStamp.Before(msgs[j].TimeStamp) })
+
+  batchSize := imp.BatchSize
+  if batchSize <= 0 {
+    batchSize = DefaultBatchSize
+  }
+  keys := map[string]db.UUID{}
+  batch := make([]db.Message, 0, batchSize)
+  flush := func() error {
+    if len(batch) == 0 {
+      return nil
+    }
+    if err := imp.database.SaveMessages(name, batch); err != nil {
+      return err
+    }
+    result.Messages += len(batch)
+    batch = batch[:0]
+    return nil
+  }
+
+  for _, msg := range msgs {
+    if strings.TrimSpace(msg.Content) == "" {
+      continue
+    }
+    userID, created, err := imp.placeholder(msg.Author)
+    if err != nil {
+      return result, err
+    }
+    if created {
+      result.Users++
+    }
+
+    message := db.Message{
+      ID:        uuid.New(),
+      TimeStamp: msg.TimeStamp,
+      UserID:    userID,
+      Content:   msg.Content,
+    }
+    if parent, ok := keys[msg.ReplyTo]; ok && msg.ReplyTo != "" {
+      message.ReplyTo = parent
+    }
+    if msg.Key != "" {
+      keys[msg.Key] = message.ID
+    }
+
+    batch = append(batch, message)
+    if len(batch) == batchSize {
+      if err := flush(); err != nil {
+        return result, err
+      }
+    }
+  }
+  if err := flush(); err != nil {
+    return result, err
+  }
+  log.Printf(" -> Import: Imported %d Messages into \"%s\", creating %d placeholder Users", result.Messages, name, result.Users)
+  return result, nil
+}
+
+// placeholder :: Returns the placeholder User for author, creating it if this is the
+//    first Message they're seen sending. Usernames held by anyone else are never
+//    reused, a suffixed Username is tried instead.
+func(imp *Importer)placeholder(author string)( db.UUID, bool, error ){
+  if userID, ok := imp.users[author]; ok {
+    return userID, false, nil
+  }
+  if author == "" {
+    author = "unknown"
+  }
+
+  for n := 0; n < maxPlaceholderAttempts; n++ {
+    username := author
+    if n > 0 {
+      username = fmt.Sprintf("%s~%d", author, n)
+    }
+    if _, err := imp.database.GetUserbyUsername(username); err == nil {
+      continue
+    }
+    // An empty hash never matches any password, and SQLite won't store a missing one.
+    user := db.User{ UserID: uuid.New(), Username: username, HashedPassword: []byte{} }
+    if err := imp.database.SaveUser(user, nil); err != nil {
+      // Held by a deactivated User, which GetUserbyUsername doesn't see.
+      continue
+    }
+    if err := imp.database.DeactivateUser(user.UserID); err != nil {
+      return uuid.Nil, false, err
+    }
+    imp.users[author] = user.UserID
+    return user.UserID, true, nil
+  }
+  return uuid.Nil, false, fmt.Errorf("No free Username left for \"%s\"", author)
+}
@@ -0,0 +1,202 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"chatatui_backend/db"
+
+	"github.com/google/uuid"
+)
+
+func TestParseIRCLog(t *testing.T) {
+  steps := []struct{
+    name  string
+    log   string
+    want  []string
+    first time.Time
+  }{
+    {
+      "Exported txt",
+      "[2024-03-01 09:15:00] <alice> morning\n[2024-03-01 09:16:30] <bob> hey\n    second line\n",
+      []string{ "alice: morning", "bob: hey\nsecond line" },
+      time.Date(2024, 3, 1, 9, 15, 0, 0, time.UTC),
+    },
+    {
+      "irssi",
+      "--- Log opened Fri Mar 01 09:00:00 2024\n09:15 <@alice> morning\n09:15 -!- bob [~bob@host] has joined #lobby\n--- Day changed Sat Mar 02 2024\n10:00  * bob waves\n",
+      []string{ "alice: morning", "bob: _waves_" },
+      time.Date(2024, 3, 1, 9, 15, 0, 0, time.UTC),
+    },
+    {
+      "WeeChat",
+      "2024-03-01 09:15:00\t-->\tbob (~bob@host) has joined #lobby\n2024-03-01 09:15:05\t+alice\tmorning\n2024-03-01 09:15:09\t *\tbob waves\n",
+      []string{ "alice: morning", "bob: _waves_" },
+      time.Date(2024, 3, 1, 9, 15, 5, 0, time.UTC),
+    },
+  }
+  for _, step := range steps {
+    channel, err := ParseIRCLog(strings.NewReader(step.log), "lobby")
+    if err != nil {
+      t.Errorf("FAILED: %s: %v", step.name, err)
+      continue
+    }
+    var got []string
+    for _, msg := range channel.Messages {
+      got = append(got, msg.Author+": "+msg.Content)
+    }
+    if strings.Join(got, "|") != strings.Join(step.want, "|") || !channel.Messages[0].TimeStamp.Equal(step.first) {
+      t.Errorf("FAILED: %s: Got %q at %v Want %q at %v", step.name, got, channel.Messages[0].TimeStamp, step.want, step.first)
+    }
+  }
+
+  if _, err := ParseIRCLog(strings.NewReader("09:15 <alice> when?\n"), "lobby"); err == nil {
+    t.Errorf("FAILED: Got nil Want an error for a clock without a date")
+  }
+}
+
+// slackArchive :: Zips up files, by name, the way a Slack export is laid out.
+func slackArchive(t *testing.T, files map[string]string) *bytes.Reader {
+  var buf bytes.Buffer
+  archive := zip.NewWriter(&buf)
+  for name, content := range files {
+    w, err := archive.Create(name)
+    if err != nil {
+      t.Fatalf("FAILED: Failed to create %s: %v", name, err)
+    }
+    w.Write([]byte(content))
+  }
+  if err := archive.Close(); err != nil {
+    t.Fatalf("FAILED: Failed to zip Slack export: %v", err)
+  }
+  return bytes.NewReader(buf.Bytes())
+}
+
+func TestImport(t *testing.T) {
+  database := db.NewMemoryDatabase()
+  owner := db.User{ UserID: uuid.New(), Username: "admin" }
+  taken := db.User{ UserID: uuid.New(), Username: "carol" }
+  for _, user := range []db.User{ owner, taken } {
+    if err := database.SaveUser(user, nil); err != nil {
+      t.Fatalf("FAILED: Failed to save User: %v", err)
+    }
+  }
+
+  archive := slackArchive(t, map[string]string{
+    "users.json":    `[{"id": "U1", "name": "alice"}, {"id": "U2", "name": "carol"}]`,
+    "channels.json": `[{"id": "C1", "name": "dev-team", "topic": {"value": "shipping"}}]`,
+    "groups.json":   `[{"id": "G1", "name": "secret", "topic": {"value": ""}}]`,
+    "dev-team/2024-03-01.json": `[
+      {"type": "message", "user": "U1", "text": "hi &lt;3 <@U2>", "ts": "1709284500.000100"},
+      {"type": "message", "subtype": "channel_join", "user": "U2", "text": "<@U2> has joined", "ts": "1709284501.000000"},
+      {"type": "message", "user": "U2", "text": "see <https://example.com|the docs>", "ts": "1709284502.000000", "thread_ts": "1709284500.000100"}
+    ]`,
+    "dev-team/2024-03-02.json": `[{"type": "message", "user": "U1", "text": "day two", "ts": "1709370000.000000"}]`,
+    "secret/2024-03-01.json":   `[{"type": "message", "user": "U2", "text": "psst", "ts": "1709284600.000000"}]`,
+  })
+  channels, err := ParseSlackExport(archive, archive.Size())
+  if err != nil || len(channels) != 2 {
+    t.Fatalf("FAILED: Got %d channels, %v Want 2", len(channels), err)
+  }
+  if msgs := channels[0].Messages; len(msgs) != 3 || msgs[0].Content != "hi <3 @carol" || msgs[1].Content != "see the docs (https://example.com)" || msgs[1].ReplyTo != msgs[0].Key {
+    t.Errorf("FAILED: Got %+v Want 3 Messages with Slack markup removed", msgs)
+  }
+
+  imp := NewImporter(database, owner.UserID)
+  imp.BatchSize = 2
+  var results []*Result
+  for _, channel := range channels {
+    result, err := imp.Import(channel)
+    if err != nil {
+      t.Fatalf("FAILED: Failed to import %s: %v", channel.Name, err)
+    }
+    results = append(results, result)
+  }
+  if results[0].Chatroom != "devteam" || results[0].Messages != 3 || results[0].Users != 2 || results[1].Users != 0 {
+    t.Errorf("FAILED: Got %+v, %+v Want 3 Messages into devteam from 2 new Users", results[0], results[1])
+  }
+
+  room, err := database.GetChatroom("devteam")
+  if err != nil || room.Topic != "shipping" || !room.Public {
+    t.Errorf("FAILED: Got %+v, %v Want the public devteam Chatroom", room, err)
+  }
+  if room, err := database.GetChatroom("secret"); err != nil || room.Public {
+    t.Errorf("FAILED: Got %+v, %v Want the private secret Chatroom", room, err)
+  }
+
+  page, err := database.Paginate("devteam", 0, 0, 10)
+  if err != nil || len(page.Messages) != 3 {
+    t.Fatalf("FAILED: Got %+v, %v Want 3 Messages", page, err)
+  }
+  first, reply := page.Messages[0], page.Messages[1]
+  if !first.TimeStamp.Equal(time.Unix(1709284500, 100000)) || reply.ThreadID != first.ID {
+    t.Errorf("FAILED: Got %+v, %+v Want the original timestamps and thread", first, reply)
+  }
+  if first.UserID == owner.UserID || reply.UserID == taken.UserID {
+    t.Errorf("FAILED: Imported Messages were attributed to existing Users")
+  }
+  if _, err := database.GetUserbyUsername("alice"); err == nil {
+    t.Errorf("FAILED: Placeholder User is active")
+  }
+  if user, err := database.GetUserbyUsername("carol"); err != nil || user.UserID != taken.UserID {
+    t.Errorf("FAILED: Got %+v, %v Want the existing carol untouched", user, err)
+  }
+
+  // Placeholders are deactivated, but their Messages still export under their names.
+  var authors []string
+  err = db.ExportMessages(database, "devteam", db.ExportFilter{}, func(message *db.ExportedMessage) error {
+    authors = append(authors, message.Username)
+    return nil
+  })
+  if err != nil || fmt.Sprint(authors) != fmt.Sprint([]string{ "alice", "carol~1", "alice" }) {
+    t.Errorf("FAILED: Got %q, %v Want the placeholders' Usernames", authors, err)
+  }
+
+  if _, err := imp.Import(channels[0]); err == nil {
+    t.Errorf("FAILED: Got nil Want an error importing into an existing Chatroom")
+  }
+
+  short := &Channel{ Name: "#ops", Messages: []Message{{ Author: "dave", TimeStamp: time.Now(), Content: "hello" }} }
+  if _, err := imp.Import(short); err == nil {
+    t.Errorf("FAILED: Got nil Want an error importing into a Chatroom name the API rejects")
+  }
+  orphan := &Channel{ Name: "orphaned", Messages: short.Messages }
+  if _, err := NewImporter(database, uuid.New()).Import(orphan); err == nil {
+    t.Errorf("FAILED: Got nil Want an error importing for an unknown owner")
+  }
+  for _, name := range []string{ "ops", "orphaned" } {
+    if _, err := database.GetChatroom(name); err == nil {
+      t.Errorf("FAILED: Chatroom \"%s\" was created by a rejected import", name)
+    }
+  }
+}
+
+func TestImportIntoSQLite(t *testing.T) {
+  database, err := db.NewSQLiteDatabase(filepath.Join(t.TempDir(), "chatatui_import.sqlite"))
+  if err != nil {
+    t.Fatalf("FAILED: Failed to open SQLite Database: %v", err)
+  }
+  defer database.Close()
+  owner := db.User{ UserID: uuid.New(), Username: "admin", HashedPassword: []byte("hash") }
+  if err := database.SaveUser(owner, nil); err != nil {
+    t.Fatalf("FAILED: Failed to save User: %v", err)
+  }
+
+  channel := &Channel{ Name: "archive", Messages: []Message{{ Author: "erin", TimeStamp: time.Now(), Content: "from the old server" }} }
+  if _, err := NewImporter(database, owner.UserID).Import(channel); err != nil {
+    t.Fatalf("FAILED: Failed to import: %v", err)
+  }
+  var authors []string
+  err = db.ExportMessages(database, "archive", db.ExportFilter{}, func(message *db.ExportedMessage) error {
+    authors = append(authors, message.Username)
+    return nil
+  })
+  if err != nil || fmt.Sprint(authors) != fmt.Sprint([]string{ "erin" }) {
+    t.Errorf("FAILED: Got %q, %v Want the placeholder's Username", authors, err)
+  }
+}
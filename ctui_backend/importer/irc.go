@@ -0,0 +1,114 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// --> IRC log lines. Timestamps are taken to be UTC.
+var (
+  // [2006-01-02 15:04:05] <nick> text, which is also what /export?format=txt writes.
+  ircFullLine    = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2}) (\d{2}:\d{2}(?::\d{2})?)\] (.*)$`)
+  // 2006-01-02 15:04:05<TAB>nick<TAB>text, as written by WeeChat.
+  ircWeechatLine = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\t([^\t]*)\t(.*)$`)
+  // 15:04 <nick> text, as written by irssi. The date comes from the last
+  //    "Log opened" or "Day changed" line.
+  ircClockLine   = regexp.MustCompile(`^\[?(\d{2}:\d{2}(?::\d{2})?)\]? (.*)$`)
+  ircLogOpened   = regexp.MustCompile(`^--- Log opened \w{3} (\w{3} \d{2} \d{2}:\d{2}:\d{2} \d{4})$`)
+  ircDayChanged  = regexp.MustCompile(`^--- Day changed \w{3} (\w{3} \d{2} \d{4})$`)
+
+  ircMessage = regexp.MustCompile(`^<[ @+%~&]?([^>]+)> ?(.*)$`)
+  ircAction  = regexp.MustCompile(`^ ?\* (\S+) (.*)$`)
+)
+
+// ircContinuation :: Multi-line Messages exported as txt indent every line after the first.
+const ircContinuation = "    "
+
+// ParseIRCLog :: Reads a single IRC log into a Channel called name. Joins, parts,
+//    mode changes and the like are skipped. Actions("* nick waves") are kept, with
+//    their text in italics.
+func ParseIRCLog(r io.Reader, name string)( *Channel, error ){
+  channel := &Channel{ Name: name, Public: true }
+  var date time.Time
+
+  scanner := bufio.NewScanner(r)
+  scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+  for lineNumber := 1; scanner.Scan(); lineNumber++ {
+    line := strings.TrimRight(scanner.Text(), "\r")
+
+    if strings.HasPrefix(line, ircContinuation) && len(channel.Messages) != 0 {
+      last := &channel.Messages[len(channel.Messages)-1]
+      last.Content += "\n" + strings.TrimPrefix(line, ircContinuation)
+      continue
+    }
+
+    var stamp time.Time
+    var rest string
+    var err error
+    if m := ircLogOpened.FindStringSubmatch(line); m != nil {
+      if date, err = time.Parse("Jan 02 15:04:05 2006", m[1]); err != nil {
+        return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+      }
+      continue
+    } else if m := ircDayChanged.FindStringSubmatch(line); m != nil {
+      if date, err = time.Parse("Jan 02 2006", m[1]); err != nil {
+        return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+      }
+      continue
+    } else if m := ircFullLine.FindStringSubmatch(line); m != nil {
+      if stamp, err = parseIRCTime(m[1], m[2]); err != nil {
+        return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+      }
+      rest = m[3]
+    } else if m := ircWeechatLine.FindStringSubmatch(line); m != nil {
+      if stamp, err = time.Parse("2006-01-02 15:04:05", m[1]); err != nil {
+        return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+      }
+      rest = weechatRest(m[2], m[3])
+    } else if m := ircClockLine.FindStringSubmatch(line); m != nil {
+      if date.IsZero() {
+        return nil, fmt.Errorf("line %d: Timestamp without a date. Expected a \"--- Log opened\" line first", lineNumber)
+      }
+      if stamp, err = parseIRCTime(date.Format("2006-01-02"), m[1]); err != nil {
+        return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+      }
+      rest = m[2]
+    } else {
+      continue
+    }
+
+    if m := ircMessage.FindStringSubmatch(rest); m != nil {
+      channel.Messages = append(channel.Messages, Message{ Author: m[1], TimeStamp: stamp, Content: m[2] })
+    } else if m := ircAction.FindStringSubmatch(rest); m != nil {
+      channel.Messages = append(channel.Messages, Message{ Author: m[1], TimeStamp: stamp, Content: "_" + m[2] + "_" })
+    }
+  }
+  if err := scanner.Err(); err != nil {
+    return nil, err
+  }
+  return channel, nil
+}
+
+func parseIRCTime(date, clock string)( time.Time, error ){
+  if len(clock) == len("15:04") {
+    clock += ":00"
+  }
+  return time.Parse("2006-01-02 15:04:05", date+" "+clock)
+}
+
+// weechatRest :: Rewrites WeeChat's prefix column into the "<nick> text" or
+//    "* nick text" ircMessage and ircAction expect. Anything prefixed with one of
+//    WeeChat's arrows is a join, part or network notice.
+func weechatRest(prefix, text string) string {
+  switch strings.TrimSpace(prefix) {
+  case "", "-->", "<--", "--", "=!=":
+    return ""
+  case "*":
+    return "* " + text
+  }
+  return "<" + strings.TrimSpace(prefix) + "> " + text
+}
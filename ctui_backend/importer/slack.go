@@ -0,0 +1,208 @@
+package importer
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"chatatui_backend/db"
+
+	"github.com/ugorji/go/codec"
+)
+
+type slackUser struct {
+  ID   string `codec:"id"`
+  Name string `codec:"name"`
+}
+
+type slackChannel struct {
+  ID    string `codec:"id"`
+  Name  string `codec:"name"`
+  Topic struct {
+    Value string `codec:"value"`
+  } `codec:"topic"`
+}
+
+type slackMessage struct {
+  Type     string `codec:"type"`
+  Subtype  string `codec:"subtype"`
+  User     string `codec:"user"`
+  Username string `codec:"username"`
+  Text     string `codec:"text"`
+  Ts       string `codec:"ts"`
+  ThreadTs string `codec:"thread_ts"`
+}
+
+// slackSubtypes :: Messages worth importing. Every other subtype is a join, a
+//    topic change, or some other bit of Slack bookkeeping.
+var slackSubtypes = map[string]bool{
+  "":                 true,
+  "bot_message":      true,
+  "me_message":       true,
+  "thread_broadcast": true,
+  "file_share":       true,
+}
+
+var (
+  slackMention     = regexp.MustCompile(`<@([A-Z0-9]+)(?:\|([^>]+))?>`)
+  slackChannelLink = regexp.MustCompile(`<#[A-Z0-9]+\|([^>]+)>`)
+  slackSpecial     = regexp.MustCompile(`<!([a-z]+)(?:\|[^>]*)?>`)
+  slackLink        = regexp.MustCompile(`<([a-z]+:[^|>]+)(?:\|([^>]+))?>`)
+
+  slackUnescape = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+)
+
+// ParseSlackExport :: Reads a Slack export archive into a Channel per public, and
+//    private, channel it holds. Direct messages aren't imported. Replies within a
+//    thread keep replying to the thread's first Message.
+func ParseSlackExport(r io.ReaderAt, size int64)( []*Channel, error ){
+  archive, err := zip.NewReader(r, size)
+  if err != nil {
+    return nil, err
+  }
+  files := map[string]*zip.File{}
+  for _, file := range archive.File {
+    files[file.Name] = file
+  }
+
+  var users []slackUser
+  if _, ok := files["users.json"]; !ok {
+    return nil, fmt.Errorf("users.json not found within the archive. Is it a Slack export?")
+  }
+  if err := decodeSlackFile(files["users.json"], &users); err != nil {
+    return nil, err
+  }
+  usernames := make(map[string]string, len(users))
+  for _, user := range users {
+    usernames[user.ID] = user.Name
+  }
+
+  var channels []*Channel
+  for _, listing := range []struct{ file string; public bool }{ { "channels.json", true }, { "groups.json", false } } {
+    if _, ok := files[listing.file]; !ok {
+      continue
+    }
+    var sources []slackChannel
+    if err := decodeSlackFile(files[listing.file], &sources); err != nil {
+      return nil, err
+    }
+    for _, source := range sources {
+      channel, err := parseSlackChannel(archive, source, usernames)
+      if err != nil {
+        return nil, err
+      }
+      channel.Public = listing.public
+      channels = append(channels, channel)
+    }
+  }
+  if channels == nil {
+    return nil, fmt.Errorf("No channels.json or groups.json within the archive. Is it a Slack export?")
+  }
+  return channels, nil
+}
+
+func decodeSlackFile(file *zip.File, v interface{}) error {
+  rc, err := file.Open()
+  if err != nil {
+    return err
+  }
+  defer rc.Close()
+  if err := codec.NewDecoder(rc, &db.JSONHandle).Decode(v); err != nil {
+    return fmt.Errorf("%s: %s", file.Name, err)
+  }
+  return nil
+}
+
+// parseSlackChannel :: A channel's Messages are spread across a file per day, under
+//    a directory named after the channel.
+func parseSlackChannel(archive *zip.Reader, source slackChannel, usernames map[string]string)( *Channel, error ){
+  channel := &Channel{ Name: source.Name, Topic: source.Topic.Value }
+  var days []*zip.File
+  for _, file := range archive.File {
+    if path.Dir(file.Name) == source.Name && path.Ext(file.Name) == ".json" {
+      days = append(days, file)
+    }
+  }
+  sort.Slice(days, func(i, j int) bool { return days[i].Name < days[j].Name })
+
+  for _, day := range days {
+    var raws []slackMessage
+    if err := decodeSlackFile(day, &raws); err != nil {
+      return nil, err
+    }
+    for _, raw := range raws {
+      if raw.Type != "message" || !slackSubtypes[raw.Subtype] {
+        continue
+      }
+      stamp, err := parseSlackTs(raw.Ts)
+      if err != nil {
+        return nil, fmt.Errorf("%s: %s", day.Name, err)
+      }
+      author, ok := usernames[raw.User]
+      if !ok {
+        author = raw.Username
+      }
+      message := Message{
+        Key:       raw.Ts,
+        Author:    author,
+        TimeStamp: stamp,
+        Content:   slackText(raw.Text, usernames),
+      }
+      if raw.ThreadTs != "" && raw.ThreadTs != raw.Ts {
+        message.ReplyTo = raw.ThreadTs
+      }
+      if raw.Subtype == "me_message" {
+        message.Content = "_" + message.Content + "_"
+      }
+      channel.Messages = append(channel.Messages, message)
+    }
+  }
+  return channel, nil
+}
+
+// parseSlackTs :: Slack timestamps are "seconds.microseconds" since the epoch.
+func parseSlackTs(ts string)( time.Time, error ){
+  seconds, micros, _ := strings.Cut(ts, ".")
+  sec, err := strconv.ParseInt(seconds, 10, 64)
+  if err != nil {
+    return time.Time{}, fmt.Errorf("Invalid ts \"%s\"", ts)
+  }
+  var usec int64
+  if micros != "" {
+    if usec, err = strconv.ParseInt(micros, 10, 64); err != nil {
+      return time.Time{}, fmt.Errorf("Invalid ts \"%s\"", ts)
+    }
+  }
+  return time.Unix(sec, usec*int64(time.Microsecond)).UTC(), nil
+}
+
+// slackText :: Turns Slack's markup for mentions, channels and links back into
+//    plain text.
+func slackText(text string, usernames map[string]string) string {
+  text = slackMention.ReplaceAllStringFunc(text, func(mention string) string {
+    m := slackMention.FindStringSubmatch(mention)
+    if name, ok := usernames[m[1]]; ok {
+      return "@" + name
+    }
+    if m[2] != "" {
+      return "@" + m[2]
+    }
+    return "@" + m[1]
+  })
+  text = slackChannelLink.ReplaceAllString(text, "#$1")
+  text = slackSpecial.ReplaceAllString(text, "@$1")
+  text = slackLink.ReplaceAllStringFunc(text, func(link string) string {
+    m := slackLink.FindStringSubmatch(link)
+    if m[2] != "" && m[2] != m[1] {
+      return m[2] + " (" + m[1] + ")"
+    }
+    return m[1]
+  })
+  return slackUnescape.Replace(text)
+}
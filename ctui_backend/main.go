@@ -1,11 +1,17 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"chatatui_backend/db"
+	"chatatui_backend/notify"
 	"chatatui_backend/router"
 	"chatatui_backend/ws"
 )
@@ -13,28 +19,98 @@ import (
 var live_chatrooms sync.Map
 
 type Config struct {
-	Port      string
-	DevDBPath string
+	Port       string
+	DevDBPath  string
+	DevSQLPath string
+	Database   string
+
+	// Server wide Message retention. Chatrooms can override either.
+	RetentionAge      time.Duration
+	RetentionMessages int
+	JanitorInterval   time.Duration
+
+	// Usernames allowed to reach /admin/*.
+	Admins string
+
+	// Scheduled bbolt backups. Off while BackupDir is empty.
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupKeep     int
+
+	// Where password reset tokens are written, for whoever runs the server to
+	// pass along. "-" is stdout, and empty turns password resets off.
+	NotifyFile string
+
+	// How long a User can go without sending anything before they're shown as
+	// Background within a Chatroom.
+	IdleTimeout time.Duration
 }
 
 func main() {
-	config := Config{
-		Port:      ":8080",
-		DevDBPath: "../DevDB/chatatui_dev.db",
+	if runCommand(os.Args[1:]) {
+		return
 	}
 
-  database, err := db.NewDatabase(config.DevDBPath)
+	config := defaultConfig()
+	flag.StringVar(&config.Database, "db", "bbolt", "Database backend to use: \"bbolt\", \"sqlite\" or \"memory\"")
+	flag.DurationVar(&config.RetentionAge, "retention-age", 0, "Delete Messages older than this. 0 keeps them forever")
+	flag.IntVar(&config.RetentionMessages, "retention-messages", 0, "Keep at most this many Messages per Chatroom. 0 keeps them all")
+	flag.DurationVar(&config.JanitorInterval, "janitor-interval", db.DefaultJanitorInterval, "How often expired Messages are deleted")
+	flag.StringVar(&config.Admins, "admins", "", "Comma separated Usernames allowed to reach /admin/*")
+	flag.StringVar(&config.BackupDir, "backup-dir", "", "Directory to write scheduled backups into. Empty turns them off")
+	flag.DurationVar(&config.BackupInterval, "backup-interval", db.DefaultBackupInterval, "How often scheduled backups are written")
+	flag.IntVar(&config.BackupKeep, "backup-keep", db.DefaultBackupsKept, "How many scheduled backups to keep. 0 keeps them all")
+	flag.StringVar(&config.NotifyFile, "notify-file", "-", "File password reset tokens are appended to. \"-\" is stdout, empty turns password resets off")
+	flag.DurationVar(&config.IdleTimeout, "idle-timeout", ws.DefaultIdleTimeout, "How long a connected User can go without sending anything before they're shown as Background")
+	flag.Parse()
+
+  database, err := openDatabase(config)
 	if err != nil {
-		log.Fatalf(" -> FATAL: Failed to Create Local Database.")
+		log.Fatalf(" -> FATAL: Failed to Create Local Database: %s", err.Error())
 		return
 	}
   defer database.Close()
 
+	janitor := db.NewJanitor(database, db.Retention{
+		MaxAge:      config.RetentionAge,
+		MaxMessages: config.RetentionMessages,
+	})
+	janitor.Interval = config.JanitorInterval
+	stopJanitor := make(chan struct{})
+	defer close(stopJanitor)
+	go janitor.Run(stopJanitor)
+
+	if config.BackupDir != "" {
+		snapshotter, ok := database.(db.Snapshotter)
+		if !ok {
+			log.Fatalf(" -> FATAL: The \"%s\" Database doesn't support backups", config.Database)
+		}
+		backups := db.NewBackups(snapshotter, config.BackupDir)
+		backups.Interval = config.BackupInterval
+		backups.Keep = config.BackupKeep
+		go backups.Run(stopJanitor)
+	}
+
 	wsHub := ws.NewHub()
 	router := router.NewRouter(
 		database,
 		wsHub,
 	)
+	if config.Admins != "" {
+		router.SetAdmins(strings.Split(config.Admins, ",")...)
+	}
+	if config.NotifyFile != "" {
+		notifier, err := notify.NewFileNotifier(config.NotifyFile)
+		if err != nil {
+			log.Fatalf(" -> FATAL: Failed to open \"%s\" for notifications: %s", config.NotifyFile, err.Error())
+		}
+		defer notifier.Close()
+		router.SetNotifier(notifier)
+	}
+	router.SetIdleTimeout(config.IdleTimeout)
+	if err := router.ResetPresence(); err != nil {
+		log.Printf(" -> Failed to reset live statuses: %s", err.Error())
+	}
 
 	http.Handle("/", router.SetupRouter())
 
@@ -44,6 +120,29 @@ func main() {
 	}
 }
 
+func defaultConfig() Config {
+	return Config{
+		Port:       ":8080",
+		DevDBPath:  "../DevDB/chatatui_dev.db",
+		DevSQLPath: "../DevDB/chatatui_dev.sqlite",
+	}
+}
+
+// openDatabase :: Creates the ChatatuiDatabase selected by config.Database.
+//    "memory" is never persisted, and is handy for demos and throwaway servers.
+func openDatabase(config Config)( db.ChatatuiDatabase, error ){
+	switch config.Database {
+	case "bbolt":
+		return db.NewDatabase(config.DevDBPath)
+	case "sqlite":
+		return db.NewSQLiteDatabase(config.DevSQLPath)
+	case "memory":
+		return db.NewMemoryDatabase(), nil
+	default:
+		return nil, fmt.Errorf("unknown database backend \"%s\"", config.Database)
+	}
+}
+
 // func mainvs1() {
 // 	// testData(&Chatrooms)
 //
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Notifier :: Delivers messages to Users outside of ChataTUI. Every Notifier has to
+//    be safe to call from multiple goroutines at once.
+type Notifier interface {
+  // NotifyPasswordReset :: Hands username the reset token they'll need to choose a
+  //    new password, before expiresAt.
+  NotifyPasswordReset(username, resetToken string, expiresAt time.Time) error
+}
+
+// WriterNotifier :: Users don't have an email address, or any other way of being
+//    reached yet. So for now, notifications are written out a line at a time for
+//    whoever runs the server to pass along.
+type WriterNotifier struct {
+  mu sync.Mutex
+  w  io.Writer
+}
+
+func NewWriterNotifier(w io.Writer) *WriterNotifier {
+  return &WriterNotifier{ w: w }
+}
+
+// NewFileNotifier :: Appends to path, creating it if needed. A path of "-" writes to
+//    stdout. The file is only readable by it's owner, since anyone able to read it
+//    can reset passwords.
+func NewFileNotifier(path string)( *WriterNotifier, error ){
+  if path == "-" {
+    return NewWriterNotifier(os.Stdout), nil
+  }
+  file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+  if err != nil {
+    return nil, err
+  }
+  return NewWriterNotifier(file), nil
+}
+
+func(n *WriterNotifier)NotifyPasswordReset(username, resetToken string, expiresAt time.Time) error {
+  n.mu.Lock()
+  defer n.mu.Unlock()
+
+  _, err := fmt.Fprintf(n.w, "[%s] Password reset for \"%s\": token %s, valid until %s\n",
+    time.Now().UTC().Format(time.RFC3339), username, resetToken, expiresAt.UTC().Format(time.RFC3339),
+  )
+  return err
+}
+
+// Close :: Closes the file written to, if there is one. Stdout is left open.
+func(n *WriterNotifier)Close() error {
+  n.mu.Lock()
+  defer n.mu.Unlock()
+
+  if file, ok := n.w.(*os.File); ok && file != os.Stdout {
+    return file.Close()
+  }
+  return nil
+}
@@ -2,19 +2,22 @@ package router
 
 import (
 	"chatatui_backend/db"
+	"chatatui_backend/notify"
 	"chatatui_backend/token"
 	"chatatui_backend/ws"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
-	"unicode"
 
 	// "log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -43,10 +46,41 @@ type Router struct {
   database      db.ChatatuiDatabase
   wsHub         *ws.Hub
   liveChatrooms sync.Map
+  admins        map[string]bool
+  notifier      notify.Notifier
+  idleTimeout   time.Duration
+
+  // The Chatrooms each User is connected to, and not Offline within. A User is
+  // Online for as long as they're connected to any.
+  presenceMu sync.Mutex
+  presence   map[uuid.UUID]map[string]bool
 }
 
 func NewRouter(database db.ChatatuiDatabase, wsHub *ws.Hub) *Router {
-  return &Router{ database, wsHub, sync.Map{} }
+  return &Router{
+    database, wsHub, sync.Map{}, map[string]bool{}, nil, ws.DefaultIdleTimeout,
+    sync.Mutex{}, map[uuid.UUID]map[string]bool{},
+  }
+}
+
+// SetAdmins :: The Usernames allowed to reach /admin/*. Nobody is, by default.
+func( router *Router )SetAdmins(usernames ...string) {
+  router.admins = make(map[string]bool, len(usernames))
+  for _, username := range usernames {
+    router.admins[username] = true
+  }
+}
+
+// SetNotifier :: How password reset tokens reach their Users. Without one, password
+//    resets are turned off.
+func( router *Router )SetNotifier(notifier notify.Notifier) {
+  router.notifier = notifier
+}
+
+// SetIdleTimeout :: How long a User can go without sending anything before they're
+//    shown as Background. Only applies to Chatrooms entered afterwards.
+func( router *Router )SetIdleTimeout(idleTimeout time.Duration) {
+  router.idleTimeout = idleTimeout
 }
 
 func( router *Router )SetupRouter() *mux.Router {
@@ -55,6 +89,8 @@ func( router *Router )SetupRouter() *mux.Router {
   r.HandleFunc("/", router.Home).Methods("GET")
   r.HandleFunc("/User/Signin", router.UserSignIn).Methods("POST")
   r.HandleFunc("/User/Signup", router.UserSignup).Methods("POST")
+  r.HandleFunc("/User/password/reset", router.RequestPasswordReset).Methods("POST")
+  r.HandleFunc("/User/password/reset/confirm", router.ResetPassword).Methods("POST")
 
   s := r.PathPrefix("/").Subrouter()
 
@@ -64,15 +100,50 @@ func( router *Router )SetupRouter() *mux.Router {
   s.HandleFunc("/chatrooms", router.ListPublicChatrooms).Methods("GET");
   s.HandleFunc("/chatrooms", router.SaveChatroom).Methods("POST")
 
-  s.HandleFunc("/chatrooms/{room_id}", router.GetChatroomMeta).Methods("GET")
-  s.HandleFunc("/chatrooms/{room_id}", router.SaveChatroom).Methods("PUT")
-  s.HandleFunc("/chatrooms/{room_id}", router.DeleteChatroom).Methods("DELETE")
-
-  s.HandleFunc("/chatrooms/{room_id}/join", router.JoinChatrooom).Methods("GET")
-
-  s.HandleFunc("/chatrooms/{room_id}/messages", router.GetChatroomMessages).Methods("GET")
-  s.HandleFunc("/chatrooms/{room_id}/load", router.OnLoadChatroom).Methods("GET")
-  s.HandleFunc("/chatrooms/{room_id}/ws", router.EnterChatroom).Methods("")
+  s.HandleFunc("/chatrooms/{room_name}", router.GetChatroomMeta).Methods("GET")
+  s.HandleFunc("/chatrooms/{room_name}", router.UpdateChatroomSettings).Methods("PUT")
+  s.HandleFunc("/chatrooms/{room_name}", router.DeleteChatroom).Methods("DELETE")
+
+  s.HandleFunc("/chatrooms/{room_name}/join", router.JoinChatrooom).Methods("GET")
+  s.HandleFunc("/chatrooms/{room_name}/invite", router.InviteToChatroom).Methods("POST")
+  s.HandleFunc("/chatrooms/{room_name}/invite/{username}", router.RevokeInvitation).Methods("DELETE")
+  s.HandleFunc("/chatrooms/{room_name}/invites", router.CreateInviteLink).Methods("POST")
+  s.HandleFunc("/chatrooms/{room_name}/read", router.MarkChatroomRead).Methods("POST")
+  s.HandleFunc("/chatrooms/{room_name}/members", router.ListChatroomMembers).Methods("GET")
+  s.HandleFunc("/chatrooms/{room_name}/members/{username}", router.UpdateChatroomMember).Methods("PUT")
+  s.HandleFunc("/chatrooms/{room_name}/members/{username}", router.KickChatroomMember).Methods("DELETE")
+  s.HandleFunc("/chatrooms/{room_name}/roles", router.ListChatroomRoles).Methods("GET")
+  s.HandleFunc("/chatrooms/{room_name}/roles/{role}", router.SaveChatroomRole).Methods("PUT")
+  s.HandleFunc("/chatrooms/{room_name}/roles/{role}", router.DeleteChatroomRole).Methods("DELETE")
+
+  s.HandleFunc("/chatrooms/{room_name}/messages", router.GetChatroomMessages).Methods("GET")
+  s.HandleFunc("/chatrooms/{room_name}/export", router.ExportChatroom).Methods("GET")
+  s.HandleFunc("/chatrooms/{room_name}/messages/{message_id}", router.EditChatroomMessage).Methods("PATCH")
+  s.HandleFunc("/chatrooms/{room_name}/messages/{message_id}", router.DeleteChatroomMessage).Methods("DELETE")
+  s.HandleFunc("/chatrooms/{room_name}/messages/{message_id}/revisions", router.GetMessageRevisions).Methods("GET")
+  s.HandleFunc("/chatrooms/{room_name}/messages/{message_id}/thread", router.GetMessageThread).Methods("GET")
+  s.HandleFunc("/chatrooms/{room_name}/messages/{message_id}/reactions/{reaction}", router.UpdateReaction).Methods("POST", "DELETE")
+  s.HandleFunc("/chatrooms/{room_name}/load", router.OnLoadChatroom).Methods("GET")
+  s.HandleFunc("/chatrooms/{room_name}/ws", router.EnterChatroom).Methods("GET")
+
+  s.HandleFunc("/search/messages", router.SearchMessages).Methods("GET")
+
+  s.HandleFunc("/invites/{code}", router.PreviewInviteLink).Methods("GET")
+  s.HandleFunc("/invites/{code}/accept", router.AcceptInviteLink).Methods("POST")
+
+  s.HandleFunc("/User/me", router.DeactivateAccount).Methods("DELETE")
+  s.HandleFunc("/User/password", router.ChangePassword).Methods("POST")
+  s.HandleFunc("/User/me/chatrooms", router.GetJoinedChatrooms).Methods("GET")
+  s.HandleFunc("/User/me/invitations", router.GetPendingInvitations).Methods("GET")
+
+  s.HandleFunc("/user/me", router.UpdateProfile).Methods("PUT")
+  s.HandleFunc("/user/by-name/{username}", router.GetUserProfileByName).Methods("GET")
+  s.HandleFunc("/user/{user_id}", router.GetUserProfile).Methods("GET")
+
+  s.HandleFunc("/dm", router.ListDirectChatrooms).Methods("GET")
+  s.HandleFunc("/dm/{username}", router.OpenDirectChatroom).Methods("POST")
+
+  s.HandleFunc("/admin/backup", router.BackupDatabase).Methods("GET")
 
   return r
 }
@@ -216,13 +287,332 @@ func extractUserIDfromContext(r *http.Request) uuid.UUID {
   return userUID
 }
 
+// authorize :: Looks up userID's membership of roomName, and checks it with
+//    db.Authorize. member is nil for non-Members, or if the lookup failed.
+func(router *Router)authorize(
+  roomName string,
+  userID uuid.UUID,
+  perm db.Permission,
+  target *db.ChatroomMember,
+)( *db.ChatroomMember, error ){
+  member, err := router.database.GetChatroomMember(roomName, userID)
+  if _, ok := err.(db.GetDataError); err != nil && !ok {
+    return nil, err
+  }
+  return member, db.Authorize(member, perm, target)
+}
+
 func(router *Router)validateRoomMemeber(roomName string, userID uuid.UUID) bool {
-  member, err := router.database.GetChatroomMemberStatus(roomName, userID)
-  if err != nil {
+  if _, err := router.authorize(roomName, userID, 0, nil); err != nil {
     fmt.Printf("Failed to Validate User's Membership status")
     return false
   }
-  return *member != db.Blocked
+  return true
+}
+
+// parsePageQuery :: Parses the optional before, after and limit query parameters
+//    shared by every paginated route. Writes a 400 response on failure.
+func parsePageQuery(w http.ResponseWriter, r *http.Request)( uint64, uint64, int, bool ){
+  query := r.URL.Query()
+  before, err := db.DecodeCursor(query.Get("before"))
+  if err != nil {
+    http.Error(w, "Failed to query before parameter", http.StatusBadRequest)
+    return 0, 0, 0, false
+  }
+  after, err := db.DecodeCursor(query.Get("after"))
+  if err != nil {
+    http.Error(w, "Failed to query after parameter", http.StatusBadRequest)
+    return 0, 0, 0, false
+  }
+  limit := db.DefaultPageSize
+  if limitQuery := query.Get("limit"); limitQuery != "" {
+    if limit, err = strconv.Atoi(limitQuery); err != nil || limit <= 0 {
+      http.Error(w, "Failed to query limit parameter", http.StatusBadRequest)
+      return 0, 0, 0, false
+    }
+  }
+  return before, after, limit, true
+}
+
+// canModifyMessage :: Authors may edit and delete their own Messages as long as they
+//    may still post. Anyone else's requires perm.
+func(router *Router)canModifyMessage(roomName string, userID uuid.UUID, message *db.Message, perm db.Permission) bool {
+  if message.UserID == userID {
+    perm = db.PermPost
+  }
+  _, err := router.authorize(roomName, userID, perm, nil)
+  return err == nil
+}
+
+// getRouteMessage :: Looks up {message_id} within {room_name}, and checks that the
+//    User may modify it, with perm for anyone else's. Writes the error response and
+//    returns nil on failure.
+func(router *Router)getRouteMessage(
+  w http.ResponseWriter,
+  r *http.Request,
+  perm db.Permission,
+)( string, *db.Message, uuid.UUID ){
+  vars := mux.Vars(r)
+  roomName := vars["room_name"]
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return roomName, nil, userUID
+  }
+  messageID, err := uuid.Parse(vars["message_id"])
+  if err != nil {
+    http.Error(w, "Invalid Message ID", http.StatusBadRequest)
+    return roomName, nil, userUID
+  }
+
+  message, err := router.database.GetMessage(roomName, messageID)
+  if err != nil {
+    switch err.(type){
+    case db.GetDataError:
+      http.Error(w, "Message not found", http.StatusNotFound)
+    default:
+      http.Error(w, "Failed to retreive Message", http.StatusInternalServerError)
+    }
+    return roomName, nil, userUID
+  }
+  if !router.canModifyMessage(roomName, userUID, message, perm) {
+    http.Error(w, "Not allowed to modify Message", http.StatusForbidden)
+    return roomName, nil, userUID
+  }
+  return roomName, message, userUID
+}
+
+// broadcastEvent :: Pushes event to everyone connected to the Chatroom's Hub. If
+//    nobody is connected, there's no Hub, and nothing to do.
+func(router *Router)broadcastEvent(chatroom string, event interface{}) {
+  room, err := router.database.GetChatroom(chatroom)
+  if err != nil {
+    return
+  }
+  hub, ok := router.liveChatrooms.Load(room.RoomID)
+  if !ok {
+    return
+  }
+
+  var data []byte
+  enc := codec.NewEncoderBytes(&data, &db.JSONHandle)
+  if err := enc.Encode(event); err != nil {
+    log.Printf(" -> broadcastEvent: Failed to encode %T: %s", event, err)
+    return
+  }
+  hub.(*ws.Hub).Broadcast(data)
+}
+
+// presenceFunc :: The ws.PresenceFunc of roomName's Hub. Stores the User's live
+//    status within the Chatroom, keeps their online status in step, and returns
+//    the PresenceEvent sent to everyone else connected.
+func(router *Router)presenceFunc(roomName string) ws.PresenceFunc {
+  return func(userID db.UUID, status db.Status) []byte {
+    user, err := router.database.GetUserByID(userID)
+    if err != nil {
+      log.Printf(" -> presenceFunc: Failed to find User \"%s\": %s", userID, err)
+      return nil
+    }
+    if err := router.database.UpdateChatroomUserStatus(roomName, user.Username, status); err != nil {
+      log.Printf(" -> presenceFunc: Failed to update \"%s\"'s status in \"%s\": %s", user.Username, roomName, err)
+    }
+    router.updateOnlineStatus(user, roomName, status)
+
+    var data []byte
+    enc := codec.NewEncoderBytes(&data, &db.JSONHandle)
+    if err := enc.Encode(db.PresenceEvent{
+      Event:    db.PresenceChanged,
+      Chatroom: roomName,
+      UserID:   userID,
+      Username: user.Username,
+      Status:   status.String(),
+    }); err != nil {
+      log.Printf(" -> presenceFunc: Failed to encode PresenceEvent: %s", err)
+      return nil
+    }
+    return data
+  }
+}
+
+// updateOnlineStatus :: Marks user Online once they connect to their first
+//    Chatroom, and Offline once they've left their last.
+func(router *Router)updateOnlineStatus(user *db.User, roomName string, status db.Status) {
+  router.presenceMu.Lock()
+  defer router.presenceMu.Unlock()
+
+  rooms := router.presence[user.UserID]
+  wasOnline := len(rooms) != 0
+  if status == db.Offline {
+    delete(rooms, roomName)
+    if len(rooms) == 0 {
+      delete(router.presence, user.UserID)
+    }
+  } else {
+    if rooms == nil {
+      rooms = make(map[string]bool)
+      router.presence[user.UserID] = rooms
+    }
+    rooms[roomName] = true
+  }
+
+  if isOnline := len(rooms) != 0; isOnline != wasOnline {
+    if err := router.database.SaveUsersOnlineStatus(user.Username, isOnline); err != nil {
+      log.Printf(" -> updateOnlineStatus: Failed to update \"%s\"'s online status: %s", user.Username, err)
+    }
+  }
+}
+
+// clearPresence :: Drops userID's live status within every Chatroom they're
+//    connected to. Deactivated Users can't be looked up by their Hubs once they
+//    disconnect, so this is done beforehand.
+func(router *Router)clearPresence(userID uuid.UUID) {
+  user, err := router.database.GetUserByID(userID)
+  if err != nil {
+    return
+  }
+  router.presenceMu.Lock()
+  defer router.presenceMu.Unlock()
+
+  for roomName := range router.presence[userID] {
+    router.database.UpdateChatroomUserStatus(roomName, user.Username, db.Delete)
+  }
+  delete(router.presence, userID)
+}
+
+// resetChatroomPresence :: Marks everyone still shown as Online or Background within
+//    roomName as Offline. Statuses outlive the Hubs that set them whenever the
+//    server stops, so this is done before a Chatroom's Hub starts.
+func(router *Router)resetChatroomPresence(roomName string) error {
+  statuses, err := router.database.GetChatroomUserStatus(roomName)
+  if err != nil {
+    if _, ok := err.(db.GetDataError); ok {
+      return nil
+    }
+    return err
+  }
+  for username, status := range statuses {
+    if status == db.Status(db.Offline).String() {
+      continue
+    }
+    if err := router.database.UpdateChatroomUserStatus(roomName, username, db.Offline); err != nil {
+      log.Printf(" -> resetChatroomPresence: Failed to reset \"%s\"'s status in \"%s\": %s", username, roomName, err)
+    }
+  }
+  return nil
+}
+
+// ResetPresence :: Called once on startup. Nobody is connected to anything yet, so
+//    anyone left Online or Background by the last run is Offline.
+func( router *Router )ResetPresence() error {
+  rooms, err := router.database.GetChatrooms()
+  if err != nil {
+    return err
+  }
+  for _, room := range rooms {
+    if err := router.resetChatroomPresence(room.RoomName); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// getRouteMember :: Shared by UpdateChatroomMember and KickChatroomMember. Looks up
+//    {room_name} and {username}, and checks that the requesting User may manage them.
+//    On failure, an error has already been written and room is nil.
+func(router *Router)getRouteMember(
+  w http.ResponseWriter,
+  r *http.Request,
+)( room *db.Chatroom, member *db.User, actor *db.ChatroomMember ){
+  vars := mux.Vars(r)
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return nil, nil, nil
+  }
+
+  room, err := router.database.GetChatroom(vars["room_name"])
+  if err != nil {
+    http.Error(w, "Chatroom not found", http.StatusNotFound)
+    return nil, nil, nil
+  }
+  if room.IsDirect() {
+    http.Error(w, "Direct Chatrooms have no Moderators", http.StatusBadRequest)
+    return nil, nil, nil
+  }
+  actor, err = router.authorize(room.RoomName, userUID, db.PermManageMembers, nil)
+  if err != nil {
+    http.Error(w, "Not allowed to manage Members", http.StatusForbidden)
+    return nil, nil, nil
+  }
+
+  member, err = router.database.GetUserbyUsername(vars["username"])
+  if err != nil {
+    http.Error(w, "User not found", http.StatusNotFound)
+    return nil, nil, nil
+  }
+  if member.UserID == userUID {
+    http.Error(w, "Can't manage yourself", http.StatusBadRequest)
+    return nil, nil, nil
+  }
+  if member.UserID == room.OwnerID {
+    http.Error(w, "The Chatroom's creator can't be managed", http.StatusForbidden)
+    return nil, nil, nil
+  }
+  target, err := router.database.GetChatroomMember(room.RoomName, member.UserID)
+  if err != nil {
+    http.Error(w, "User is not a Member", http.StatusNotFound)
+    return nil, nil, nil
+  }
+  if err := db.Authorize(actor, db.PermManageMembers, target); err != nil {
+    http.Error(w, "Not allowed to manage this Member", http.StatusForbidden)
+    return nil, nil, nil
+  }
+  return room, member, actor
+}
+
+// requireAdmin :: Writes an error, and returns false, unless the requesting User is
+//    one of the server's admins.
+func(router *Router)requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return false
+  }
+  user, err := router.database.GetUserByID(userUID)
+  if err != nil || !router.admins[user.Username] {
+    http.Error(w, "Admins only", http.StatusForbidden)
+    return false
+  }
+  return true
+}
+
+// disconnectMember :: Drops userID's live connections to the Chatroom's Hub, if it's running.
+func(router *Router)disconnectMember(room *db.Chatroom, userID uuid.UUID) {
+  if hub, ok := router.liveChatrooms.Load(room.RoomID); ok {
+    hub.(*ws.Hub).Disconnect(userID)
+  }
+}
+
+// disconnectEverywhere :: Drops userID's live connections to every running Hub.
+func(router *Router)disconnectEverywhere(userID uuid.UUID) {
+  router.liveChatrooms.Range(func(_, hub interface{}) bool {
+    hub.(*ws.Hub).Disconnect(userID)
+    return true
+  })
+}
+
+// respondWithMessageChangeError :: Shared by EditChatroomMessage and DeleteChatroomMessage.
+func respondWithMessageChangeError(w http.ResponseWriter, err error) {
+  switch err.(type){
+  case db.MessageDeletedError:
+    http.Error(w, err.Error(), http.StatusConflict)
+  case db.GetDataError:
+    http.Error(w, "Message not found", http.StatusNotFound)
+  default:
+    http.Error(w, "Failed to update Message", http.StatusInternalServerError)
+  }
 }
 
 // ---------------------- Router HandleFuncs ----------------------
@@ -340,17 +730,25 @@ func( router *Router )UserSignIn(
   w.Header().Set("Content-Type", "application/json")
 
   var userData = struct{
-    Username string `json:"username"`
-    Password string `json:"password"`
+    Username   string `json:"username"`
+    Password   string `json:"password"`
+    Reactivate bool   `json:"reactivate"`
   }{ }
 
-  DecodeBodyOrError(w, r, userData)
+  if err := DecodeBodyOrError(w, r, &userData); err != nil {
+    http.Error(w, "Invalid Request Payload", http.StatusBadRequest)
+    return
+  }
   defer r.Body.Close()
 
   signingUser, err := router.database.GetUserbyUsername(userData.Username)
+  deactivated := false
   if err != nil {
-    http.Error(w, "Invalid Username", http.StatusUnauthorized)
-    return
+    if signingUser, err = router.database.GetDeactivatedUser(userData.Username); err != nil {
+      http.Error(w, "Invalid Username", http.StatusUnauthorized)
+      return
+    }
+    deactivated = true
   }
 
   if err := bcrypt.CompareHashAndPassword(
@@ -361,14 +759,21 @@ func( router *Router )UserSignIn(
     return
   }
 
+  // Only once the password checks out is a deactivated account given away.
+  if deactivated {
+    if !userData.Reactivate {
+      writeJSONError(w, "Account is deactivated. Sign in again with \"reactivate\": true to reactivate it", http.StatusForbidden)
+      return
+    }
+    if err := router.database.ActivateUser(signingUser.UserID); err != nil {
+      http.Error(w, "Failed to reactivate account", http.StatusInternalServerError)
+      return
+    }
+  }
+
   // User is now Verified via username & password
   userID := signingUser.UserID
 
-  if err := router.database.SaveUsersOnlineStatus(signingUser.Username, true); err != nil {
-    http.Error(w, err.Error(), http.StatusInternalServerError)
-    return
-  }
-
   newToken, err := token.CreateToken(userID.String())
   if err != nil {
     http.Error(w, "Failed to create new Token", http.StatusInternalServerError)
@@ -392,192 +797,1392 @@ func( router *Router )UserSignIn(
   w.Write(jsonBytes)
 }
 
-func( router *Router )UserSignup(
+// DeactivateAccount :: DELETE /User/me
+//    Hides the requesting User until they sign in again and choose to reactivate.
+//    Their Access Token is revoked and every live connection dropped. Nothing is
+//    freed, their Username, Messages and memberships are all kept.
+func( router *Router )DeactivateAccount(
   w http.ResponseWriter,
   r *http.Request,
-){
-  // w.Header().Set("Authorization", "Bearer "+tokenString)
-  w.Header().Set("Content-Type", "application/json")
-  var userSignupData = struct{
-    Username  string `json:"username"`
-    Password  string `json:"password"`
-  }{ }
-
-  decoder := json.NewDecoder(r.Body)
-  if err := decoder.Decode(&userSignupData); err != nil {
-    http.Error(w, "Invalid Request Payload", http.StatusBadRequest)
-    return
-  }
+) {
   defer r.Body.Close()
 
-  hashedPassword, err := bcrypt.GenerateFromPassword(
-    []byte(userSignupData.Password),
-    bcrypt.DefaultCost,
-  )
-  if err != nil {
-    http.Error(w, "Failed while hashing the password", http.StatusInternalServerError)
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
     return
   }
-  uid := uuid.New()
 
-  accessToken, err := token.CreateToken(uid.String())
-  if err != nil {
-    http.Error(w, "Failed to Create Token", http.StatusInternalServerError)
+  router.clearPresence(userUID)
+  if err := router.database.DeactivateUser(userUID); err != nil {
+    if _, ok := err.(db.GetDataError); ok {
+      http.Error(w, "User not found", http.StatusNotFound)
+      return
+    }
+    http.Error(w, "Failed to deactivate account", http.StatusInternalServerError)
     return
   }
 
-  user := db.User{
-    UserID: uid,
-    Username: userSignupData.Username,
-    HashedPassword: hashedPassword,
-  }
-  // Save and Store the User and new Access Token.
-  err = router.database.SaveUser(user, accessToken)
-  if err != nil {
-    http.Error(w, "Failed to store created User in Database", http.StatusInternalServerError)
-    return
-  }
+  router.disconnectEverywhere(userUID)
+  w.WriteHeader(http.StatusOK)
+}
 
-  var jsonBytes []byte
-  enc := codec.NewEncoderBytes(&jsonBytes, &db.JSONHandle)
+// GetUserProfile :: GET /user/{user_id}
+//    Returns the User's Profile. "me" stands in for the requesting User.
+func( router *Router )GetUserProfile(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
 
-  if err := enc.Encode(accessToken); err != nil {
-    http.Error(w, "Failed to Encode Access Token", http.StatusInternalServerError)
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
     return
   }
 
-  // w.Header().Set("Authorization", "Bearer "+accessToken.Token)
-  w.WriteHeader(http.StatusCreated)
-  w.Write(jsonBytes)
+  profileID := userUID
+  if id := mux.Vars(r)["user_id"]; id != "me" {
+    var err error
+    if profileID, err = uuid.Parse(id); err != nil {
+      http.Error(w, "Invalid UserID", http.StatusBadRequest)
+      return
+    }
+  }
+  router.respondWithProfile(w, r, profileID)
 }
 
-func( router *Router )ListPublicChatrooms(
+// GetUserProfileByName :: GET /user/by-name/{username}
+//    Same as GetUserProfile, for when only the User's Username is known.
+func( router *Router )GetUserProfileByName(
   w http.ResponseWriter,
   r *http.Request,
-){
+) {
+  defer r.Body.Close()
 
+  user, err := router.database.GetUserbyUsername(mux.Vars(r)["username"])
+  if err != nil {
+    http.Error(w, "User not found", http.StatusNotFound)
+    return
+  }
+  router.respondWithProfile(w, r, user.UserID)
 }
 
-func(router *Router)ValidateChatroom(chatroom *db.Chatroom) error {
-  name := chatroom.RoomName
-
-  if len(name) <= 5 || len(name) >= 50{
-    return fmt.Errorf("RoomName must be between 5 and 50 characters")
-  }
-  for _, r := range name {
-    if !unicode.IsLetter(r)  && !unicode.IsNumber(r){
-      return fmt.Errorf(
-        "RoomName can only consist of Letters and Numbers: \"%v\" is not aloud",
-        r,
-      )
+// respondWithProfile :: Shared by GetUserProfile and GetUserProfileByName. Only ever
+//    sends a db.Profile, never the db.User behind it.
+func( router *Router )respondWithProfile(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+  profile, err := router.database.GetProfile(userID)
+  if err != nil {
+    if _, ok := err.(db.GetDataError); ok {
+      http.Error(w, "User not found", http.StatusNotFound)
+      return
     }
+    http.Error(w, "Failed to retreive Profile", http.StatusInternalServerError)
+    return
   }
-  if _, err := router.database.GetUserByID(chatroom.OwnerID); err != nil {
-    return fmt.Errorf("Invalid UserID \"%s\"", chatroom.OwnerID)
-  }
-
-  return nil
+  RespondWithDataOrError(w, r, profile, nil, http.StatusOK)
 }
 
-// SaveChatroom: Handles both Chatroom Creation and Chatroom Updates.
-func( router *Router )SaveChatroom(
+// UpdateProfile :: PUT /user/me
+//    Changes any part of the requesting User's Profile. Fields left out of the
+//    body are kept. Returns the updated Profile.
+func( router *Router )UpdateProfile(
   w http.ResponseWriter,
   r *http.Request,
-){
-  var chatroom db.Chatroom
-  dec := codec.NewDecoder(r.Body, &db.JSONHandle)
+) {
   defer r.Body.Close()
 
-
-  if err := dec.Decode(chatroom); err != nil {
-    http.Error(w, "Invalid Chatroom Data", http.StatusBadRequest)
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
     return
   }
 
-  if err := router.ValidateChatroom(&chatroom); err != nil {
-    http.Error(w, err.Error(), http.StatusUnauthorized)
+  var settings db.ProfileSettings
+  if err := codec.NewDecoder(r.Body, &db.JSONHandle).Decode(&settings); err != nil {
+    http.Error(w, "Invalid Profile", http.StatusBadRequest)
     return
   }
 
-  switch r.Method {
-  case http.MethodPost:
-    // Handle Chatroom Creation
-    if err := router.database.SaveChatroom(&chatroom, false); err != nil {
-      http.Error(w, "Failed to save new Chatroom", http.StatusInternalServerError)
-    }
-  case http.MethodPut:
-    // Handle Chatroom Update
-    if err := router.database.SaveChatroom(&chatroom, true); err != nil {
-      http.Error(w, "Failed to update Chatroom", http.StatusInternalServerError)
-    }
+  profile, err := router.database.GetProfile(userUID)
+  if err != nil {
+    http.Error(w, "User not found", http.StatusNotFound)
+    return
   }
-
-  w.WriteHeader(http.StatusOK)
+  if err := settings.Apply(profile); err != nil {
+    http.Error(w, err.Error(), http.StatusBadRequest)
+    return
+  }
+  profile.UpdatedAt = time.Now()
+  if err := router.database.SaveProfile(profile); err != nil {
+    http.Error(w, "Failed to save Profile", http.StatusInternalServerError)
+    return
+  }
+  RespondWithDataOrError(w, r, profile, nil, http.StatusOK)
 }
 
-// GetChatroomMeta :: /chatrooms/room_id
-func( router *Router )GetChatroomMeta(
+// ChangePassword :: POST /User/password
+//    Expects the User's current 'old_password' and a 'new_password'. Their Access
+//    Token is replaced along with their password, signing out every other session
+//    and dropping their live connections, and the new Access Token is sent back.
+func( router *Router )ChangePassword(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+
+  var passwords = struct{
+    OldPassword string `json:"old_password"`
+    NewPassword string `json:"new_password"`
+  }{ }
+  if err := DecodeBodyOrError(w, r, &passwords); err != nil {
+    http.Error(w, "Invalid Request Payload", http.StatusBadRequest)
+    return
+  }
+  if passwords.NewPassword == "" {
+    http.Error(w, "New password can't be empty", http.StatusBadRequest)
+    return
+  }
+
+  user, err := router.database.GetUserByID(userUID)
+  if err != nil {
+    http.Error(w, "User not found", http.StatusNotFound)
+    return
+  }
+  if err := bcrypt.CompareHashAndPassword(
+    user.HashedPassword,
+    []byte(passwords.OldPassword),
+  ); err != nil {
+    http.Error(w, "Password is not correct", http.StatusUnauthorized)
+    return
+  }
+
+  hashedPassword, err := bcrypt.GenerateFromPassword(
+    []byte(passwords.NewPassword),
+    bcrypt.DefaultCost,
+  )
+  if err != nil {
+    http.Error(w, "Failed while hashing the password", http.StatusInternalServerError)
+    return
+  }
+  newToken, err := token.CreateToken(userUID.String())
+  if err != nil {
+    http.Error(w, "Failed to create new Token", http.StatusInternalServerError)
+    return
+  }
+  if err := router.database.UpdateUserPassword(userUID, hashedPassword, newToken); err != nil {
+    http.Error(w, "Failed to change password", http.StatusInternalServerError)
+    return
+  }
+
+  router.disconnectEverywhere(userUID)
+  RespondWithDataOrError(w, r, newToken, nil, http.StatusOK)
+}
+
+// RequestPasswordReset :: POST /User/password/reset
+//    Expects a 'username'. Creates a single use reset token, valid for
+//    db.DefaultPasswordResetTTL, and hands it to the Router's Notifier. Whether the
+//    User exists or not is never given away, the response is always the same.
+func( router *Router )RequestPasswordReset(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+
+  if router.notifier == nil {
+    http.Error(w, "Password resets are turned off", http.StatusNotImplemented)
+    return
+  }
+
+  var request = struct{
+    Username string `json:"username"`
+  }{ }
+  if err := DecodeBodyOrError(w, r, &request); err != nil || request.Username == "" {
+    http.Error(w, "Invalid Request Payload", http.StatusBadRequest)
+    return
+  }
+
+  user, err := router.database.GetUserbyUsername(request.Username)
+  if err != nil {
+    w.WriteHeader(http.StatusAccepted)
+    return
+  }
+
+  reset, resetToken, err := db.NewPasswordReset(user.UserID, 0)
+  if err != nil {
+    http.Error(w, "Failed to create reset token", http.StatusInternalServerError)
+    return
+  }
+  if err := router.database.SavePasswordReset(&reset); err != nil {
+    http.Error(w, "Failed to store reset token", http.StatusInternalServerError)
+    return
+  }
+  // A delivery failure only gets logged. Answering any differently would give away
+  //    that the User exists.
+  if err := router.notifier.NotifyPasswordReset(user.Username, resetToken, reset.ExpiresAt); err != nil {
+    log.Printf(" -> RequestPasswordReset: Failed to deliver reset token for \"%s\": %s", user.Username, err)
+  }
+  w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword :: POST /User/password/reset/confirm
+//    Expects a 'token' from RequestPasswordReset and a 'new_password'. The reset
+//    token is used up whether or not it's still valid. The User's Access Token is
+//    revoked and every live connection dropped, so they have to sign in again.
+func( router *Router )ResetPassword(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+
+  var request = struct{
+    Token       string `json:"token"`
+    NewPassword string `json:"new_password"`
+  }{ }
+  if err := DecodeBodyOrError(w, r, &request); err != nil {
+    http.Error(w, "Invalid Request Payload", http.StatusBadRequest)
+    return
+  }
+  if request.NewPassword == "" {
+    http.Error(w, "New password can't be empty", http.StatusBadRequest)
+    return
+  }
+
+  reset, err := router.database.ConsumePasswordReset(request.Token)
+  if err != nil {
+    switch err.(type) {
+    case db.GetDataError, db.FailedSecurityCheckError:
+      http.Error(w, "Invalid or expired reset token", http.StatusUnauthorized)
+    default:
+      http.Error(w, "Failed to check reset token", http.StatusInternalServerError)
+    }
+    return
+  }
+
+  hashedPassword, err := bcrypt.GenerateFromPassword(
+    []byte(request.NewPassword),
+    bcrypt.DefaultCost,
+  )
+  if err != nil {
+    http.Error(w, "Failed while hashing the password", http.StatusInternalServerError)
+    return
+  }
+  if err := router.database.UpdateUserPassword(reset.UserID, hashedPassword, nil); err != nil {
+    if _, ok := err.(db.GetDataError); ok {
+      // Deactivated since the reset token was handed out.
+      http.Error(w, "Invalid or expired reset token", http.StatusUnauthorized)
+      return
+    }
+    http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+    return
+  }
+
+  router.disconnectEverywhere(reset.UserID)
+  w.WriteHeader(http.StatusOK)
+}
+
+func( router *Router )UserSignup(
   w http.ResponseWriter,
   r *http.Request,
 ){
+  // w.Header().Set("Authorization", "Bearer "+tokenString)
   w.Header().Set("Content-Type", "application/json")
+  var userSignupData = struct{
+    Username  string `json:"username"`
+    Password  string `json:"password"`
+  }{ }
 
-  vars := mux.Vars(r)
-  roomName, exists := vars["room_name"]
-  if !exists {
-    http.Error(w, "Room name is missing", http.StatusBadRequest)
+  decoder := json.NewDecoder(r.Body)
+  if err := decoder.Decode(&userSignupData); err != nil {
+    http.Error(w, "Invalid Request Payload", http.StatusBadRequest)
+    return
+  }
+  defer r.Body.Close()
+
+  // Deactivated Users keep their Username.
+  if _, err := router.database.GetUserbyUsername(userSignupData.Username); err == nil {
+    http.Error(w, "Username is already taken", http.StatusConflict)
+    return
+  }
+  if _, err := router.database.GetDeactivatedUser(userSignupData.Username); err == nil {
+    http.Error(w, "Username is already taken", http.StatusConflict)
+    return
+  }
+
+  hashedPassword, err := bcrypt.GenerateFromPassword(
+    []byte(userSignupData.Password),
+    bcrypt.DefaultCost,
+  )
+  if err != nil {
+    http.Error(w, "Failed while hashing the password", http.StatusInternalServerError)
+    return
+  }
+  uid := uuid.New()
+
+  accessToken, err := token.CreateToken(uid.String())
+  if err != nil {
+    http.Error(w, "Failed to Create Token", http.StatusInternalServerError)
+    return
+  }
+
+  user := db.User{
+    UserID: uid,
+    Username: userSignupData.Username,
+    HashedPassword: hashedPassword,
+  }
+  // Save and Store the User and new Access Token.
+  err = router.database.SaveUser(user, accessToken)
+  if err != nil {
+    http.Error(w, "Failed to store created User in Database", http.StatusInternalServerError)
+    return
+  }
+
+  var jsonBytes []byte
+  enc := codec.NewEncoderBytes(&jsonBytes, &db.JSONHandle)
+
+  if err := enc.Encode(accessToken); err != nil {
+    http.Error(w, "Failed to Encode Access Token", http.StatusInternalServerError)
+    return
+  }
+
+  // w.Header().Set("Authorization", "Bearer "+accessToken.Token)
+  w.WriteHeader(http.StatusCreated)
+  w.Write(jsonBytes)
+}
+
+// ListPublicChatrooms :: /chatrooms?q=<name>&sort=name|members|activity&cursor=<cursor>&limit=N
+//    The Chatroom Directory. Every query parameter is optional. q matches any part
+//    of a Chatroom's name, ignoring case.
+func( router *Router )ListPublicChatrooms(
+  w http.ResponseWriter,
+  r *http.Request,
+){
+  defer r.Body.Close()
+
+  query := r.URL.Query()
+  search := strings.ToLower(strings.TrimSpace(query.Get("q")))
+  order := query.Get("sort")
+  if order == "" {
+    order = db.SortByName
+  }
+  if !db.ValidDirectorySort(order) {
+    http.Error(w, "Failed to query sort parameter", http.StatusBadRequest)
+    return
+  }
+  limit := db.DefaultPageSize
+  if limitQuery := query.Get("limit"); limitQuery != "" {
+    var err error
+    if limit, err = strconv.Atoi(limitQuery); err != nil || limit <= 0 {
+      http.Error(w, "Failed to query limit parameter", http.StatusBadRequest)
+      return
+    }
+  }
+
+  directory, err := router.database.GetChatroomDirectory()
+  if err != nil {
+    http.Error(w, "Failed to retreive public Chatrooms", http.StatusInternalServerError)
+    return
+  }
+
+  entries := make([]db.DirectoryEntry, 0, len(directory))
+  for _, entry := range directory {
+    if search != "" && !strings.Contains(strings.ToLower(entry.RoomName), search) {
+      continue
+    }
+    entries = append(entries, entry)
+  }
+
+  page, err := db.PageDirectory(entries, order, query.Get("cursor"), limit)
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusBadRequest)
+    return
+  }
+  // Live statuses aren't a sort order, so they're only looked up for this page.
+  for i := range page.Chatrooms {
+    if page.Chatrooms[i].OnlineCount, err = router.countOnline(page.Chatrooms[i].RoomName); err != nil {
+      http.Error(w, "Failed to retreive Chatroom details", http.StatusInternalServerError)
+      return
+    }
+  }
+  RespondWithDataOrError(w, r, page, nil, http.StatusOK)
+}
+
+// countOnline :: How many of a Chatroom's Members are Online. A Chatroom nobody has
+//    entered yet has no live statuses at all.
+func(router *Router)countOnline(roomName string)( int, error ){
+  statuses, err := router.database.GetChatroomUserStatus(roomName)
+  if err != nil {
+    if _, ok := err.(db.GetDataError); !ok {
+      return 0, err
+    }
+  }
+  online := 0
+  for _, status := range statuses {
+    if status == "Online" {
+      online++
+    }
+  }
+  return online, nil
+}
+
+func(router *Router)ValidateChatroom(chatroom *db.Chatroom) error {
+  if err := db.ValidateRoomName(chatroom.RoomName); err != nil {
+    return err
+  }
+  if _, err := router.database.GetUserByID(chatroom.OwnerID); err != nil {
+    return fmt.Errorf("Invalid UserID \"%s\"", chatroom.OwnerID)
+  }
+
+  return nil
+}
+
+// SaveChatroom :: POST /chatrooms
+//    Creates a new Chatroom, owned by the requesting User. Settings can be given
+//    up front, and later changed with UpdateChatroomSettings.
+func( router *Router )SaveChatroom(
+  w http.ResponseWriter,
+  r *http.Request,
+){
+  var chatroom db.Chatroom
+  dec := codec.NewDecoder(r.Body, &db.JSONHandle)
+  defer r.Body.Close()
+
+
+  if err := dec.Decode(&chatroom); err != nil {
+    http.Error(w, "Invalid Chatroom Data", http.StatusBadRequest)
+    return
+  }
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+  chatroom.RoomID = uuid.New()
+  chatroom.OwnerID = userUID
+  chatroom.PeerID = uuid.Nil
+
+  if err := router.ValidateChatroom(&chatroom); err != nil {
+    http.Error(w, err.Error(), http.StatusUnauthorized)
+    return
+  }
+  if err := db.ValidateChatroomSettings(&chatroom); err != nil {
+    http.Error(w, err.Error(), http.StatusBadRequest)
+    return
+  }
+
+  if err := router.database.SaveChatroom(&chatroom, false); err != nil {
+    http.Error(w, "Failed to save new Chatroom", http.StatusInternalServerError)
+    return
+  }
+  RespondWithDataOrError(w, r, chatroom, nil, http.StatusOK)
+}
+
+// UpdateChatroomSettings :: PUT /chatrooms/{room_name}
+//    Changes any of the Chatroom's settings, for Members allowed to. Settings
+//    left out of the body are kept. Everyone connected to the Chatroom is sent a
+//    ChatroomEvent with the updated Chatroom.
+func( router *Router )UpdateChatroomSettings(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+  roomName := mux.Vars(r)["room_name"]
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+
+  var settings db.ChatroomSettings
+  if err := codec.NewDecoder(r.Body, &db.JSONHandle).Decode(&settings); err != nil {
+    http.Error(w, "Invalid Chatroom settings", http.StatusBadRequest)
+    return
+  }
+
+  room, err := router.database.UpdateChatroomSettings(roomName, userUID, &settings)
+  if err != nil {
+    switch err.(type){
+    case db.InvalidSettingsError:
+      http.Error(w, err.Error(), http.StatusBadRequest)
+    case db.FailedSecurityCheckError:
+      http.Error(w, "Not allowed to change the Chatroom's settings", http.StatusForbidden)
+    case db.GetDataError:
+      http.Error(w, "Chatroom not found", http.StatusNotFound)
+    default:
+      http.Error(w, "Failed to update Chatroom", http.StatusInternalServerError)
+    }
+    return
+  }
+
+  if hub, ok := router.liveChatrooms.Load(room.RoomID); ok {
+    hub.(*ws.Hub).SetSlowMode(room.SlowModeInterval())
+  }
+  router.broadcastEvent(roomName, &db.ChatroomEvent{
+    Event:     db.ChatroomUpdated,
+    Chatroom:  roomName,
+    Settings:  *room,
+    UpdatedBy: userUID,
+  })
+  RespondWithDataOrError(w, r, room, nil, http.StatusOK)
+}
+
+// GetChatroomMeta :: /chatrooms/room_id
+func( router *Router )GetChatroomMeta(
+  w http.ResponseWriter,
+  r *http.Request,
+){
+  w.Header().Set("Content-Type", "application/json")
+
+  vars := mux.Vars(r)
+  roomName, exists := vars["room_name"]
+  if !exists {
+    http.Error(w, "Room name is missing", http.StatusBadRequest)
+    return
+  }
+
+  chatroom, err := router.database.GetChatroom(roomName)
+  if err != nil {
+    var redirectErrorURL string
+    var httpStatus int
+
+    switch err.(type){
+      case db.BucketNotFoundError:
+      case db.DecoderError:
+        redirectErrorURL = "/?error=internal_server_error"
+        httpStatus = http.StatusInternalServerError
+      case db.GetDataError:
+        redirectErrorURL = "/?error=chatroom_doesnt_exist"
+        httpStatus = http.StatusBadRequest
+      default:
+        redirectErrorURL = "/?error=unknown_error"
+        httpStatus = http.StatusInternalServerError
+    }
+
+    http.Redirect(
+      w,r,
+      redirectErrorURL,
+      httpStatus,
+    )
+    return
+  }
+  var bytes []byte
+  enc := codec.NewEncoderBytes(&bytes, &db.JSONHandle)
+
+  if err := enc.Encode(&chatroom); err != nil {
+    http.Redirect(w,r, "/?error=internal_error", http.StatusInternalServerError)
+    return
+  }
+
+  w.WriteHeader(http.StatusOK)
+  w.Write(bytes)
+}
+
+// DeleteChatroom :: Deactivates Chatroom. Authentication is based on the Token
+//     provided by the Authentication Bearer Header
+func( router *Router )DeleteChatroom(
+  w http.ResponseWriter,
+  r *http.Request,
+){
+  if r.Method != http.MethodDelete {
+    http.Error(w, "Invalid HTTP Request", http.StatusMethodNotAllowed)
+    return
+  }
+  vars := mux.Vars(r)
+  roomName := vars["room_name"]
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+
+  if err := router.database.DeactivateChatroom(roomName, userUID); err != nil {
+    switch err.(type){
+    case db.FailedSecurityCheckError:
+      http.Error(w, err.Error(), http.StatusForbidden)
+    default:
+      http.Error(w, err.Error(), http.StatusInternalServerError)
+    }
+    return
+  }
+  w.WriteHeader(http.StatusOK)
+}
+
+// JoinChatrooom :: For becoming a Member of a particular Chatroom. Private Chatrooms
+//    require an Invitation, see InviteToChatroom, which is used up on joining.
+func( router *Router )JoinChatrooom(
+  w http.ResponseWriter,
+  r *http.Request,
+){
+  w.Header().Set("Content-Type", "application/json")
+  defer r.Body.Close()
+  roomName := mux.Vars(r)["room_name"]
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    writeJSONError(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+  user, err := router.database.GetUserByID(userUID)
+  if err != nil {
+    writeJSONError(w, "User not found", http.StatusUnauthorized)
+    return
+  }
+
+  // Calls upon database.JoinChatroom. If Chatroom.Public is set to false. Then
+  // an Invitation will be required and will fail if missing/expired.
+  if err := router.database.JoinChatroom(roomName, user.Username); err != nil {
+    switch err.(type) {
+    case db.GetDataError:
+      writeJSONError(w, "Chatroom or Invitation not found", http.StatusBadRequest)
+    case db.FailedSecurityCheckError:
+      writeJSONError(w, "invitation invalid", http.StatusUnauthorized)
+    case db.ChatroomFullError:
+      writeJSONError(w, "Chatroom is full", http.StatusConflict)
+    case db.BucketNotFoundError, db.DecoderError:
+      writeJSONError(w, "internal server error", http.StatusInternalServerError)
+    default:
+      writeJSONError(w, "Unknown Error", http.StatusInternalServerError)
+    }
+    return
+  }
+}
+
+func( router *Router )EnterChatroom(
+  w http.ResponseWriter,
+  r *http.Request,
+){
+  vars := mux.Vars(r)
+  roomName := vars["room_name"]
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+
+  // room, exist := router.DbChatrooms.Rooms[roomID];
+  room, err := router.database.GetChatroom(roomName)
+  if err != nil {
+    http.Redirect(w,r, "/chatrooms?error=invalid_chatroom", http.StatusNotFound)
+    return
+  }
+
+  if member, err := router.authorize(roomName, userUID, 0, nil); err != nil {
+    switch _, denied := err.(db.FailedSecurityCheckError); {
+    case !denied:
+      http.Redirect(w,r, "/chatrooms?error=internal_error", http.StatusInternalServerError)
+    case member == nil:
+      http.Redirect(w,r, "/chatrooms?error=not_a_member", http.StatusUnauthorized)
+    default:
+      http.Redirect(w,r, "/chatrooms?error=user_is_blocked", http.StatusUnauthorized)
+    }
+    return
+  }
+
+  hub, ok := router.liveChatrooms.LoadOrStore(room.RoomID, ws.NewHub())
+  // If Chatroom is not running. Start an instance in a Goroutine. The Hub keeps
+  // every Member's live status up to date from then on.
+  if !ok {
+    if err := router.resetChatroomPresence(roomName); err != nil {
+      log.Printf(" -> EnterChatroom: Failed to reset live statuses of \"%s\": %s", roomName, err)
+    }
+    hub.(*ws.Hub).TrackPresence(router.presenceFunc(roomName), router.idleTimeout)
+    go hub.(*ws.Hub).Run()
+  }
+  hub.(*ws.Hub).SetSlowMode(room.SlowModeInterval())
+
+  // If Chatroom is running, Serve the Websocket instance via hub.
+  ws.ServeWs(hub.(*ws.Hub), router.database, room.RoomName, userUID, w, r)
+}
+
+func( router *Router)OnLoadChatroom(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  vars := mux.Vars(r)
+  defer r.Body.Close()
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+  roomName := vars["room_name"]
+
+  if !router.validateRoomMemeber(roomName, userUID) {
+    http.Error(w, "Failed to validate Chatroom Membership", http.StatusUnauthorized)
+    return
+  }
+
+  // Load the latest page of Messages. The client scrolls back from PrevCursor.
+  page, err := router.database.Paginate(roomName, 0, 0, db.DefaultPageSize)
+  if err != nil {
+    http.Redirect(w,r, "/chatrooms?error=internal_error", http.StatusInternalServerError)
+    return
+  }
+
+  RespondWithDataOrError(w, r, page, nil, http.StatusOK)
+}
+
+// GetChatroomMessages :: /chatrooms/{room_name}/messages?before=<cursor>&after=<cursor>&limit=N
+//    All query parameters are optional. Without a cursor, the latest Messages are returned.
+func( router *Router )GetChatroomMessages(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  vars := mux.Vars(r)
+  defer r.Body.Close()
+
+  roomName := vars["room_name"]
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+
+  if !router.validateRoomMemeber(roomName, userUID) {
+    http.Error(w, "Failed to validate Chatroom Membership", http.StatusUnauthorized)
+    return
+  }
+
+  before, after, limit, ok := parsePageQuery(w, r)
+  if !ok {
+    return
+  }
+
+  page, err := router.database.Paginate(roomName, before, after, limit)
+  if err != nil {
+    http.Redirect(w,r, "/chatrooms?error=internal_error", http.StatusInternalServerError)
+    return
+  }
+
+  RespondWithDataOrError(w, r, page, nil, http.StatusOK)
+}
+
+// ExportChatroom :: GET /chatrooms/{room_name}/export?format=ndjson|json|txt|md&from=...&to=...
+//    Streams every Message within the Chatroom, oldest first, for it's Members. from and to
+//    are optional RFC3339 timestamps, both inclusive. format defaults to ndjson.
+func( router *Router )ExportChatroom(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  vars := mux.Vars(r)
+  defer r.Body.Close()
+
+  roomName := vars["room_name"]
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+
+  if !router.validateRoomMemeber(roomName, userUID) {
+    http.Error(w, "Failed to validate Chatroom Membership", http.StatusUnauthorized)
+    return
+  }
+
+  params := r.URL.Query()
+  format := params.Get("format")
+  if format == "" {
+    format = db.ExportNDJSON
+  }
+
+  var filter db.ExportFilter
+  var err error
+  if from := params.Get("from"); from != "" {
+    if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+      http.Error(w, "Failed to query from parameter", http.StatusBadRequest)
+      return
+    }
+  }
+  if to := params.Get("to"); to != "" {
+    if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+      http.Error(w, "Failed to query to parameter", http.StatusBadRequest)
+      return
+    }
+  }
+
+  exporter, err := db.NewExporter(format, w, roomName)
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusBadRequest)
+    return
+  }
+
+  w.Header().Set("Content-Type", db.ExportContentType(format))
+  w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", roomName, format))
+  err = db.ExportMessages(router.database, roomName, filter, exporter.WriteMessage)
+  if err == nil {
+    err = exporter.Close()
+  }
+  if err != nil {
+    // Part of the export may already be out. Cutting it short is all that's left.
+    log.Printf(" -> ExportChatroom: Failed to export \"%s\": %s", roomName, err)
+  }
+}
+
+// SearchMessages :: GET /search/messages?q=...&room=...&from=...&before=...&after=...&limit=...
+//    Every term within q must match. before and after are RFC3339 timestamps, from
+//    is a Username. Only Messages from Chatrooms the User is a non-Blocked member
+//    of are searched, newest first.
+func( router *Router )SearchMessages(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+
+  params := r.URL.Query()
+  query := db.NewSearchQuery(params.Get("q"))
+  if len(query.Terms) == 0 {
+    http.Error(w, "Failed to query q parameter", http.StatusBadRequest)
+    return
+  }
+  query.Chatroom = params.Get("room")
+
+  if from := params.Get("from"); from != "" {
+    // Deactivated authors, like imported placeholders, are still searchable.
+    user, err := router.database.GetUserbyUsername(from)
+    if err != nil {
+      if user, err = router.database.GetDeactivatedUser(from); err != nil {
+        http.Error(w, "User not found", http.StatusNotFound)
+        return
+      }
+    }
+    query.UserID = user.UserID
+  }
+
+  var err error
+  if before := params.Get("before"); before != "" {
+    if query.Before, err = time.Parse(time.RFC3339, before); err != nil {
+      http.Error(w, "Failed to query before parameter", http.StatusBadRequest)
+      return
+    }
+  }
+  if after := params.Get("after"); after != "" {
+    if query.After, err = time.Parse(time.RFC3339, after); err != nil {
+      http.Error(w, "Failed to query after parameter", http.StatusBadRequest)
+      return
+    }
+  }
+  limit := db.DefaultPageSize
+  if limitQuery := params.Get("limit"); limitQuery != "" {
+    if limit, err = strconv.Atoi(limitQuery); err != nil || limit <= 0 {
+      http.Error(w, "Failed to query limit parameter", http.StatusBadRequest)
+      return
+    }
+  }
+  query.Limit = db.ClampPageSize(limit)
+
+  if query.Chatroom != "" && !router.validateRoomMemeber(query.Chatroom, userUID) {
+    http.Error(w, "Failed to validate Chatroom Membership", http.StatusUnauthorized)
+    return
+  }
+
+  joined, err := router.database.GetJoinedChatrooms(userUID)
+  if err != nil {
+    log.Printf(" -> SearchMessages: Failed to get joined Chatrooms: %s", err.Error())
+    http.Error(w, "Failed to search Messages", http.StatusInternalServerError)
+    return
+  }
+  query.Chatrooms = make([]db.RoomName, 0, len(joined))
+  for _, room := range joined {
+    query.Chatrooms = append(query.Chatrooms, room.Chatroom)
+  }
+
+  results, err := router.database.SearchMessages(query)
+  if err != nil {
+    log.Printf(" -> SearchMessages: Search FAILURE: %s", err.Error())
+    http.Error(w, "Failed to search Messages", http.StatusInternalServerError)
+    return
+  }
+
+  RespondWithDataOrError(w, r, db.SearchResults{ Results: results }, nil, http.StatusOK)
+}
+
+// EditChatroomMessage :: PATCH /chatrooms/{room_name}/messages/{message_id}
+//    Replaces the Message's content. The previous content is kept as a revision,
+//    and an "edited" event is sent to everyone within the Chatroom.
+func( router *Router )EditChatroomMessage(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+
+  var edit = struct{
+    Content string `json:"content"`
+  }{ }
+  if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+    http.Error(w, "Invalid Request Payload", http.StatusBadRequest)
+    return
+  }
+  if strings.TrimSpace(edit.Content) == "" {
+    http.Error(w, "Message content can't be empty", http.StatusBadRequest)
+    return
+  }
+
+  roomName, message, userUID := router.getRouteMessage(w, r, db.PermEditOthers)
+  if message == nil {
+    return
+  }
+
+  edited, err := router.database.EditMessage(roomName, message.ID, userUID, edit.Content)
+  if err != nil {
+    respondWithMessageChangeError(w, err)
+    return
+  }
+
+  router.broadcastEvent(roomName, &db.ChatroomMessage{ Event: db.MessageEdited, Chatroom: roomName, Message: *edited })
+  RespondWithDataOrError(w, r, edited, nil, http.StatusOK)
+}
+
+// DeleteChatroomMessage :: DELETE /chatrooms/{room_name}/messages/{message_id}
+//    Leaves a tombstone in the Message's place, and sends a "deleted" event to
+//    everyone within the Chatroom.
+func( router *Router )DeleteChatroomMessage(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+
+  roomName, message, _ := router.getRouteMessage(w, r, db.PermDeleteOthers)
+  if message == nil {
+    return
+  }
+
+  tombstone, err := router.database.DeleteMessage(roomName, message.ID)
+  if err != nil {
+    respondWithMessageChangeError(w, err)
+    return
+  }
+
+  router.broadcastEvent(roomName, &db.ChatroomMessage{ Event: db.MessageDeleted, Chatroom: roomName, Message: *tombstone })
+  RespondWithDataOrError(w, r, tombstone, nil, http.StatusOK)
+}
+
+// GetMessageRevisions :: GET /chatrooms/{room_name}/messages/{message_id}/revisions
+//    Every previous content of the Message, oldest first. Open to every Member.
+func( router *Router )GetMessageRevisions(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  vars := mux.Vars(r)
+  defer r.Body.Close()
+
+  roomName := vars["room_name"]
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+  if !router.validateRoomMemeber(roomName, userUID) {
+    http.Error(w, "Failed to validate Chatroom Membership", http.StatusUnauthorized)
+    return
+  }
+  messageID, err := uuid.Parse(vars["message_id"])
+  if err != nil {
+    http.Error(w, "Invalid Message ID", http.StatusBadRequest)
+    return
+  }
+
+  revisions, err := router.database.GetMessageRevisions(roomName, messageID)
+  if err != nil {
+    switch err.(type){
+    case db.GetDataError:
+      http.Error(w, "Message not found", http.StatusNotFound)
+    default:
+      http.Error(w, "Failed to retreive Message revisions", http.StatusInternalServerError)
+    }
+    return
+  }
+
+  RespondWithDataOrError(w, r, map[string][]db.MessageRevision{ "revisions": revisions }, nil, http.StatusOK)
+}
+
+// GetMessageThread :: GET /chatrooms/{room_name}/messages/{message_id}/thread
+//    Returns the thread's root, and a page of it's replies. Takes the same before,
+//    after and limit parameters as GetChatroomMessages. If message_id is itself a
+//    reply, the whole thread it belongs to is returned.
+func( router *Router )GetMessageThread(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  vars := mux.Vars(r)
+  defer r.Body.Close()
+
+  roomName := vars["room_name"]
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+  if !router.validateRoomMemeber(roomName, userUID) {
+    http.Error(w, "Failed to validate Chatroom Membership", http.StatusUnauthorized)
+    return
+  }
+  messageID, err := uuid.Parse(vars["message_id"])
+  if err != nil {
+    http.Error(w, "Invalid Message ID", http.StatusBadRequest)
+    return
+  }
+  before, after, limit, ok := parsePageQuery(w, r)
+  if !ok {
+    return
+  }
+
+  root, err := router.database.GetMessage(roomName, messageID)
+  if err == nil && root.ThreadID != uuid.Nil {
+    root, err = router.database.GetMessage(roomName, root.ThreadID)
+  }
+  if err != nil {
+    switch err.(type){
+    case db.GetDataError:
+      http.Error(w, "Message not found", http.StatusNotFound)
+    default:
+      http.Error(w, "Failed to retreive Message", http.StatusInternalServerError)
+    }
+    return
+  }
+
+  replies, err := router.database.PaginateThread(roomName, root.ID, before, after, limit)
+  if err != nil {
+    http.Error(w, "Failed to retreive thread", http.StatusInternalServerError)
+    return
+  }
+
+  RespondWithDataOrError(w, r, db.ThreadPage{ Root: *root, Replies: replies }, nil, http.StatusOK)
+}
+
+// UpdateReaction :: POST or DELETE /chatrooms/{room_name}/messages/{message_id}/reactions/{reaction}
+//    Adds, or removes, the User's reaction to a Message. Any non-Blocked Member may
+//    react. Responds with the updated Message, and sends a ReactionEvent to everyone
+//    within the Chatroom.
+func( router *Router )UpdateReaction(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  vars := mux.Vars(r)
+  defer r.Body.Close()
+
+  roomName := vars["room_name"]
+  reaction := vars["reaction"]
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+  if !router.validateRoomMemeber(roomName, userUID) {
+    http.Error(w, "Failed to validate Chatroom Membership", http.StatusUnauthorized)
+    return
+  }
+  messageID, err := uuid.Parse(vars["message_id"])
+  if err != nil {
+    http.Error(w, "Invalid Message ID", http.StatusBadRequest)
+    return
+  }
+  if !db.ValidReaction(reaction) {
+    http.Error(w, "Invalid Reaction", http.StatusBadRequest)
+    return
+  }
+
+  var message *db.Message
+  event := db.ReactionAdded
+  if r.Method == http.MethodDelete {
+    event = db.ReactionRemoved
+    message, err = router.database.RemoveReaction(roomName, messageID, userUID, reaction)
+  } else {
+    message, err = router.database.AddReaction(roomName, messageID, userUID, reaction)
+  }
+  if err != nil {
+    respondWithMessageChangeError(w, err)
+    return
+  }
+
+  router.broadcastEvent(roomName, &db.ReactionEvent{
+    Event:     event,
+    Chatroom:  roomName,
+    MessageID: messageID,
+    Reaction:  reaction,
+    UserID:    userUID,
+    Count:     message.ReactionCount(reaction),
+  })
+  RespondWithDataOrError(w, r, message, nil, http.StatusOK)
+}
+
+// MarkChatroomRead :: POST /chatrooms/{room_name}/read
+//    Advances the User's read marker to the optional {"seq": n} body, or to the
+//    latest Message when there's no body. Read markers never move backwards.
+func( router *Router )MarkChatroomRead(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  vars := mux.Vars(r)
+  defer r.Body.Close()
+
+  roomName := vars["room_name"]
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+  if !router.validateRoomMemeber(roomName, userUID) {
+    http.Error(w, "Failed to validate Chatroom Membership", http.StatusUnauthorized)
+    return
+  }
+
+  var read = struct{
+    Seq uint64 `json:"seq"`
+  }{ }
+  if err := json.NewDecoder(r.Body).Decode(&read); err != nil && err != io.EOF {
+    http.Error(w, "Invalid Request Payload", http.StatusBadRequest)
+    return
+  }
+
+  marker, err := router.database.MarkRead(roomName, userUID, read.Seq)
+  if err != nil {
+    http.Error(w, "Failed to update read marker", http.StatusInternalServerError)
+    return
+  }
+
+  RespondWithDataOrError(w, r, map[string]interface{}{ "chatroom": roomName, "last_read": marker }, nil, http.StatusOK)
+}
+
+// GetJoinedChatrooms :: GET /User/me/chatrooms
+//    Every Chatroom the User belongs to, along with their unread counts.
+func( router *Router )GetJoinedChatrooms(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+
+  rooms, err := router.database.GetJoinedChatrooms(userUID)
+  if err != nil {
+    http.Error(w, "Failed to retreive joined Chatrooms", http.StatusInternalServerError)
+    return
+  }
+
+  RespondWithDataOrError(w, r, map[string][]db.JoinedChatroom{ "chatrooms": rooms }, nil, http.StatusOK)
+}
+
+// OpenDirectChatroom :: POST /dm/{username}
+//    Opens, or fetches, the Direct Chatroom between the User and {username}. It's
+//    entered over /chatrooms/{room_name}/ws like any other Chatroom.
+func( router *Router )OpenDirectChatroom(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+
+  peer, err := router.database.GetUserbyUsername(mux.Vars(r)["username"])
+  if err != nil {
+    http.Error(w, "User not found", http.StatusNotFound)
+    return
+  }
+  if peer.UserID == userUID {
+    http.Error(w, "Can't open a Direct Chatroom with yourself", http.StatusBadRequest)
+    return
+  }
+
+  room, err := router.database.OpenDirectChatroom(userUID, peer.UserID)
+  if err != nil {
+    http.Error(w, "Failed to open Direct Chatroom", http.StatusInternalServerError)
+    return
+  }
+
+  RespondWithDataOrError(w, r, room, nil, http.StatusOK)
+}
+
+// ListDirectChatrooms :: GET /dm
+//    The User's Direct Chatrooms, with the other User's name resolved.
+func( router *Router )ListDirectChatrooms(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+
+  rooms, err := router.database.GetJoinedChatrooms(userUID)
+  if err != nil {
+    http.Error(w, "Failed to retreive Direct Chatrooms", http.StatusInternalServerError)
+    return
+  }
+
+  type directChatroom struct {
+    Chatroom string      `codec:"chatroom"`
+    PeerID   uuid.UUID   `codec:"peer_id"`
+    PeerName db.UserName `codec:"peer_name"`
+    LastRead uint64      `codec:"last_read"`
+    Unread   uint64      `codec:"unread"`
+  }
+  direct := []directChatroom{}
+  for _, room := range rooms {
+    if room.PeerID == uuid.Nil {
+      continue
+    }
+    peer, err := router.database.GetUserByID(room.PeerID)
+    if err != nil {
+      // Deactivated Users keep their Direct Chatrooms, just without a name.
+      peer = &db.User{ UserID: room.PeerID }
+    }
+    direct = append(direct, directChatroom{
+      Chatroom: room.Chatroom,
+      PeerID:   peer.UserID,
+      PeerName: peer.Username,
+      LastRead: room.LastRead,
+      Unread:   room.Unread,
+    })
+  }
+
+  RespondWithDataOrError(w, r, map[string]interface{}{ "direct_chatrooms": direct }, nil, http.StatusOK)
+}
+
+// InviteToChatroom :: POST /chatrooms/{room_name}/invite
+//    Owners and Moderators invite a User, by Username, to a private Chatroom. The
+//    optional expires_in is in seconds, and defaults to db.DefaultInvitationTTL.
+func( router *Router )InviteToChatroom(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+  roomName := mux.Vars(r)["room_name"]
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+
+  var invite struct{
+    Username  string `codec:"username"`
+    ExpiresIn int64  `codec:"expires_in"`
+  }
+  if err := codec.NewDecoder(r.Body, &db.JSONHandle).Decode(&invite); err != nil || invite.ExpiresIn < 0 {
+    http.Error(w, "Invalid Invitation", http.StatusBadRequest)
+    return
+  }
+
+  room, err := router.database.GetChatroom(roomName)
+  if err != nil {
+    http.Error(w, "Chatroom not found", http.StatusNotFound)
+    return
+  }
+  if room.Public || room.IsDirect() {
+    http.Error(w, "Only private Chatrooms require Invitations", http.StatusBadRequest)
+    return
+  }
+  if _, err := router.authorize(roomName, userUID, db.PermInvite, nil); err != nil {
+    http.Error(w, "Not allowed to invite Users", http.StatusForbidden)
+    return
+  }
+
+  invitee, err := router.database.GetUserbyUsername(invite.Username)
+  if err != nil {
+    http.Error(w, "User not found", http.StatusNotFound)
+    return
+  }
+  if _, err := router.database.GetChatroomMemberStatus(roomName, invitee.UserID); err == nil {
+    // Blocked Users included, since they can't rejoin.
+    http.Error(w, "User is already a Member", http.StatusConflict)
+    return
+  }
+
+  invitation := db.NewInvitation(room, invitee.UserID, userUID, time.Duration(invite.ExpiresIn)*time.Second)
+  if err := router.database.SaveInvitation(&invitation); err != nil {
+    http.Error(w, "Failed to save Invitation", http.StatusInternalServerError)
+    return
+  }
+
+  RespondWithDataOrError(w, r, invitation, nil, http.StatusCreated)
+}
+
+// RevokeInvitation :: DELETE /chatrooms/{room_name}/invite/{username}
+//    The inviter, and anyone allowed to manage Members, may revoke an Invitation.
+//    The invitee may decline it.
+func( router *Router )RevokeInvitation(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+  vars := mux.Vars(r)
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
     return
   }
 
-  chatroom, err := router.database.GetChatroom(roomName)
+  room, err := router.database.GetChatroom(vars["room_name"])
   if err != nil {
-    var redirectErrorURL string
-    var httpStatus int
-
-    switch err.(type){
-      case db.BucketNotFoundError:
-      case db.DecoderError:
-        redirectErrorURL = "/?error=internal_server_error"
-        httpStatus = http.StatusInternalServerError
-      case db.GetDataError:
-        redirectErrorURL = "/?error=chatroom_doesnt_exist"
-        httpStatus = http.StatusBadRequest
-      default:
-        redirectErrorURL = "/?error=unknown_error"
-        httpStatus = http.StatusInternalServerError
-    }
-
-    http.Redirect(
-      w,r,
-      redirectErrorURL,
-      httpStatus,
-    )
+    http.Error(w, "Chatroom not found", http.StatusNotFound)
+    return
+  }
+  invitee, err := router.database.GetUserbyUsername(vars["username"])
+  if err != nil {
+    http.Error(w, "User not found", http.StatusNotFound)
+    return
+  }
+  invitation, err := router.database.GetInvitation(room.RoomID, invitee.UserID)
+  if err != nil {
+    http.Error(w, "Invitation not found", http.StatusNotFound)
     return
   }
-  var bytes []byte
-  enc := codec.NewEncoderBytes(&bytes, &db.JSONHandle)
 
-  if err := enc.Encode(&chatroom); err != nil {
-    http.Redirect(w,r, "/?error=internal_error", http.StatusInternalServerError)
+  allowed := invitation.InvitedBy == userUID || invitation.UserID == userUID
+  if !allowed {
+    _, err := router.authorize(room.RoomName, userUID, db.PermManageMembers, nil)
+    allowed = err == nil
+  }
+  if !allowed {
+    http.Error(w, "Not allowed to revoke Invitation", http.StatusForbidden)
     return
   }
 
+  if err := router.database.RemoveInvitation(room.RoomID, invitee.UserID); err != nil {
+    http.Error(w, "Failed to revoke Invitation", http.StatusInternalServerError)
+    return
+  }
   w.WriteHeader(http.StatusOK)
-  w.Write(bytes)
 }
 
-// DeleteChatroom :: Deactivates Chatroom. Authentication is based on the Token
-//     provided by the Authentication Bearer Header
-func( router *Router )DeleteChatroom(
+// GetPendingInvitations :: GET /User/me/invitations
+//    Every unexpired Invitation the User has yet to accept, newest first.
+func( router *Router )GetPendingInvitations(
   w http.ResponseWriter,
   r *http.Request,
-){
-  if r.Method != http.MethodDelete {
-    http.Error(w, "Invalid HTTP Request", http.StatusMethodNotAllowed)
-    return
-  }
-  vars := mux.Vars(r)
-  roomName := vars["room_name"]
+) {
+  defer r.Body.Close()
 
   userUID := extractUserIDfromContext(r)
   if userUID == uuid.Nil {
@@ -585,58 +2190,106 @@ func( router *Router )DeleteChatroom(
     return
   }
 
-  if err := router.database.DeactivateChatroom(roomName, userUID); err != nil {
-    http.Error(w, err.Error(), http.StatusInternalServerError)
+  invitations, err := router.database.GetPendingInvitations(userUID)
+  if err != nil {
+    http.Error(w, "Failed to retreive Invitations", http.StatusInternalServerError)
+    return
   }
-  w.WriteHeader(http.StatusOK)
+
+  RespondWithDataOrError(w, r, map[string][]db.Invitation{ "invitations": invitations }, nil, http.StatusOK)
 }
 
-// JoinChatrooom :: For becoming a Member of a particular Chatroom
-func( router *Router )JoinChatrooom(
+// CreateInviteLink :: POST /chatrooms/{room_name}/invites
+//    Owners and Moderators create a code anyone can redeem through /invites/{code}.
+//    Both expires_in(seconds) and max_uses are optional. A max_uses of 0 is unlimited.
+func( router *Router )CreateInviteLink(
   w http.ResponseWriter,
   r *http.Request,
-){
-  w.Header().Set("Content-Type", "application/json")
-  var joinRoomHandle struct {
-    RoomName   string `json:"room_name"`
-    UserName   string `json:"user_name"`
-    Invitation []byte `json:"invitation"`
+) {
+  defer r.Body.Close()
+  roomName := mux.Vars(r)["room_name"]
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
   }
 
-  decoder := codec.NewDecoder(r.Body, &db.JSONHandle)
-  if err := decoder.Decode(&joinRoomHandle); err != nil {
-    http.Error(w, "Invalid Join room Handle", http.StatusBadRequest)
+  var options = struct{
+    ExpiresIn int64 `json:"expires_in"`
+    MaxUses   int   `json:"max_uses"`
+  }{ }
+  if err := json.NewDecoder(r.Body).Decode(&options); err != nil && err != io.EOF {
+    http.Error(w, "Invalid Request Payload", http.StatusBadRequest)
+    return
+  }
+  if options.ExpiresIn < 0 || options.MaxUses < 0 {
+    http.Error(w, "expires_in and max_uses can't be negative", http.StatusBadRequest)
+    return
+  }
+
+  room, err := router.database.GetChatroom(roomName)
+  if err != nil {
+    http.Error(w, "Chatroom not found", http.StatusNotFound)
+    return
+  }
+  if room.IsDirect() {
+    http.Error(w, "Direct Chatrooms can't be joined", http.StatusBadRequest)
+    return
+  }
+  if _, err := router.authorize(roomName, userUID, db.PermInvite, nil); err != nil {
+    http.Error(w, "Not allowed to create invite links", http.StatusForbidden)
     return
   }
+
+  link, err := db.NewInviteLink(room, userUID, time.Duration(options.ExpiresIn)*time.Second, options.MaxUses)
+  if err != nil {
+    http.Error(w, "Failed to create invite link", http.StatusInternalServerError)
+    return
+  }
+  if err := router.database.SaveInviteLink(&link); err != nil {
+    http.Error(w, "Failed to save invite link", http.StatusInternalServerError)
+    return
+  }
+
+  RespondWithDataOrError(w, r, link, nil, http.StatusCreated)
+}
+
+// PreviewInviteLink :: GET /invites/{code}
+//    Shows which Chatroom a code leads to before redeeming it.
+func( router *Router )PreviewInviteLink(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
   defer r.Body.Close()
 
-  // Calls upon database.EnterChatroom. If Chatroom.Public is set to false. Then
-  // Invitation will be required and will fail if missing/invalid.
-  if err := router.database.JoinChatroom(
-    joinRoomHandle.RoomName,
-    joinRoomHandle.UserName,
-    joinRoomHandle.Invitation,
-  ); err != nil {
-    switch err.(type) {
-    case db.GetDataError:
-      writeJSONError(w, "Malfromed Data Provider", http.StatusBadRequest)
-    case db.FailedSecurityCheckError:
-      writeJSONError(w, "invitation invalid", http.StatusUnauthorized)
-    case db.BucketNotFoundError, db.DecoderError:
-      writeJSONError(w, "internal server error", http.StatusInternalServerError)
-    default:
-      writeJSONError(w, "Unknown Error", http.StatusInternalServerError)
-    }
+  link, err := router.database.GetInviteLink(mux.Vars(r)["code"])
+  if err != nil {
+    http.Error(w, "Invite link not found", http.StatusNotFound)
+    return
+  }
+  entry, err := router.database.GetDirectoryEntry(link.Chatroom)
+  if err != nil || !link.Usable(time.Now()) {
+    http.Error(w, "Invite link has expired", http.StatusGone)
+    return
+  }
+  if entry.OnlineCount, err = router.countOnline(link.Chatroom); err != nil {
+    http.Error(w, "Failed to retreive Chatroom details", http.StatusInternalServerError)
     return
   }
+
+  RespondWithDataOrError(w, r, map[string]interface{}{ "invite": link, "chatroom": entry }, nil, http.StatusOK)
 }
 
-func( router *Router )EnterChatroom(
+// AcceptInviteLink :: POST /invites/{code}/accept
+//    Redeems the code, adding the User to it's Chatroom as a Member. Existing
+//    Members don't use the code up, and Blocked Users can't rejoin with one.
+func( router *Router )AcceptInviteLink(
   w http.ResponseWriter,
   r *http.Request,
-){
-  vars := mux.Vars(r)
-  roomName := vars["room_name"]
+) {
+  defer r.Body.Close()
+  code := mux.Vars(r)["code"]
 
   userUID := extractUserIDfromContext(r)
   if userUID == uuid.Nil {
@@ -644,82 +2297,228 @@ func( router *Router )EnterChatroom(
     return
   }
 
-  // room, exist := router.DbChatrooms.Rooms[roomID];
-  room, err := router.database.GetChatroom(roomName)
+  link, err := router.database.GetInviteLink(code)
   if err != nil {
-    http.Redirect(w,r, "/chatrooms?error=invalid_chatroom", http.StatusNotFound)
+    http.Error(w, "Invite link not found", http.StatusNotFound)
+    return
+  }
+  if member, err := router.database.GetChatroomMemberStatus(link.Chatroom, userUID); err == nil {
+    if *member == db.Blocked {
+      http.Error(w, "User is blocked", http.StatusForbidden)
+    } else {
+      http.Error(w, "User is already a Member", http.StatusConflict)
+    }
     return
   }
 
-  member, err := router.database.GetChatroomMemberStatus(roomName, userUID)
-  if err != nil {
+  // Redeeming also checks the Chatroom has room, and adds the Member, so that
+  // concurrent redemptions can't overshoot either limit.
+  if link, err = router.database.RedeemInviteLink(code, userUID); err != nil {
     switch err.(type){
-    case db.BucketNotFoundError:
-      http.Redirect(w,r, "/chatrooms?error=internal_error", http.StatusInternalServerError)
-    case db.GetDataError:
-      http.Redirect(w,r, "/chatrooms?error=not_a_member", http.StatusUnauthorized)
+    case db.ChatroomFullError:
+      http.Error(w, "Chatroom is full", http.StatusConflict)
+    case db.AlreadyMemberError:
+      http.Error(w, "User is already a Member", http.StatusConflict)
+    case db.FailedSecurityCheckError, db.GetDataError:
+      http.Error(w, "Invite link has expired", http.StatusGone)
+    default:
+      http.Error(w, "Failed to redeem invite link", http.StatusInternalServerError)
     }
     return
   }
 
-  if *member == db.Blocked {
-    http.Redirect(w,r, "/chatrooms?error=user_is_blocked", http.StatusUnauthorized)
+  RespondWithDataOrError(w, r, db.JoinedChatroom{ Chatroom: link.Chatroom, MemberType: db.Member }, nil, http.StatusOK)
+}
+
+// UpdateChatroomMember :: PUT /chatrooms/{room_name}/members/{username}
+//    Hands a Member one of the Chatroom's Roles, or "blocked". Only Roles the
+//    requesting User outranks can be handed out. Blocking a Member bans them,
+//    dropping their live connection to the Chatroom.
+func( router *Router )UpdateChatroomMember(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+
+  var update struct{
+    Role string `codec:"role"`
+  }
+  if err := codec.NewDecoder(r.Body, &db.JSONHandle).Decode(&update); err != nil {
+    http.Error(w, "Invalid Member update", http.StatusBadRequest)
+    return
+  }
+  room, member, actor := router.getRouteMember(w, r)
+  if room == nil {
     return
   }
 
-  // Change User's room Status
-  // Find way of detecting if a user's ws connection disconnects ?
+  role := *db.DefaultRole(db.BlockedRole)
+  if update.Role != db.BlockedRole {
+    roles, err := router.database.GetChatroomRoles(room.RoomName)
+    if err != nil {
+      http.Error(w, "Failed to retreive Roles", http.StatusInternalServerError)
+      return
+    }
+    found := false
+    for _, r := range roles {
+      if r.Name == update.Role {
+        role, found = r, true
+      }
+    }
+    if !found {
+      http.Error(w, fmt.Sprintf("Unknown role \"%s\"", update.Role), http.StatusBadRequest)
+      return
+    }
+  }
+  if err := db.Authorize(actor, db.PermManageMembers, &db.ChatroomMember{ Role: role }); err != nil {
+    http.Error(w, "Not allowed to hand out this Role", http.StatusForbidden)
+    return
+  }
 
-  hub, ok := router.liveChatrooms.LoadOrStore(room.RoomID, ws.NewHub())
-  // If Chatroom is not running. Start an instance in a Goroutine.
-  if !ok {
-    go hub.(*ws.Hub).Run()
+  var err error
+  if role.Name == db.BlockedRole {
+    // Blocked Users can't hold a live status, so clear it while they still can.
+    router.database.UpdateChatroomUserStatus(room.RoomName, member.Username, db.Delete)
+    err = router.database.SaveChatroomMember(room.RoomName, member.UserID, db.Blocked)
+  } else {
+    err = router.database.SetChatroomMemberRole(room.RoomName, member.UserID, role.Name)
+  }
+  if err != nil {
+    http.Error(w, "Failed to update Member", http.StatusInternalServerError)
+    return
+  }
+  if role.Name == db.BlockedRole {
+    router.disconnectMember(room, member.UserID)
   }
 
-  // If Chatroom is running, Serve the Websocket instance via hub.
-  ws.ServeWs(hub.(*ws.Hub), router.database, w, r)
+  updated, err := router.database.GetChatroomMember(room.RoomName, member.UserID)
+  RespondWithDataOrError(w, r, updated, err, http.StatusOK)
 }
 
-func( router *Router)OnLoadChatroom(
+// KickChatroomMember :: DELETE /chatrooms/{room_name}/members/{username}
+//    Removes a Member from the Chatroom, and drops their live connection to it.
+//    Unlike a ban, they're free to rejoin.
+func( router *Router )KickChatroomMember(
   w http.ResponseWriter,
   r *http.Request,
 ) {
-  w.Header().Set("Content-Type", "application/json")
+  defer r.Body.Close()
 
-  vars := mux.Vars(r)
+  room, member, _ := router.getRouteMember(w, r)
+  if room == nil {
+    return
+  }
+
+  router.database.UpdateChatroomUserStatus(room.RoomName, member.Username, db.Delete)
+  if err := router.database.RemoveChatroomMember(room.RoomName, member.UserID); err != nil {
+    http.Error(w, "Failed to remove Member", http.StatusInternalServerError)
+    return
+  }
+  router.disconnectMember(room, member.UserID)
+
+  w.WriteHeader(http.StatusOK)
+}
+
+// ListChatroomMembers :: GET /chatrooms/{room_name}/members
+//    Every non-Blocked Member of the Chatroom, along with their Role and live
+//    status, sorted by Username. Only Members may list them.
+func( router *Router )ListChatroomMembers(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
   defer r.Body.Close()
+  roomName := mux.Vars(r)["room_name"]
 
   userUID := extractUserIDfromContext(r)
   if userUID == uuid.Nil {
     http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
     return
   }
-  roomName := vars["room_name"]
-
   if !router.validateRoomMemeber(roomName, userUID) {
-    http.Error(w, "Failed to validate Chatroom Membership", http.StatusUnauthorized)
+    http.Error(w, "User is not a Member of this Chatroom", http.StatusForbidden)
     return
   }
 
-  msgs, err := router.database.Paginate(roomName, 0, db.DefaultPageSize)
+  members, err := router.database.GetChatroomMembers(roomName)
   if err != nil {
-    http.Redirect(w,r, "/chatrooms?error=internal_error", http.StatusInternalServerError)
+    http.Error(w, "Failed to retreive Members", http.StatusInternalServerError)
     return
   }
+  statuses, err := router.database.GetChatroomUserStatus(roomName)
+  if err != nil {
+    if _, ok := err.(db.GetDataError); !ok {
+      http.Error(w, "Failed to retreive live statuses", http.StatusInternalServerError)
+      return
+    }
+  }
 
-  w.WriteHeader(http.StatusOK)
-  w.Write(msgs)
+  list := make([]db.MemberStatus, 0, len(members))
+  for memberID, memberType := range members {
+    if memberType == db.Blocked {
+      continue
+    }
+    // Deactivated Users stay Members, but can't be looked up.
+    user, err := router.database.GetUserByID(memberID)
+    if err != nil {
+      continue
+    }
+    member, err := router.database.GetChatroomMember(roomName, memberID)
+    if err != nil {
+      http.Error(w, "Failed to retreive Members", http.StatusInternalServerError)
+      return
+    }
+    status, ok := statuses[user.Username]
+    if !ok {
+      status = db.Status(db.Offline).String()
+    }
+    list = append(list, db.MemberStatus{
+      UserID:   memberID,
+      Username: user.Username,
+      Role:     member.Role.Name,
+      Status:   status,
+    })
+  }
+  sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+
+  RespondWithDataOrError(w, r, map[string]interface{}{ "members": list }, nil, http.StatusOK)
 }
 
-func( router *Router )GetChatroomMessages(
+// ListChatroomRoles :: GET /chatrooms/{room_name}/roles
+//    Every Role within the Chatroom, highest ranking first.
+func( router *Router )ListChatroomRoles(
   w http.ResponseWriter,
   r *http.Request,
 ) {
-  w.Header().Set("Content-Type", "application/json")
-  vars := mux.Vars(r)
   defer r.Body.Close()
+  roomName := mux.Vars(r)["room_name"]
 
-  roomName := vars["room_name"]
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
+    return
+  }
+  if !router.validateRoomMemeber(roomName, userUID) {
+    http.Error(w, "User is not a Member of this Chatroom", http.StatusForbidden)
+    return
+  }
+
+  roles, err := router.database.GetChatroomRoles(roomName)
+  if err != nil {
+    http.Error(w, "Failed to retreive Roles", http.StatusInternalServerError)
+    return
+  }
+  RespondWithDataOrError(w, r, map[string]interface{}{ "roles": roles }, nil, http.StatusOK)
+}
+
+// SaveChatroomRole :: PUT /chatrooms/{room_name}/roles/{role}
+//    Creates, or updates, a Role. Only Owners may manage Roles, and the Owner's own
+//    Role can't be changed.
+func( router *Router )SaveChatroomRole(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+  vars := mux.Vars(r)
 
   userUID := extractUserIDfromContext(r)
   if userUID == uuid.Nil {
@@ -727,36 +2526,93 @@ func( router *Router )GetChatroomMessages(
     return
   }
 
-  if !router.validateRoomMemeber(roomName, userUID) {
-    http.Error(w, "Failed to validate Chatroom Membership", http.StatusUnauthorized)
+  var role db.Role
+  if err := codec.NewDecoder(r.Body, &db.JSONHandle).Decode(&role); err != nil {
+    http.Error(w, "Invalid Role", http.StatusBadRequest)
     return
   }
+  role.Name = vars["role"]
 
-  // TYLER: You were figuring out PAGINATING your messages on EnterChatroom
+  if _, err := router.database.GetChatroom(vars["room_name"]); err != nil {
+    http.Error(w, "Chatroom not found", http.StatusNotFound)
+    return
+  }
+  if _, err := router.authorize(vars["room_name"], userUID, db.PermManageRoles, nil); err != nil {
+    http.Error(w, "Not allowed to manage Roles", http.StatusForbidden)
+    return
+  }
+  if err := db.ValidateRole(&role); err != nil {
+    http.Error(w, err.Error(), http.StatusBadRequest)
+    return
+  }
 
-  // Query and Convert any received paginate parameters. If any fail, fall back
-  // onto default parameters.
-  pageQuery := r.URL.Query().Get("page")
-  page, err := strconv.Atoi(pageQuery)
-  if err != nil {
-    // page = 0
-    http.Error(w, "Failed to query page parameter", http.StatusBadRequest)
+  if err := router.database.SaveChatroomRole(vars["room_name"], &role); err != nil {
+    http.Error(w, "Failed to save Role", http.StatusInternalServerError)
     return
   }
-  limitQuery := r.URL.Query().Get("limit")
-  limit, err := strconv.Atoi(limitQuery)
-  if err != nil {
-    // limit = db.DefaultPageSize
-    http.Error(w, "Failed to query limit parameter", http.StatusBadGateway)
+  RespondWithDataOrError(w, r, role, nil, http.StatusOK)
+}
+
+// DeleteChatroomRole :: DELETE /chatrooms/{room_name}/roles/{role}
+//    Deletes a custom Role. Members holding it become plain Members.
+func( router *Router )DeleteChatroomRole(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  defer r.Body.Close()
+  vars := mux.Vars(r)
+
+  userUID := extractUserIDfromContext(r)
+  if userUID == uuid.Nil {
+    http.Error(w, "Unable to find UserID in Context", http.StatusUnauthorized)
     return
   }
 
-  // Load and send back Raw Chatroom messages before connecting User to Chatroom WS
-  msgs, err := router.database.Paginate(roomName, page, limit)
-  if err != nil {
-    http.Redirect(w,r, "/chatrooms?error=internal_error", http.StatusInternalServerError)
+  if _, err := router.database.GetChatroom(vars["room_name"]); err != nil {
+    http.Error(w, "Chatroom not found", http.StatusNotFound)
+    return
+  }
+  if _, err := router.authorize(vars["room_name"], userUID, db.PermManageRoles, nil); err != nil {
+    http.Error(w, "Not allowed to manage Roles", http.StatusForbidden)
+    return
+  }
+  if db.IsDefaultRole(vars["role"]) {
+    http.Error(w, "Default Roles can't be deleted", http.StatusBadRequest)
+    return
+  }
+
+  if err := router.database.DeleteChatroomRole(vars["room_name"], vars["role"]); err != nil {
+    switch err.(type){
+    case db.GetDataError:
+      http.Error(w, "Role not found", http.StatusNotFound)
+    default:
+      http.Error(w, "Failed to delete Role", http.StatusInternalServerError)
+    }
+    return
+  }
+  w.WriteHeader(http.StatusOK)
+}
+
+// BackupDatabase :: GET /admin/backup
+//    Streams a consistent snapshot of the Database, for admins only. Restore it
+//    with the restore command, while the server is stopped.
+func( router *Router )BackupDatabase(
+  w http.ResponseWriter,
+  r *http.Request,
+) {
+  if !router.requireAdmin(w, r) {
+    return
+  }
+  snapshotter, ok := router.database.(db.Snapshotter)
+  if !ok {
+    http.Error(w, "This Database doesn't support snapshots", http.StatusNotImplemented)
     return
   }
 
-  w.Write(msgs)
+  w.Header().Set("Content-Type", "application/octet-stream")
+  w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", db.BackupFileName(time.Now())))
+  if n, err := snapshotter.WriteSnapshot(w); err != nil {
+    // Headers are long gone by now. Cutting the response short is all that's left.
+    log.Printf(" -> BackupDatabase: Failed after %d bytes: %s", n, err)
+  }
 }
@@ -0,0 +1,1377 @@
+package router
+
+import (
+	"bytes"
+	"chatatui_backend/db"
+	"chatatui_backend/notify"
+	"chatatui_backend/token"
+	"chatatui_backend/ws"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// newTestServer :: Spins up the full Router, backed by an in-memory Database.
+func newTestServer(t *testing.T)( *httptest.Server, db.ChatatuiDatabase ){
+  database := db.NewMemoryDatabase()
+  router := NewRouter(database, ws.NewHub())
+  server := httptest.NewServer(router.SetupRouter())
+  t.Cleanup(server.Close)
+  return server, database
+}
+
+// signup :: Creates a new User through /User/Signup, returning the User and their Access Token.
+func signup(t *testing.T, server *httptest.Server, database db.ChatatuiDatabase, username string)( *db.User, *token.Token ){
+  body, _ := json.Marshal(map[string]string{ "username": username, "password": "password" })
+  resp, err := http.Post(server.URL+"/User/Signup", "application/json", bytes.NewReader(body))
+  if err != nil {
+    t.Fatalf("FAILED: Failed to Signup: %v", err)
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusCreated {
+    t.Fatalf("FAILED: Signup Got status %d Want %d", resp.StatusCode, http.StatusCreated)
+  }
+
+  var accessToken token.Token
+  if err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&accessToken); err != nil {
+    t.Fatalf("FAILED: Failed to decode Access Token: %v", err)
+  }
+  user, err := database.GetUserbyUsername(username)
+  if err != nil {
+    t.Fatalf("FAILED: Signed up User not in Database: %v", err)
+  }
+  return user, &accessToken
+}
+
+// readEvent :: Reads from conn until an event other than skip arrives. The Hub
+//    batches whatever's queued up into a single frame, one event per line.
+func readEvent(conn *websocket.Conn, skip string)( []byte, error ){
+  for {
+    _, data, err := conn.ReadMessage()
+    if err != nil {
+      return nil, err
+    }
+    for _, line := range bytes.Split(data, []byte{'\n'}) {
+      var event struct{ Event string `codec:"event"` }
+      if err := codec.NewDecoderBytes(line, &db.JSONHandle).Decode(&event); err != nil || event.Event != skip {
+        return line, nil
+      }
+    }
+  }
+}
+
+// isTimeout :: Whether err is a read deadline passing rather than the connection closing.
+func isTimeout(err error) bool {
+  netErr, ok := err.(net.Error)
+  return ok && netErr.Timeout()
+}
+
+// authedRequest :: Sends a request with the 'Authentication: Bearer' header set.
+func authedRequest(t *testing.T, method, url string, accessToken *token.Token, body []byte) *http.Response {
+  req, err := http.NewRequest(method, url, bytes.NewReader(body))
+  if err != nil {
+    t.Fatalf("FAILED: Failed to create request: %v", err)
+  }
+  req.Header.Set("Authentication", "Bearer "+accessToken.Token)
+  resp, err := http.DefaultClient.Do(req)
+  if err != nil {
+    t.Fatalf("FAILED: %s %s: %v", method, url, err)
+  }
+  return resp
+}
+
+func TestGetChatroomMessages(t *testing.T) {
+  server, database := newTestServer(t)
+  user, accessToken := signup(t, server, database, "scroller")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "scrollback", OwnerID: user.UserID, Public: true }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+  for i := 0; i < 5; i++ {
+    msg := db.Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: user.UserID, Content: "hi" }
+    if err := database.SaveMessage(room.RoomName, &msg); err != nil {
+      t.Fatalf("FAILED: Failed to save Message: %v", err)
+    }
+  }
+
+  getPage := func(query string) *db.MessagePage {
+    resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms/scrollback/messages"+query, accessToken, nil)
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+      t.Fatalf("FAILED: %s Got status %d Want %d", query, resp.StatusCode, http.StatusOK)
+    }
+    var page db.MessagePage
+    if err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&page); err != nil {
+      t.Fatalf("FAILED: Failed to decode MessagePage: %v", err)
+    }
+    return &page
+  }
+
+  t.Run("Scroll back through history", func(t *testing.T){
+    var seen []uint64
+    query := "?limit=2"
+    for pages := 0; pages < 10; pages++ {
+      page := getPage(query)
+      for i := len(page.Messages)-1; i >= 0; i-- {
+        seen = append(seen, page.Messages[i].Seq)
+      }
+      if page.PrevCursor == "" {
+        break
+      }
+      query = "?limit=2&before=" + page.PrevCursor
+    }
+    if len(seen) != 5 || seen[0] != 5 || seen[4] != 1 {
+      t.Errorf("FAILED: Got %v Want [5 4 3 2 1]", seen)
+    }
+  })
+
+  t.Run("Invalid cursor", func(t *testing.T){
+    resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms/scrollback/messages?before=nope", accessToken, nil)
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusBadRequest {
+      t.Errorf("FAILED: Got status %d Want %d", resp.StatusCode, http.StatusBadRequest)
+    }
+  })
+}
+
+func TestSearchMessages(t *testing.T) {
+  server, database := newTestServer(t)
+  searcher, accessToken := signup(t, server, database, "searcher")
+  stranger, _ := signup(t, server, database, "stranger")
+
+  for _, room := range []db.Chatroom{
+    { RoomID: uuid.New(), RoomName: "ours",   OwnerID: searcher.UserID },
+    { RoomID: uuid.New(), RoomName: "theirs", OwnerID: stranger.UserID },
+  } {
+    if err := database.SaveChatroom(&room, false); err != nil {
+      t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+    }
+    msg := db.Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: room.OwnerID, Content: "Deploy is green" }
+    if err := database.SaveMessage(room.RoomName, &msg); err != nil {
+      t.Fatalf("FAILED: Failed to save Message: %v", err)
+    }
+  }
+
+  t.Run("Only Chatrooms the User is a member of", func(t *testing.T){
+    resp := authedRequest(t, http.MethodGet, server.URL+"/search/messages?q=deploy", accessToken, nil)
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+      t.Errorf("FAILED: Got status %d Want %d", resp.StatusCode, http.StatusOK)
+      return
+    }
+    var results db.SearchResults
+    if err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&results); err != nil {
+      t.Errorf("FAILED: Failed to decode SearchResults: %v", err)
+      return
+    }
+    if len(results.Results) != 1 || results.Results[0].Chatroom != "ours" {
+      t.Errorf("FAILED: Got %+v Want a single result from \"ours\"", results.Results)
+    }
+  })
+
+  t.Run("Chatroom the User isn't a member of", func(t *testing.T){
+    resp := authedRequest(t, http.MethodGet, server.URL+"/search/messages?q=deploy&room=theirs", accessToken, nil)
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusUnauthorized {
+      t.Errorf("FAILED: Got status %d Want %d", resp.StatusCode, http.StatusUnauthorized)
+    }
+  })
+
+  t.Run("Deactivated author", func(t *testing.T){
+    ghost := db.User{ UserID: uuid.New(), Username: "ghostwriter", HashedPassword: []byte("hash") }
+    if err := database.SaveUser(ghost, nil); err != nil {
+      t.Fatalf("FAILED: Failed to save User: %v", err)
+    }
+    msg := db.Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: ghost.UserID, Content: "Deploy from the archive" }
+    if err := database.SaveMessage("ours", &msg); err != nil {
+      t.Fatalf("FAILED: Failed to save Message: %v", err)
+    }
+    if err := database.DeactivateUser(ghost.UserID); err != nil {
+      t.Fatalf("FAILED: Failed to deactivate User: %v", err)
+    }
+
+    resp := authedRequest(t, http.MethodGet, server.URL+"/search/messages?q=deploy&from=ghostwriter", accessToken, nil)
+    defer resp.Body.Close()
+    var results db.SearchResults
+    if err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&results); err != nil || resp.StatusCode != http.StatusOK {
+      t.Errorf("FAILED: Got status %d, %v Want %d", resp.StatusCode, err, http.StatusOK)
+      return
+    }
+    if len(results.Results) != 1 || results.Results[0].Message.ID != msg.ID {
+      t.Errorf("FAILED: Got %+v Want the deactivated author's Message", results.Results)
+    }
+  })
+
+  t.Run("Unknown author", func(t *testing.T){
+    resp := authedRequest(t, http.MethodGet, server.URL+"/search/messages?q=deploy&from=nobody", accessToken, nil)
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusNotFound {
+      t.Errorf("FAILED: Got status %d Want %d", resp.StatusCode, http.StatusNotFound)
+    }
+  })
+
+  t.Run("Missing query", func(t *testing.T){
+    resp := authedRequest(t, http.MethodGet, server.URL+"/search/messages", accessToken, nil)
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusBadRequest {
+      t.Errorf("FAILED: Got status %d Want %d", resp.StatusCode, http.StatusBadRequest)
+    }
+  })
+}
+
+func TestEditAndDeleteMessages(t *testing.T) {
+  server, database := newTestServer(t)
+  author, authorToken := signup(t, server, database, "author")
+  bystander, bystanderToken := signup(t, server, database, "bystander")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "edits", OwnerID: author.UserID, Public: true }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+  if err := database.SaveChatroomMember(room.RoomName, bystander.UserID, db.Member); err != nil {
+    t.Fatalf("FAILED: Failed to add Member: %v", err)
+  }
+  msg := db.Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: author.UserID, Content: "tpyo" }
+  if err := database.SaveMessage(room.RoomName, &msg); err != nil {
+    t.Fatalf("FAILED: Failed to save Message: %v", err)
+  }
+  url := server.URL + "/chatrooms/edits/messages/" + msg.ID.String()
+  edit := []byte(`{"content": "typo"}`)
+
+  cases := []struct{
+    name        string
+    method      string
+    accessToken *token.Token
+    body        []byte
+    want        int
+  }{
+    { "Only the author may edit", http.MethodPatch,  bystanderToken, edit,                   http.StatusForbidden  },
+    { "Empty content",            http.MethodPatch,  authorToken,    []byte(`{"content":""}`), http.StatusBadRequest },
+    { "Author edits",             http.MethodPatch,  authorToken,    edit,                   http.StatusOK         },
+    { "Author deletes",           http.MethodDelete, authorToken,    nil,                    http.StatusOK         },
+    { "Can't edit a tombstone",   http.MethodPatch,  authorToken,    edit,                   http.StatusConflict   },
+  }
+  for _, tc := range cases {
+    resp := authedRequest(t, tc.method, url, tc.accessToken, tc.body)
+    resp.Body.Close()
+    if resp.StatusCode != tc.want {
+      t.Errorf("FAILED: %s: Got status %d Want %d", tc.name, resp.StatusCode, tc.want)
+    }
+  }
+
+  got, err := database.GetMessage(room.RoomName, msg.ID)
+  if err != nil || !got.IsDeleted() {
+    t.Errorf("FAILED: Got %+v, %v Want a tombstone", got, err)
+  }
+}
+
+func TestGetMessageThread(t *testing.T) {
+  server, database := newTestServer(t)
+  user, accessToken := signup(t, server, database, "threader")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "threads", OwnerID: user.UserID, Public: true }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+  root := db.Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: user.UserID, Content: "root" }
+  if err := database.SaveMessage(room.RoomName, &root); err != nil {
+    t.Fatalf("FAILED: Failed to save Message: %v", err)
+  }
+  reply := db.Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: user.UserID, Content: "reply", ReplyTo: root.ID }
+  if err := database.SaveMessage(room.RoomName, &reply); err != nil {
+    t.Fatalf("FAILED: Failed to save reply: %v", err)
+  }
+
+  // Asking for the thread of a reply returns the whole thread.
+  for _, id := range []uuid.UUID{ root.ID, reply.ID } {
+    resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms/threads/messages/"+id.String()+"/thread", accessToken, nil)
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+      t.Errorf("FAILED: Got status %d Want %d", resp.StatusCode, http.StatusOK)
+      continue
+    }
+    var thread db.ThreadPage
+    if err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&thread); err != nil {
+      t.Errorf("FAILED: Failed to decode ThreadPage: %v", err)
+      continue
+    }
+    if thread.Root.ID != root.ID || len(thread.Replies.Messages) != 1 || thread.Replies.Messages[0].ThreadID != root.ID {
+      t.Errorf("FAILED: Got %+v Want \"root\" with a single reply", thread)
+    }
+  }
+}
+
+func TestUpdateReaction(t *testing.T) {
+  server, database := newTestServer(t)
+  user, accessToken := signup(t, server, database, "reactor")
+  _, outsiderToken := signup(t, server, database, "outsider")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "reactions", OwnerID: user.UserID, Public: true }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+  msg := db.Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: user.UserID, Content: "lgtm?" }
+  if err := database.SaveMessage(room.RoomName, &msg); err != nil {
+    t.Fatalf("FAILED: Failed to save Message: %v", err)
+  }
+  url := server.URL + "/chatrooms/reactions/messages/" + msg.ID.String() + "/reactions/"
+
+  cases := []struct{
+    name        string
+    method      string
+    accessToken *token.Token
+    reaction    string
+    want        int
+    wantCount   int
+  }{
+    { "Add",                 http.MethodPost,   accessToken,   "+1",          http.StatusOK,           1 },
+    { "Not a member",        http.MethodPost,   outsiderToken, "+1",          http.StatusUnauthorized, 1 },
+    { "Too long",            http.MethodPost,   accessToken,   strings.Repeat("a", db.MaxReactionLength+1), http.StatusBadRequest, 1 },
+    { "Remove",              http.MethodDelete, accessToken,   "+1",          http.StatusOK,           0 },
+  }
+  for _, tc := range cases {
+    resp := authedRequest(t, tc.method, url+tc.reaction, tc.accessToken, nil)
+    resp.Body.Close()
+    if resp.StatusCode != tc.want {
+      t.Errorf("FAILED: %s: Got status %d Want %d", tc.name, resp.StatusCode, tc.want)
+    }
+    got, _ := database.GetMessage(room.RoomName, msg.ID)
+    if got.ReactionCount("+1") != tc.wantCount {
+      t.Errorf("FAILED: %s: Got %d \"+1\" Reactions Want %d", tc.name, got.ReactionCount("+1"), tc.wantCount)
+    }
+  }
+}
+
+func TestReadMarkers(t *testing.T) {
+  server, database := newTestServer(t)
+  user, accessToken := signup(t, server, database, "sidebar")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "badges", OwnerID: user.UserID, Public: true }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+  for i := 0; i < 3; i++ {
+    msg := db.Message{ ID: uuid.New(), TimeStamp: time.Now(), UserID: user.UserID, Content: "hi" }
+    if err := database.SaveMessage(room.RoomName, &msg); err != nil {
+      t.Fatalf("FAILED: Failed to save Message: %v", err)
+    }
+  }
+
+  getUnread := func() uint64 {
+    resp := authedRequest(t, http.MethodGet, server.URL+"/User/me/chatrooms", accessToken, nil)
+    defer resp.Body.Close()
+    var joined struct{
+      Chatrooms []db.JoinedChatroom `codec:"chatrooms"`
+    }
+    if err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&joined); err != nil {
+      t.Fatalf("FAILED: Failed to decode joined Chatrooms: %v", err)
+    }
+    if len(joined.Chatrooms) != 1 || joined.Chatrooms[0].Chatroom != room.RoomName {
+      t.Fatalf("FAILED: Got %+v Want only \"%s\"", joined.Chatrooms, room.RoomName)
+    }
+    return joined.Chatrooms[0].Unread
+  }
+
+  steps := []struct{
+    name string
+    body []byte
+    want uint64
+  }{
+    { "Read up to seq 1",   []byte(`{"seq": 1}`), 2 },
+    { "Read everything",    nil,                  0 },
+  }
+  for _, step := range steps {
+    resp := authedRequest(t, http.MethodPost, server.URL+"/chatrooms/badges/read", accessToken, step.body)
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+      t.Errorf("FAILED: %s: Got status %d Want %d", step.name, resp.StatusCode, http.StatusOK)
+      continue
+    }
+    if got := getUnread(); got != step.want {
+      t.Errorf("FAILED: %s: Got %d unread Want %d", step.name, got, step.want)
+    }
+  }
+}
+
+func TestDirectChatrooms(t *testing.T) {
+  server, database := newTestServer(t)
+  alice, aliceToken := signup(t, server, database, "alice")
+  _, bobToken := signup(t, server, database, "bob")
+  _, carolToken := signup(t, server, database, "carol")
+
+  open := func(accessToken *token.Token, username string, want int) *db.Chatroom {
+    resp := authedRequest(t, http.MethodPost, server.URL+"/dm/"+username, accessToken, nil)
+    defer resp.Body.Close()
+    if resp.StatusCode != want {
+      t.Fatalf("FAILED: /dm/%s Got status %d Want %d", username, resp.StatusCode, want)
+    }
+    if want != http.StatusOK {
+      return nil
+    }
+    var room db.Chatroom
+    if err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&room); err != nil {
+      t.Fatalf("FAILED: Failed to decode Chatroom: %v", err)
+    }
+    return &room
+  }
+
+  dm := open(aliceToken, "bob", http.StatusOK)
+  if again := open(bobToken, "alice", http.StatusOK); again.RoomID != dm.RoomID {
+    t.Errorf("FAILED: Got %+v Want the same Direct Chatroom %+v", again, dm)
+  }
+  open(aliceToken, "alice", http.StatusBadRequest)
+  open(aliceToken, "nobody", http.StatusNotFound)
+
+  resp := authedRequest(t, http.MethodGet, server.URL+"/dm", bobToken, nil)
+  defer resp.Body.Close()
+  var list struct{
+    DirectChatrooms []struct{
+      Chatroom string    `codec:"chatroom"`
+      PeerID   uuid.UUID `codec:"peer_id"`
+      PeerName string    `codec:"peer_name"`
+    } `codec:"direct_chatrooms"`
+  }
+  if err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&list); err != nil {
+    t.Fatalf("FAILED: Failed to decode Direct Chatrooms: %v", err)
+  }
+  if len(list.DirectChatrooms) != 1 || list.DirectChatrooms[0].PeerID != alice.UserID || list.DirectChatrooms[0].PeerName != "alice" {
+    t.Errorf("FAILED: Got %+v Want only the Direct Chatroom with alice", list.DirectChatrooms)
+  }
+
+  for _, tc := range []struct{
+    name        string
+    accessToken *token.Token
+    want        int
+  }{
+    { "Member",   bobToken,   http.StatusOK },
+    { "Outsider", carolToken, http.StatusUnauthorized },
+  }{
+    resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms/"+dm.RoomName+"/messages", tc.accessToken, nil)
+    resp.Body.Close()
+    if resp.StatusCode != tc.want {
+      t.Errorf("FAILED: %s Got status %d Want %d", tc.name, resp.StatusCode, tc.want)
+    }
+  }
+}
+
+func TestListPublicChatrooms(t *testing.T) {
+  server, database := newTestServer(t)
+  user, accessToken := signup(t, server, database, "browser")
+  _, _ = signup(t, server, database, "visitor")
+
+  for _, room := range []db.Chatroom{
+    { RoomID: uuid.New(), RoomName: "loungeA", OwnerID: user.UserID, Public: true },
+    { RoomID: uuid.New(), RoomName: "loungeB", OwnerID: user.UserID, Public: true },
+    { RoomID: uuid.New(), RoomName: "library", OwnerID: user.UserID, Public: true },
+    { RoomID: uuid.New(), RoomName: "loungeSecret", OwnerID: user.UserID },
+  } {
+    if err := database.SaveChatroom(&room, false); err != nil {
+      t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+    }
+  }
+  if err := database.JoinChatroom("loungeB", "visitor"); err != nil {
+    t.Fatalf("FAILED: Failed to join Chatroom: %v", err)
+  }
+  if err := database.UpdateChatroomUserStatus("loungeB", "visitor", db.Online); err != nil {
+    t.Fatalf("FAILED: Failed to update live status: %v", err)
+  }
+
+  list := func(query string, want int) *db.DirectoryPage {
+    resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms"+query, accessToken, nil)
+    defer resp.Body.Close()
+    if resp.StatusCode != want {
+      t.Fatalf("FAILED: %s Got status %d Want %d", query, resp.StatusCode, want)
+    }
+    if want != http.StatusOK {
+      return nil
+    }
+    var page db.DirectoryPage
+    if err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&page); err != nil {
+      t.Fatalf("FAILED: Failed to decode DirectoryPage: %v", err)
+    }
+    return &page
+  }
+
+  first := list("?q=LOUNGE&sort=members&limit=1", http.StatusOK)
+  if len(first.Chatrooms) != 1 || first.NextCursor == "" {
+    t.Fatalf("FAILED: Got %+v Want a single entry and a NextCursor", first)
+  }
+  if got := first.Chatrooms[0]; got.RoomName != "loungeB" || got.MemberCount != 2 || got.OnlineCount != 1 {
+    t.Errorf("FAILED: Got %+v Want loungeB with 2 members, 1 online", got)
+  }
+  second := list("?q=lounge&sort=members&limit=1&cursor="+first.NextCursor, http.StatusOK)
+  if len(second.Chatrooms) != 1 || second.Chatrooms[0].RoomName != "loungeA" || second.NextCursor != "" {
+    t.Errorf("FAILED: Got %+v Want only loungeA, and no more pages", second)
+  }
+
+  list("?sort=popularity", http.StatusBadRequest)
+  list("?cursor=garbage", http.StatusBadRequest)
+}
+
+func TestInvitations(t *testing.T) {
+  server, database := newTestServer(t)
+  owner, ownerToken := signup(t, server, database, "keeper")
+  guest, guestToken := signup(t, server, database, "guest")
+  _, thirdToken := signup(t, server, database, "third")
+
+  for _, room := range []db.Chatroom{
+    { RoomID: uuid.New(), RoomName: "vault01", OwnerID: owner.UserID },
+    { RoomID: uuid.New(), RoomName: "plaza01", OwnerID: owner.UserID, Public: true },
+  } {
+    if err := database.SaveChatroom(&room, false); err != nil {
+      t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+    }
+  }
+
+  steps := []struct{
+    name        string
+    method, url string
+    accessToken *token.Token
+    body        string
+    want        int
+  }{
+    { "Join without an Invitation", http.MethodGet,    "/chatrooms/vault01/join",         guestToken, "", http.StatusBadRequest },
+    { "Public Chatrooms",           http.MethodPost,   "/chatrooms/plaza01/invite",       ownerToken, `{"username": "guest"}`, http.StatusBadRequest },
+    { "Unknown User",               http.MethodPost,   "/chatrooms/vault01/invite",       ownerToken, `{"username": "nobody"}`, http.StatusNotFound },
+    { "Invite guest",               http.MethodPost,   "/chatrooms/vault01/invite",       ownerToken, `{"username": "guest", "expires_in": 60}`, http.StatusCreated },
+    { "Join with the Invitation",   http.MethodGet,    "/chatrooms/vault01/join",         guestToken, "", http.StatusOK },
+    { "Already a Member",           http.MethodPost,   "/chatrooms/vault01/invite",       ownerToken, `{"username": "guest"}`, http.StatusConflict },
+    { "Members can't invite",       http.MethodPost,   "/chatrooms/vault01/invite",       guestToken, `{"username": "third"}`, http.StatusForbidden },
+    { "Invite third",               http.MethodPost,   "/chatrooms/vault01/invite",       ownerToken, `{"username": "third"}`, http.StatusCreated },
+    { "Others can't revoke",        http.MethodDelete, "/chatrooms/vault01/invite/third", guestToken, "", http.StatusForbidden },
+    { "Inviter revokes",            http.MethodDelete, "/chatrooms/vault01/invite/third", ownerToken, "", http.StatusOK },
+    { "Revoked Invitation",         http.MethodGet,    "/chatrooms/vault01/join",         thirdToken, "", http.StatusBadRequest },
+  }
+  for _, step := range steps {
+    resp := authedRequest(t, step.method, server.URL+step.url, step.accessToken, []byte(step.body))
+    resp.Body.Close()
+    if resp.StatusCode != step.want {
+      t.Errorf("FAILED: %s: Got status %d Want %d", step.name, resp.StatusCode, step.want)
+    }
+    if step.name == "Invite guest" {
+      resp := authedRequest(t, http.MethodGet, server.URL+"/User/me/invitations", guestToken, nil)
+      var pending struct{
+        Invitations []db.Invitation `codec:"invitations"`
+      }
+      err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&pending)
+      resp.Body.Close()
+      if err != nil || len(pending.Invitations) != 1 || pending.Invitations[0].Chatroom != "vault01" || pending.Invitations[0].InvitedBy != owner.UserID {
+        t.Errorf("FAILED: Got %+v, %v Want a single Invitation to vault01", pending.Invitations, err)
+      }
+    }
+  }
+
+  if status, err := database.GetChatroomMemberStatus("vault01", guest.UserID); err != nil || *status != db.Member {
+    t.Errorf("FAILED: Got %v, %v Want guest to be a Member", status, err)
+  }
+}
+
+func TestInviteLinks(t *testing.T) {
+  server, database := newTestServer(t)
+  owner, ownerToken := signup(t, server, database, "host")
+  contractor, contractorToken := signup(t, server, database, "contractor")
+  _, lateToken := signup(t, server, database, "latecomer")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "project01", OwnerID: owner.UserID }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+
+  resp := authedRequest(t, http.MethodPost, server.URL+"/chatrooms/project01/invites", contractorToken, nil)
+  resp.Body.Close()
+  if resp.StatusCode != http.StatusForbidden {
+    t.Errorf("FAILED: Non-Members Got status %d Want %d", resp.StatusCode, http.StatusForbidden)
+  }
+
+  resp = authedRequest(t, http.MethodPost, server.URL+"/chatrooms/project01/invites", ownerToken, []byte(`{"max_uses": 1}`))
+  var link db.InviteLink
+  err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&link)
+  resp.Body.Close()
+  if resp.StatusCode != http.StatusCreated || err != nil || link.Code == "" || link.MaxUses != 1 {
+    t.Fatalf("FAILED: Got %d %+v, %v Want a new single use InviteLink", resp.StatusCode, link, err)
+  }
+
+  resp = authedRequest(t, http.MethodGet, server.URL+"/invites/"+link.Code, contractorToken, nil)
+  var preview struct{
+    Chatroom db.DirectoryEntry `codec:"chatroom"`
+  }
+  err = codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&preview)
+  resp.Body.Close()
+  if err != nil || preview.Chatroom.RoomName != "project01" || preview.Chatroom.MemberCount != 1 {
+    t.Errorf("FAILED: Got %+v, %v Want a preview of project01", preview, err)
+  }
+
+  steps := []struct{
+    name        string
+    method, url string
+    accessToken *token.Token
+    want        int
+  }{
+    { "Unknown code",      http.MethodGet,  "/invites/nosuchcode",            contractorToken, http.StatusNotFound },
+    { "Members keep uses", http.MethodPost, "/invites/"+link.Code+"/accept", ownerToken,      http.StatusConflict },
+    { "Redeem",            http.MethodPost, "/invites/"+link.Code+"/accept", contractorToken, http.StatusOK },
+    { "Used up",           http.MethodPost, "/invites/"+link.Code+"/accept", lateToken,       http.StatusGone },
+    { "Used up preview",   http.MethodGet,  "/invites/"+link.Code,           lateToken,       http.StatusGone },
+  }
+  for _, step := range steps {
+    resp := authedRequest(t, step.method, server.URL+step.url, step.accessToken, nil)
+    resp.Body.Close()
+    if resp.StatusCode != step.want {
+      t.Errorf("FAILED: %s: Got status %d Want %d", step.name, resp.StatusCode, step.want)
+    }
+  }
+
+  if status, err := database.GetChatroomMemberStatus("project01", contractor.UserID); err != nil || *status != db.Member {
+    t.Errorf("FAILED: Got %v, %v Want contractor to be a Member", status, err)
+  }
+}
+
+func TestModeration(t *testing.T) {
+  server, database := newTestServer(t)
+  owner, ownerToken := signup(t, server, database, "warden")
+  _, modToken := signup(t, server, database, "deputy")
+  troll, trollToken := signup(t, server, database, "troll")
+  _, bystanderToken := signup(t, server, database, "bystander")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "arena01", OwnerID: owner.UserID, Public: true }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+  for _, accessToken := range []*token.Token{ modToken, trollToken, bystanderToken } {
+    resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms/arena01/join", accessToken, nil)
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+      t.Fatalf("FAILED: Join Got status %d Want %d", resp.StatusCode, http.StatusOK)
+    }
+  }
+
+  header := http.Header{}
+  header.Set("Authentication", "Bearer "+trollToken.Token)
+  conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/chatrooms/arena01/ws", header)
+  if err != nil {
+    t.Fatalf("FAILED: Failed to connect to Chatroom: %v", err)
+  }
+  defer conn.Close()
+
+  steps := []struct{
+    name        string
+    method, url string
+    accessToken *token.Token
+    body        string
+    want        int
+  }{
+    { "Members can't moderate",        http.MethodPut,    "/chatrooms/arena01/members/troll",     bystanderToken, `{"role": "blocked"}`,   http.StatusForbidden },
+    { "Unknown role",                  http.MethodPut,    "/chatrooms/arena01/members/deputy",    ownerToken,     `{"role": "admin"}`,     http.StatusBadRequest },
+    { "Promote deputy",                http.MethodPut,    "/chatrooms/arena01/members/deputy",    ownerToken,     `{"role": "moderator"}`, http.StatusOK },
+    { "Moderators can't touch Owners", http.MethodPut,    "/chatrooms/arena01/members/warden",    modToken,       `{"role": "member"}`,    http.StatusForbidden },
+    { "Moderators can't kick Owners",  http.MethodDelete, "/chatrooms/arena01/members/warden",    modToken,       "",                      http.StatusForbidden },
+    { "Moderators can't promote",      http.MethodPut,    "/chatrooms/arena01/members/bystander", modToken,       `{"role": "moderator"}`, http.StatusForbidden },
+    { "Not yourself",                  http.MethodPut,    "/chatrooms/arena01/members/deputy",    modToken,       `{"role": "owner"}`,     http.StatusBadRequest },
+    { "Ban troll",                     http.MethodPut,    "/chatrooms/arena01/members/troll",     modToken,       `{"role": "blocked"}`,   http.StatusOK },
+    { "Banned can't rejoin",           http.MethodGet,    "/chatrooms/arena01/join",              trollToken,     "",                      http.StatusUnauthorized },
+    { "Kick bystander",                http.MethodDelete, "/chatrooms/arena01/members/bystander", modToken,       "",                      http.StatusOK },
+    { "Not a Member",                  http.MethodDelete, "/chatrooms/arena01/members/bystander", modToken,       "",                      http.StatusNotFound },
+    { "Kicked can rejoin",             http.MethodGet,    "/chatrooms/arena01/join",              bystanderToken, "",                      http.StatusOK },
+  }
+  for _, step := range steps {
+    resp := authedRequest(t, step.method, server.URL+step.url, step.accessToken, []byte(step.body))
+    resp.Body.Close()
+    if resp.StatusCode != step.want {
+      t.Errorf("FAILED: %s: Got status %d Want %d", step.name, resp.StatusCode, step.want)
+    }
+  }
+
+  conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+  if _, err := readEvent(conn, db.PresenceChanged); err == nil {
+    t.Errorf("FAILED: Banned User's connection is still open")
+  } else if _, ok := err.(*websocket.CloseError); !ok {
+    t.Errorf("FAILED: Got %v Want the Hub to close the connection", err)
+  }
+  if status, err := database.GetChatroomMemberStatus("arena01", troll.UserID); err != nil || *status != db.Blocked {
+    t.Errorf("FAILED: Got %v, %v Want troll to be Blocked", status, err)
+  }
+}
+
+func TestChatroomRoles(t *testing.T) {
+  server, database := newTestServer(t)
+  owner, ownerToken := signup(t, server, database, "founder")
+  _, modToken := signup(t, server, database, "steward")
+  _, memberToken := signup(t, server, database, "regular")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "guild01", OwnerID: owner.UserID, Public: true }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+  for _, accessToken := range []*token.Token{ modToken, memberToken } {
+    resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms/guild01/join", accessToken, nil)
+    resp.Body.Close()
+  }
+
+  recruiter := fmt.Sprintf(`{"permissions": %d, "rank": 50}`, db.PermPost|db.PermInvite)
+  steps := []struct{
+    name        string
+    method, url string
+    accessToken *token.Token
+    body        string
+    want        int
+  }{
+    { "Promote steward",             http.MethodPut,    "/chatrooms/guild01/members/steward", ownerToken,  `{"role": "moderator"}`,            http.StatusOK },
+    { "Members can't manage Roles",  http.MethodPut,    "/chatrooms/guild01/roles/recruiter", memberToken, recruiter,                          http.StatusForbidden },
+    { "Moderators can't either",     http.MethodPut,    "/chatrooms/guild01/roles/recruiter", modToken,    recruiter,                          http.StatusForbidden },
+    { "The Owner's Role is fixed",   http.MethodPut,    "/chatrooms/guild01/roles/owner",     ownerToken,  `{"permissions": 1, "rank": 1}`,    http.StatusBadRequest },
+    { "Unknown Permissions",         http.MethodPut,    "/chatrooms/guild01/roles/recruiter", ownerToken,  `{"permissions": 256, "rank": 50}`, http.StatusBadRequest },
+    { "Create recruiter",            http.MethodPut,    "/chatrooms/guild01/roles/recruiter", ownerToken,  recruiter,                          http.StatusOK },
+    { "Members can't invite",        http.MethodPost,   "/chatrooms/guild01/invites",         memberToken, "",                                 http.StatusForbidden },
+    { "Hand out recruiter",          http.MethodPut,    "/chatrooms/guild01/members/regular", modToken,    `{"role": "recruiter"}`,            http.StatusOK },
+    { "Recruiters can invite",       http.MethodPost,   "/chatrooms/guild01/invites",         memberToken, "",                                 http.StatusCreated },
+    { "Unknown Role",                http.MethodPut,    "/chatrooms/guild01/members/regular", ownerToken,  `{"role": "admiral"}`,              http.StatusBadRequest },
+    { "Default Roles stay",          http.MethodDelete, "/chatrooms/guild01/roles/member",    ownerToken,  "",                                 http.StatusBadRequest },
+    { "Delete recruiter",            http.MethodDelete, "/chatrooms/guild01/roles/recruiter", ownerToken,  "",                                 http.StatusOK },
+    { "Deleted Role",                http.MethodDelete, "/chatrooms/guild01/roles/recruiter", ownerToken,  "",                                 http.StatusNotFound },
+    { "Back to a plain Member",      http.MethodPost,   "/chatrooms/guild01/invites",         memberToken, "",                                 http.StatusForbidden },
+    { "Moderators can't deactivate", http.MethodDelete, "/chatrooms/guild01",                 modToken,    "",                                 http.StatusForbidden },
+  }
+  for _, step := range steps {
+    resp := authedRequest(t, step.method, server.URL+step.url, step.accessToken, []byte(step.body))
+    resp.Body.Close()
+    if resp.StatusCode != step.want {
+      t.Errorf("FAILED: %s: Got status %d Want %d", step.name, resp.StatusCode, step.want)
+    }
+  }
+
+  resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms/guild01/roles", memberToken, nil)
+  var listed struct{
+    Roles []db.Role `codec:"roles"`
+  }
+  err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&listed)
+  resp.Body.Close()
+  if err != nil || len(listed.Roles) != 3 || listed.Roles[0].Name != db.OwnerRole {
+    t.Errorf("FAILED: Got %+v, %v Want the 3 default Roles, Owner first", listed.Roles, err)
+  }
+}
+
+func TestChatroomSettings(t *testing.T) {
+  server, database := newTestServer(t)
+  _, ownerToken := signup(t, server, database, "curator")
+  _, memberToken := signup(t, server, database, "visitor")
+  _, stragglerToken := signup(t, server, database, "straggler")
+
+  resp := authedRequest(t, http.MethodPost, server.URL+"/chatrooms", ownerToken, []byte(`{"room_name": "lounge01", "public": true, "topic": "welcome"}`))
+  var created db.Chatroom
+  err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&created)
+  resp.Body.Close()
+  if resp.StatusCode != http.StatusOK || err != nil || created.Topic != "welcome" {
+    t.Fatalf("FAILED: Got status %d, %+v, %v Want the created Chatroom", resp.StatusCode, created, err)
+  }
+  resp = authedRequest(t, http.MethodGet, server.URL+"/chatrooms/lounge01/join", memberToken, nil)
+  resp.Body.Close()
+
+  header := http.Header{}
+  header.Set("Authentication", "Bearer "+memberToken.Token)
+  conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/chatrooms/lounge01/ws", header)
+  if err != nil {
+    t.Fatalf("FAILED: Failed to connect to Chatroom: %v", err)
+  }
+  defer conn.Close()
+
+  steps := []struct{
+    name        string
+    method, url string
+    accessToken *token.Token
+    body        string
+    want        int
+  }{
+    { "Members can't change settings", http.MethodPut, "/chatrooms/lounge01",      memberToken,    `{"topic": "mine now"}`,                                          http.StatusForbidden },
+    { "Invalid slow mode",             http.MethodPut, "/chatrooms/lounge01",      ownerToken,     `{"slow_mode": -5}`,                                              http.StatusBadRequest },
+    { "Unknown Chatroom",              http.MethodPut, "/chatrooms/nosuchroom",    ownerToken,     `{"topic": "hello"}`,                                             http.StatusNotFound },
+    { "Update settings",               http.MethodPut, "/chatrooms/lounge01",      ownerToken,     `{"topic": "quiet please", "max_members": 2, "read_only": true}`, http.StatusOK },
+    { "Full Chatroom",                 http.MethodGet, "/chatrooms/lounge01/join", stragglerToken, "",                                                               http.StatusConflict },
+  }
+  for _, step := range steps {
+    resp := authedRequest(t, step.method, server.URL+step.url, step.accessToken, []byte(step.body))
+    resp.Body.Close()
+    if resp.StatusCode != step.want {
+      t.Errorf("FAILED: %s: Got status %d Want %d", step.name, resp.StatusCode, step.want)
+    }
+  }
+
+  conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+  data, err := readEvent(conn, db.PresenceChanged)
+  if err != nil {
+    t.Fatalf("FAILED: Failed to read settings event: %v", err)
+  }
+  var event db.ChatroomEvent
+  if err := codec.NewDecoderBytes(data, &db.JSONHandle).Decode(&event); err != nil {
+    t.Fatalf("FAILED: Failed to decode settings event: %v", err)
+  }
+  if event.Event != db.ChatroomUpdated || event.Settings.Topic != "quiet please" || !event.Settings.ReadOnly || event.Settings.Description != "" {
+    t.Errorf("FAILED: Got %+v Want a %s event with the new settings", event, db.ChatroomUpdated)
+  }
+}
+
+func TestSlowMode(t *testing.T) {
+  server, database := newTestServer(t)
+  owner, ownerToken := signup(t, server, database, "snail")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "garden01", OwnerID: owner.UserID, Public: true }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+  resp := authedRequest(t, http.MethodPut, server.URL+"/chatrooms/garden01", ownerToken, []byte(`{"slow_mode": 60}`))
+  resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    t.Fatalf("FAILED: Slow mode Got status %d Want %d", resp.StatusCode, http.StatusOK)
+  }
+
+  header := http.Header{}
+  header.Set("Authentication", "Bearer "+ownerToken.Token)
+  conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/chatrooms/garden01/ws", header)
+  if err != nil {
+    t.Fatalf("FAILED: Failed to connect to Chatroom: %v", err)
+  }
+  defer conn.Close()
+
+  for _, content := range []string{ "first", "too soon" } {
+    if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"message": {"content": "`+content+`"}}`)); err != nil {
+      t.Fatalf("FAILED: Failed to write to Chatroom: %v", err)
+    }
+  }
+
+  conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+  for rejected := false; !rejected; {
+    _, data, err := conn.ReadMessage()
+    if err != nil {
+      t.Fatalf("FAILED: Never told the Message was rejected: %v", err)
+    }
+    for _, line := range bytes.Split(data, []byte{'\n'}) {
+      var event db.RejectedEvent
+      if err := codec.NewDecoderBytes(line, &db.JSONHandle).Decode(&event); err != nil {
+        t.Fatalf("FAILED: Failed to decode event: %v", err)
+      }
+      if event.Event == db.MessageRejected {
+        rejected = event.Chatroom == "garden01" && event.Reason != ""
+        if !rejected {
+          t.Fatalf("FAILED: Got %+v Want a reason for the rejection", event)
+        }
+      }
+    }
+  }
+
+  page, err := database.Paginate("garden01", 0, 0, db.MaxPageSize)
+  if err != nil || len(page.Messages) != 1 || page.Messages[0].Content != "first" || page.Messages[0].UserID != owner.UserID {
+    t.Errorf("FAILED: Got %+v, %v Want only the first Message stored", page, err)
+  }
+}
+
+func TestBackupDatabase(t *testing.T) {
+  dir := t.TempDir()
+  database, err := db.NewDatabase(filepath.Join(dir, "chatatui_admin.db"))
+  if err != nil {
+    t.Fatalf("FAILED: Failed to open Database: %v", err)
+  }
+  defer database.Close()
+  router := NewRouter(database, ws.NewHub())
+  router.SetAdmins("sysop")
+  server := httptest.NewServer(router.SetupRouter())
+  defer server.Close()
+
+  _, adminToken := signup(t, server, database, "sysop")
+  _, userToken := signup(t, server, database, "lurker")
+
+  resp := authedRequest(t, http.MethodGet, server.URL+"/admin/backup", userToken, nil)
+  resp.Body.Close()
+  if resp.StatusCode != http.StatusForbidden {
+    t.Errorf("FAILED: Non admin Got status %d Want %d", resp.StatusCode, http.StatusForbidden)
+  }
+
+  resp = authedRequest(t, http.MethodGet, server.URL+"/admin/backup", adminToken, nil)
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    t.Fatalf("FAILED: Admin Got status %d Want %d", resp.StatusCode, http.StatusOK)
+  }
+  snapshot := filepath.Join(dir, "snapshot.db")
+  file, _ := os.Create(snapshot)
+  io.Copy(file, resp.Body)
+  file.Close()
+  if err := db.ValidateSnapshot(snapshot); err != nil {
+    t.Errorf("FAILED: Got %v Want a valid snapshot", err)
+  }
+}
+
+func TestExportChatroom(t *testing.T) {
+  server, database := newTestServer(t)
+  user, accessToken := signup(t, server, database, "historian")
+  _, outsiderToken := signup(t, server, database, "stranger")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "annals", OwnerID: user.UserID, Public: true }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+  start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+  for i, content := range []string{ "one", "two", "three" } {
+    msg := db.Message{ ID: uuid.New(), TimeStamp: start.Add(time.Duration(i) * time.Hour), UserID: user.UserID, Content: content }
+    if err := database.SaveMessage(room.RoomName, &msg); err != nil {
+      t.Fatalf("FAILED: Failed to save Message: %v", err)
+    }
+  }
+
+  steps := []struct{
+    name        string
+    query       string
+    accessToken *token.Token
+    want        int
+    contains    string
+  }{
+    { "Not a Member",   "",                                      outsiderToken, http.StatusUnauthorized, "" },
+    { "Unknown format", "?format=pdf",                           accessToken,   http.StatusBadRequest,   "" },
+    { "Invalid from",   "?from=yesterday",                       accessToken,   http.StatusBadRequest,   "" },
+    { "NDJSON",         "",                                      accessToken,   http.StatusOK,           `"username":"historian"` },
+    { "JSON",           "?format=json&to=2024-01-01T13:00:00Z",  accessToken,   http.StatusOK,           `{"chatroom":"annals","messages":[` },
+    { "Text",           "?format=txt&from=2024-01-01T14:00:00Z", accessToken,   http.StatusOK,           "[2024-01-01 14:00:00] <historian> three\n" },
+    { "Markdown",       "?format=md",                            accessToken,   http.StatusOK,           "# annals\n\n**historian**" },
+  }
+  for _, step := range steps {
+    resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms/annals/export"+step.query, step.accessToken, nil)
+    body, _ := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if resp.StatusCode != step.want {
+      t.Errorf("FAILED: %s: Got status %d Want %d", step.name, resp.StatusCode, step.want)
+      continue
+    }
+    if !strings.Contains(string(body), step.contains) {
+      t.Errorf("FAILED: %s: Got %q Want it to contain %q", step.name, body, step.contains)
+    }
+  }
+
+  resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms/annals/export?format=json&to=2024-01-01T13:00:00Z", accessToken, nil)
+  defer resp.Body.Close()
+  var export struct {
+    Messages []db.ExportedMessage `codec:"messages"`
+  }
+  if err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&export); err != nil || len(export.Messages) != 2 {
+    t.Errorf("FAILED: Got %+v, %v Want the first 2 Messages", export, err)
+  }
+}
+
+func TestDeactivateAccount(t *testing.T) {
+  server, database := newTestServer(t)
+  owner, _ := signup(t, server, database, "founder")
+  leaver, leaverToken := signup(t, server, database, "leaver")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "farewell", OwnerID: owner.UserID, Public: true }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+  resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms/farewell/join", leaverToken, nil)
+  resp.Body.Close()
+
+  header := http.Header{}
+  header.Set("Authentication", "Bearer "+leaverToken.Token)
+  conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/chatrooms/farewell/ws", header)
+  if err != nil {
+    t.Fatalf("FAILED: Failed to connect to Chatroom: %v", err)
+  }
+  defer conn.Close()
+
+  resp = authedRequest(t, http.MethodDelete, server.URL+"/User/me", leaverToken, nil)
+  resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    t.Fatalf("FAILED: Deactivate Got status %d Want %d", resp.StatusCode, http.StatusOK)
+  }
+
+  conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+  if _, err := readEvent(conn, db.PresenceChanged); err == nil {
+    t.Errorf("FAILED: Deactivated User's connection is still open")
+  }
+  resp = authedRequest(t, http.MethodGet, server.URL+"/User/me/chatrooms", leaverToken, nil)
+  resp.Body.Close()
+  if resp.StatusCode == http.StatusOK {
+    t.Errorf("FAILED: Deactivated User's Access Token still works")
+  }
+  if _, err := database.GetUserByID(leaver.UserID); err == nil {
+    t.Errorf("FAILED: Deactivated User is still visible")
+  }
+  if status, err := database.GetChatroomMemberStatus("farewell", leaver.UserID); err != nil || *status != db.Member {
+    t.Errorf("FAILED: Got %v, %v Want the membership kept", status, err)
+  }
+
+  steps := []struct{
+    name string
+    path string
+    body string
+    want int
+  }{
+    { "Username stays reserved", "/User/Signup", `{"username": "leaver", "password": "squatter"}`,                     http.StatusConflict },
+    { "Wrong password",          "/User/Signin", `{"username": "leaver", "password": "guess"}`,                        http.StatusUnauthorized },
+    { "Offered reactivation",    "/User/Signin", `{"username": "leaver", "password": "password"}`,                     http.StatusForbidden },
+    { "Reactivate",              "/User/Signin", `{"username": "leaver", "password": "password", "reactivate": true}`, http.StatusCreated },
+    { "Active again",            "/User/Signin", `{"username": "leaver", "password": "password"}`,                     http.StatusCreated },
+    { "Others unaffected",       "/User/Signin", `{"username": "founder", "password": "password"}`,                    http.StatusCreated },
+  }
+  for _, step := range steps {
+    resp, err := http.Post(server.URL+step.path, "application/json", strings.NewReader(step.body))
+    if err != nil {
+      t.Fatalf("FAILED: %s: %v", step.name, err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != step.want {
+      t.Errorf("FAILED: %s: Got status %d Want %d", step.name, resp.StatusCode, step.want)
+    }
+  }
+
+  if user, err := database.GetUserByID(leaver.UserID); err != nil || user.Username != "leaver" {
+    t.Errorf("FAILED: Got %+v, %v Want leaver reactivated", user, err)
+  }
+}
+
+func TestChangePassword(t *testing.T) {
+  server, database := newTestServer(t)
+  user, oldToken := signup(t, server, database, "rotator")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "keyring", OwnerID: user.UserID, Public: true }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+  header := http.Header{}
+  header.Set("Authentication", "Bearer "+oldToken.Token)
+  conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/chatrooms/keyring/ws", header)
+  if err != nil {
+    t.Fatalf("FAILED: Failed to connect to Chatroom: %v", err)
+  }
+  defer conn.Close()
+
+  steps := []struct{
+    name string
+    body string
+    want int
+  }{
+    { "Malformed body",     `{"old_password": `,                                       http.StatusBadRequest },
+    { "Empty new password", `{"old_password": "password", "new_password": ""}`,        http.StatusBadRequest },
+    { "Wrong old password", `{"old_password": "guess", "new_password": "hunter2"}`,    http.StatusUnauthorized },
+    { "Changed",            `{"old_password": "password", "new_password": "hunter2"}`, http.StatusOK },
+  }
+  var newToken token.Token
+  for _, step := range steps {
+    resp := authedRequest(t, http.MethodPost, server.URL+"/User/password", oldToken, []byte(step.body))
+    if resp.StatusCode != step.want {
+      t.Errorf("FAILED: %s: Got status %d Want %d", step.name, resp.StatusCode, step.want)
+    }
+    if resp.StatusCode == http.StatusOK {
+      if err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&newToken); err != nil {
+        t.Errorf("FAILED: Failed to decode Access Token: %v", err)
+      }
+    }
+    resp.Body.Close()
+  }
+
+  conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+  if _, err := readEvent(conn, db.PresenceChanged); err == nil || isTimeout(err) {
+    t.Errorf("FAILED: Connection opened with the old Access Token is still open: %v", err)
+  }
+  resp := authedRequest(t, http.MethodGet, server.URL+"/User/me/chatrooms", oldToken, nil)
+  resp.Body.Close()
+  if resp.StatusCode == http.StatusOK {
+    t.Errorf("FAILED: Old Access Token still works")
+  }
+  resp = authedRequest(t, http.MethodGet, server.URL+"/User/me/chatrooms", &newToken, nil)
+  resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    t.Errorf("FAILED: New Access Token Got status %d Want %d", resp.StatusCode, http.StatusOK)
+  }
+
+  for password, want := range map[string]int{ "password": http.StatusUnauthorized, "hunter2": http.StatusCreated } {
+    body, _ := json.Marshal(map[string]string{ "username": user.Username, "password": password })
+    resp, err := http.Post(server.URL+"/User/Signin", "application/json", bytes.NewReader(body))
+    if err != nil {
+      t.Fatalf("FAILED: Failed to Signin: %v", err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != want {
+      t.Errorf("FAILED: Signin with \"%s\" Got status %d Want %d", password, resp.StatusCode, want)
+    }
+  }
+}
+
+func TestPasswordReset(t *testing.T) {
+  database := db.NewMemoryDatabase()
+  var delivered bytes.Buffer
+  router := NewRouter(database, ws.NewHub())
+  router.SetNotifier(notify.NewWriterNotifier(&delivered))
+  server := httptest.NewServer(router.SetupRouter())
+  defer server.Close()
+
+  user, accessToken := signup(t, server, database, "amnesiac")
+
+  post := func(path, body string) int {
+    resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+    if err != nil {
+      t.Fatalf("FAILED: %s: %v", path, err)
+    }
+    resp.Body.Close()
+    return resp.StatusCode
+  }
+
+  // Unknown Users get the same response, and nothing is delivered.
+  if status := post("/User/password/reset", `{"username": "nobody"}`); status != http.StatusAccepted || delivered.Len() != 0 {
+    t.Errorf("FAILED: Unknown User Got status %d and %q Want %d and nothing delivered", status, delivered.String(), http.StatusAccepted)
+  }
+  if status := post("/User/password/reset", `{"username": "amnesiac"}`); status != http.StatusAccepted {
+    t.Fatalf("FAILED: Request reset Got status %d Want %d", status, http.StatusAccepted)
+  }
+  fields := strings.Fields(delivered.String())
+  resetToken := ""
+  for i, field := range fields {
+    if field == "token" && i+1 < len(fields) {
+      resetToken = strings.TrimSuffix(fields[i+1], ",")
+    }
+  }
+  if !strings.Contains(delivered.String(), `"amnesiac"`) || resetToken == "" {
+    t.Fatalf("FAILED: Got %q Want a reset token delivered for amnesiac", delivered.String())
+  }
+
+  steps := []struct{
+    name string
+    body string
+    want int
+  }{
+    { "Empty new password", fmt.Sprintf(`{"token": "%s", "new_password": ""}`, resetToken),           http.StatusBadRequest },
+    { "Unknown token",      `{"token": "made-up", "new_password": "remembered"}`,                     http.StatusUnauthorized },
+    { "Reset",              fmt.Sprintf(`{"token": "%s", "new_password": "remembered"}`, resetToken), http.StatusOK },
+    { "Used up",            fmt.Sprintf(`{"token": "%s", "new_password": "again"}`, resetToken),      http.StatusUnauthorized },
+  }
+  for _, step := range steps {
+    if status := post("/User/password/reset/confirm", step.body); status != step.want {
+      t.Errorf("FAILED: %s: Got status %d Want %d", step.name, status, step.want)
+    }
+  }
+
+  resp := authedRequest(t, http.MethodGet, server.URL+"/User/me/chatrooms", accessToken, nil)
+  resp.Body.Close()
+  if resp.StatusCode == http.StatusOK {
+    t.Errorf("FAILED: Access Token from before the reset still works")
+  }
+  if status := post("/User/Signin", `{"username": "amnesiac", "password": "password"}`); status != http.StatusUnauthorized {
+    t.Errorf("FAILED: Old password Got status %d Want %d", status, http.StatusUnauthorized)
+  }
+  if status := post("/User/Signin", `{"username": "amnesiac", "password": "remembered"}`); status != http.StatusCreated {
+    t.Errorf("FAILED: New password Got status %d Want %d", status, http.StatusCreated)
+  }
+  if _, err := database.GetUserByID(user.UserID); err != nil {
+    t.Errorf("FAILED: Failed to find reset User: %v", err)
+  }
+}
+
+func TestPasswordResetWithoutNotifier(t *testing.T) {
+  server, _ := newTestServer(t)
+  resp, err := http.Post(server.URL+"/User/password/reset", "application/json", strings.NewReader(`{"username": "anyone"}`))
+  if err != nil {
+    t.Fatalf("FAILED: %v", err)
+  }
+  resp.Body.Close()
+  if resp.StatusCode != http.StatusNotImplemented {
+    t.Errorf("FAILED: Got status %d Want %d", resp.StatusCode, http.StatusNotImplemented)
+  }
+}
+
+type failingNotifier struct{}
+
+func(failingNotifier)NotifyPasswordReset(username, resetToken string, expiresAt time.Time) error {
+  return fmt.Errorf("nowhere to deliver to")
+}
+
+func TestPasswordResetDeliveryFailure(t *testing.T) {
+  database := db.NewMemoryDatabase()
+  router := NewRouter(database, ws.NewHub())
+  router.SetNotifier(failingNotifier{})
+  server := httptest.NewServer(router.SetupRouter())
+  defer server.Close()
+
+  signup(t, server, database, "forgetful")
+  for _, username := range []string{ "forgetful", "nobody" } {
+    resp, err := http.Post(server.URL+"/User/password/reset", "application/json", strings.NewReader(`{"username": "`+username+`"}`))
+    if err != nil {
+      t.Fatalf("FAILED: %v", err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusAccepted {
+      t.Errorf("FAILED: \"%s\" Got status %d Want %d", username, resp.StatusCode, http.StatusAccepted)
+    }
+  }
+}
+
+func TestUserProfile(t *testing.T) {
+  server, database := newTestServer(t)
+  user, accessToken := signup(t, server, database, "artist")
+  _, viewerToken := signup(t, server, database, "viewer")
+
+  avatar := "\x1b[35m(=^.^=)\x1b[0m\n  ||"
+  body, _ := json.Marshal(map[string]string{
+    "display_name": "The Artist",
+    "bio":          "Draws in the terminal.",
+    "timezone":     "Asia/Tokyo",
+    "avatar":       avatar,
+  })
+  steps := []struct{
+    name string
+    body string
+    want int
+  }{
+    { "Malformed body",   `{"bio": `,                       http.StatusBadRequest },
+    { "Unknown timezone", `{"timezone": "Nowhere/Special"}`, http.StatusBadRequest },
+    { "Cursor movement",  `{"avatar": "\u001b[2J"}`,        http.StatusBadRequest },
+    { "Updated",          string(body),                     http.StatusOK },
+    { "Partial update",   `{"company": "Ink Inc."}`,        http.StatusOK },
+  }
+  for _, step := range steps {
+    resp := authedRequest(t, http.MethodPut, server.URL+"/user/me", accessToken, []byte(step.body))
+    resp.Body.Close()
+    if resp.StatusCode != step.want {
+      t.Errorf("FAILED: %s: Got status %d Want %d", step.name, resp.StatusCode, step.want)
+    }
+  }
+
+  for _, path := range []string{ "/user/" + user.UserID.String(), "/user/by-name/artist" } {
+    resp := authedRequest(t, http.MethodGet, server.URL+path, viewerToken, nil)
+    raw, _ := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+      t.Errorf("FAILED: %s Got status %d Want %d", path, resp.StatusCode, http.StatusOK)
+      continue
+    }
+    if strings.Contains(strings.ToLower(string(raw)), "password") {
+      t.Errorf("FAILED: %s leaked the User's password hash: %s", path, raw)
+    }
+    var profile db.Profile
+    if err := codec.NewDecoderBytes(raw, &db.JSONHandle).Decode(&profile); err != nil {
+      t.Errorf("FAILED: Failed to decode Profile: %v", err)
+      continue
+    }
+    if profile.Username != "artist" || profile.DisplayName != "The Artist" || profile.Company != "Ink Inc." || profile.Avatar != avatar || profile.Timezone != "Asia/Tokyo" {
+      t.Errorf("FAILED: %s Got %+v Want artist's Profile", path, profile)
+    }
+  }
+
+  for path, want := range map[string]int{
+    "/user/me":                     http.StatusOK,
+    "/user/not-a-uuid":             http.StatusBadRequest,
+    "/user/" + uuid.New().String(): http.StatusNotFound,
+    "/user/by-name/nobody":         http.StatusNotFound,
+  } {
+    resp := authedRequest(t, http.MethodGet, server.URL+path, viewerToken, nil)
+    resp.Body.Close()
+    if resp.StatusCode != want {
+      t.Errorf("FAILED: %s Got status %d Want %d", path, resp.StatusCode, want)
+    }
+  }
+}
+
+func TestPresence(t *testing.T) {
+  database := db.NewMemoryDatabase()
+  router := NewRouter(database, ws.NewHub())
+  router.SetIdleTimeout(200 * time.Millisecond)
+  server := httptest.NewServer(router.SetupRouter())
+  t.Cleanup(server.Close)
+
+  host, hostToken := signup(t, server, database, "host")
+  guest, guestToken := signup(t, server, database, "guest")
+  _, outsiderToken := signup(t, server, database, "outsider")
+
+  room := db.Chatroom{ RoomID: uuid.New(), RoomName: "porch01", OwnerID: host.UserID, Public: true }
+  if err := database.SaveChatroom(&room, false); err != nil {
+    t.Fatalf("FAILED: Failed to create Chatroom: %v", err)
+  }
+  resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms/porch01/join", guestToken, nil)
+  resp.Body.Close()
+
+  dial := func(accessToken *token.Token) *websocket.Conn {
+    header := http.Header{}
+    header.Set("Authentication", "Bearer "+accessToken.Token)
+    conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/chatrooms/porch01/ws", header)
+    if err != nil {
+      t.Fatalf("FAILED: Failed to connect to Chatroom: %v", err)
+    }
+    t.Cleanup(func(){ conn.Close() })
+    return conn
+  }
+  hostConn := dial(hostToken)
+  // waitFor :: Reads the host's PresenceEvents until userID reaches status.
+  waitFor := func(userID uuid.UUID, status string) {
+    hostConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+    for {
+      _, data, err := hostConn.ReadMessage()
+      if err != nil {
+        t.Fatalf("FAILED: Never saw %s go %s: %v", userID, status, err)
+      }
+      found := false
+      for _, line := range bytes.Split(data, []byte{'\n'}) {
+        var event db.PresenceEvent
+        if err := codec.NewDecoderBytes(line, &db.JSONHandle).Decode(&event); err != nil {
+          t.Fatalf("FAILED: Failed to decode PresenceEvent: %v", err)
+        }
+        if event.Event == db.PresenceChanged && event.Chatroom == "porch01" && event.UserID == userID && event.Status == status {
+          found = true
+        }
+      }
+      if found {
+        return
+      }
+    }
+  }
+  members := func(accessToken *token.Token, want int) map[string]string {
+    resp := authedRequest(t, http.MethodGet, server.URL+"/chatrooms/porch01/members", accessToken, nil)
+    defer resp.Body.Close()
+    if resp.StatusCode != want {
+      t.Fatalf("FAILED: Members Got status %d Want %d", resp.StatusCode, want)
+    }
+    if want != http.StatusOK {
+      return nil
+    }
+    var list struct{ Members []db.MemberStatus `codec:"members"` }
+    if err := codec.NewDecoder(resp.Body, &db.JSONHandle).Decode(&list); err != nil {
+      t.Fatalf("FAILED: Failed to decode Members: %v", err)
+    }
+    statuses := make(map[string]string)
+    for _, member := range list.Members {
+      statuses[member.Username+"/"+member.Role] = member.Status
+    }
+    return statuses
+  }
+
+  waitFor(host.UserID, "Online")
+  if got := members(hostToken, http.StatusOK); len(got) != 2 || got["host/owner"] != "Online" || got["guest/member"] != "Offline" {
+    t.Errorf("FAILED: Got %v Want host Online and guest Offline", got)
+  }
+  members(outsiderToken, http.StatusForbidden)
+
+  guestConn := dial(guestToken)
+  waitFor(guest.UserID, "Online")
+  waitFor(guest.UserID, "Background")
+
+  // Anything sent, even something that isn't a Message, counts as activity.
+  if err := guestConn.WriteMessage(websocket.TextMessage, []byte("still here")); err != nil {
+    t.Fatalf("FAILED: Failed to write to Chatroom: %v", err)
+  }
+  waitFor(guest.UserID, "Online")
+
+  guestConn.Close()
+  waitFor(guest.UserID, "Offline")
+  if got := members(hostToken, http.StatusOK); got["guest/member"] != "Offline" {
+    t.Errorf("FAILED: Got %v Want guest Offline", got)
+  }
+  if statuses, err := database.GetChatroomUserStatus("porch01"); err != nil || statuses["guest"] != "Offline" {
+    t.Errorf("FAILED: Got %v, %v Want guest Offline in the Database", statuses, err)
+  }
+}
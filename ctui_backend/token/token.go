@@ -6,7 +6,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	// "github.com/google/uuid"
+	"github.com/google/uuid"
 )
 
 const TEMPSECRET = "cbb3589b-8035-4ac2-b978-23dd0d0052b5-4d2f599e-0ff7-476c-a7ed-de66b64244f6"
@@ -17,9 +17,12 @@ type Token struct {
   Token string  `codec:"token"`
 }
 
+// CreateToken :: "jti" keeps two Tokens created for the same uid within the same
+//    second apart. Otherwise replacing a User's Token wouldn't always revoke it.
 func CreateToken(uid string)( *Token, error ){
   token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
     "token_id": uid,
+    "jti":      uuid.NewString(),
     "exp":     time.Now().Add(time.Hour * 72).Unix(),
   })
 
@@ -115,7 +118,7 @@ func( userToken *Token )TokenIsExpired()( bool,error ){
     log.Printf(" -> Error: Unknown eroor occurred while retreiving expiration from Claims")
     return false, err
   }
-  return exp.Unix() >= now, nil
+  return exp.Unix() < now, nil
 }
 
 func( userToken *Token )GetTokenID()( string,error ){
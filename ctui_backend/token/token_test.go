@@ -28,7 +28,7 @@ func TestToken(t *testing.T) {
       return
     }
 
-    retreivedID, err := userToken.GetUserID()
+    retreivedID, err := userToken.GetTokenID()
     if err != nil {
       t.Errorf("FAILED: Failed to retreive UserID: %v", err.Error())
       return
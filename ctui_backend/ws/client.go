@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
 )
 
 const (
@@ -31,9 +32,15 @@ var upgrader = websocket.Upgrader{
 // Client => The middleman between the Websocket connection and the Hub.
 type Client struct {
   hub      *Hub
+  chatroom string
+  userID   db.UUID
   conn     *websocket.Conn
   messages chan[]byte // For storing the Messages in the Database
   send     chan[]byte
+
+  // Owned by the Hub's Run.
+  lastActive time.Time
+  idle       bool
 }
 
 
@@ -45,6 +52,7 @@ type Client struct {
 func(c *Client)readPump() {
   defer func() {
 		c.hub.unregister <-c
+    close(c.messages)
     c.conn.Close()
   }()
 
@@ -71,9 +79,12 @@ func(c *Client)readPump() {
     fmt.Println(" -> READING MSG")
     message = bytes.TrimSpace(bytes.Replace(message, newLine, space, -1))
 
-    // Add to Database.
+    // Anything sent brings an idle User back Online.
+    c.hub.active <- c
+
+    // Add to Database. databaseHandler broadcasts the Message once it's been
+    // stored, and has been given it's sequence number.
     c.messages <- message
-    c.hub.broadcast <- message
   }
 }
 
@@ -126,23 +137,55 @@ func(c *Client)writePump() {
 
 func databaseHandler(c *Client, database db.ChatatuiDatabase) {
   for msg := range c.messages {
-    // For every new message, save to ChatatuiDatabase/Messages/{msg-id}.
+    now := time.Now()
+    last, allowed := c.hub.allowPost(c.userID, now)
+    if !allowed {
+      log.Printf(" -> databaseHandler: Dropping Message: slow mode is on")
+      c.reject("Slow mode is on. Wait a little longer between Messages")
+      continue
+    }
+    // For every new message, save to ChatatuiDatabase/Messages/{chatroom}/{seq}.
     // Requires extracting the Chatroom name from the message.
-    if err := database.HandleRawMessage(msg); err != nil {
+    // Who sent the Message, and where, is whoever's connected. Not whatever the
+    // Message claims.
+    stored, err := database.HandleRawMessage(c.chatroom, c.userID, msg)
+    if err != nil {
       switch err.(type){
       case db.BucketNotFoundError:
       case db.DecoderError:
       case db.EncoderError:
       case db.PutDataError:
       }
+      log.Printf(" -> databaseHandler: Dropping Message: %s", err)
+      c.hub.undoPost(c.userID, now, last)
+      continue
     }
+    c.hub.broadcast <- stored
+  }
+}
+
+// reject :: Tells the Client their Message was dropped, and why.
+func(c *Client)reject(reason string) {
+  var data []byte
+  enc := codec.NewEncoderBytes(&data, &db.JSONHandle)
+  if err := enc.Encode(db.RejectedEvent{
+    Event:    db.MessageRejected,
+    Chatroom: c.chatroom,
+    Reason:   reason,
+  }); err != nil {
+    log.Printf(" -> reject: Failed to encode RejectedEvent: %s", err)
+    return
   }
+  c.hub.sendTo(c, data)
 }
 
-// Handle Websocket requests from the Peer
+// Handle Websocket requests from the Peer. userID is connecting to chatroom, and
+// every Message they send is stored as theirs, within chatroom.
 func ServeWs(
   hub *Hub,
   db db.ChatatuiDatabase,
+  chatroom string,
+  userID db.UUID,
   w http.ResponseWriter,
   r *http.Request,
 ){
@@ -153,6 +196,8 @@ func ServeWs(
   }
   client := &Client{
     hub:hub,
+    chatroom:chatroom,
+    userID:userID,
     conn:conn,
     messages: make(chan []byte, 0),
     send: make(chan []byte, 256),
@@ -1,15 +1,52 @@
 package ws
 
+import (
+	"chatatui_backend/db"
+	"sync"
+	"time"
+)
 
 // "cloud.google.com/go/firestore"
 // "github.com/gorilla/websocket"
 // "google.golang.org/genproto/googleapis/firestore/v1"
 
+// DefaultIdleTimeout :: How long a User can go without sending anything before
+//    they're shown as Background rather than Online.
+const DefaultIdleTimeout = 5 * time.Minute
+
+// PresenceFunc :: Called from within Run whenever a User's presence in the Hub
+//    changes. Online once they connect, or send something again after idling.
+//    Background once every connection they have open has been idle for longer
+//    than the idle timeout. Offline once their last connection is gone, whether
+//    it was closed, dropped, or stopped answering pings. Whatever it returns is
+//    sent to every Client. Run waits on it, so it must not call back into the Hub.
+type PresenceFunc func(userID db.UUID, status db.Status) []byte
+
+// directMessage :: A message meant for client alone.
+type directMessage struct {
+  client  *Client
+  message []byte
+}
+
 type Hub struct {
   clients map[*Client]bool
   broadcast chan []byte
   register chan *Client
   unregister chan *Client
+  disconnect chan db.UUID
+  active chan *Client
+  direct chan directMessage
+
+  // Presence. Owned by Run, and set up by TrackPresence before it starts.
+  onPresence  PresenceFunc
+  idleTimeout time.Duration
+  presence    map[db.UUID]db.Status
+
+  // Slow mode. Shared by every Client's databaseHandler, so it's guarded by mu
+  // rather than owned by Run.
+  mu       sync.Mutex
+  slowMode time.Duration
+  lastPost map[db.UUID]time.Time
 }
 
 func NewHub() *Hub {
@@ -17,29 +54,206 @@ func NewHub() *Hub {
     broadcast:  make(chan []byte),
     register:   make(chan *Client),
     unregister: make(chan *Client),
+    disconnect: make(chan db.UUID),
+    active:     make(chan *Client),
+    direct:     make(chan directMessage),
     clients:    make(map[*Client]bool),
+    presence:   make(map[db.UUID]db.Status),
+    lastPost:   make(map[db.UUID]time.Time),
+  }
+}
+
+// Disconnect :: Drops every connection userID has open to the Hub. Used once a
+//    User is kicked or banned from the Chatroom.
+func(h *Hub)Disconnect(userID db.UUID) {
+  h.disconnect <- userID
+}
+
+// Broadcast :: Sends message to every Client connected to the Hub. Used for
+//    events that don't originate from a Client's websocket, like edits.
+func(h *Hub)Broadcast(message []byte) {
+  h.broadcast <- message
+}
+
+// sendTo :: Sends message to client alone. Goes through Run, which owns every
+//    Client's send channel, and drops message if client has since disconnected.
+func(h *Hub)sendTo(client *Client, message []byte) {
+  h.direct <- directMessage{ client, message }
+}
+
+// SetSlowMode :: How long each User has to wait between Messages. Kept in step with
+//    the Chatroom's SlowMode setting.
+func(h *Hub)SetSlowMode(interval time.Duration) {
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  h.slowMode = interval
+}
+
+// allowPost :: Whether userID has waited long enough since their last Message. If
+//    so, now is taken as their last Message straight away, under the same lock,
+//    so a User with several connections can't get more than one Message through.
+//    The Message before it is returned for undoPost.
+func(h *Hub)allowPost(userID db.UUID, now time.Time)( time.Time, bool ){
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  last, ok := h.lastPost[userID]
+  if ok && now.Sub(last) < h.slowMode {
+    return last, false
+  }
+  if h.slowMode == 0 {
+    delete(h.lastPost, userID)
+  } else {
+    h.lastPost[userID] = now
+  }
+  return last, true
+}
+
+// undoPost :: Hands back what allowPost took at now, once the Message turned out
+//    not to be stored after all.
+func(h *Hub)undoPost(userID db.UUID, now, last time.Time) {
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  if taken, ok := h.lastPost[userID]; !ok || !taken.Equal(now) {
+    return
+  }
+  if last.IsZero() {
+    delete(h.lastPost, userID)
+    return
+  }
+  h.lastPost[userID] = last
+}
+
+// TrackPresence :: Has the Hub report every change in a User's presence to
+//    onPresence. An idleTimeout of 0 falls back onto DefaultIdleTimeout. Must be
+//    called before Run.
+func(h *Hub)TrackPresence(onPresence PresenceFunc, idleTimeout time.Duration) {
+  if idleTimeout <= 0 {
+    idleTimeout = DefaultIdleTimeout
+  }
+  h.onPresence = onPresence
+  h.idleTimeout = idleTimeout
+}
+
+// userStatus :: Online while any of userID's Clients is active, Background once
+//    they've all gone idle, and Offline once there are none left.
+func(h *Hub)userStatus(userID db.UUID) db.Status {
+  status := db.Status(db.Offline)
+  for client := range h.clients {
+    if client.userID != userID {
+      continue
+    }
+    if !client.idle {
+      return db.Online
+    }
+    status = db.Background
+  }
+  return status
+}
+
+// updatePresence :: Reports userID's status if it's changed since it was last
+//    reported, and sends out whatever onPresence returns.
+func(h *Hub)updatePresence(userID db.UUID) {
+  if h.onPresence == nil {
+    return
+  }
+  status := h.userStatus(userID)
+  last, ok := h.presence[userID]
+  if !ok {
+    last = db.Offline
+  }
+  if status == last {
+    return
+  }
+  if status == db.Offline {
+    delete(h.presence, userID)
+  } else {
+    h.presence[userID] = status
+  }
+  if event := h.onPresence(userID, status); event != nil {
+    h.send(event)
+  }
+}
+
+// send :: Hands message to every Client. Clients too slow to keep up are dropped.
+func(h *Hub)send(message []byte) {
+  var dropped []db.UUID
+  for client := range h.clients {
+    select {
+    case client.send <- message:
+    default:
+      close(client.send)
+      delete(h.clients, client)
+      dropped = append(dropped, client.userID)
+    }
+  }
+  for _, userID := range dropped {
+    h.updatePresence(userID)
+  }
+}
+
+// checkIdle :: Marks Clients that haven't sent anything within the idle timeout
+//    as idle.
+func(h *Hub)checkIdle(now time.Time) {
+  var idled []db.UUID
+  for client := range h.clients {
+    if !client.idle && now.Sub(client.lastActive) >= h.idleTimeout {
+      client.idle = true
+      idled = append(idled, client.userID)
+    }
+  }
+  for _, userID := range idled {
+    h.updatePresence(userID)
   }
 }
 
 func(h *Hub)Run() {
+  // Without presence tracking, the ticker never fires.
+  var idleCheck <-chan time.Time
+  if h.onPresence != nil {
+    ticker := time.NewTicker(h.idleTimeout / 4)
+    defer ticker.Stop()
+    idleCheck = ticker.C
+  }
+
   for {
     select {
     case client := <-h.register:
+      client.lastActive = time.Now()
       h.clients[client] = true
+      h.updatePresence(client.userID)
     case client := <-h.unregister:
       if _, ok := h.clients[client]; ok {
         delete(h.clients, client)
         close(client.send)
+        h.updatePresence(client.userID)
       }
-    case message := <-h.broadcast:
+    case userID := <-h.disconnect:
       for client := range h.clients {
+        if client.userID == userID {
+          delete(h.clients, client)
+          close(client.send)
+        }
+      }
+      h.updatePresence(userID)
+    case client := <-h.active:
+      if _, ok := h.clients[client]; ok {
+        client.lastActive = time.Now()
+        if client.idle {
+          client.idle = false
+          h.updatePresence(client.userID)
+        }
+      }
+    case direct := <-h.direct:
+      if _, ok := h.clients[direct.client]; ok {
         select {
-        case client.send <- message:
+        case direct.client.send <- direct.message:
         default:
-          close(client.send)
-          delete(h.clients, client)
         }
       }
+    case now := <-idleCheck:
+      h.checkIdle(now)
+    case message := <-h.broadcast:
+      h.send(message)
     }
   }
 }